@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	nethttp "net/http"
 	"os"
@@ -11,6 +12,10 @@ import (
 
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/db"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/adapter/broker"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/adapter/notifier"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/adapter/oidc"
+	replog "github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/adapter/repository/log"
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/adapter/repository/postgres"
 	reporedis "github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/adapter/repository/redis"
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/application"
@@ -19,11 +24,19 @@ import (
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/server/http"
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/server/observe"
 	"github.com/Chengxufeng1994/go-saga-example/common/bootstrap"
+	"github.com/Chengxufeng1994/go-saga-example/common/codec"
 	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
 	"github.com/Chengxufeng1994/go-saga-example/common/redis"
 	"github.com/Chengxufeng1994/go-saga-example/common/token"
 )
 
+const (
+	// keySyncPeriod is how often the stored keyring is reloaded at most
+	keySyncPeriod = time.Minute
+	// userEventRelayPeriod is how often the user event outbox is published
+	userEventRelayPeriod = 5 * time.Second
+)
+
 func main() {
 	bootCfg := bootstrap.LoadBootstrapConfig("")
 	appCfg := libconfig.LoadApplicationConfig("")
@@ -49,22 +62,113 @@ func main() {
 		config.ContextLogger.WithError(err).Fatal("redis connection error")
 	}
 	config.ContextLogger.Infoln("redis connect successfully")
-	tokenEnhancer := token.NewJWTEnhancer([]byte(appCfg.JWTConfig.Secret))
-	// initialize token repository
-	tokenRepository := reporedis.NewTokenRepository(rcc)
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	tokenEnhancer, err := newTokenEnhancer(backgroundCtx, &appCfg.JWTConfig, postgres.NewSigningKeyRepository(gormDb))
+	if err != nil {
+		config.ContextLogger.WithError(err).Fatal("token enhancer error")
+	}
+	// initialize session repository
+	sessionRepository := reporedis.NewSessionRepository(rcc)
+	// initialize security event repository
+	securityEventRepository := replog.NewSecurityEventRepository()
 	// initialize user repository
 	userRepository := postgres.NewUserRepository(gormDb)
+	// initialize role repository
+	roleRepository := postgres.NewRoleRepository(gormDb)
+	// initialize account token repository
+	accountTokenRepository := reporedis.NewAccountTokenRepository(rcc)
+	// initialize notifier
+	accountNotifier, err := notifier.NewNotifier(appCfg)
+	if err != nil {
+		config.ContextLogger.WithError(err).Fatal("notifier error")
+	}
+	// account tokens are signed with their own key, never the jwt secret
+	if appCfg.AccountConfig.TokenSecret == "" {
+		config.ContextLogger.Fatal("account.token_secret is required")
+	}
+	// initialize account service
+	accountService := application.NewAccountService(
+		userRepository,
+		sessionRepository,
+		roleRepository,
+		accountTokenRepository,
+		accountNotifier,
+		appCfg.AccountConfig,
+		appCfg.RBACConfig.AdminEmails,
+		[]byte(appCfg.AccountConfig.TokenSecret))
+	// initialize mfa repository
+	mfaRepository := postgres.NewMFARepository(gormDb)
+	// initialize mfa challenge repository
+	mfaChallengeRepository := reporedis.NewMFAChallengeRepository(rcc)
+	// initialize mfa service
+	mfaService := application.NewMFAService(userRepository, mfaRepository, appCfg.MFAConfig)
+	// initialize login attempt repository
+	loginAttemptRepository := reporedis.NewLoginAttemptRepository(rcc)
+	// initialize identity repository
+	identityRepository := postgres.NewIdentityRepository(gormDb)
+	// initialize oidc state repository
+	oidcStateRepository := reporedis.NewOIDCStateRepository(rcc)
+	// the oidc state cookie is signed with its own key
+	if len(appCfg.OIDCConfig.Providers) > 0 && appCfg.OIDCConfig.CookieSecret == "" {
+		config.ContextLogger.Fatal("oidc.cookie_secret is required")
+	}
+	// initialize oidc service
+	oidcService := application.NewOIDCService(
+		oidc.NewProviders(appCfg.OIDCConfig),
+		identityRepository,
+		oidcStateRepository,
+		userRepository,
+		roleRepository,
+		appCfg.RBACConfig.AdminEmails,
+		[]byte(appCfg.OIDCConfig.CookieSecret),
+		appCfg.OIDCConfig.StateExpires)
 	// initialize auth service
 	authService := application.NewAuthService(
 		userRepository,
-		tokenRepository,
+		sessionRepository,
+		roleRepository,
+		accountService,
+		mfaService,
+		oidcService,
+		mfaChallengeRepository,
+		loginAttemptRepository,
+		securityEventRepository,
 		tokenEnhancer,
 		appCfg.JWTConfig.AccessTokenExpires,
-		appCfg.JWTConfig.RefreshTokenExpires)
+		appCfg.JWTConfig.RefreshTokenExpires,
+		appCfg.AccountConfig.RequireEmailVerification,
+		appCfg.MFAConfig.ChallengeExpires,
+		appCfg.LoginConfig)
+	// initialize event publisher
+	publisher, err := broker.NewNATSPublisher(appCfg)
+	if err != nil {
+		config.ContextLogger.WithError(err).Fatal("nats connection error")
+	}
+	// initialize message codec
+	messageCodec, err := codec.New(appCfg.MessagingConfig.Codec)
+	if err != nil {
+		config.ContextLogger.WithError(err).Fatal("message codec error")
+	}
+	// initialize user event repository
+	userEventRepository := broker.NewUserEventPublisher(publisher, messageCodec)
+	// initialize user event relay, it publishes the events of the outbox
+	userEventRelay := application.NewUserEventRelay(postgres.NewUserEventOutboxRepository(gormDb), userEventRepository)
+	userEventRelay.Start(backgroundCtx, userEventRelayPeriod)
 	// initialize user service
-	userService := application.NewUserService(userRepository)
+	userService := application.NewUserService(
+		userRepository,
+		roleRepository,
+		sessionRepository,
+		loginAttemptRepository,
+		accountService,
+		appCfg.LoginConfig)
+	// initialize api key repository
+	apiKeyRepository := postgres.NewAPIKeyRepository(gormDb)
+	// initialize api key service
+	apiKeyService := application.NewAPIKeyService(apiKeyRepository, userRepository, roleRepository)
 	// initialize application
-	app := application.New(authService, userService)
+	app := application.New(authService, userService, accountService, mfaService, apiKeyService, oidcService)
 	// initialize gin engine
 	engine := http.NewGinEngine(bootCfg)
 	// initialize route
@@ -72,7 +176,7 @@ func main() {
 	// initialize http server
 	httpSrv := http.New(bootCfg, engine, router)
 	// initialize grpc server
-	grpcSrv := grpc.New(bootCfg, authService)
+	grpcSrv := grpc.New(bootCfg, authService, apiKeyService)
 	// initialize server
 	srv := server.New(httpSrv, grpcSrv)
 
@@ -92,6 +196,7 @@ func main() {
 	}()
 
 	<-errc
+	stopBackground()
 	// First we close the connection with gorm:
 	sqlDB, err := gormDb.DB()
 	if err := sqlDB.Close(); err != nil {
@@ -101,6 +206,10 @@ func main() {
 	if err := rcc.Close(); err != nil {
 		config.ContextLogger.WithError(err).Fatal("close redis connection error")
 	}
+	// Then we close the event publisher:
+	if err := publisher.Close(); err != nil {
+		config.ContextLogger.WithError(err).Fatal("close publisher error")
+	}
 
 	// graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
@@ -110,3 +219,58 @@ func main() {
 
 	config.ContextLogger.Infoln("server exiting")
 }
+
+// newTokenEnhancer creates the enhancer of the configured algorithm.
+// Asymmetric algorithms sign with the configured key files, or else with the
+// keyring kept in the database, which every replica shares and which
+// survives restarts. Only the stored keyring rotates.
+func newTokenEnhancer(ctx context.Context, jwtCfg *libconfig.JWTConfig, store token.KeyStore) (token.Enhancer, error) {
+	if jwtCfg.Algorithm == "" || jwtCfg.Algorithm == "HS256" {
+		return token.NewJWTEnhancer([]byte(jwtCfg.Secret)), nil
+	}
+
+	method, err := token.SigningMethod(jwtCfg.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	retention := jwtCfg.Rotation.Retention
+	if retention == 0 {
+		retention = jwtCfg.RefreshTokenExpires
+	}
+	keyring := token.NewKeyring(method,
+		time.Duration(jwtCfg.Rotation.PrePublish)*time.Second,
+		time.Duration(retention)*time.Second)
+
+	if len(jwtCfg.Keys) > 0 {
+		if jwtCfg.Rotation.Interval > 0 {
+			return nil, errors.New("key files are rotated by deploying new files, remove jwt.rotation.interval")
+		}
+		for _, keyCfg := range jwtCfg.Keys {
+			key, err := token.LoadSigningKey(keyCfg.ID, keyCfg.File)
+			if err != nil {
+				return nil, err
+			}
+			if key.Method != method {
+				return nil, fmt.Errorf("key %s is not a %s key", key.ID, method.Alg())
+			}
+			keyring.Add(key)
+		}
+		return token.NewKeyringEnhancer(keyring), nil
+	}
+
+	interval := time.Duration(jwtCfg.Rotation.Interval) * time.Second
+	if err := keyring.Sync(ctx, store, interval); err != nil {
+		return nil, err
+	}
+	// replicas pick up keys rotated by another replica within the pre-publish window
+	period := keySyncPeriod
+	if prePublish := time.Duration(jwtCfg.Rotation.PrePublish) * time.Second / 2; prePublish > 0 && prePublish < period {
+		period = prePublish
+	}
+	keyring.StartSync(ctx, store, interval, period, func(err error) {
+		config.ContextLogger.WithError(err).Error("sync signing keys")
+	})
+
+	return token.NewKeyringEnhancer(keyring), nil
+}
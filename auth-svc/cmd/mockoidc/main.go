@@ -0,0 +1,324 @@
+// mockoidc is an OpenID Connect provider for local development and tests. It
+// signs in any email without a password, with the authorization code flow
+// and PKCE just like a real provider.
+//
+//	go run ./cmd/mockoidc -addr :9100 -issuer http://localhost:9100
+//
+// Passing login_hint to the authorize endpoint skips the sign in form.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/adapter/oidc"
+	"github.com/Chengxufeng1994/go-saga-example/common/token"
+	"github.com/golang-jwt/jwt/v4"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	codeExpires    = time.Minute
+	idTokenExpires = 5 * time.Minute
+)
+
+var signInPage = template.Must(template.New("signin").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Mock OIDC sign in</title></head>
+<body>
+<h1>Mock OIDC sign in</h1>
+<form method="post" action="/authorize">
+{{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}<p><label>Email <input type="email" name="email" required></label></p>
+<p><label>Given name <input type="text" name="given_name"></label></p>
+<p><label>Family name <input type="text" name="family_name"></label></p>
+<p><label><input type="checkbox" name="email_verified" value="true" checked> Email verified</label></p>
+<button type="submit" name="action" value="approve">Sign in</button>
+<button type="submit" name="action" value="deny">Deny</button>
+</form>
+</body>
+</html>
+`))
+
+// authorization is what a code was issued for
+type authorization struct {
+	clientID      string
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+	email         string
+	emailVerified bool
+	givenName     string
+	familyName    string
+	expiresAt     time.Time
+}
+
+type provider struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	keyring      *token.Keyring
+
+	mu    sync.Mutex
+	codes map[string]*authorization
+}
+
+func main() {
+	addr := flag.String("addr", ":9100", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9100", "issuer url, has to match the address the provider is reached at")
+	clientID := flag.String("client-id", "auth-svc", "client id of the relying party")
+	clientSecret := flag.String("client-secret", "mock-secret", "client secret of the relying party, empty for a public client")
+	flag.Parse()
+
+	keyring := token.NewKeyring(jwt.SigningMethodRS256, 0, 0)
+	key, err := token.GenerateSigningKey(jwt.SigningMethodRS256, time.Now())
+	if err != nil {
+		log.WithError(err).Fatal("generate signing key")
+	}
+	keyring.Add(key)
+
+	p := &provider{
+		issuer:       *issuer,
+		clientID:     *clientID,
+		clientSecret: *clientSecret,
+		keyring:      keyring,
+		codes:        map[string]*authorization{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/jwks", p.jwks)
+
+	log.WithFields(log.Fields{"addr": *addr, "issuer": *issuer}).Info("mock oidc provider listening")
+	if err := http.ListenAndServe(*addr, mux); err != nil {
+		log.WithError(err).Fatal("listen")
+	}
+}
+
+func (p *provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, oidc.Discovery{
+		Issuer:                p.issuer,
+		AuthorizationEndpoint: p.issuer + "/authorize",
+		TokenEndpoint:         p.issuer + "/token",
+		JWKSURI:               p.issuer + "/jwks",
+	})
+}
+
+func (p *provider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, p.keyring.JWKS())
+}
+
+// authorize shows the sign in form, or issues the code once it is submitted
+func (p *provider) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := r.Form
+
+	if params.Get("client_id") != p.clientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(params.Get("redirect_uri"))
+	if err != nil || !redirectURI.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	// from here on errors are reported back to the client
+	if params.Get("response_type") != "code" {
+		redirectError(w, r, redirectURI, params.Get("state"), "unsupported_response_type")
+		return
+	}
+	if params.Get("code_challenge") == "" || params.Get("code_challenge_method") != "S256" {
+		redirectError(w, r, redirectURI, params.Get("state"), "invalid_request")
+		return
+	}
+
+	email := params.Get("email")
+	emailVerified := params.Get("email_verified") == "true"
+	if r.Method == http.MethodGet {
+		if email = params.Get("login_hint"); email == "" {
+			p.renderSignIn(w, params)
+			return
+		}
+		emailVerified = true
+	} else if params.Get("action") == "deny" {
+		redirectError(w, r, redirectURI, params.Get("state"), "access_denied")
+		return
+	}
+	if email == "" {
+		p.renderSignIn(w, params)
+		return
+	}
+
+	code, err := randomValue()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	p.mu.Lock()
+	p.codes[code] = &authorization{
+		clientID:      p.clientID,
+		redirectURI:   redirectURI.String(),
+		codeChallenge: params.Get("code_challenge"),
+		nonce:         params.Get("nonce"),
+		email:         email,
+		emailVerified: emailVerified,
+		givenName:     params.Get("given_name"),
+		familyName:    params.Get("family_name"),
+		expiresAt:     time.Now().Add(codeExpires),
+	}
+	p.mu.Unlock()
+
+	query := redirectURI.Query()
+	query.Set("code", code)
+	query.Set("state", params.Get("state"))
+	redirectURI.RawQuery = query.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (p *provider) renderSignIn(w http.ResponseWriter, params url.Values) {
+	hidden := map[string]string{}
+	for _, name := range []string{"client_id", "redirect_uri", "response_type", "scope", "state", "nonce", "code_challenge", "code_challenge_method"} {
+		hidden[name] = params.Get(name)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := signInPage.Execute(w, struct{ Params map[string]string }{hidden}); err != nil {
+		log.WithError(err).Error("render sign in")
+	}
+}
+
+// token exchanges a code for the id token, the code verifier has to match
+// the challenge the code was issued for
+func (p *provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != p.clientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(p.clientSecret)) != 1 {
+		tokenError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	// codes are single use, a failed exchange burns it as well
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	auth, ok := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+	if !ok || time.Now().After(auth.expiresAt) || auth.clientID != clientID || auth.redirectURI != r.PostForm.Get("redirect_uri") {
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != auth.codeChallenge {
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	idToken, err := p.signIDToken(auth)
+	if err != nil {
+		log.WithError(err).Error("sign id token")
+		tokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+	accessToken, err := randomValue()
+	if err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(idTokenExpires.Seconds()),
+		"id_token":     idToken,
+	})
+}
+
+func (p *provider) signIDToken(auth *authorization) (string, error) {
+	key, err := p.keyring.Active()
+	if err != nil {
+		return "", err
+	}
+
+	// the subject is stable per email so signing in again finds the same identity
+	sum := sha256.Sum256([]byte(auth.email))
+	now := time.Now()
+	claims := &oidc.IDTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    p.issuer,
+			Subject:   "mock-" + hex.EncodeToString(sum[:8]),
+			Audience:  jwt.ClaimStrings{auth.clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(idTokenExpires)),
+		},
+		Nonce:         auth.nonce,
+		Email:         auth.email,
+		EmailVerified: auth.emailVerified,
+		GivenName:     auth.givenName,
+		FamilyName:    auth.familyName,
+	}
+
+	idToken := jwt.NewWithClaims(key.Method, claims)
+	idToken.Header["kid"] = key.ID
+	return idToken.SignedString(key.PrivateKey)
+}
+
+func redirectError(w http.ResponseWriter, r *http.Request, redirectURI *url.URL, state, code string) {
+	query := redirectURI.Query()
+	query.Set("error", code)
+	query.Set("state", state)
+	redirectURI.RawQuery = query.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func tokenError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithError(err).Error("write response")
+	}
+}
+
+func randomValue() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
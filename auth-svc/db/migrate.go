@@ -2,6 +2,7 @@ package db
 
 import (
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/db/model"
+	"github.com/Chengxufeng1994/go-saga-example/common/rbac"
 	"gorm.io/gorm"
 )
 
@@ -17,5 +18,36 @@ func NewMigrator(db *gorm.DB) *Migrator {
 
 // Migrate database migrate
 func (m *Migrator) Migrate() error {
-	return m.db.AutoMigrate(&model.User{})
+	if err := m.db.AutoMigrate(&model.User{}, &model.Role{}, &model.Permission{}, &model.UserMFA{}, &model.MFARecoveryCode{}, &model.APIKey{}, &model.UserIdentity{}, &model.SigningKey{}, &model.UserEvent{}); err != nil {
+		return err
+	}
+	return m.seedRoles()
+}
+
+// seedRoles creates the default roles and grants them their permissions
+func (m *Migrator) seedRoles() error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		for name, permissionNames := range rbac.DefaultRoles {
+			role := model.Role{Name: name}
+			if err := tx.Where(&role).FirstOrCreate(&role).Error; err != nil {
+				return err
+			}
+
+			permissions := make([]model.Permission, 0, len(permissionNames))
+			for _, permissionName := range permissionNames {
+				permission := model.Permission{Name: permissionName}
+				if err := tx.Where(&permission).FirstOrCreate(&permission).Error; err != nil {
+					return err
+				}
+				permissions = append(permissions, permission)
+			}
+			if len(permissions) == 0 {
+				continue
+			}
+			if err := tx.Model(&role).Association("Permissions").Append(permissions); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/model"
+)
+
+// api key data model, only the hash of the key is stored
+type APIKey struct {
+	model.BaseModel
+	UserID     uint64   `gorm:"index;not null"`
+	Name       string   `gorm:"type:varchar(100);not null"`
+	Prefix     string   `gorm:"type:varchar(16);not null"`
+	KeyHash    string   `gorm:"type:varchar(64);uniqueIndex;not null"`
+	Scopes     []string `gorm:"type:text;serializer:json;not null"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+}
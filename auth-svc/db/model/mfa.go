@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/model"
+)
+
+// user mfa data model, one per user
+type UserMFA struct {
+	model.BaseModel
+	UserID          uint64 `gorm:"uniqueIndex;not null"`
+	EncryptedSecret string `gorm:"type:text;not null"`
+	Enabled         bool   `gorm:"default:false;not null"`
+	LastUsedStep    int64  `gorm:"default:0;not null"`
+	ConfirmedAt     *time.Time
+}
+
+// mfa recovery code data model, only the hash of the code is stored
+type MFARecoveryCode struct {
+	model.BaseModel
+	UserID   uint64 `gorm:"index;not null"`
+	CodeHash string `gorm:"type:varchar(64);uniqueIndex;not null"`
+	UsedAt   *time.Time
+}
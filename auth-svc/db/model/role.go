@@ -0,0 +1,16 @@
+package model
+
+import "github.com/Chengxufeng1994/go-saga-example/common/model"
+
+// role data model
+type Role struct {
+	model.BaseModel
+	Name        string       `gorm:"type:varchar(50);unique;not null"`
+	Permissions []Permission `gorm:"many2many:role_permissions"`
+}
+
+// permission data model
+type Permission struct {
+	model.BaseModel
+	Name string `gorm:"type:varchar(50);unique;not null"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// signing key data model of the shared token keyring. PrivateKey is the PEM
+// encoded PKCS #8 key, access to this table grants minting tokens.
+type SigningKey struct {
+	ID string `gorm:"type:varchar(64);primaryKey"`
+	// PreviousID is the key this key succeeded, unique so that only one
+	// replica wins a rotation
+	PreviousID string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	PrivateKey []byte    `gorm:"not null"`
+	ActiveAt   time.Time `gorm:"not null"`
+	CreatedAt  time.Time
+}
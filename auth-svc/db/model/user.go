@@ -2,14 +2,17 @@ package model
 
 import "github.com/Chengxufeng1994/go-saga-example/common/model"
 
-// user data model
+// user data model, the phone number is optional so only numbers that are
+// set have to be unique
 type User struct {
 	model.BaseModel
-	Active      bool   `gorm:"default:true"`
-	FirstName   string `gorm:"type:varchar(50);not null"`
-	LastName    string `gorm:"type:varchar(50);not null"`
-	Email       string `gorm:"type:varchar(320);unique;not null"`
-	Address     string `gorm:"type:text;not null"`
-	PhoneNumber string `gorm:"type:varchar(20);unique;not null"`
-	Password    string `gorm:"type:varchar(100);not null"`
+	Active        bool   `gorm:"default:true"`
+	EmailVerified bool   `gorm:"default:false;not null"`
+	FirstName     string `gorm:"type:varchar(50);not null"`
+	LastName      string `gorm:"type:varchar(50);not null"`
+	Email         string `gorm:"type:varchar(320);unique;not null"`
+	Address       string `gorm:"type:text;not null"`
+	PhoneNumber   string `gorm:"type:varchar(20);not null;uniqueIndex:idx_users_phone_number,where:phone_number <> ''"`
+	Password      string `gorm:"type:varchar(100);not null"`
+	Roles         []Role `gorm:"many2many:user_roles"`
 }
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// user event outbox data model. Events are written in the transaction of
+// the change they record and deleted once published.
+type UserEvent struct {
+	ID         string    `gorm:"type:varchar(64);primaryKey"`
+	Type       string    `gorm:"type:varchar(32);not null"`
+	UserID     uint64    `gorm:"not null"`
+	OccurredAt time.Time `gorm:"not null"`
+	CreatedAt  time.Time `gorm:"index"`
+}
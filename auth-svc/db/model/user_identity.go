@@ -0,0 +1,12 @@
+package model
+
+import "github.com/Chengxufeng1994/go-saga-example/common/model"
+
+// user identity data model, links the subject of an external oidc provider to a user
+type UserIdentity struct {
+	model.BaseModel
+	UserID   uint64 `gorm:"index;not null"`
+	Provider string `gorm:"type:varchar(50);uniqueIndex:idx_user_identities_provider_subject;not null"`
+	Subject  string `gorm:"type:varchar(255);uniqueIndex:idx_user_identities_provider_subject;not null"`
+	Email    string `gorm:"type:varchar(320);not null"`
+}
@@ -0,0 +1,29 @@
+package dto
+
+import "time"
+
+type APIKeyCreationRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=365"`
+}
+
+type APIKey struct {
+	ID         uint64     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// APIKeyCreated carries the plaintext key, which is only ever returned on creation
+type APIKeyCreated struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type VerifyAPIKeyRequest struct {
+	APIKey string `json:"api_key"`
+}
@@ -1,13 +1,21 @@
 package dto
 
+import "time"
+
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email     string `json:"email"`
+	Password  string `json:"password"`
+	UserAgent string `json:"-"`
+	IPAddress string `json:"-"`
 }
 
+// LoginResponse carries either the token pair or, when mfa is enabled, the
+// challenge token to exchange for it
 type LoginResponse struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
+	AccessToken    string `json:"access_token,omitempty"`
+	RefreshToken   string `json:"refresh_token,omitempty"`
+	MFARequired    bool   `json:"mfa_required,omitempty"`
+	ChallengeToken string `json:"challenge_token,omitempty"`
 }
 
 type VerifyTokenRequest struct {
@@ -15,8 +23,11 @@ type VerifyTokenRequest struct {
 }
 
 type VerifyTokenResponse struct {
-	UserId    uint64 `json:"user_id"`
-	IsExpired bool   `json:"is_expired"`
+	UserId      uint64   `json:"user_id"`
+	SessionId   string   `json:"session_id"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+	IsExpired   bool     `json:"is_expired"`
 }
 
 type RefreshTokenRequest struct {
@@ -27,3 +38,12 @@ type RefreshTokenResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 }
+
+type Session struct {
+	ID        string    `json:"id"`
+	UserAgent string    `json:"user_agent"`
+	IPAddress string    `json:"ip_address"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Current   bool      `json:"current"`
+}
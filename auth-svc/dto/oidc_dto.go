@@ -0,0 +1,32 @@
+package dto
+
+import "time"
+
+// OIDCCallbackRequest is the redirect back from the provider, which carries
+// either the code or the error of a rejected sign in
+type OIDCCallbackRequest struct {
+	Provider         string `form:"-"`
+	Code             string `form:"code"`
+	State            string `form:"state" binding:"required"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+	// StateCookie is the signed state of the browser following the callback
+	StateCookie string `form:"-"`
+	UserAgent   string `form:"-"`
+	IPAddress   string `form:"-"`
+}
+
+// OIDCAuthorization redirects the browser to the provider, StateCookie binds
+// the sign in to the browser and has to be sent back with the callback
+type OIDCAuthorization struct {
+	URL         string
+	StateCookie string
+	ExpiresIn   int
+}
+
+type Identity struct {
+	ID        uint64    `json:"id"`
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -25,3 +25,41 @@ type UserCreationRequest struct {
 	PhoneNumber string `json:"phone_number"`
 	Password    string `json:"password"`
 }
+
+type UserRolesRequest struct {
+	Roles []string `json:"roles" binding:"required"`
+}
+
+type UserRoles struct {
+	UserID uint64   `json:"user_id"`
+	Roles  []string `json:"roles"`
+}
+
+type UserLockout struct {
+	UserID         uint64     `json:"user_id"`
+	Email          string     `json:"email"`
+	Locked         bool       `json:"locked"`
+	LockedUntil    *time.Time `json:"locked_until,omitempty"`
+	Lockouts       int        `json:"lockouts"`
+	RecentFailures int64      `json:"recent_failures"`
+}
+
+// UserProfileRequest updates the given fields of the profile. Changing the
+// email requires the password, the email is switched once confirmed.
+type UserProfileRequest struct {
+	FirstName   *string `json:"first_name" binding:"omitempty,min=1,max=50"`
+	LastName    *string `json:"last_name" binding:"omitempty,min=1,max=50"`
+	Email       *string `json:"email" binding:"omitempty,email,max=320"`
+	Address     *string `json:"address" binding:"omitempty,max=500"`
+	PhoneNumber *string `json:"phone_number" binding:"omitempty,e164"`
+	Password    string  `json:"password"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
+}
+
+type ConfirmPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
@@ -0,0 +1,46 @@
+// broker
+package broker
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/codec/natscodec"
+	"github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill-nats/v2/pkg/nats"
+	"github.com/ThreeDotsLabs/watermill/message"
+	nc "github.com/nats-io/nats.go"
+)
+
+var (
+	logger    = watermill.NewStdLogger(false, false)
+	marshaler = natscodec.NewMarshaler()
+)
+
+// NewNATSPublisher returns a NATS publisher for event streaming
+func NewNATSPublisher(appCfg *config.ApplicationConfig) (message.Publisher, error) {
+	natsUrl := fmt.Sprintf("nats://%s:%d", appCfg.NatsConfig.Host, appCfg.NatsConfig.Port)
+	options := []nc.Option{
+		nc.RetryOnFailedConnect(true),
+		nc.Timeout(30 * time.Second),
+		nc.ReconnectWait(1 * time.Second),
+	}
+
+	jsConfig := nats.JetStreamConfig{
+		AutoProvision: true,
+		AckAsync:      true,
+		TrackMsgId:    true,
+		Disabled:      false,
+	}
+
+	return nats.NewPublisher(
+		nats.PublisherConfig{
+			URL:         natsUrl,
+			NatsOptions: options,
+			Marshaler:   marshaler,
+			JetStream:   jsConfig,
+		},
+		logger,
+	)
+}
@@ -0,0 +1,45 @@
+// broker
+package broker
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	"github.com/Chengxufeng1994/go-saga-example/common/codec"
+	"github.com/Chengxufeng1994/go-saga-example/common/event"
+	"github.com/Chengxufeng1994/go-saga-example/common/pb"
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type UserEventPublisher struct {
+	publisher message.Publisher
+	codec     codec.Codec
+}
+
+func NewUserEventPublisher(publisher message.Publisher, messageCodec codec.Codec) repository.UserEventRepository {
+	return &UserEventPublisher{
+		publisher: publisher,
+		codec:     messageCodec,
+	}
+}
+
+// PublishUserDeleted implements repository.UserEventRepository. The message
+// takes the id of the event, consumers drop a republished event.
+func (p *UserEventPublisher) PublishUserDeleted(ctx context.Context, evt *entity.UserDeletedEvent) error {
+	msg := message.NewMessage(evt.ID, nil)
+	payload, err := codec.Encode(p.codec, &pb.UserDeleted{
+		UserId:    evt.UserID,
+		Timestamp: timestamppb.New(evt.DeletedAt),
+	}, msg.Metadata)
+	if err != nil {
+		return err
+	}
+	msg.Payload = payload
+	middleware.SetCorrelationID(watermill.NewUUID(), msg)
+
+	return p.publisher.Publish(event.UserDeletedTopic, msg)
+}
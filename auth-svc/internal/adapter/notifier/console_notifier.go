@@ -0,0 +1,30 @@
+package notifier
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// ConsoleNotifier prints notifications to the log for local development
+type ConsoleNotifier struct {
+	logger *logrus.Entry
+}
+
+func NewConsoleNotifier() repository.Notifier {
+	return &ConsoleNotifier{
+		logger: config.ContextLogger.WithFields(logrus.Fields{"type": "notifier:ConsoleNotifier"}),
+	}
+}
+
+// Notify implements repository.Notifier.
+func (n *ConsoleNotifier) Notify(ctx context.Context, notification *entity.Notification) error {
+	n.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"to":      notification.To,
+		"subject": notification.Subject,
+	}).Info(notification.Body)
+	return nil
+}
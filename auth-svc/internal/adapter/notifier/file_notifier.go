@@ -0,0 +1,40 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+)
+
+// FileNotifier appends notifications to a mailbox file for local development
+type FileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileNotifier(path string) repository.Notifier {
+	return &FileNotifier{
+		path: path,
+	}
+}
+
+// Notify implements repository.Notifier.
+func (n *FileNotifier) Notify(ctx context.Context, notification *entity.Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC1123Z), notification.To, notification.Subject, notification.Body)
+	return err
+}
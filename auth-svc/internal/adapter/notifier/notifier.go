@@ -0,0 +1,21 @@
+package notifier
+
+import (
+	"fmt"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
+)
+
+// NewNotifier creates the notifier of the configured provider
+func NewNotifier(appCfg *libconfig.ApplicationConfig) (repository.Notifier, error) {
+	cfg := appCfg.AccountConfig.Notifier
+	switch cfg.Provider {
+	case "", "console":
+		return NewConsoleNotifier(), nil
+	case "file":
+		return NewFileNotifier(cfg.File), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier provider: %s", cfg.Provider)
+	}
+}
@@ -0,0 +1,203 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/token"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	httpTimeout   = 10 * time.Second
+	jwksCacheTTL  = time.Hour
+)
+
+var (
+	ErrIssuerMismatch   = errors.New("id token issuer mismatch")
+	ErrAudienceMismatch = errors.New("id token audience mismatch")
+	ErrNonceMismatch    = errors.New("id token nonce mismatch")
+	ErrMissingIDToken   = errors.New("token response has no id token")
+)
+
+// Provider signs users in through the authorization code flow with PKCE.
+// The provider metadata is discovered from the issuer on first use.
+type Provider struct {
+	cfg    libconfig.OIDCProvider
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *Discovery
+	keySet    token.KeySet
+}
+
+func NewProvider(cfg libconfig.OIDCProvider) repository.IdentityProvider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: httpTimeout},
+	}
+}
+
+// NewProviders returns the configured providers by name
+func NewProviders(cfg libconfig.OIDC) map[string]repository.IdentityProvider {
+	providers := make(map[string]repository.IdentityProvider, len(cfg.Providers))
+	for _, providerCfg := range cfg.Providers {
+		providers[providerCfg.Name] = NewProvider(providerCfg)
+	}
+	return providers
+}
+
+// Name implements repository.IdentityProvider.
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// AuthCodeURL implements repository.IdentityProvider.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	discovery, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return discovery.AuthorizationEndpoint + sep + query.Encode(), nil
+}
+
+// Exchange implements repository.IdentityProvider. The id token returned for
+// the code is verified against the keys of the provider before it is trusted.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*entity.ExternalIdentity, error) {
+	discovery, keySet, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tokenResp tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, fmt.Errorf("decode token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("exchange code: %d %s %s", resp.StatusCode, tokenResp.Error, tokenResp.ErrorDescription)
+	}
+	if tokenResp.IDToken == "" {
+		return nil, ErrMissingIDToken
+	}
+
+	claims, err := p.verifyIDToken(keySet, tokenResp.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.ExternalIdentity{
+		Provider:      p.cfg.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		FirstName:     claims.GivenName,
+		LastName:      claims.FamilyName,
+	}, nil
+}
+
+func (p *Provider) verifyIDToken(keySet token.KeySet, value, nonce string) (*IDTokenClaims, error) {
+	parser := jwt.NewParser(jwt.WithValidMethods(token.ValidMethods))
+	parsed, err := parser.ParseWithClaims(value, &IDTokenClaims{}, token.KeyFunc(keySet))
+	if err != nil {
+		return nil, err
+	}
+
+	claims := parsed.Claims.(*IDTokenClaims)
+	if !claims.VerifyIssuer(p.cfg.Issuer, true) {
+		return nil, ErrIssuerMismatch
+	}
+	if !claims.VerifyAudience(p.cfg.ClientID, true) {
+		return nil, ErrAudienceMismatch
+	}
+	if claims.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+	return claims, nil
+}
+
+// discover fetches the provider metadata once, a failed discovery is retried
+// on the next sign in
+func (p *Provider) discover(ctx context.Context) (*Discovery, token.KeySet, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, p.keySet, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.cfg.Issuer, "/")+discoveryPath, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("discover %s: unexpected status %d", p.cfg.Issuer, resp.StatusCode)
+	}
+
+	var discovery Discovery
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return nil, nil, fmt.Errorf("decode discovery: %w", err)
+	}
+	if discovery.Issuer != p.cfg.Issuer {
+		return nil, nil, fmt.Errorf("discover %s: %w", p.cfg.Issuer, ErrIssuerMismatch)
+	}
+
+	p.discovery = &discovery
+	p.keySet = token.NewRemoteKeySet(discovery.JWKSURI, jwksCacheTTL)
+	return p.discovery, p.keySet, nil
+}
@@ -0,0 +1,35 @@
+// log
+package log
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// SecurityEventRepository writes security events to the audit log
+type SecurityEventRepository struct {
+	logger *logrus.Entry
+}
+
+func NewSecurityEventRepository() repository.SecurityEventRepository {
+	return &SecurityEventRepository{
+		logger: config.ContextLogger.WithFields(logrus.Fields{"type": "audit:SecurityEvent"}),
+	}
+}
+
+// PublishSecurityEvent implements repository.SecurityEventRepository.
+func (r *SecurityEventRepository) PublishSecurityEvent(ctx context.Context, event *entity.SecurityEvent) error {
+	r.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"event":       event.Type,
+		"user_id":     event.UserID,
+		"session_id":  event.SessionID,
+		"ip_address":  event.IPAddress,
+		"user_agent":  event.UserAgent,
+		"occurred_at": event.OccurredAt,
+	}).Warn("security event")
+	return nil
+}
@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/db/model"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	"gorm.io/gorm"
+)
+
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) repository.APIKeyRepository {
+	return &APIKeyRepository{
+		db: db,
+	}
+}
+
+// CreateAPIKey implements repository.APIKeyRepository.
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, key *entity.APIKey) (*entity.APIKey, error) {
+	m := &model.APIKey{
+		UserID:    key.UserID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		KeyHash:   key.KeyHash,
+		Scopes:    key.Scopes,
+		ExpiresAt: key.ExpiresAt,
+	}
+	if err := r.db.WithContext(ctx).Create(m).Error; err != nil {
+		return nil, repository.NewErrFailedCreate("APIKey").Wrap(err)
+	}
+
+	return toAPIKeyEntity(m), nil
+}
+
+// ListAPIKeys implements repository.APIKeyRepository.
+func (r *APIKeyRepository) ListAPIKeys(ctx context.Context, userID uint64) ([]*entity.APIKey, error) {
+	var keys []model.APIKey
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+
+	res := make([]*entity.APIKey, 0, len(keys))
+	for i := range keys {
+		res = append(res, toAPIKeyEntity(&keys[i]))
+	}
+	return res, nil
+}
+
+// GetAPIKeyByHash implements repository.APIKeyRepository.
+func (r *APIKeyRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*entity.APIKey, error) {
+	var key model.APIKey
+	err := r.db.WithContext(ctx).Where("key_hash = ?", keyHash).First(&key).Error
+	if err != nil {
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return nil, repository.NewErrNotFound("APIKey", "key_hash")
+		}
+
+		return nil, err
+	}
+
+	return toAPIKeyEntity(&key), nil
+}
+
+// DeleteAPIKey implements repository.APIKeyRepository.
+func (r *APIKeyRepository) DeleteAPIKey(ctx context.Context, userID, id uint64) error {
+	result := r.db.WithContext(ctx).Unscoped().Where("id = ? AND user_id = ?", id, userID).Delete(&model.APIKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return repository.NewErrNotFound("APIKey", fmt.Sprintf("%d", id))
+	}
+
+	return nil
+}
+
+// TouchAPIKey implements repository.APIKeyRepository.
+func (r *APIKeyRepository) TouchAPIKey(ctx context.Context, id uint64, usedAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&model.APIKey{}).Where("id = ?", id).Update("last_used_at", usedAt).Error
+}
+
+func toAPIKeyEntity(m *model.APIKey) *entity.APIKey {
+	return &entity.APIKey{
+		ID:         m.ID,
+		UserID:     m.UserID,
+		Name:       m.Name,
+		Prefix:     m.Prefix,
+		KeyHash:    m.KeyHash,
+		Scopes:     m.Scopes,
+		CreatedAt:  m.CreatedAt,
+		ExpiresAt:  m.ExpiresAt,
+		LastUsedAt: m.LastUsedAt,
+	}
+}
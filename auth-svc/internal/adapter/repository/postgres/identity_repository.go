@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/db/model"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+type IdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewIdentityRepository(db *gorm.DB) repository.IdentityRepository {
+	return &IdentityRepository{
+		db: db,
+	}
+}
+
+// CreateIdentity implements repository.IdentityRepository.
+func (r *IdentityRepository) CreateIdentity(ctx context.Context, identity *entity.UserIdentity) (*entity.UserIdentity, error) {
+	m := &model.UserIdentity{
+		UserID:   identity.UserID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}
+	if err := r.db.WithContext(ctx).Create(m).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == repository.UniqueViolation {
+			return nil, repository.NewErrInvalidInput("UserIdentity", "subject", identity.Subject).Wrap(err)
+		}
+		return nil, repository.NewErrFailedCreate("UserIdentity").Wrap(err)
+	}
+
+	return toIdentityEntity(m), nil
+}
+
+// GetIdentity implements repository.IdentityRepository.
+func (r *IdentityRepository) GetIdentity(ctx context.Context, provider, subject string) (*entity.UserIdentity, error) {
+	var identity model.UserIdentity
+	err := r.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return nil, repository.NewErrNotFound("UserIdentity", fmt.Sprintf("%s:%s", provider, subject))
+		}
+
+		return nil, err
+	}
+
+	return toIdentityEntity(&identity), nil
+}
+
+// ListIdentities implements repository.IdentityRepository.
+func (r *IdentityRepository) ListIdentities(ctx context.Context, userID uint64) ([]*entity.UserIdentity, error) {
+	var identities []model.UserIdentity
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+
+	res := make([]*entity.UserIdentity, 0, len(identities))
+	for i := range identities {
+		res = append(res, toIdentityEntity(&identities[i]))
+	}
+	return res, nil
+}
+
+func toIdentityEntity(m *model.UserIdentity) *entity.UserIdentity {
+	return &entity.UserIdentity{
+		ID:        m.ID,
+		UserID:    m.UserID,
+		Provider:  m.Provider,
+		Subject:   m.Subject,
+		Email:     m.Email,
+		CreatedAt: m.CreatedAt,
+	}
+}
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/db/model"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type MFARepository struct {
+	db *gorm.DB
+}
+
+func NewMFARepository(db *gorm.DB) repository.MFARepository {
+	return &MFARepository{
+		db: db,
+	}
+}
+
+// GetUserMFA implements repository.MFARepository.
+func (r *MFARepository) GetUserMFA(ctx context.Context, userID uint64) (*entity.UserMFA, error) {
+	var mfa model.UserMFA
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&mfa).Error
+	if err != nil {
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return nil, repository.NewErrNotFound("UserMFA", fmt.Sprintf("user_id=%d", userID))
+		}
+
+		return nil, err
+	}
+
+	return &entity.UserMFA{
+		UserID:          mfa.UserID,
+		EncryptedSecret: mfa.EncryptedSecret,
+		Enabled:         mfa.Enabled,
+		LastUsedStep:    mfa.LastUsedStep,
+		ConfirmedAt:     mfa.ConfirmedAt,
+	}, nil
+}
+
+// SaveUserMFA implements repository.MFARepository.
+func (r *MFARepository) SaveUserMFA(ctx context.Context, mfa *entity.UserMFA) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"encrypted_secret", "enabled", "last_used_step", "confirmed_at", "updated_at"}),
+	}).Create(&model.UserMFA{
+		UserID:          mfa.UserID,
+		EncryptedSecret: mfa.EncryptedSecret,
+		Enabled:         mfa.Enabled,
+		LastUsedStep:    mfa.LastUsedStep,
+		ConfirmedAt:     mfa.ConfirmedAt,
+	}).Error
+}
+
+// EnableUserMFA implements repository.MFARepository.
+func (r *MFARepository) EnableUserMFA(ctx context.Context, userID uint64, step int64, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&model.UserMFA{}).
+			Where("user_id = ? AND enabled = ?", userID, false).
+			Updates(map[string]any{"enabled": true, "last_used_step": step, "confirmed_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return repository.NewErrNotFound("UserMFA", fmt.Sprintf("user_id=%d", userID))
+		}
+
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]model.MFARecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			codes = append(codes, model.MFARecoveryCode{UserID: userID, CodeHash: hash})
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// DeleteUserMFA implements repository.MFARepository.
+func (r *MFARepository) DeleteUserMFA(ctx context.Context, userID uint64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("user_id = ?", userID).Delete(&model.UserMFA{}).Error
+	})
+}
+
+// UseTimeStep implements repository.MFARepository. A time step can be used
+// only once so that an observed code cannot be replayed.
+func (r *MFARepository) UseTimeStep(ctx context.Context, userID uint64, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&model.UserMFA{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// UseRecoveryCode implements repository.MFARepository.
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID uint64, codeHash string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&model.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
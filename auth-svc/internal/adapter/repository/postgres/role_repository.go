@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/db/model"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	"gorm.io/gorm"
+)
+
+type RoleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) repository.RoleRepository {
+	return &RoleRepository{
+		db: db,
+	}
+}
+
+// GetUserRoles implements repository.RoleRepository.
+func (r *RoleRepository) GetUserRoles(ctx context.Context, userID uint64) ([]*entity.Role, error) {
+	var rows []struct {
+		Role       string
+		Permission *string
+	}
+	err := r.db.WithContext(ctx).
+		Table("user_roles").
+		Select("roles.name AS role, permissions.name AS permission").
+		Joins("JOIN roles ON roles.id = user_roles.role_id AND roles.deleted_at IS NULL").
+		Joins("LEFT JOIN role_permissions ON role_permissions.role_id = roles.id").
+		Joins("LEFT JOIN permissions ON permissions.id = role_permissions.permission_id AND permissions.deleted_at IS NULL").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.name, permissions.name").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]*entity.Role, 0)
+	for _, row := range rows {
+		if len(roles) == 0 || roles[len(roles)-1].Name != row.Role {
+			roles = append(roles, &entity.Role{Name: row.Role, Permissions: []string{}})
+		}
+		if row.Permission != nil {
+			role := roles[len(roles)-1]
+			role.Permissions = append(role.Permissions, *row.Permission)
+		}
+	}
+
+	return roles, nil
+}
+
+// SetUserRoles implements repository.RoleRepository.
+func (r *RoleRepository) SetUserRoles(ctx context.Context, userID uint64, names []string) error {
+	var roles []model.Role
+	if err := r.db.WithContext(ctx).Where("name IN ?", names).Find(&roles).Error; err != nil {
+		return err
+	}
+	if len(roles) != len(names) {
+		return repository.NewErrInvalidInput("Role", "name", strings.Join(names, ","))
+	}
+
+	user := &model.User{}
+	user.ID = userID
+	return r.db.WithContext(ctx).Model(user).Association("Roles").Replace(roles)
+}
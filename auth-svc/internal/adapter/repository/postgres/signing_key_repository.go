@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/db/model"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	"github.com/Chengxufeng1994/go-saga-example/common/token"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SigningKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewSigningKeyRepository(db *gorm.DB) repository.SigningKeyRepository {
+	return &SigningKeyRepository{
+		db: db,
+	}
+}
+
+// LoadKeys implements repository.SigningKeyRepository.
+func (r *SigningKeyRepository) LoadKeys(ctx context.Context) ([]*token.SigningKey, error) {
+	var keys []model.SigningKey
+	if err := r.db.WithContext(ctx).Order("active_at").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+
+	res := make([]*token.SigningKey, 0, len(keys))
+	for i := range keys {
+		key, err := token.ParseSigningKey(keys[i].ID, keys[i].PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		key.ActiveAt = keys[i].ActiveAt
+		res = append(res, key)
+	}
+	return res, nil
+}
+
+// AddKey implements repository.SigningKeyRepository.
+func (r *SigningKeyRepository) AddKey(ctx context.Context, key *token.SigningKey, previousID string) (bool, error) {
+	dat, err := token.EncodeSigningKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	// a replica which rotated the same key first makes the insert a no-op
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&model.SigningKey{
+		ID:         key.ID,
+		PreviousID: previousID,
+		PrivateKey: dat,
+		ActiveAt:   key.ActiveAt,
+	})
+	if result.Error != nil {
+		return false, repository.NewErrFailedCreate("SigningKey").Wrap(result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RemoveKey implements repository.SigningKeyRepository.
+func (r *SigningKeyRepository) RemoveKey(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.SigningKey{}).Error
+}
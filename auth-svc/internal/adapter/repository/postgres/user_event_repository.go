@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/db/model"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	"gorm.io/gorm"
+)
+
+type UserEventOutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewUserEventOutboxRepository(db *gorm.DB) repository.UserEventOutboxRepository {
+	return &UserEventOutboxRepository{
+		db: db,
+	}
+}
+
+// ListUserEvents implements repository.UserEventOutboxRepository.
+func (r *UserEventOutboxRepository) ListUserEvents(ctx context.Context, limit int) ([]*entity.UserDeletedEvent, error) {
+	var events []model.UserEvent
+	if err := r.db.WithContext(ctx).Where("type = ?", entity.UserEventDeleted).Order("created_at").Limit(limit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	res := make([]*entity.UserDeletedEvent, 0, len(events))
+	for _, evt := range events {
+		res = append(res, &entity.UserDeletedEvent{
+			ID:        evt.ID,
+			UserID:    evt.UserID,
+			DeletedAt: evt.OccurredAt,
+		})
+	}
+	return res, nil
+}
+
+// RemoveUserEvent implements repository.UserEventOutboxRepository.
+func (r *UserEventOutboxRepository) RemoveUserEvent(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.UserEvent{}).Error
+}
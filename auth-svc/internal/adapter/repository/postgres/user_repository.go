@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/db/model"
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
 )
@@ -84,7 +87,7 @@ func (r *UserRepository) GetUserByID(ctx context.Context, id uint64) (*entity.Us
 // GetUserByEmail implements repository.UserRepository.
 func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*entity.User, error) {
 	var user entity.User
-	err := r.db.WithContext(ctx).Model(&entity.User{}).Where("email = ?", email).First(&user).Error
+	err := r.db.WithContext(ctx).Model(&entity.User{}).Where("LOWER(email) = LOWER(?)", email).First(&user).Error
 	if err != nil {
 		if errors.Is(err, repository.ErrRecordNotFound) {
 			return nil, repository.NewErrNotFound("User", fmt.Sprintf("email=%s", email))
@@ -95,3 +98,97 @@ func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*ent
 
 	return &user, nil
 }
+
+// MarkEmailVerified implements repository.UserRepository.
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, id uint64) error {
+	return r.updateUser(ctx, id, "email_verified", true)
+}
+
+// UpdatePassword implements repository.UserRepository.
+func (r *UserRepository) UpdatePassword(ctx context.Context, id uint64, hashedPassword string) error {
+	return r.updateUser(ctx, id, "password", hashedPassword)
+}
+
+// UpdateProfile implements repository.UserRepository.
+func (r *UserRepository) UpdateProfile(ctx context.Context, user *entity.User) (*entity.User, error) {
+	result := r.db.WithContext(ctx).Model(&entity.User{}).
+		Where("id = ?", user.ID).
+		Select("first_name", "last_name", "email", "email_verified", "address", "phone_number").
+		Updates(user)
+	if result.Error != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(result.Error, &pgErr) && pgErr.Code == repository.UniqueViolation {
+			switch pgErr.ConstraintName {
+			case "uni_users_email":
+				return nil, repository.NewErrInvalidInput("User", "email", user.Email).Wrap(result.Error)
+			case "idx_users_phone_number":
+				return nil, repository.NewErrInvalidInput("User", "phone_number", user.PhoneNumber).Wrap(result.Error)
+			}
+		}
+
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, repository.NewErrNotFound("User", fmt.Sprintf("%d", user.ID))
+	}
+
+	return r.GetUserByID(ctx, user.ID)
+}
+
+// SetActive implements repository.UserRepository.
+func (r *UserRepository) SetActive(ctx context.Context, id uint64, active bool) error {
+	return r.updateUser(ctx, id, "active", active)
+}
+
+// DeleteUser implements repository.UserRepository. The user is removed
+// along with its roles, mfa settings, api keys and linked identities,
+// nothing is kept behind. The user deleted event is recorded in the outbox in
+// the same transaction.
+func (r *UserRepository) DeleteUser(ctx context.Context, id uint64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user := &model.User{}
+		user.ID = id
+		if err := tx.Model(user).Association("Roles").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&model.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&model.UserMFA{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&model.APIKey{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", id).Delete(&model.UserIdentity{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().Where("id = ?", id).Delete(&model.User{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return repository.NewErrNotFound("User", fmt.Sprintf("%d", id))
+		}
+
+		return tx.Create(&model.UserEvent{
+			ID:         uuid.NewString(),
+			Type:       entity.UserEventDeleted,
+			UserID:     id,
+			OccurredAt: time.Now(),
+		}).Error
+	})
+}
+
+func (r *UserRepository) updateUser(ctx context.Context, id uint64, column string, value any) error {
+	result := r.db.WithContext(ctx).Model(&entity.User{}).Where("id = ?", id).Update(column, value)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return repository.NewErrNotFound("User", fmt.Sprintf("%d", id))
+	}
+
+	return nil
+}
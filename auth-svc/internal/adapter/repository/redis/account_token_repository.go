@@ -0,0 +1,63 @@
+// redis
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	libredis "github.com/Chengxufeng1994/go-saga-example/common/redis"
+)
+
+type AccountTokenRepository struct {
+	rc libredis.RedisCache
+}
+
+func (r *AccountTokenRepository) buildAccountTokenKey(purpose entity.AccountTokenPurpose, id string) string {
+	return fmt.Sprintf("account_token:%s:%s", purpose, id)
+}
+
+func NewAccountTokenRepository(rc libredis.RedisCache) repository.AccountTokenRepository {
+	return &AccountTokenRepository{
+		rc: rc,
+	}
+}
+
+// StoreAccountToken implements repository.AccountTokenRepository.
+func (r *AccountTokenRepository) StoreAccountToken(ctx context.Context, purpose entity.AccountTokenPurpose, id string, userID uint64, ttl int) error {
+	return r.rc.Set(ctx, r.buildAccountTokenKey(purpose, id), userID, ttl)
+}
+
+// ConsumeAccountToken implements repository.AccountTokenRepository.
+func (r *AccountTokenRepository) ConsumeAccountToken(ctx context.Context, purpose entity.AccountTokenPurpose, id string) (uint64, error) {
+	var userID uint64
+	ok, err := r.rc.GetDel(ctx, r.buildAccountTokenKey(purpose, id), &userID)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, repository.NewErrNotFound("AccountToken", id)
+	}
+
+	return userID, nil
+}
+
+// StoreEmailChange implements repository.AccountTokenRepository.
+func (r *AccountTokenRepository) StoreEmailChange(ctx context.Context, id string, change *entity.EmailChange, ttl int) error {
+	return r.rc.Set(ctx, r.buildAccountTokenKey(entity.AccountTokenEmailChange, id), change, ttl)
+}
+
+// ConsumeEmailChange implements repository.AccountTokenRepository.
+func (r *AccountTokenRepository) ConsumeEmailChange(ctx context.Context, id string) (*entity.EmailChange, error) {
+	change := &entity.EmailChange{}
+	ok, err := r.rc.GetDel(ctx, r.buildAccountTokenKey(entity.AccountTokenEmailChange, id), change)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, repository.NewErrNotFound("EmailChange", id)
+	}
+
+	return change, nil
+}
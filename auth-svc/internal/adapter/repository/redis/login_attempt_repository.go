@@ -0,0 +1,105 @@
+// redis
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	libredis "github.com/Chengxufeng1994/go-saga-example/common/redis"
+	"github.com/google/uuid"
+)
+
+type LoginAttemptRepository struct {
+	rc libredis.RedisCache
+}
+
+func (r *LoginAttemptRepository) buildIPAttemptsKey(ip string) string {
+	return fmt.Sprintf("login_attempts:ip:%s", ip)
+}
+
+func (r *LoginAttemptRepository) buildFailuresKey(email string) string {
+	return fmt.Sprintf("login_failures:%s", email)
+}
+
+func (r *LoginAttemptRepository) buildLockoutKey(email string) string {
+	return fmt.Sprintf("login_lockout:%s", email)
+}
+
+func NewLoginAttemptRepository(rc libredis.RedisCache) repository.LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		rc: rc,
+	}
+}
+
+// RecordIPAttempt implements repository.LoginAttemptRepository.
+func (r *LoginAttemptRepository) RecordIPAttempt(ctx context.Context, ip string, window int) (int64, error) {
+	return r.record(ctx, r.buildIPAttemptsKey(ip), window)
+}
+
+// RecordFailure implements repository.LoginAttemptRepository.
+func (r *LoginAttemptRepository) RecordFailure(ctx context.Context, email string, window int) (int64, error) {
+	return r.record(ctx, r.buildFailuresKey(email), window)
+}
+
+// CountFailures implements repository.LoginAttemptRepository.
+func (r *LoginAttemptRepository) CountFailures(ctx context.Context, email string, window int) (int64, error) {
+	return r.count(ctx, r.buildFailuresKey(email), time.Now(), window)
+}
+
+// ResetFailures implements repository.LoginAttemptRepository.
+func (r *LoginAttemptRepository) ResetFailures(ctx context.Context, email string) error {
+	return r.rc.Del(ctx, r.buildFailuresKey(email))
+}
+
+// GetLockout implements repository.LoginAttemptRepository.
+func (r *LoginAttemptRepository) GetLockout(ctx context.Context, email string) (*entity.Lockout, error) {
+	var lockout entity.Lockout
+	ok, err := r.rc.Get(ctx, r.buildLockoutKey(email), &lockout)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, repository.NewErrNotFound("Lockout", email)
+	}
+
+	return &lockout, nil
+}
+
+// StoreLockout implements repository.LoginAttemptRepository.
+func (r *LoginAttemptRepository) StoreLockout(ctx context.Context, lockout *entity.Lockout, ttl int) error {
+	return r.rc.Set(ctx, r.buildLockoutKey(lockout.Email), lockout, ttl)
+}
+
+// RemoveLockout implements repository.LoginAttemptRepository.
+func (r *LoginAttemptRepository) RemoveLockout(ctx context.Context, email string) error {
+	if err := r.rc.Del(ctx, r.buildLockoutKey(email)); err != nil {
+		return err
+	}
+	return r.ResetFailures(ctx, email)
+}
+
+// record adds an attempt to the sorted set scored by its time in
+// milliseconds and returns the attempts within the window
+func (r *LoginAttemptRepository) record(ctx context.Context, key string, window int) (int64, error) {
+	now := time.Now()
+	if err := r.rc.ZAdd(ctx, key, float64(now.UnixMilli()), uuid.NewString()); err != nil {
+		return 0, err
+	}
+	if err := r.rc.Expire(ctx, key, window); err != nil {
+		return 0, err
+	}
+	return r.count(ctx, key, now, window)
+}
+
+// count drops the attempts that slid out of the window and counts the rest
+func (r *LoginAttemptRepository) count(ctx context.Context, key string, now time.Time, window int) (int64, error) {
+	start := now.Add(-time.Duration(window) * time.Second).UnixMilli()
+	if err := r.rc.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.FormatInt(start, 10)); err != nil {
+		return 0, err
+	}
+	return r.rc.ZCard(ctx, key)
+}
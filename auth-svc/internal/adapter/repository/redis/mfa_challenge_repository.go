@@ -0,0 +1,78 @@
+// redis
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	libredis "github.com/Chengxufeng1994/go-saga-example/common/redis"
+)
+
+type MFAChallengeRepository struct {
+	rc libredis.RedisCache
+}
+
+func (r *MFAChallengeRepository) buildChallengeKey(id string) string {
+	return fmt.Sprintf("mfa_challenge:%s", id)
+}
+
+func (r *MFAChallengeRepository) buildAttemptsKey(id string) string {
+	return fmt.Sprintf("mfa_challenge:%s:attempts", id)
+}
+
+func NewMFAChallengeRepository(rc libredis.RedisCache) repository.MFAChallengeRepository {
+	return &MFAChallengeRepository{
+		rc: rc,
+	}
+}
+
+// StoreChallenge implements repository.MFAChallengeRepository.
+func (r *MFAChallengeRepository) StoreChallenge(ctx context.Context, challenge *entity.MFAChallenge) error {
+	ttl := int(time.Until(challenge.ExpiresAt).Seconds())
+	if ttl <= 0 {
+		return r.RemoveChallenge(ctx, challenge.ID)
+	}
+	return r.rc.Set(ctx, r.buildChallengeKey(challenge.ID), challenge, ttl)
+}
+
+// GetChallenge implements repository.MFAChallengeRepository.
+func (r *MFAChallengeRepository) GetChallenge(ctx context.Context, id string) (*entity.MFAChallenge, error) {
+	var challenge entity.MFAChallenge
+	ok, err := r.rc.Get(ctx, r.buildChallengeKey(id), &challenge)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, repository.NewErrNotFound("MFAChallenge", id)
+	}
+
+	return &challenge, nil
+}
+
+// RemoveChallenge implements repository.MFAChallengeRepository.
+func (r *MFAChallengeRepository) RemoveChallenge(ctx context.Context, id string) error {
+	if err := r.rc.Del(ctx, r.buildChallengeKey(id)); err != nil {
+		return err
+	}
+	return r.rc.Del(ctx, r.buildAttemptsKey(id))
+}
+
+// CountAttempt implements repository.MFAChallengeRepository.
+func (r *MFAChallengeRepository) CountAttempt(ctx context.Context, challenge *entity.MFAChallenge) (int, error) {
+	key := r.buildAttemptsKey(challenge.ID)
+	attempts, err := r.rc.Incr(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	// the counter expires along with its challenge
+	if attempts == 1 {
+		ttl := int(time.Until(challenge.ExpiresAt).Seconds()) + 1
+		if err := r.rc.Expire(ctx, key, ttl); err != nil {
+			return 0, err
+		}
+	}
+	return int(attempts), nil
+}
@@ -0,0 +1,49 @@
+// redis
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	libredis "github.com/Chengxufeng1994/go-saga-example/common/redis"
+)
+
+type OIDCStateRepository struct {
+	rc libredis.RedisCache
+}
+
+func (r *OIDCStateRepository) buildStateKey(id string) string {
+	return fmt.Sprintf("oidc_state:%s", id)
+}
+
+func NewOIDCStateRepository(rc libredis.RedisCache) repository.OIDCStateRepository {
+	return &OIDCStateRepository{
+		rc: rc,
+	}
+}
+
+// StoreState implements repository.OIDCStateRepository.
+func (r *OIDCStateRepository) StoreState(ctx context.Context, state *entity.OIDCState) error {
+	ttl := int(time.Until(state.ExpiresAt).Seconds())
+	if ttl <= 0 {
+		return nil
+	}
+	return r.rc.Set(ctx, r.buildStateKey(state.ID), state, ttl)
+}
+
+// ConsumeState implements repository.OIDCStateRepository.
+func (r *OIDCStateRepository) ConsumeState(ctx context.Context, id string) (*entity.OIDCState, error) {
+	var state entity.OIDCState
+	ok, err := r.rc.GetDel(ctx, r.buildStateKey(id), &state)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, repository.NewErrNotFound("OIDCState", id)
+	}
+
+	return &state, nil
+}
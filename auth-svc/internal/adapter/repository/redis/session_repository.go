@@ -0,0 +1,145 @@
+// redis
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	libredis "github.com/Chengxufeng1994/go-saga-example/common/redis"
+)
+
+// rotateSessionScript swaps the session in a single step so two requests
+// presenting the same refresh token can not both rotate it
+const rotateSessionScript = `
+local current = redis.call('GET', KEYS[1])
+if not current then
+	return -1
+end
+if cjson.decode(current)['refresh_token_id'] ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'EX', ARGV[3])
+return 1
+`
+
+type SessionRepository struct {
+	rc libredis.RedisCache
+}
+
+func (r *SessionRepository) buildSessionKey(sessionID string) string {
+	return fmt.Sprintf("session:%s", sessionID)
+}
+
+func (r *SessionRepository) buildUserSessionsKey(userID uint64) string {
+	return fmt.Sprintf("user:%d:sessions", userID)
+}
+
+func NewSessionRepository(rc libredis.RedisCache) repository.SessionRepository {
+	return &SessionRepository{
+		rc: rc,
+	}
+}
+
+// CreateSession implements repository.SessionRepository.
+func (r *SessionRepository) CreateSession(ctx context.Context, session *entity.Session, ttl int) error {
+	if err := r.rc.Set(ctx, r.buildSessionKey(session.ID), session, ttl); err != nil {
+		return err
+	}
+
+	userSessionsKey := r.buildUserSessionsKey(session.UserID)
+	if err := r.rc.SAdd(ctx, userSessionsKey, session.ID); err != nil {
+		return err
+	}
+	// the index lives as long as the newest session
+	return r.rc.Expire(ctx, userSessionsKey, ttl)
+}
+
+// GetSession implements repository.SessionRepository.
+func (r *SessionRepository) GetSession(ctx context.Context, sessionID string) (*entity.Session, error) {
+	var session entity.Session
+	ok, err := r.rc.Get(ctx, r.buildSessionKey(sessionID), &session)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, repository.NewErrNotFound("Session", sessionID)
+	}
+
+	return &session, nil
+}
+
+// RotateSession implements repository.SessionRepository.
+func (r *SessionRepository) RotateSession(ctx context.Context, session *entity.Session, refreshTokenID string, ttl int) (bool, error) {
+	dat, err := json.Marshal(session)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := r.rc.Eval(ctx, rotateSessionScript, []string{r.buildSessionKey(session.ID)}, refreshTokenID, dat, ttl)
+	if err != nil {
+		return false, err
+	}
+	switch res {
+	case int64(-1):
+		return false, repository.NewErrNotFound("Session", session.ID)
+	case int64(0):
+		return false, nil
+	}
+
+	return true, r.rc.Expire(ctx, r.buildUserSessionsKey(session.UserID), ttl)
+}
+
+// ListSessions implements repository.SessionRepository.
+func (r *SessionRepository) ListSessions(ctx context.Context, userID uint64) ([]*entity.Session, error) {
+	userSessionsKey := r.buildUserSessionsKey(userID)
+	ids, err := r.rc.SMembers(ctx, userSessionsKey)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sessions := make([]*entity.Session, 0, len(ids))
+	for _, id := range ids {
+		var session entity.Session
+		ok, err := r.rc.Get(ctx, r.buildSessionKey(id), &session)
+		if err != nil {
+			return nil, err
+		}
+		// drop index entries of sessions which have already expired
+		if !ok || session.ExpiresAt.Before(now) {
+			_ = r.rc.SRem(ctx, userSessionsKey, id)
+			continue
+		}
+		sessions = append(sessions, &session)
+	}
+
+	return sessions, nil
+}
+
+// RemoveSession implements repository.SessionRepository.
+func (r *SessionRepository) RemoveSession(ctx context.Context, userID uint64, sessionID string) error {
+	if err := r.rc.Del(ctx, r.buildSessionKey(sessionID)); err != nil {
+		return err
+	}
+	return r.rc.SRem(ctx, r.buildUserSessionsKey(userID), sessionID)
+}
+
+// RemoveSessions implements repository.SessionRepository.
+func (r *SessionRepository) RemoveSessions(ctx context.Context, userID uint64) error {
+	userSessionsKey := r.buildUserSessionsKey(userID)
+	ids, err := r.rc.SMembers(ctx, userSessionsKey)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := r.rc.Del(ctx, r.buildSessionKey(id)); err != nil {
+			return err
+		}
+	}
+	return r.rc.Del(ctx, userSessionsKey)
+}
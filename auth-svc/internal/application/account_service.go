@@ -0,0 +1,358 @@
+package application
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/usecase"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/utils"
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/constant"
+	"github.com/Chengxufeng1994/go-saga-example/common/model"
+	"github.com/Chengxufeng1994/go-saga-example/common/rbac"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultVerificationTokenExpires  = 86400
+	defaultPasswordResetTokenExpires = 3600
+	accountTokenSize                 = 32
+)
+
+type AccountService struct {
+	logger                 *log.Entry
+	userRepository         repository.UserRepository
+	sessionRepository      repository.SessionRepository
+	roleRepository         repository.RoleRepository
+	accountTokenRepository repository.AccountTokenRepository
+	notifier               repository.Notifier
+	adminEmails            []string
+	secret                 []byte
+	linkBaseURL            string
+	verificationExpires    int
+	passwordResetExpires   int
+}
+
+func NewAccountService(
+	userRepository repository.UserRepository,
+	sessionRepository repository.SessionRepository,
+	roleRepository repository.RoleRepository,
+	accountTokenRepository repository.AccountTokenRepository,
+	notifier repository.Notifier,
+	accountCfg libconfig.Account,
+	adminEmails []string,
+	secret []byte,
+) usecase.AccountUseCase {
+	svc := &AccountService{
+		logger:                 config.ContextLogger.WithFields(log.Fields{"type": "service:AccountService"}),
+		userRepository:         userRepository,
+		sessionRepository:      sessionRepository,
+		roleRepository:         roleRepository,
+		accountTokenRepository: accountTokenRepository,
+		notifier:               notifier,
+		adminEmails:            adminEmails,
+		secret:                 secret,
+		linkBaseURL:            strings.TrimSuffix(accountCfg.LinkBaseURL, "/"),
+		verificationExpires:    accountCfg.VerificationTokenExpires,
+		passwordResetExpires:   accountCfg.PasswordResetTokenExpires,
+	}
+	if svc.verificationExpires == 0 {
+		svc.verificationExpires = defaultVerificationTokenExpires
+	}
+	if svc.passwordResetExpires == 0 {
+		svc.passwordResetExpires = defaultPasswordResetTokenExpires
+	}
+
+	return svc
+}
+
+// sign computes the signature binding the token id to its purpose
+func (svc *AccountService) sign(purpose entity.AccountTokenPurpose, id string) string {
+	mac := hmac.New(sha256.New, svc.secret)
+	mac.Write([]byte(string(purpose) + ":" + id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// newToken returns a random token id and the signed token
+func (svc *AccountService) newToken(purpose entity.AccountTokenPurpose) (string, string, error) {
+	b := make([]byte, accountTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	id := base64.RawURLEncoding.EncodeToString(b)
+	return id, id + "." + svc.sign(purpose, id), nil
+}
+
+// verifyToken verifies the signature and returns the token id
+func (svc *AccountService) verifyToken(purpose entity.AccountTokenPurpose, token string) (string, error) {
+	id, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(svc.sign(purpose, id))) {
+		return "", usecase.ErrInvalidAccountToken
+	}
+	return id, nil
+}
+
+// issueToken stores a random token id for the user and returns the signed token
+func (svc *AccountService) issueToken(ctx context.Context, purpose entity.AccountTokenPurpose, userID uint64, ttl int) (string, error) {
+	id, token, err := svc.newToken(purpose)
+	if err != nil {
+		return "", err
+	}
+
+	if err := svc.accountTokenRepository.StoreAccountToken(ctx, purpose, id, userID, ttl); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeToken verifies the signature and redeems the token, it can only be redeemed once
+func (svc *AccountService) consumeToken(ctx context.Context, purpose entity.AccountTokenPurpose, token string) (uint64, error) {
+	id, err := svc.verifyToken(purpose, token)
+	if err != nil {
+		return 0, err
+	}
+
+	userID, err := svc.accountTokenRepository.ConsumeAccountToken(ctx, purpose, id)
+	if err != nil {
+		var nfErr *repository.ErrNotFound
+		if errors.As(err, &nfErr) {
+			return 0, usecase.ErrInvalidAccountToken
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+func (svc *AccountService) link(path, token string) string {
+	if svc.linkBaseURL == "" {
+		return token
+	}
+	return fmt.Sprintf("%s%s?token=%s", svc.linkBaseURL, path, url.QueryEscape(token))
+}
+
+// SendEmailVerification implements usecase.AccountUseCase.
+func (svc *AccountService) SendEmailVerification(ctx context.Context, req *dto.EmailRequest) (string, error) {
+	user, err := svc.userRepository.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		var nfErr *repository.ErrNotFound
+		if errors.As(err, &nfErr) {
+			// never reveal whether an email is registered
+			return "Ok", nil
+		}
+		return "", model.NewAppError("SendEmailVerification", "app.user.get_user_by_email.error", nil, "").Wrap(err)
+	}
+	if user.EmailVerified {
+		return "Ok", nil
+	}
+
+	token, err := svc.issueToken(ctx, entity.AccountTokenEmailVerification, user.ID, svc.verificationExpires)
+	if err != nil {
+		return "", model.NewAppError("SendEmailVerification", "app.account.issue_token.error", nil, "").Wrap(err)
+	}
+
+	err = svc.notifier.Notify(ctx, &entity.Notification{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body:    fmt.Sprintf("Hi %s, verify your email with %s", user.FirstName, svc.link("/verify-email", token)),
+	})
+	if err != nil {
+		return "", model.NewAppError("SendEmailVerification", "app.account.notify.error", nil, "").Wrap(err)
+	}
+
+	return "Ok", nil
+}
+
+// VerifyEmail implements usecase.AccountUseCase.
+func (svc *AccountService) VerifyEmail(ctx context.Context, req *dto.VerifyEmailRequest) (string, error) {
+	userID, err := svc.consumeToken(ctx, entity.AccountTokenEmailVerification, req.Token)
+	if err != nil {
+		return "", model.NewAppError("VerifyEmail", "app.account.consume_token.error", nil, "").Wrap(err)
+	}
+
+	if err := svc.userRepository.MarkEmailVerified(ctx, userID); err != nil {
+		return "", model.NewAppError("VerifyEmail", "app.user.mark_email_verified.error", nil, "").Wrap(err)
+	}
+	if err := svc.grantAdmin(ctx, userID); err != nil {
+		return "", model.NewAppError("VerifyEmail", "app.user.set_roles.error", nil, "").Wrap(err)
+	}
+
+	return "Ok", nil
+}
+
+// RequestEmailChange implements usecase.AccountUseCase. The email of the
+// signed in user is switched once the link sent to the new email is
+// followed, which proves owning it.
+func (svc *AccountService) RequestEmailChange(ctx context.Context, req *dto.EmailRequest) (string, error) {
+	userID := ctx.Value(constant.CtxUserKey).(uint64)
+	user, err := svc.userRepository.GetUserByID(ctx, userID)
+	if err != nil {
+		return "", model.NewAppError("RequestEmailChange", "app.user.get_by_id.error", nil, "").Wrap(err)
+	}
+	_, err = svc.userRepository.GetUserByEmail(ctx, req.Email)
+	var nfErr *repository.ErrNotFound
+	switch {
+	case err == nil:
+		return "", model.NewAppError("RequestEmailChange", "app.user.get_user_by_email.error", nil, "").Wrap(usecase.ErrProfileConflict)
+	case !errors.As(err, &nfErr):
+		return "", model.NewAppError("RequestEmailChange", "app.user.get_user_by_email.error", nil, "").Wrap(err)
+	}
+
+	id, token, err := svc.newToken(entity.AccountTokenEmailChange)
+	if err != nil {
+		return "", model.NewAppError("RequestEmailChange", "app.account.issue_token.error", nil, "").Wrap(err)
+	}
+	err = svc.accountTokenRepository.StoreEmailChange(ctx, id, &entity.EmailChange{UserID: userID, Email: req.Email}, svc.verificationExpires)
+	if err != nil {
+		return "", model.NewAppError("RequestEmailChange", "app.account.issue_token.error", nil, "").Wrap(err)
+	}
+
+	err = svc.notifier.Notify(ctx, &entity.Notification{
+		To:      req.Email,
+		Subject: "Confirm your new email",
+		Body:    fmt.Sprintf("Hi %s, confirm your new email with %s", user.FirstName, svc.link("/confirm-email", token)),
+	})
+	if err != nil {
+		return "", model.NewAppError("RequestEmailChange", "app.account.notify.error", nil, "").Wrap(err)
+	}
+
+	return "Ok", nil
+}
+
+// ConfirmEmailChange implements usecase.AccountUseCase. The previous email
+// is told about the change.
+func (svc *AccountService) ConfirmEmailChange(ctx context.Context, req *dto.VerifyEmailRequest) (string, error) {
+	id, err := svc.verifyToken(entity.AccountTokenEmailChange, req.Token)
+	if err != nil {
+		return "", model.NewAppError("ConfirmEmailChange", "app.account.consume_token.error", nil, "").Wrap(err)
+	}
+	change, err := svc.accountTokenRepository.ConsumeEmailChange(ctx, id)
+	if err != nil {
+		var nfErr *repository.ErrNotFound
+		if errors.As(err, &nfErr) {
+			err = usecase.ErrInvalidAccountToken
+		}
+		return "", model.NewAppError("ConfirmEmailChange", "app.account.consume_token.error", nil, "").Wrap(err)
+	}
+
+	user, err := svc.userRepository.GetUserByID(ctx, change.UserID)
+	if err != nil {
+		return "", model.NewAppError("ConfirmEmailChange", "app.user.get_by_id.error", nil, "").Wrap(err)
+	}
+	previousEmail := user.Email
+	user.Email = change.Email
+	user.EmailVerified = true
+	if _, err := svc.userRepository.UpdateProfile(ctx, user); err != nil {
+		var iiErr *repository.ErrInvalidInput
+		if errors.As(err, &iiErr) {
+			err = usecase.ErrProfileConflict
+		}
+		return "", model.NewAppError("ConfirmEmailChange", "app.user.update_profile.error", nil, "").Wrap(err)
+	}
+
+	err = svc.notifier.Notify(ctx, &entity.Notification{
+		To:      previousEmail,
+		Subject: "Your email was changed",
+		Body:    fmt.Sprintf("Hi %s, the email of your account was changed to %s", user.FirstName, change.Email),
+	})
+	if err != nil {
+		svc.logger.WithError(err).Error("notify previous email")
+	}
+	if err := svc.grantAdmin(ctx, user.ID); err != nil {
+		return "", model.NewAppError("ConfirmEmailChange", "app.user.set_roles.error", nil, "").Wrap(err)
+	}
+
+	return "Ok", nil
+}
+
+// grantAdmin bootstraps the admins listed in rbac.admin_emails, only once the
+// user proved owning the address
+func (svc *AccountService) grantAdmin(ctx context.Context, userID uint64) error {
+	user, err := svc.userRepository.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(svc.adminEmails, user.Email) {
+		return nil
+	}
+
+	roles, err := svc.roleRepository.GetUserRoles(ctx, userID)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(roles)+1)
+	for _, role := range roles {
+		if role.Name == rbac.RoleAdmin {
+			return nil
+		}
+		names = append(names, role.Name)
+	}
+	return svc.roleRepository.SetUserRoles(ctx, userID, append(names, rbac.RoleAdmin))
+}
+
+// RequestPasswordReset implements usecase.AccountUseCase.
+func (svc *AccountService) RequestPasswordReset(ctx context.Context, req *dto.EmailRequest) (string, error) {
+	user, err := svc.userRepository.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		var nfErr *repository.ErrNotFound
+		if errors.As(err, &nfErr) {
+			// never reveal whether an email is registered
+			return "Ok", nil
+		}
+		return "", model.NewAppError("RequestPasswordReset", "app.user.get_user_by_email.error", nil, "").Wrap(err)
+	}
+
+	token, err := svc.issueToken(ctx, entity.AccountTokenPasswordReset, user.ID, svc.passwordResetExpires)
+	if err != nil {
+		return "", model.NewAppError("RequestPasswordReset", "app.account.issue_token.error", nil, "").Wrap(err)
+	}
+
+	err = svc.notifier.Notify(ctx, &entity.Notification{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body:    fmt.Sprintf("Hi %s, reset your password with %s", user.FirstName, svc.link("/reset-password", token)),
+	})
+	if err != nil {
+		return "", model.NewAppError("RequestPasswordReset", "app.account.notify.error", nil, "").Wrap(err)
+	}
+
+	return "Ok", nil
+}
+
+// ResetPassword implements usecase.AccountUseCase.
+func (svc *AccountService) ResetPassword(ctx context.Context, req *dto.ResetPasswordRequest) (string, error) {
+	userID, err := svc.consumeToken(ctx, entity.AccountTokenPasswordReset, req.Token)
+	if err != nil {
+		return "", model.NewAppError("ResetPassword", "app.account.consume_token.error", nil, "").Wrap(err)
+	}
+
+	hashedPassword, err := utils.HashedPassword(req.Password)
+	if err != nil {
+		return "", model.NewAppError("ResetPassword", "app.user.hash_password.error", nil, "").Wrap(err)
+	}
+	if err := svc.userRepository.UpdatePassword(ctx, userID, hashedPassword); err != nil {
+		return "", model.NewAppError("ResetPassword", "app.user.update_password.error", nil, "").Wrap(err)
+	}
+	// the reset link was delivered to the mailbox, which proves its ownership
+	if err := svc.userRepository.MarkEmailVerified(ctx, userID); err != nil {
+		svc.logger.WithError(err).Error("mark email verified")
+	}
+	// sign out every device, whoever knew the old password is locked out
+	if err := svc.sessionRepository.RemoveSessions(ctx, userID); err != nil {
+		svc.logger.WithError(err).Error("remove sessions")
+	}
+
+	return "Ok", nil
+}
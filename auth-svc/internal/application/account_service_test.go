@@ -0,0 +1,167 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/usecase"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/utils"
+	"github.com/Chengxufeng1994/go-saga-example/common/constant"
+	"github.com/Chengxufeng1994/go-saga-example/common/rbac"
+)
+
+func TestGrantAdmin(t *testing.T) {
+	tests := []struct {
+		name  string
+		email string
+		roles []string
+		want  []string
+	}{
+		{"listed address", "root@shop.test", []string{rbac.RoleCustomer}, []string{rbac.RoleCustomer, rbac.RoleAdmin}},
+		{"already admin", "root@shop.test", []string{rbac.RoleAdmin}, []string{rbac.RoleAdmin}},
+		{"other address", "user@shop.test", []string{rbac.RoleCustomer}, []string{rbac.RoleCustomer}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roleRepository := &fakeRoleRepository{roles: map[uint64][]string{1: tt.roles}}
+			svc := &AccountService{
+				userRepository: &fakeUserRepository{users: map[uint64]*entity.User{1: {ID: 1, Email: tt.email}}},
+				roleRepository: roleRepository,
+				adminEmails:    []string{"root@shop.test"},
+			}
+
+			if err := svc.grantAdmin(context.Background(), 1); err != nil {
+				t.Fatalf("grantAdmin() error = %v", err)
+			}
+			if got := roleRepository.roles[1]; !slices.Equal(got, tt.want) {
+				t.Errorf("roles = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccountToken(t *testing.T) {
+	ctx := context.Background()
+	tokens := &fakeAccountTokenRepository{tokens: map[string]uint64{}}
+	svc := &AccountService{accountTokenRepository: tokens, secret: []byte("account-secret")}
+	other := &AccountService{accountTokenRepository: tokens, secret: []byte("jwt-secret")}
+
+	issue := func(t *testing.T) string {
+		token, err := svc.issueToken(ctx, entity.AccountTokenPasswordReset, 7, 60)
+		if err != nil {
+			t.Fatalf("issueToken() error = %v", err)
+		}
+		return token
+	}
+	tests := []struct {
+		name    string
+		consume func(token string) (uint64, error)
+	}{
+		{"other purpose", func(token string) (uint64, error) {
+			return svc.consumeToken(ctx, entity.AccountTokenEmailVerification, token)
+		}},
+		{"other secret", func(token string) (uint64, error) {
+			return other.consumeToken(ctx, entity.AccountTokenPasswordReset, token)
+		}},
+		{"tampered signature", func(token string) (uint64, error) {
+			return svc.consumeToken(ctx, entity.AccountTokenPasswordReset, token+"x")
+		}},
+		{"missing signature", func(token string) (uint64, error) {
+			id, _, _ := strings.Cut(token, ".")
+			return svc.consumeToken(ctx, entity.AccountTokenPasswordReset, id)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.consume(issue(t)); !errors.Is(err, usecase.ErrInvalidAccountToken) {
+				t.Errorf("consumeToken() error = %v, want %v", err, usecase.ErrInvalidAccountToken)
+			}
+		})
+	}
+
+	token := issue(t)
+	if userID, err := svc.consumeToken(ctx, entity.AccountTokenPasswordReset, token); err != nil || userID != 7 {
+		t.Fatalf("consumeToken() = %d, %v, want 7", userID, err)
+	}
+	if _, err := svc.consumeToken(ctx, entity.AccountTokenPasswordReset, token); !errors.Is(err, usecase.ErrInvalidAccountToken) {
+		t.Errorf("second consumeToken() error = %v, want %v", err, usecase.ErrInvalidAccountToken)
+	}
+}
+
+func TestEmailChange(t *testing.T) {
+	hashedPassword, err := utils.HashedPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantErr  error
+	}{
+		{"wrong password", "new@shop.test", "wrong", usecase.ErrIncorrectPassword},
+		{"email in use", "taken@shop.test", "password", usecase.ErrProfileConflict},
+		{"confirmed", "new@shop.test", "password", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), constant.CtxUserKey, uint64(1))
+			users := &fakeUserRepository{users: map[uint64]*entity.User{
+				1: {ID: 1, FirstName: "Ann", Email: "old@shop.test", EmailVerified: true, Password: hashedPassword},
+				2: {ID: 2, Email: "taken@shop.test"},
+			}}
+			notifier := &fakeNotifier{}
+			accountService := &AccountService{
+				logger:         config.ContextLogger,
+				userRepository: users,
+				roleRepository: &fakeRoleRepository{roles: map[uint64][]string{}},
+				accountTokenRepository: &fakeAccountTokenRepository{
+					tokens:  map[string]uint64{},
+					changes: map[string]*entity.EmailChange{},
+				},
+				notifier: notifier,
+				secret:   []byte("account-secret"),
+			}
+			userService := &UserService{userRepository: users, accountService: accountService}
+
+			lastName := "Lee"
+			_, err := userService.UpdateProfile(ctx, &dto.UserProfileRequest{Email: &tt.email, LastName: &lastName, Password: tt.password})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateProfile() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := users.users[1].Email; got != "old@shop.test" {
+				t.Fatalf("email = %s before confirmation, want old@shop.test", got)
+			}
+			if tt.wantErr != nil {
+				if len(notifier.sent) != 0 {
+					t.Errorf("sent %d notifications, want none", len(notifier.sent))
+				}
+				return
+			}
+
+			if len(notifier.sent) != 1 || notifier.sent[0].To != tt.email {
+				t.Fatalf("notifications = %v, want one to %s", notifier.sent, tt.email)
+			}
+			fields := strings.Fields(notifier.sent[0].Body)
+			token := fields[len(fields)-1]
+			if _, err := accountService.ConfirmEmailChange(ctx, &dto.VerifyEmailRequest{Token: token}); err != nil {
+				t.Fatalf("ConfirmEmailChange() error = %v", err)
+			}
+			if user := users.users[1]; user.Email != tt.email || !user.EmailVerified || user.LastName != lastName {
+				t.Errorf("user = %+v, want verified %s", user, tt.email)
+			}
+			if len(notifier.sent) != 2 || notifier.sent[1].To != "old@shop.test" {
+				t.Errorf("notifications = %v, want the previous email told", notifier.sent)
+			}
+			if _, err := accountService.ConfirmEmailChange(ctx, &dto.VerifyEmailRequest{Token: token}); !errors.Is(err, usecase.ErrInvalidAccountToken) {
+				t.Errorf("second ConfirmEmailChange() error = %v, want %v", err, usecase.ErrInvalidAccountToken)
+			}
+		})
+	}
+}
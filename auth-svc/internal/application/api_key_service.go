@@ -0,0 +1,212 @@
+package application
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/usecase"
+	"github.com/Chengxufeng1994/go-saga-example/common/constant"
+	"github.com/Chengxufeng1994/go-saga-example/common/model"
+	"github.com/Chengxufeng1994/go-saga-example/common/rbac"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	apiKeyPrefix             = "sk_"
+	apiKeyIDSize             = 4
+	apiKeySecretSize         = 32
+	defaultAPIKeyExpiresDays = 90
+	// apiKeyLastUsedResolution limits the writes of busy keys
+	apiKeyLastUsedResolution = time.Minute
+)
+
+type APIKeyService struct {
+	logger           *log.Entry
+	apiKeyRepository repository.APIKeyRepository
+	userRepository   repository.UserRepository
+	roleRepository   repository.RoleRepository
+}
+
+func NewAPIKeyService(
+	apiKeyRepository repository.APIKeyRepository,
+	userRepository repository.UserRepository,
+	roleRepository repository.RoleRepository,
+) usecase.APIKeyUseCase {
+	return &APIKeyService{
+		logger:           config.ContextLogger.WithFields(log.Fields{"type": "service:APIKeyService"}),
+		apiKeyRepository: apiKeyRepository,
+		userRepository:   userRepository,
+		roleRepository:   roleRepository,
+	}
+}
+
+// CreateAPIKey implements usecase.APIKeyUseCase. A key can only be scoped to
+// permissions the caller holds.
+func (svc *APIKeyService) CreateAPIKey(ctx context.Context, req *dto.APIKeyCreationRequest) (*dto.APIKeyCreated, error) {
+	userId := ctx.Value(constant.CtxUserKey).(uint64)
+	permissions, _ := ctx.Value(constant.CtxPermissionsKey).([]string)
+
+	scopes := slices.Clone(req.Scopes)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+	if !rbac.HasPermission(permissions, scopes...) {
+		return nil, model.NewAppError("CreateAPIKey", "app.api_key.invalid_scope.error", nil, "").Wrap(usecase.ErrScopeNotGranted)
+	}
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	expiresInDays := req.ExpiresInDays
+	if expiresInDays == 0 {
+		expiresInDays = defaultAPIKeyExpiresDays
+	}
+	expiresAt := time.Now().AddDate(0, 0, expiresInDays)
+
+	key, prefix, err := generateAPIKey()
+	if err != nil {
+		return nil, model.NewAppError("CreateAPIKey", "app.api_key.gen_key.error", nil, "").Wrap(err)
+	}
+
+	created, err := svc.apiKeyRepository.CreateAPIKey(ctx, &entity.APIKey{
+		UserID:    userId,
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   hashAPIKey(key),
+		Scopes:    scopes,
+		ExpiresAt: &expiresAt,
+	})
+	if err != nil {
+		return nil, model.NewAppError("CreateAPIKey", "app.api_key.create.error", nil, "").Wrap(err)
+	}
+
+	return &dto.APIKeyCreated{
+		APIKey: *newAPIKeyDTO(created),
+		Key:    key,
+	}, nil
+}
+
+// ListAPIKeys implements usecase.APIKeyUseCase.
+func (svc *APIKeyService) ListAPIKeys(ctx context.Context) ([]*dto.APIKey, error) {
+	userId := ctx.Value(constant.CtxUserKey).(uint64)
+	keys, err := svc.apiKeyRepository.ListAPIKeys(ctx, userId)
+	if err != nil {
+		return nil, model.NewAppError("ListAPIKeys", "app.api_key.list.error", nil, "").Wrap(err)
+	}
+
+	res := make([]*dto.APIKey, 0, len(keys))
+	for _, key := range keys {
+		res = append(res, newAPIKeyDTO(key))
+	}
+	return res, nil
+}
+
+// RevokeAPIKey implements usecase.APIKeyUseCase.
+func (svc *APIKeyService) RevokeAPIKey(ctx context.Context, id uint64) (string, error) {
+	userId := ctx.Value(constant.CtxUserKey).(uint64)
+	if err := svc.apiKeyRepository.DeleteAPIKey(ctx, userId, id); err != nil {
+		var nfErr *repository.ErrNotFound
+		if errors.As(err, &nfErr) {
+			return "", model.NewAppError("RevokeAPIKey", "app.api_key.delete.error", nil, "").Wrap(usecase.ErrAPIKeyNotFound)
+		}
+		return "", model.NewAppError("RevokeAPIKey", "app.api_key.delete.error", nil, "").Wrap(err)
+	}
+	return "Ok", nil
+}
+
+// VerifyAPIKey implements usecase.APIKeyUseCase. The key is granted its
+// scopes as far as the owner still holds them.
+func (svc *APIKeyService) VerifyAPIKey(ctx context.Context, req *dto.VerifyAPIKeyRequest) (*dto.VerifyTokenResponse, error) {
+	key, err := svc.apiKeyRepository.GetAPIKeyByHash(ctx, hashAPIKey(req.APIKey))
+	if err != nil {
+		var nfErr *repository.ErrNotFound
+		if errors.As(err, &nfErr) {
+			return nil, model.NewAppError("VerifyAPIKey", "app.api_key.invalid.error", nil, "").Wrap(usecase.ErrInvalidAPIKey)
+		}
+		return nil, model.NewAppError("VerifyAPIKey", "app.api_key.get.error", nil, "").Wrap(err)
+	}
+	now := time.Now()
+	if key.Expired(now) {
+		return nil, model.NewAppError("VerifyAPIKey", "app.api_key.invalid.error", nil, "").Wrap(usecase.ErrInvalidAPIKey)
+	}
+
+	user, err := svc.userRepository.GetUserByID(ctx, key.UserID)
+	if err != nil {
+		var nfErr *repository.ErrNotFound
+		if errors.As(err, &nfErr) {
+			return nil, model.NewAppError("VerifyAPIKey", "app.api_key.invalid.error", nil, "").Wrap(usecase.ErrInvalidAPIKey)
+		}
+		return nil, model.NewAppError("VerifyAPIKey", "app.user.get_by_id.error", nil, "").Wrap(err)
+	}
+	if !user.Active {
+		return nil, model.NewAppError("VerifyAPIKey", "app.api_key.invalid.error", nil, "").Wrap(usecase.ErrInvalidAPIKey)
+	}
+
+	roles, err := svc.roleRepository.GetUserRoles(ctx, key.UserID)
+	if err != nil {
+		return nil, model.NewAppError("VerifyAPIKey", "app.auth.get_roles.error", nil, "").Wrap(err)
+	}
+	permissions := make([]string, 0, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		for _, role := range roles {
+			if slices.Contains(role.Permissions, scope) {
+				permissions = append(permissions, scope)
+				break
+			}
+		}
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyLastUsedResolution {
+		if err := svc.apiKeyRepository.TouchAPIKey(ctx, key.ID, now); err != nil {
+			svc.logger.WithError(err).Error("touch api key")
+		}
+	}
+
+	return &dto.VerifyTokenResponse{
+		UserId:      key.UserID,
+		Roles:       []string{},
+		Permissions: permissions,
+	}, nil
+}
+
+// generateAPIKey returns a random key and the prefix identifying it in listings
+func generateAPIKey() (string, string, error) {
+	id := make([]byte, apiKeyIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	secret := make([]byte, apiKeySecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	prefix := apiKeyPrefix + hex.EncodeToString(id)
+	return prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+// hashAPIKey keys are random enough for a plain digest to be safe at rest
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func newAPIKeyDTO(key *entity.APIKey) *dto.APIKey {
+	return &dto.APIKey{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		CreatedAt:  key.CreatedAt,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+	}
+}
@@ -0,0 +1,73 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/usecase"
+)
+
+// fakeAPIKeyRepository serves keys by their hash, unused methods panic
+type fakeAPIKeyRepository struct {
+	repository.APIKeyRepository
+	keys map[string]*entity.APIKey
+}
+
+func (r *fakeAPIKeyRepository) GetAPIKeyByHash(_ context.Context, hash string) (*entity.APIKey, error) {
+	key, ok := r.keys[hash]
+	if !ok {
+		return nil, repository.NewErrNotFound("APIKey", hash)
+	}
+	return key, nil
+}
+
+func (r *fakeAPIKeyRepository) TouchAPIKey(context.Context, uint64, time.Time) error {
+	return nil
+}
+
+func TestVerifyAPIKey(t *testing.T) {
+	expired := time.Now().Add(-time.Hour)
+	keys := map[string]*entity.APIKey{
+		hashAPIKey("sk_valid"):    {ID: 1, UserID: 1},
+		hashAPIKey("sk_expired"):  {ID: 2, UserID: 1, ExpiresAt: &expired},
+		hashAPIKey("sk_inactive"): {ID: 3, UserID: 2},
+		hashAPIKey("sk_deleted"):  {ID: 4, UserID: 3},
+	}
+	users := map[uint64]*entity.User{
+		1: {ID: 1, Active: true},
+		2: {ID: 2, Active: false},
+	}
+	tests := []struct {
+		name    string
+		key     string
+		wantErr error
+	}{
+		{"valid key", "sk_valid", nil},
+		{"unknown or revoked key", "sk_unknown", usecase.ErrInvalidAPIKey},
+		{"expired key", "sk_expired", usecase.ErrInvalidAPIKey},
+		{"deactivated user", "sk_inactive", usecase.ErrInvalidAPIKey},
+		{"deleted user", "sk_deleted", usecase.ErrInvalidAPIKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &APIKeyService{
+				apiKeyRepository: &fakeAPIKeyRepository{keys: keys},
+				userRepository:   &fakeUserRepository{users: users},
+				roleRepository:   &fakeRoleRepository{roles: map[uint64][]string{}},
+			}
+
+			res, err := svc.VerifyAPIKey(context.Background(), &dto.VerifyAPIKeyRequest{APIKey: tt.key})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyAPIKey() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && res.UserId != 1 {
+				t.Errorf("VerifyAPIKey() user = %d, want 1", res.UserId)
+			}
+		})
+	}
+}
@@ -3,13 +3,21 @@ package application
 import "github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/usecase"
 
 type Application struct {
-	AuthService usecase.AuthUseCase
-	UserService usecase.UserUseCase
+	AuthService    usecase.AuthUseCase
+	UserService    usecase.UserUseCase
+	AccountService usecase.AccountUseCase
+	MFAService     usecase.MFAUseCase
+	APIKeyService  usecase.APIKeyUseCase
+	OIDCService    usecase.OIDCUseCase
 }
 
-func New(authService usecase.AuthUseCase, userService usecase.UserUseCase) *Application {
+func New(authService usecase.AuthUseCase, userService usecase.UserUseCase, accountService usecase.AccountUseCase, mfaService usecase.MFAUseCase, apiKeyService usecase.APIKeyUseCase, oidcService usecase.OIDCUseCase) *Application {
 	return &Application{
-		AuthService: authService,
-		UserService: userService,
+		AuthService:    authService,
+		UserService:    userService,
+		AccountService: accountService,
+		MFAService:     mfaService,
+		APIKeyService:  apiKeyService,
+		OIDCService:    oidcService,
 	}
 }
@@ -0,0 +1,322 @@
+package application
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	logger := log.New()
+	logger.Out = io.Discard
+	config.ContextLogger = log.NewEntry(logger)
+	os.Exit(m.Run())
+}
+
+// fakeUserRepository serves users from memory, unused methods panic
+type fakeUserRepository struct {
+	repository.UserRepository
+	users map[uint64]*entity.User
+}
+
+func (r *fakeUserRepository) GetUserByID(_ context.Context, id uint64) (*entity.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, repository.NewErrNotFound("User", "id")
+	}
+	return user, nil
+}
+
+func (r *fakeUserRepository) GetUserByEmail(_ context.Context, email string) (*entity.User, error) {
+	for _, user := range r.users {
+		if strings.EqualFold(user.Email, email) {
+			return user, nil
+		}
+	}
+	return nil, repository.NewErrNotFound("User", "email")
+}
+
+func (r *fakeUserRepository) SetActive(_ context.Context, id uint64, active bool) error {
+	user, ok := r.users[id]
+	if !ok {
+		return repository.NewErrNotFound("User", "id")
+	}
+	user.Active = active
+	return nil
+}
+
+func (r *fakeUserRepository) UpdateProfile(_ context.Context, user *entity.User) (*entity.User, error) {
+	for _, other := range r.users {
+		if other.ID != user.ID && other.Email == user.Email {
+			return nil, repository.NewErrInvalidInput("User", "email", user.Email)
+		}
+	}
+	updated := *user
+	r.users[user.ID] = &updated
+	return &updated, nil
+}
+
+// fakeRoleRepository keeps the role names of users in memory
+type fakeRoleRepository struct {
+	roles map[uint64][]string
+}
+
+func (r *fakeRoleRepository) GetUserRoles(_ context.Context, userID uint64) ([]*entity.Role, error) {
+	roles := make([]*entity.Role, 0, len(r.roles[userID]))
+	for _, name := range r.roles[userID] {
+		roles = append(roles, &entity.Role{Name: name})
+	}
+	return roles, nil
+}
+
+func (r *fakeRoleRepository) SetUserRoles(_ context.Context, userID uint64, roles []string) error {
+	r.roles[userID] = roles
+	return nil
+}
+
+// fakeAccountTokenRepository keeps account tokens in memory, consuming removes them
+type fakeAccountTokenRepository struct {
+	tokens  map[string]uint64
+	changes map[string]*entity.EmailChange
+}
+
+func (r *fakeAccountTokenRepository) StoreAccountToken(_ context.Context, purpose entity.AccountTokenPurpose, id string, userID uint64, _ int) error {
+	r.tokens[string(purpose)+":"+id] = userID
+	return nil
+}
+
+func (r *fakeAccountTokenRepository) ConsumeAccountToken(_ context.Context, purpose entity.AccountTokenPurpose, id string) (uint64, error) {
+	userID, ok := r.tokens[string(purpose)+":"+id]
+	if !ok {
+		return 0, repository.NewErrNotFound("AccountToken", id)
+	}
+	delete(r.tokens, string(purpose)+":"+id)
+	return userID, nil
+}
+
+func (r *fakeAccountTokenRepository) StoreEmailChange(_ context.Context, id string, change *entity.EmailChange, _ int) error {
+	r.changes[id] = change
+	return nil
+}
+
+func (r *fakeAccountTokenRepository) ConsumeEmailChange(_ context.Context, id string) (*entity.EmailChange, error) {
+	change, ok := r.changes[id]
+	if !ok {
+		return nil, repository.NewErrNotFound("EmailChange", id)
+	}
+	delete(r.changes, id)
+	return change, nil
+}
+
+// fakeNotifier keeps the sent notifications
+type fakeNotifier struct {
+	sent []*entity.Notification
+}
+
+func (n *fakeNotifier) Notify(_ context.Context, notification *entity.Notification) error {
+	n.sent = append(n.sent, notification)
+	return nil
+}
+
+// fakeMFAChallengeRepository keeps challenges and their attempt counters in memory
+type fakeMFAChallengeRepository struct {
+	mu         sync.Mutex
+	challenges map[string]*entity.MFAChallenge
+	attempts   map[string]int
+}
+
+func (r *fakeMFAChallengeRepository) StoreChallenge(_ context.Context, challenge *entity.MFAChallenge) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.challenges[challenge.ID] = challenge
+	return nil
+}
+
+func (r *fakeMFAChallengeRepository) GetChallenge(_ context.Context, id string) (*entity.MFAChallenge, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	challenge, ok := r.challenges[id]
+	if !ok {
+		return nil, repository.NewErrNotFound("MFAChallenge", id)
+	}
+	return challenge, nil
+}
+
+func (r *fakeMFAChallengeRepository) RemoveChallenge(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.challenges, id)
+	delete(r.attempts, id)
+	return nil
+}
+
+func (r *fakeMFAChallengeRepository) CountAttempt(_ context.Context, challenge *entity.MFAChallenge) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts[challenge.ID]++
+	return r.attempts[challenge.ID], nil
+}
+
+// fakeLoginAttemptRepository counts attempts in memory, the window is ignored
+type fakeLoginAttemptRepository struct {
+	mu         sync.Mutex
+	ipAttempts map[string]int64
+	failures   map[string]int64
+	lockouts   map[string]*entity.Lockout
+}
+
+func newFakeLoginAttemptRepository() *fakeLoginAttemptRepository {
+	return &fakeLoginAttemptRepository{
+		ipAttempts: map[string]int64{},
+		failures:   map[string]int64{},
+		lockouts:   map[string]*entity.Lockout{},
+	}
+}
+
+func (r *fakeLoginAttemptRepository) RecordIPAttempt(_ context.Context, ip string, _ int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ipAttempts[ip]++
+	return r.ipAttempts[ip], nil
+}
+
+func (r *fakeLoginAttemptRepository) RecordFailure(_ context.Context, email string, _ int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures[email]++
+	return r.failures[email], nil
+}
+
+func (r *fakeLoginAttemptRepository) CountFailures(_ context.Context, email string, _ int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.failures[email], nil
+}
+
+func (r *fakeLoginAttemptRepository) ResetFailures(_ context.Context, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.failures, email)
+	return nil
+}
+
+func (r *fakeLoginAttemptRepository) GetLockout(_ context.Context, email string) (*entity.Lockout, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lockout, ok := r.lockouts[email]
+	if !ok {
+		return nil, repository.NewErrNotFound("Lockout", email)
+	}
+	copied := *lockout
+	return &copied, nil
+}
+
+func (r *fakeLoginAttemptRepository) StoreLockout(_ context.Context, lockout *entity.Lockout, _ int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *lockout
+	r.lockouts[lockout.Email] = &copied
+	return nil
+}
+
+func (r *fakeLoginAttemptRepository) RemoveLockout(_ context.Context, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.lockouts, email)
+	return nil
+}
+
+// expireLockout moves the lock of the account into the past
+func (r *fakeLoginAttemptRepository) expireLockout(email string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lockouts[email].LockedUntil = time.Now().Add(-time.Second)
+}
+
+// fakeSecurityEventRepository collects the published events
+type fakeSecurityEventRepository struct {
+	mu     sync.Mutex
+	events []*entity.SecurityEvent
+}
+
+func (r *fakeSecurityEventRepository) PublishSecurityEvent(_ context.Context, evt *entity.SecurityEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, evt)
+	return nil
+}
+
+// fakeSessionRepository keeps sessions in memory, the ttl is ignored and
+// unused methods panic
+type fakeSessionRepository struct {
+	repository.SessionRepository
+	mu       sync.Mutex
+	sessions map[string]*entity.Session
+}
+
+func newFakeSessionRepository() *fakeSessionRepository {
+	return &fakeSessionRepository{sessions: map[string]*entity.Session{}}
+}
+
+func (r *fakeSessionRepository) CreateSession(_ context.Context, session *entity.Session, _ int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *session
+	r.sessions[session.ID] = &copied
+	return nil
+}
+
+func (r *fakeSessionRepository) GetSession(_ context.Context, id string) (*entity.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, repository.NewErrNotFound("Session", id)
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (r *fakeSessionRepository) RotateSession(_ context.Context, session *entity.Session, refreshTokenID string, _ int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current, ok := r.sessions[session.ID]
+	if !ok {
+		return false, repository.NewErrNotFound("Session", session.ID)
+	}
+	if current.RefreshTokenID != refreshTokenID {
+		return false, nil
+	}
+	copied := *session
+	r.sessions[session.ID] = &copied
+	return true, nil
+}
+
+func (r *fakeSessionRepository) ListSessions(_ context.Context, userID uint64) ([]*entity.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sessions := make([]*entity.Session, 0)
+	for _, session := range r.sessions {
+		if session.UserID == userID {
+			copied := *session
+			sessions = append(sessions, &copied)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *fakeSessionRepository) RemoveSession(_ context.Context, _ uint64, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.sessions, id)
+	return nil
+}
@@ -3,6 +3,7 @@ package application
 import (
 	"context"
 	"errors"
+	"slices"
 	"time"
 
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
@@ -11,37 +12,86 @@ import (
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/usecase"
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/utils"
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
 	"github.com/Chengxufeng1994/go-saga-example/common/constant"
 	"github.com/Chengxufeng1994/go-saga-example/common/model"
+	"github.com/Chengxufeng1994/go-saga-example/common/rbac"
 	"github.com/Chengxufeng1994/go-saga-example/common/token"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel/trace"
 )
 
+const (
+	defaultMFAChallengeExpires = 300
+	maxMFAAttempts             = 5
+)
+
 type AuthService struct {
-	logger              *log.Entry
-	userRepository      repository.UserRepository
-	tokenRepository     repository.TokenRepository
-	tokenEnhancer       token.Enhancer
-	accessTokenExpires  int
-	refreshTokenExpires int
+	logger                  *log.Entry
+	userRepository          repository.UserRepository
+	sessionRepository       repository.SessionRepository
+	roleRepository          repository.RoleRepository
+	accountService          usecase.AccountUseCase
+	mfaService              usecase.MFAUseCase
+	oidcService             usecase.OIDCUseCase
+	mfaChallengeRepository  repository.MFAChallengeRepository
+	loginAttemptRepository  repository.LoginAttemptRepository
+	securityEventRepository repository.SecurityEventRepository
+	tokenEnhancer           token.Enhancer
+	accessTokenExpires      int
+	refreshTokenExpires     int
+	requireEmailVerified    bool
+	mfaChallengeExpires     int
+	loginCfg                libconfig.Login
+	// dummyPasswordHash is compared against for unknown emails so they take
+	// as long to reject as a wrong password
+	dummyPasswordHash string
 }
 
 func NewAuthService(
 	userRepository repository.UserRepository,
-	tokenRepository repository.TokenRepository,
+	sessionRepository repository.SessionRepository,
+	roleRepository repository.RoleRepository,
+	accountService usecase.AccountUseCase,
+	mfaService usecase.MFAUseCase,
+	oidcService usecase.OIDCUseCase,
+	mfaChallengeRepository repository.MFAChallengeRepository,
+	loginAttemptRepository repository.LoginAttemptRepository,
+	securityEventRepository repository.SecurityEventRepository,
 	tokenEnhancer token.Enhancer,
 	accessTokenExpires, refreshTokenExpires int,
+	requireEmailVerified bool,
+	mfaChallengeExpires int,
+	loginCfg libconfig.Login,
 ) usecase.AuthUseCase {
-	return &AuthService{
-		logger:              config.ContextLogger.WithFields(log.Fields{"type": "service:AuthService"}),
-		userRepository:      userRepository,
-		tokenRepository:     tokenRepository,
-		tokenEnhancer:       tokenEnhancer,
-		accessTokenExpires:  accessTokenExpires,
-		refreshTokenExpires: refreshTokenExpires,
+	svc := &AuthService{
+		logger:                  config.ContextLogger.WithFields(log.Fields{"type": "service:AuthService"}),
+		userRepository:          userRepository,
+		sessionRepository:       sessionRepository,
+		roleRepository:          roleRepository,
+		accountService:          accountService,
+		mfaService:              mfaService,
+		oidcService:             oidcService,
+		mfaChallengeRepository:  mfaChallengeRepository,
+		loginAttemptRepository:  loginAttemptRepository,
+		securityEventRepository: securityEventRepository,
+		tokenEnhancer:           tokenEnhancer,
+		accessTokenExpires:      accessTokenExpires,
+		refreshTokenExpires:     refreshTokenExpires,
+		requireEmailVerified:    requireEmailVerified,
+		mfaChallengeExpires:     mfaChallengeExpires,
+		loginCfg:                withLoginDefaults(loginCfg),
+	}
+	if svc.mfaChallengeExpires == 0 {
+		svc.mfaChallengeExpires = defaultMFAChallengeExpires
 	}
+	if hash, err := utils.HashedPassword(uuid.NewString()); err == nil {
+		svc.dummyPasswordHash = hash
+	}
+
+	return svc
 }
 
 // SignUp implements usecase.AuthUseCase.
@@ -65,6 +115,16 @@ func (svc *AuthService) SignUp(ctx context.Context, req *dto.UserCreationRequest
 		return nil, usecase.NewAppError("CreateUser", "app.user.create.error", nil, "").Wrap(err)
 	}
 
+	// admins listed in rbac.admin_emails are granted the role on email verification
+	if err := svc.roleRepository.SetUserRoles(ctx, user.ID, []string{rbac.RoleCustomer}); err != nil {
+		return nil, usecase.NewAppError("SetUserRoles", "app.user.set_roles.error", nil, "").Wrap(err)
+	}
+
+	// a failed delivery can be retried by requesting the verification again
+	if _, err := svc.accountService.SendEmailVerification(ctx, &dto.EmailRequest{Email: user.Email}); err != nil {
+		svc.logger.WithError(err).Error("send email verification")
+	}
+
 	return &dto.User{
 		ID:          user.ID,
 		CreatedAt:   user.CreatedAt,
@@ -78,6 +138,26 @@ func (svc *AuthService) SignUp(ctx context.Context, req *dto.UserCreationRequest
 	}, nil
 }
 
+// grantClaims embeds the current roles and permissions of the user
+func (svc *AuthService) grantClaims(ctx context.Context, claims *token.Claims) error {
+	roles, err := svc.roleRepository.GetUserRoles(ctx, claims.UserID)
+	if err != nil {
+		return err
+	}
+
+	claims.Roles = make([]string, 0, len(roles))
+	claims.Permissions = make([]string, 0)
+	for _, role := range roles {
+		claims.Roles = append(claims.Roles, role.Name)
+		for _, permission := range role.Permissions {
+			if !slices.Contains(claims.Permissions, permission) {
+				claims.Permissions = append(claims.Permissions, permission)
+			}
+		}
+	}
+	return nil
+}
+
 func (svc *AuthService) createAccessToken(claims *token.Claims) (string, error) {
 	claims.IsRefresh = false
 	return svc.tokenEnhancer.Sign(claims)
@@ -103,6 +183,8 @@ func (svc *AuthService) createTokenPair(claims *token.Claims) (string, string, e
 	}
 
 	claims.RegisteredClaims.ExpiresAt = jwt.NewNumericDate(refreshExpiresAt)
+	// every refresh token gets its own id so rotated out tokens can be detected
+	claims.RegisteredClaims.ID = uuid.NewString()
 	refreshToken, err := svc.createRefreshToken(claims)
 	if err != nil {
 		return "", "", err
@@ -113,32 +195,167 @@ func (svc *AuthService) createTokenPair(claims *token.Claims) (string, string, e
 
 // SignIn implements usecase.AuthUseCase.
 func (svc *AuthService) SignIn(ctx context.Context, req *dto.LoginRequest) (*dto.LoginResponse, error) {
-	existed, err := svc.userRepository.GetUserByEmail(ctx, req.Email)
+	email := normalizeEmail(req.Email)
+	if err := svc.checkLoginAllowed(ctx, email, req.IPAddress); err != nil {
+		return nil, usecase.NewAppError("SignIn", "app.auth.check_login.error", nil, "").Wrap(err)
+	}
+
+	// the account is looked up by the key its attempts are counted by
+	existed, err := svc.userRepository.GetUserByEmail(ctx, email)
 	if err != nil {
-		return nil, usecase.NewAppError("SignIn", "app.user.get_user_by_email.error", nil, "").Wrap(err)
+		var nfErr *repository.ErrNotFound
+		if !errors.As(err, &nfErr) {
+			return nil, usecase.NewAppError("SignIn", "app.user.get_user_by_email.error", nil, "").Wrap(err)
+		}
+		_ = utils.ComparePassword([]byte(req.Password), []byte(svc.dummyPasswordHash))
+		svc.recordLoginFailure(ctx, email, 0, req)
+		return nil, usecase.NewAppError("SignIn", "app.auth.invalid_credentials.error", nil, "").Wrap(usecase.ErrInvalidCredentials)
 	}
 
 	if err := utils.ComparePassword([]byte(req.Password), []byte(existed.Password)); err != nil {
-		return nil, usecase.NewAppError("SignIn", "app.auth.invalid_password.error", nil, "").Wrap(err)
+		svc.recordLoginFailure(ctx, email, existed.ID, req)
+		return nil, usecase.NewAppError("SignIn", "app.auth.invalid_credentials.error", nil, "").Wrap(usecase.ErrInvalidCredentials)
+	}
+	if err := svc.loginAttemptRepository.ResetFailures(ctx, email); err != nil {
+		svc.logger.WithError(err).Error("reset login failures")
 	}
 
-	claims := &token.Claims{
-		UserID: existed.ID,
+	return svc.completeSignIn(ctx, "SignIn", existed, req.UserAgent, req.IPAddress)
+}
+
+// SignInWithOIDC implements usecase.AuthUseCase. Once the provider vouched
+// for the user the sign in continues exactly like one with a password.
+func (svc *AuthService) SignInWithOIDC(ctx context.Context, req *dto.OIDCCallbackRequest) (*dto.LoginResponse, error) {
+	userID, err := svc.oidcService.ResolveUser(ctx, req)
+	if err != nil {
+		return nil, usecase.NewAppError("SignInWithOIDC", "app.oidc.resolve_user.error", nil, "").Wrap(err)
 	}
 
-	accessToken, refreshToken, err := svc.createTokenPair(claims)
+	existed, err := svc.userRepository.GetUserByID(ctx, userID)
 	if err != nil {
-		return nil, usecase.NewAppError("SignIn", "app.auth.gen_token.error", nil, "").Wrap(err)
+		return nil, usecase.NewAppError("SignInWithOIDC", "app.user.get_by_id.error", nil, "").Wrap(err)
+	}
+	// a locked account stays locked whichever way the user signs in
+	if err := svc.checkLoginAllowed(ctx, normalizeEmail(existed.Email), req.IPAddress); err != nil {
+		return nil, usecase.NewAppError("SignInWithOIDC", "app.auth.check_login.error", nil, "").Wrap(err)
+	}
+
+	return svc.completeSignIn(ctx, "SignInWithOIDC", existed, req.UserAgent, req.IPAddress)
+}
+
+// completeSignIn signs in the authenticated user, or challenges for the
+// second factor when mfa is enabled
+func (svc *AuthService) completeSignIn(ctx context.Context, where string, existed *entity.User, userAgent, ipAddress string) (*dto.LoginResponse, error) {
+	if svc.requireEmailVerified && !existed.EmailVerified {
+		return nil, usecase.NewAppError(where, "app.auth.email_not_verified.error", nil, "").Wrap(usecase.ErrEmailNotVerified)
 	}
 
-	err = svc.tokenRepository.StoreAccessToken(ctx, claims, accessToken, svc.accessTokenExpires)
+	enabled, err := svc.mfaService.IsEnabled(ctx, existed.ID)
 	if err != nil {
-		return nil, usecase.NewAppError("SignIn", "app.auth.store_token.error", nil, "").Wrap(err)
+		return nil, usecase.NewAppError(where, "app.mfa.get_user_mfa.error", nil, "").Wrap(err)
+	}
+	if enabled {
+		challenge := &entity.MFAChallenge{
+			ID:        uuid.NewString(),
+			UserID:    existed.ID,
+			UserAgent: userAgent,
+			IPAddress: ipAddress,
+			ExpiresAt: time.Now().Add(time.Duration(svc.mfaChallengeExpires) * time.Second),
+		}
+		if err := svc.mfaChallengeRepository.StoreChallenge(ctx, challenge); err != nil {
+			return nil, usecase.NewAppError(where, "app.mfa.store_challenge.error", nil, "").Wrap(err)
+		}
+		return &dto.LoginResponse{
+			MFARequired:    true,
+			ChallengeToken: challenge.ID,
+		}, nil
+	}
+
+	if err := svc.reactivate(ctx, existed); err != nil {
+		return nil, usecase.NewAppError(where, "app.user.set_active.error", nil, "").Wrap(err)
+	}
+	return svc.issueSession(ctx, where, existed.ID, userAgent, ipAddress)
+}
+
+// VerifyMFA implements usecase.AuthUseCase.
+func (svc *AuthService) VerifyMFA(ctx context.Context, req *dto.MFAVerifyRequest) (*dto.LoginResponse, error) {
+	challenge, err := svc.mfaChallengeRepository.GetChallenge(ctx, req.ChallengeToken)
+	if err != nil {
+		var nfErr *repository.ErrNotFound
+		if errors.As(err, &nfErr) {
+			return nil, usecase.NewAppError("VerifyMFA", "app.mfa.get_challenge.error", nil, "").Wrap(usecase.ErrInvalidMFAChallenge)
+		}
+		return nil, usecase.NewAppError("VerifyMFA", "app.mfa.get_challenge.error", nil, "").Wrap(err)
+	}
+
+	// every attempt is counted before the code is checked so concurrent guesses
+	// can not exceed the limit, sign in has to start over once it is reached
+	attempts, err := svc.mfaChallengeRepository.CountAttempt(ctx, challenge)
+	if err != nil {
+		return nil, usecase.NewAppError("VerifyMFA", "app.mfa.count_attempt.error", nil, "").Wrap(err)
+	}
+	if attempts > maxMFAAttempts {
+		if err := svc.mfaChallengeRepository.RemoveChallenge(ctx, challenge.ID); err != nil {
+			svc.logger.WithError(err).Error("remove mfa challenge")
+		}
+		return nil, usecase.NewAppError("VerifyMFA", "app.mfa.get_challenge.error", nil, "").Wrap(usecase.ErrInvalidMFAChallenge)
+	}
+
+	if err := svc.mfaService.VerifyCode(ctx, challenge.UserID, req.Code); err != nil {
+		if errors.Is(err, usecase.ErrInvalidMFACode) {
+			return nil, usecase.NewAppError("VerifyMFA", "app.mfa.invalid_code.error", nil, "").Wrap(usecase.ErrInvalidMFACode)
+		}
+		return nil, usecase.NewAppError("VerifyMFA", "app.mfa.verify_code.error", nil, "").Wrap(err)
+	}
+
+	if err := svc.mfaChallengeRepository.RemoveChallenge(ctx, challenge.ID); err != nil {
+		return nil, usecase.NewAppError("VerifyMFA", "app.mfa.remove_challenge.error", nil, "").Wrap(err)
 	}
 
-	err = svc.tokenRepository.StoreRefreshToken(ctx, claims, refreshToken, svc.refreshTokenExpires)
+	existed, err := svc.userRepository.GetUserByID(ctx, challenge.UserID)
 	if err != nil {
-		return nil, usecase.NewAppError("SignIn", "app.auth.store_token.error", nil, "").Wrap(err)
+		return nil, usecase.NewAppError("VerifyMFA", "app.user.get_by_id.error", nil, "").Wrap(err)
+	}
+	if err := svc.reactivate(ctx, existed); err != nil {
+		return nil, usecase.NewAppError("VerifyMFA", "app.user.set_active.error", nil, "").Wrap(err)
+	}
+	return svc.issueSession(ctx, "VerifyMFA", challenge.UserID, challenge.UserAgent, challenge.IPAddress)
+}
+
+// reactivate activates a deactivated account again, it is only called right
+// before a session is issued so an abandoned sign in leaves the account as is
+func (svc *AuthService) reactivate(ctx context.Context, user *entity.User) error {
+	if user.Active {
+		return nil
+	}
+	return svc.userRepository.SetActive(ctx, user.ID, true)
+}
+
+// issueSession starts a new session for the user and returns its token pair
+func (svc *AuthService) issueSession(ctx context.Context, where string, userID uint64, userAgent, ipAddress string) (*dto.LoginResponse, error) {
+	claims := &token.Claims{
+		UserID:    userID,
+		SessionID: uuid.NewString(),
+	}
+	if err := svc.grantClaims(ctx, claims); err != nil {
+		return nil, usecase.NewAppError(where, "app.auth.get_roles.error", nil, "").Wrap(err)
+	}
+
+	accessToken, refreshToken, err := svc.createTokenPair(claims)
+	if err != nil {
+		return nil, usecase.NewAppError(where, "app.auth.gen_token.error", nil, "").Wrap(err)
+	}
+
+	err = svc.storeSession(ctx, &entity.Session{
+		ID:             claims.SessionID,
+		UserID:         claims.UserID,
+		RefreshTokenID: claims.ID,
+		UserAgent:      userAgent,
+		IPAddress:      ipAddress,
+		CreatedAt:      claims.IssuedAt.Time,
+	})
+	if err != nil {
+		return nil, usecase.NewAppError(where, "app.auth.store_session.error", nil, "").Wrap(err)
 	}
 
 	return &dto.LoginResponse{
@@ -147,12 +364,105 @@ func (svc *AuthService) SignIn(ctx context.Context, req *dto.LoginRequest) (*dto
 	}, nil
 }
 
+// storeSession persists the session until the refresh token expires
+func (svc *AuthService) storeSession(ctx context.Context, session *entity.Session) error {
+	session.ExpiresAt = time.Now().Add(time.Duration(svc.refreshTokenExpires) * time.Second)
+	return svc.sessionRepository.CreateSession(ctx, session, svc.refreshTokenExpires)
+}
+
+// checkSession makes sure the session of the token has not been revoked
+func (svc *AuthService) checkSession(ctx context.Context, claims *token.Claims) (*entity.Session, error) {
+	if claims.SessionID == "" {
+		return nil, usecase.ErrSessionRevoked
+	}
+
+	session, err := svc.sessionRepository.GetSession(ctx, claims.SessionID)
+	if err != nil {
+		var nfErr *repository.ErrNotFound
+		if errors.As(err, &nfErr) {
+			return nil, usecase.ErrSessionRevoked
+		}
+		return nil, err
+	}
+	if session.UserID != claims.UserID {
+		return nil, usecase.ErrSessionRevoked
+	}
+
+	return session, nil
+}
+
+// revokeFamily revokes the session of a reused refresh token along with every token issued for it
+func (svc *AuthService) revokeFamily(ctx context.Context, session *entity.Session) {
+	if err := svc.sessionRepository.RemoveSession(ctx, session.UserID, session.ID); err != nil {
+		svc.logger.WithError(err).Error("revoke session")
+	}
+
+	err := svc.securityEventRepository.PublishSecurityEvent(ctx, &entity.SecurityEvent{
+		Type:       entity.SecurityEventRefreshTokenReuse,
+		UserID:     session.UserID,
+		SessionID:  session.ID,
+		IPAddress:  session.IPAddress,
+		UserAgent:  session.UserAgent,
+		OccurredAt: time.Now(),
+	})
+	if err != nil {
+		svc.logger.WithError(err).Error("publish security event")
+	}
+}
+
 // SignOut implements usecase.AuthUseCase.
 func (svc *AuthService) SignOut(ctx context.Context) (string, error) {
-	val := ctx.Value(constant.CtxUserKey)
-	userId := val.(uint64)
-	_ = svc.tokenRepository.RemoveAccessToken(ctx, userId)
-	_ = svc.tokenRepository.RemoveRefreshToken(ctx, userId)
+	userId := ctx.Value(constant.CtxUserKey).(uint64)
+	sessionId, _ := ctx.Value(constant.CtxSessionKey).(string)
+	if err := svc.sessionRepository.RemoveSession(ctx, userId, sessionId); err != nil {
+		return "", model.NewAppError("SignOut", "app.auth.remove_session.error", nil, "").Wrap(err)
+	}
+	return "Ok", nil
+}
+
+// ListSessions implements usecase.AuthUseCase.
+func (svc *AuthService) ListSessions(ctx context.Context) ([]*dto.Session, error) {
+	userId := ctx.Value(constant.CtxUserKey).(uint64)
+	sessionId, _ := ctx.Value(constant.CtxSessionKey).(string)
+	sessions, err := svc.sessionRepository.ListSessions(ctx, userId)
+	if err != nil {
+		return nil, model.NewAppError("ListSessions", "app.auth.list_sessions.error", nil, "").Wrap(err)
+	}
+
+	res := make([]*dto.Session, 0, len(sessions))
+	for _, session := range sessions {
+		res = append(res, &dto.Session{
+			ID:        session.ID,
+			UserAgent: session.UserAgent,
+			IPAddress: session.IPAddress,
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+			Current:   session.ID == sessionId,
+		})
+	}
+
+	return res, nil
+}
+
+// RevokeSession implements usecase.AuthUseCase.
+func (svc *AuthService) RevokeSession(ctx context.Context, sessionID string) (string, error) {
+	userId := ctx.Value(constant.CtxUserKey).(uint64)
+	session, err := svc.sessionRepository.GetSession(ctx, sessionID)
+	if err != nil {
+		var nfErr *repository.ErrNotFound
+		if errors.As(err, &nfErr) {
+			return "", model.NewAppError("RevokeSession", "app.auth.get_session.error", nil, "").Wrap(usecase.ErrSessionNotFound)
+		}
+		return "", model.NewAppError("RevokeSession", "app.auth.get_session.error", nil, "").Wrap(err)
+	}
+	// never reveal sessions of other users
+	if session.UserID != userId {
+		return "", model.NewAppError("RevokeSession", "app.auth.get_session.error", nil, "").Wrap(usecase.ErrSessionNotFound)
+	}
+
+	if err := svc.sessionRepository.RemoveSession(ctx, userId, sessionID); err != nil {
+		return "", model.NewAppError("RevokeSession", "app.auth.remove_session.error", nil, "").Wrap(err)
+	}
 	return "Ok", nil
 }
 
@@ -171,8 +481,20 @@ func (svc *AuthService) VerifyToken(ctx context.Context, req *dto.VerifyTokenReq
 			return nil, model.NewAppError("VerifyToken", "app.auth.verify_token.error", nil, "").Wrap(err)
 		}
 	}
+	if claims.IsRefresh {
+		return nil, model.NewAppError("VerifyToken", "app.auth.invalid_token_type.error", nil, "").Wrap(usecase.ErrInvalidTokenType)
+	}
+
+	if _, err := svc.checkSession(ctx, claims); err != nil {
+		return nil, model.NewAppError("VerifyToken", "app.auth.revoked_token.error", nil, "").Wrap(err)
+	}
 
-	return &dto.VerifyTokenResponse{UserId: claims.UserID}, nil
+	return &dto.VerifyTokenResponse{
+		UserId:      claims.UserID,
+		SessionId:   claims.SessionID,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+	}, nil
 }
 
 // RefreshToken implements usecase.AuthUseCase.
@@ -181,6 +503,17 @@ func (svc *AuthService) RefreshToken(ctx context.Context, req *dto.RefreshTokenR
 	if err != nil {
 		return nil, model.NewAppError("RefreshToken", "app.auth.verify_token.error", nil, "").Wrap(err)
 	}
+	if !claims.IsRefresh {
+		return nil, model.NewAppError("RefreshToken", "app.auth.invalid_token_type.error", nil, "").Wrap(usecase.ErrInvalidTokenType)
+	}
+	session, err := svc.checkSession(ctx, claims)
+	if err != nil {
+		return nil, model.NewAppError("RefreshToken", "app.auth.revoked_token.error", nil, "").Wrap(err)
+	}
+	if claims.ID != session.RefreshTokenID {
+		svc.revokeFamily(ctx, session)
+		return nil, model.NewAppError("RefreshToken", "app.auth.reused_token.error", nil, "").Wrap(usecase.ErrRefreshTokenReused)
+	}
 	existed, err := svc.userRepository.GetUserByID(ctx, claims.UserID)
 	if err != nil {
 		var nfErr *repository.ErrNotFound
@@ -194,13 +527,47 @@ func (svc *AuthService) RefreshToken(ctx context.Context, req *dto.RefreshTokenR
 		return nil, model.NewAppError("RefreshToken", "app.user.get_by_id.error", nil, "").Wrap(err)
 	}
 
+	// pick up role changes made since the last refresh
+	if err := svc.grantClaims(ctx, claims); err != nil {
+		return nil, model.NewAppError("RefreshToken", "app.auth.get_roles.error", nil, "").Wrap(err)
+	}
+
 	accessToken, refreshToken, err := svc.createTokenPair(claims)
 	if err != nil {
 		return nil, model.NewAppError("RefreshToken", "app.auth.gen_token.error", nil, "").Wrap(err)
 	}
 
+	// rotate the family to the new refresh token, this also extends the lifetime of the session.
+	// the swap only succeeds while the session still holds the presented token, a concurrent
+	// request with the same token loses the race and is treated as a reuse
+	presentedID := session.RefreshTokenID
+	session.RefreshTokenID = claims.ID
+	session.ExpiresAt = time.Now().Add(time.Duration(svc.refreshTokenExpires) * time.Second)
+	rotated, err := svc.sessionRepository.RotateSession(ctx, session, presentedID, svc.refreshTokenExpires)
+	if err != nil {
+		var nfErr *repository.ErrNotFound
+		if errors.As(err, &nfErr) {
+			return nil, model.NewAppError("RefreshToken", "app.auth.revoked_token.error", nil, "").Wrap(usecase.ErrSessionRevoked)
+		}
+		return nil, model.NewAppError("RefreshToken", "app.auth.store_session.error", nil, "").Wrap(err)
+	}
+	if !rotated {
+		svc.revokeFamily(ctx, session)
+		return nil, model.NewAppError("RefreshToken", "app.auth.reused_token.error", nil, "").Wrap(usecase.ErrRefreshTokenReused)
+	}
+
 	return &dto.RefreshTokenResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 	}, nil
 }
+
+// JWKS implements usecase.AuthUseCase.
+func (svc *AuthService) JWKS(ctx context.Context) (*token.JWKS, error) {
+	provider, ok := svc.tokenEnhancer.(token.JWKSProvider)
+	if !ok {
+		// symmetric keys are never published
+		return &token.JWKS{Keys: []token.JWK{}}, nil
+	}
+	return provider.JWKS(), nil
+}
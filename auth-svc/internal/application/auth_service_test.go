@@ -0,0 +1,392 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/usecase"
+	"github.com/Chengxufeng1994/go-saga-example/common/constant"
+	"github.com/Chengxufeng1994/go-saga-example/common/token"
+)
+
+// rejectingMFAService counts the codes it checks and rejects all of them
+type rejectingMFAService struct {
+	usecase.MFAUseCase
+	checked atomic.Int32
+}
+
+func (svc *rejectingMFAService) VerifyCode(context.Context, uint64, string) error {
+	svc.checked.Add(1)
+	return usecase.ErrInvalidMFACode
+}
+
+// fakeMFAService reports whether mfa is enabled for every user and accepts
+// only its code
+type fakeMFAService struct {
+	usecase.MFAUseCase
+	enabled bool
+	code    string
+}
+
+func (svc *fakeMFAService) IsEnabled(context.Context, uint64) (bool, error) {
+	return svc.enabled, nil
+}
+
+func (svc *fakeMFAService) VerifyCode(_ context.Context, _ uint64, code string) error {
+	if code != svc.code {
+		return usecase.ErrInvalidMFACode
+	}
+	return nil
+}
+
+func newMFATestService() (*AuthService, *rejectingMFAService, *fakeMFAChallengeRepository) {
+	mfaService := &rejectingMFAService{}
+	challenges := &fakeMFAChallengeRepository{
+		challenges: map[string]*entity.MFAChallenge{
+			"challenge": {ID: "challenge", UserID: 1, ExpiresAt: time.Now().Add(time.Minute)},
+		},
+		attempts: map[string]int{},
+	}
+	svc := &AuthService{
+		logger:                 config.ContextLogger,
+		mfaService:             mfaService,
+		mfaChallengeRepository: challenges,
+	}
+	return svc, mfaService, challenges
+}
+
+func TestVerifyMFAAttempts(t *testing.T) {
+	ctx := context.Background()
+	svc, mfaService, challenges := newMFATestService()
+	req := &dto.MFAVerifyRequest{ChallengeToken: "challenge", Code: "000000"}
+
+	for i := 0; i < maxMFAAttempts; i++ {
+		if _, err := svc.VerifyMFA(ctx, req); !errors.Is(err, usecase.ErrInvalidMFACode) {
+			t.Fatalf("attempt %d error = %v, want %v", i+1, err, usecase.ErrInvalidMFACode)
+		}
+	}
+	if _, err := svc.VerifyMFA(ctx, req); !errors.Is(err, usecase.ErrInvalidMFAChallenge) {
+		t.Fatalf("attempt over the limit error = %v, want %v", err, usecase.ErrInvalidMFAChallenge)
+	}
+	if got := mfaService.checked.Load(); got != maxMFAAttempts {
+		t.Errorf("codes checked = %d, want %d", got, maxMFAAttempts)
+	}
+	if _, err := challenges.GetChallenge(ctx, "challenge"); err == nil {
+		t.Error("challenge kept after too many attempts")
+	}
+}
+
+func TestVerifyMFAConcurrentAttempts(t *testing.T) {
+	ctx := context.Background()
+	svc, mfaService, _ := newMFATestService()
+	req := &dto.MFAVerifyRequest{ChallengeToken: "challenge", Code: "000000"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4*maxMFAAttempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = svc.VerifyMFA(ctx, req)
+		}()
+	}
+	wg.Wait()
+
+	if got := mfaService.checked.Load(); got > maxMFAAttempts {
+		t.Errorf("codes checked = %d, want at most %d", got, maxMFAAttempts)
+	}
+}
+
+func TestSignInReactivation(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name          string
+		emailVerified bool
+		mfaEnabled    bool
+		wantErr       error
+		wantActive    bool
+	}{
+		{name: "signed in", emailVerified: true, wantActive: true},
+		{name: "email not verified", wantErr: usecase.ErrEmailNotVerified},
+		{name: "mfa challenged", emailVerified: true, mfaEnabled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newSessionTestService()
+			users := &fakeUserRepository{users: map[uint64]*entity.User{1: {ID: 1, EmailVerified: tt.emailVerified}}}
+			svc.userRepository = users
+			svc.requireEmailVerified = true
+			svc.mfaService = &fakeMFAService{enabled: tt.mfaEnabled}
+			svc.mfaChallengeRepository = &fakeMFAChallengeRepository{challenges: map[string]*entity.MFAChallenge{}, attempts: map[string]int{}}
+
+			_, err := svc.completeSignIn(ctx, "SignIn", users.users[1], "agent", "127.0.0.1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("completeSignIn() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := users.users[1].Active; got != tt.wantActive {
+				t.Errorf("active = %t, want %t", got, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestVerifyMFAReactivation(t *testing.T) {
+	ctx := context.Background()
+	svc, _ := newSessionTestService()
+	users := &fakeUserRepository{users: map[uint64]*entity.User{1: {ID: 1, EmailVerified: true}}}
+	svc.userRepository = users
+	svc.mfaService = &fakeMFAService{enabled: true, code: "123456"}
+	svc.mfaChallengeRepository = &fakeMFAChallengeRepository{challenges: map[string]*entity.MFAChallenge{}, attempts: map[string]int{}}
+
+	res, err := svc.completeSignIn(ctx, "SignIn", users.users[1], "agent", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a wrong code does not complete the sign in
+	if _, err := svc.VerifyMFA(ctx, &dto.MFAVerifyRequest{ChallengeToken: res.ChallengeToken, Code: "000000"}); !errors.Is(err, usecase.ErrInvalidMFACode) {
+		t.Fatalf("VerifyMFA() with a wrong code error = %v, want %v", err, usecase.ErrInvalidMFACode)
+	}
+	if users.users[1].Active {
+		t.Fatal("account reactivated by a wrong code")
+	}
+
+	if _, err := svc.VerifyMFA(ctx, &dto.MFAVerifyRequest{ChallengeToken: res.ChallengeToken, Code: "123456"}); err != nil {
+		t.Fatal(err)
+	}
+	if !users.users[1].Active {
+		t.Error("account not reactivated once the session was issued")
+	}
+}
+
+func newSessionTestService() (*AuthService, *fakeSessionRepository) {
+	sessions := newFakeSessionRepository()
+	svc := &AuthService{
+		logger:                  config.ContextLogger,
+		userRepository:          &fakeUserRepository{users: map[uint64]*entity.User{1: {ID: 1}, 2: {ID: 2}}},
+		sessionRepository:       sessions,
+		roleRepository:          &fakeRoleRepository{roles: map[uint64][]string{}},
+		securityEventRepository: &fakeSecurityEventRepository{},
+		tokenEnhancer:           token.NewJWTEnhancer([]byte("secret")),
+		accessTokenExpires:      60,
+		refreshTokenExpires:     600,
+	}
+	return svc, sessions
+}
+
+// signIn starts a session of the user and returns its tokens and id
+func signIn(t *testing.T, svc *AuthService, userID uint64) (*dto.LoginResponse, string) {
+	t.Helper()
+	res, err := svc.issueSession(context.Background(), "SignIn", userID, "agent", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := svc.tokenEnhancer.Verify(res.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, claims.SessionID
+}
+
+func userContext(userID uint64, sessionID string) context.Context {
+	ctx := context.WithValue(context.Background(), constant.CtxUserKey, userID)
+	return context.WithValue(ctx, constant.CtxSessionKey, sessionID)
+}
+
+func TestVerifyTokenSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   func(svc *AuthService, res *dto.LoginResponse, sessionID string) string
+		wantErr error
+	}{
+		{"live session", func(_ *AuthService, res *dto.LoginResponse, _ string) string {
+			return res.AccessToken
+		}, nil},
+		{"refresh token", func(_ *AuthService, res *dto.LoginResponse, _ string) string {
+			return res.RefreshToken
+		}, usecase.ErrInvalidTokenType},
+		{"signed out", func(svc *AuthService, res *dto.LoginResponse, sessionID string) string {
+			_ = svc.sessionRepository.RemoveSession(context.Background(), 1, sessionID)
+			return res.AccessToken
+		}, usecase.ErrSessionRevoked},
+		{"session of another user", func(svc *AuthService, _ *dto.LoginResponse, sessionID string) string {
+			signed, _ := svc.tokenEnhancer.Sign(&token.Claims{UserID: 2, SessionID: sessionID})
+			return signed
+		}, usecase.ErrSessionRevoked},
+		{"no session", func(svc *AuthService, _ *dto.LoginResponse, _ string) string {
+			signed, _ := svc.tokenEnhancer.Sign(&token.Claims{UserID: 1})
+			return signed
+		}, usecase.ErrSessionRevoked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newSessionTestService()
+			res, sessionID := signIn(t, svc, 1)
+
+			verified, err := svc.VerifyToken(context.Background(), &dto.VerifyTokenRequest{AccessToken: tt.token(svc, res, sessionID)})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (verified.UserId != 1 || verified.SessionId != sessionID) {
+				t.Errorf("VerifyToken() = user %d session %s, want user 1 session %s", verified.UserId, verified.SessionId, sessionID)
+			}
+		})
+	}
+}
+
+func TestSignOut(t *testing.T) {
+	svc, _ := newSessionTestService()
+	res, sessionID := signIn(t, svc, 1)
+	other, _ := signIn(t, svc, 1)
+
+	if _, err := svc.SignOut(userContext(1, sessionID)); err != nil {
+		t.Fatalf("SignOut() error = %v", err)
+	}
+	if _, err := svc.VerifyToken(context.Background(), &dto.VerifyTokenRequest{AccessToken: res.AccessToken}); !errors.Is(err, usecase.ErrSessionRevoked) {
+		t.Errorf("VerifyToken() after sign out error = %v, want %v", err, usecase.ErrSessionRevoked)
+	}
+	if _, err := svc.RefreshToken(context.Background(), &dto.RefreshTokenRequest{RefreshToken: res.RefreshToken}); !errors.Is(err, usecase.ErrSessionRevoked) {
+		t.Errorf("RefreshToken() after sign out error = %v, want %v", err, usecase.ErrSessionRevoked)
+	}
+	if _, err := svc.VerifyToken(context.Background(), &dto.VerifyTokenRequest{AccessToken: other.AccessToken}); err != nil {
+		t.Errorf("VerifyToken() of another session error = %v", err)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	tests := []struct {
+		name        string
+		userID      uint64
+		sessionID   func(own string) string
+		wantErr     error
+		wantRemoved bool
+	}{
+		{"own session", 1, func(own string) string { return own }, nil, true},
+		{"session of another user", 2, func(own string) string { return own }, usecase.ErrSessionNotFound, false},
+		{"unknown session", 1, func(string) string { return "unknown" }, usecase.ErrSessionNotFound, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, sessions := newSessionTestService()
+			_, sessionID := signIn(t, svc, 1)
+
+			_, err := svc.RevokeSession(userContext(tt.userID, "current"), tt.sessionID(sessionID))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RevokeSession() error = %v, want %v", err, tt.wantErr)
+			}
+			if _, err := sessions.GetSession(context.Background(), sessionID); (err != nil) != tt.wantRemoved {
+				t.Errorf("session removed = %v, want %v", err != nil, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	svc, _ := newSessionTestService()
+	_, current := signIn(t, svc, 1)
+	_, other := signIn(t, svc, 1)
+	signIn(t, svc, 2)
+
+	sessions, err := svc.ListSessions(userContext(1, current))
+	if err != nil {
+		t.Fatalf("ListSessions() error = %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("ListSessions() = %d sessions, want the 2 of the user", len(sessions))
+	}
+	for _, session := range sessions {
+		if session.ID != current && session.ID != other {
+			t.Errorf("ListSessions() lists session %s of another user", session.ID)
+		}
+		if session.Current != (session.ID == current) {
+			t.Errorf("session %s current = %v", session.ID, session.Current)
+		}
+	}
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	ctx := context.Background()
+	svc, sessions := newSessionTestService()
+	events := svc.securityEventRepository.(*fakeSecurityEventRepository)
+	res, sessionID := signIn(t, svc, 1)
+
+	rotated, err := svc.RefreshToken(ctx, &dto.RefreshTokenRequest{RefreshToken: res.RefreshToken})
+	if err != nil {
+		t.Fatalf("RefreshToken() error = %v", err)
+	}
+	if _, err := svc.RefreshToken(ctx, &dto.RefreshTokenRequest{RefreshToken: res.RefreshToken}); !errors.Is(err, usecase.ErrRefreshTokenReused) {
+		t.Fatalf("RefreshToken() of a rotated token error = %v, want %v", err, usecase.ErrRefreshTokenReused)
+	}
+
+	// the whole family is revoked, the tokens rotated in as well
+	if _, err := sessions.GetSession(ctx, sessionID); err == nil {
+		t.Error("session kept after a reused refresh token")
+	}
+	if _, err := svc.RefreshToken(ctx, &dto.RefreshTokenRequest{RefreshToken: rotated.RefreshToken}); !errors.Is(err, usecase.ErrSessionRevoked) {
+		t.Errorf("RefreshToken() of the latest token error = %v, want %v", err, usecase.ErrSessionRevoked)
+	}
+	if _, err := svc.VerifyToken(ctx, &dto.VerifyTokenRequest{AccessToken: rotated.AccessToken}); !errors.Is(err, usecase.ErrSessionRevoked) {
+		t.Errorf("VerifyToken() of the latest token error = %v, want %v", err, usecase.ErrSessionRevoked)
+	}
+	if len(events.events) != 1 || events.events[0].Type != entity.SecurityEventRefreshTokenReuse || events.events[0].SessionID != sessionID {
+		t.Errorf("security events = %+v, want one refresh token reuse of session %s", events.events, sessionID)
+	}
+}
+
+// barrierSessionRepository holds every read of a session until all the
+// racing requests read it, so they all present the current refresh token
+type barrierSessionRepository struct {
+	*fakeSessionRepository
+	readers sync.WaitGroup
+}
+
+func (r *barrierSessionRepository) GetSession(ctx context.Context, id string) (*entity.Session, error) {
+	session, err := r.fakeSessionRepository.GetSession(ctx, id)
+	r.readers.Done()
+	r.readers.Wait()
+	return session, err
+}
+
+func TestRefreshTokenConcurrently(t *testing.T) {
+	const requests = 8
+	ctx := context.Background()
+	svc, sessions := newSessionTestService()
+	res, sessionID := signIn(t, svc, 1)
+	barrier := &barrierSessionRepository{fakeSessionRepository: sessions}
+	barrier.readers.Add(requests)
+	svc.sessionRepository = barrier
+
+	var wg sync.WaitGroup
+	var refreshed, reused atomic.Int32
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.RefreshToken(ctx, &dto.RefreshTokenRequest{RefreshToken: res.RefreshToken})
+			switch {
+			case err == nil:
+				refreshed.Add(1)
+			case errors.Is(err, usecase.ErrRefreshTokenReused):
+				reused.Add(1)
+			case errors.Is(err, usecase.ErrSessionRevoked):
+				// the family was revoked by a request which lost the swap
+			default:
+				t.Errorf("RefreshToken() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the losers are reuses, which revoke the family of the winner too
+	if refreshed.Load() != 1 || reused.Load() == 0 {
+		t.Errorf("%d requests refreshed and %d were reuses, want 1 and some", refreshed.Load(), reused.Load())
+	}
+	if _, err := sessions.GetSession(ctx, sessionID); err == nil {
+		t.Error("session kept after concurrent refreshes")
+	}
+}
@@ -0,0 +1,136 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/usecase"
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
+)
+
+const (
+	defaultLoginWindow        = 900
+	defaultLoginIPLimit       = 50
+	defaultLockoutThreshold   = 5
+	defaultLockoutDuration    = 60
+	defaultMaxLockoutDuration = 3600
+	// lockoutLevelRetention keeps the lockout level after the lock expired
+	lockoutLevelRetention = 86400
+)
+
+// withLoginDefaults fills in the unset login rate limits
+func withLoginDefaults(cfg libconfig.Login) libconfig.Login {
+	if cfg.Window == 0 {
+		cfg.Window = defaultLoginWindow
+	}
+	if cfg.IPLimit == 0 {
+		cfg.IPLimit = defaultLoginIPLimit
+	}
+	if cfg.LockoutThreshold == 0 {
+		cfg.LockoutThreshold = defaultLockoutThreshold
+	}
+	if cfg.LockoutDuration == 0 {
+		cfg.LockoutDuration = defaultLockoutDuration
+	}
+	if cfg.MaxLockoutDuration == 0 {
+		cfg.MaxLockoutDuration = defaultMaxLockoutDuration
+	}
+	return cfg
+}
+
+// normalizeEmail returns the key sign in attempts of the account are counted by
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// getLockout returns the lockout of the account, or nil when never locked
+func getLockout(ctx context.Context, loginAttemptRepository repository.LoginAttemptRepository, email string) (*entity.Lockout, error) {
+	lockout, err := loginAttemptRepository.GetLockout(ctx, email)
+	if err != nil {
+		var nfErr *repository.ErrNotFound
+		if errors.As(err, &nfErr) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return lockout, nil
+}
+
+// checkLoginAllowed counts the attempt against the client ip limit and
+// rejects attempts on locked accounts. Unknown accounts are treated the same
+// so the response does not reveal which emails are registered.
+func (svc *AuthService) checkLoginAllowed(ctx context.Context, email, ipAddress string) error {
+	if ipAddress != "" {
+		attempts, err := svc.loginAttemptRepository.RecordIPAttempt(ctx, ipAddress, svc.loginCfg.Window)
+		if err != nil {
+			return err
+		}
+		if attempts > int64(svc.loginCfg.IPLimit) {
+			return usecase.ErrTooManyAttempts
+		}
+	}
+
+	lockout, err := getLockout(ctx, svc.loginAttemptRepository, email)
+	if err != nil {
+		return err
+	}
+	if lockout != nil && lockout.Locked(time.Now()) {
+		return usecase.ErrTooManyAttempts
+	}
+	return nil
+}
+
+// recordLoginFailure counts the failure against the account and locks it once
+// the threshold is reached, every further lockout lasts twice as long
+func (svc *AuthService) recordLoginFailure(ctx context.Context, email string, userID uint64, req *dto.LoginRequest) {
+	failures, err := svc.loginAttemptRepository.RecordFailure(ctx, email, svc.loginCfg.Window)
+	if err != nil {
+		svc.logger.WithError(err).Error("record login failure")
+		return
+	}
+	if failures < int64(svc.loginCfg.LockoutThreshold) {
+		return
+	}
+
+	lockout, err := getLockout(ctx, svc.loginAttemptRepository, email)
+	if err != nil {
+		svc.logger.WithError(err).Error("get lockout")
+		return
+	}
+	if lockout == nil {
+		lockout = &entity.Lockout{Email: email}
+	}
+	lockout.Level++
+
+	duration := svc.loginCfg.LockoutDuration
+	for i := 1; i < lockout.Level && duration < svc.loginCfg.MaxLockoutDuration; i++ {
+		duration *= 2
+	}
+	duration = min(duration, svc.loginCfg.MaxLockoutDuration)
+
+	now := time.Now()
+	lockout.LockedUntil = now.Add(time.Duration(duration) * time.Second)
+	if err := svc.loginAttemptRepository.StoreLockout(ctx, lockout, duration+lockoutLevelRetention); err != nil {
+		svc.logger.WithError(err).Error("store lockout")
+		return
+	}
+	if err := svc.loginAttemptRepository.ResetFailures(ctx, email); err != nil {
+		svc.logger.WithError(err).Error("reset login failures")
+	}
+
+	err = svc.securityEventRepository.PublishSecurityEvent(ctx, &entity.SecurityEvent{
+		Type:       entity.SecurityEventAccountLocked,
+		UserID:     userID,
+		IPAddress:  req.IPAddress,
+		UserAgent:  req.UserAgent,
+		OccurredAt: now,
+	})
+	if err != nil {
+		svc.logger.WithError(err).Error("publish security event")
+	}
+}
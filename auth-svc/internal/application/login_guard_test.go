@@ -0,0 +1,159 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/usecase"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/utils"
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/token"
+)
+
+func newLoginGuardTestService(cfg libconfig.Login) (*AuthService, *fakeLoginAttemptRepository, *fakeSecurityEventRepository) {
+	attempts := newFakeLoginAttemptRepository()
+	events := &fakeSecurityEventRepository{}
+	svc := &AuthService{
+		logger:                  config.ContextLogger,
+		loginAttemptRepository:  attempts,
+		securityEventRepository: events,
+		loginCfg:                withLoginDefaults(cfg),
+	}
+	return svc, attempts, events
+}
+
+func TestCheckLoginAllowedIPLimit(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newLoginGuardTestService(libconfig.Login{IPLimit: 3})
+
+	for i := 1; i <= 3; i++ {
+		if err := svc.checkLoginAllowed(ctx, "user@shop.test", "10.0.0.1"); err != nil {
+			t.Fatalf("attempt %d error = %v", i, err)
+		}
+	}
+	if err := svc.checkLoginAllowed(ctx, "other@shop.test", "10.0.0.1"); !errors.Is(err, usecase.ErrTooManyAttempts) {
+		t.Fatalf("attempt over the ip limit error = %v, want %v", err, usecase.ErrTooManyAttempts)
+	}
+	// other clients are not affected
+	if err := svc.checkLoginAllowed(ctx, "user@shop.test", "10.0.0.2"); err != nil {
+		t.Fatalf("attempt from another ip error = %v", err)
+	}
+}
+
+func TestRecordLoginFailureLockout(t *testing.T) {
+	ctx := context.Background()
+	svc, attempts, events := newLoginGuardTestService(libconfig.Login{
+		LockoutThreshold:   3,
+		LockoutDuration:    60,
+		MaxLockoutDuration: 200,
+	})
+	const email = "user@shop.test"
+	req := &dto.LoginRequest{Email: email, IPAddress: "10.0.0.1"}
+
+	// every level locks twice as long up to the maximum
+	for _, want := range []time.Duration{60 * time.Second, 120 * time.Second, 200 * time.Second, 200 * time.Second} {
+		for i := 0; i < 3; i++ {
+			if err := svc.checkLoginAllowed(ctx, email, ""); err != nil {
+				t.Fatalf("attempt before the threshold error = %v", err)
+			}
+			svc.recordLoginFailure(ctx, email, 1, req)
+		}
+		if err := svc.checkLoginAllowed(ctx, email, ""); !errors.Is(err, usecase.ErrTooManyAttempts) {
+			t.Fatalf("attempt on a locked account error = %v, want %v", err, usecase.ErrTooManyAttempts)
+		}
+
+		lockout, _ := attempts.GetLockout(ctx, email)
+		if got := time.Until(lockout.LockedUntil); got > want || got < want-5*time.Second {
+			t.Errorf("level %d locked for %s, want %s", lockout.Level, got, want)
+		}
+		attempts.expireLockout(email)
+	}
+
+	if len(events.events) != 4 || events.events[0].Type != entity.SecurityEventAccountLocked {
+		t.Errorf("security events = %+v, want 4 lockouts", events.events)
+	}
+}
+
+func TestSignInMixedCaseEmail(t *testing.T) {
+	ctx := context.Background()
+	hash, err := utils.HashedPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newService := func() (*AuthService, *fakeLoginAttemptRepository, *fakeSecurityEventRepository) {
+		svc, attempts, events := newLoginGuardTestService(libconfig.Login{LockoutThreshold: 3, LockoutDuration: 60})
+		svc.userRepository = &fakeUserRepository{users: map[uint64]*entity.User{
+			1: {ID: 1, Email: "User@Shop.test", Password: hash, Active: true, EmailVerified: true},
+		}}
+		svc.mfaService = &fakeMFAService{}
+		svc.sessionRepository = newFakeSessionRepository()
+		svc.roleRepository = &fakeRoleRepository{roles: map[uint64][]string{}}
+		svc.tokenEnhancer = token.NewJWTEnhancer([]byte("secret"))
+		svc.accessTokenExpires, svc.refreshTokenExpires = 60, 600
+		return svc, attempts, events
+	}
+
+	t.Run("failures share one counter", func(t *testing.T) {
+		svc, attempts, events := newService()
+		for i, email := range []string{"User@Shop.test", "USER@SHOP.TEST", " user@shop.TEST"} {
+			_, err := svc.SignIn(ctx, &dto.LoginRequest{Email: email, Password: "wrong"})
+			if !errors.Is(err, usecase.ErrInvalidCredentials) {
+				t.Fatalf("attempt %d error = %v, want %v", i+1, err, usecase.ErrInvalidCredentials)
+			}
+			if got, _ := attempts.CountFailures(ctx, "user@shop.test", 0); i < 2 && got != int64(i+1) {
+				t.Fatalf("failures after attempt %d = %d, want %d", i+1, got, i+1)
+			}
+		}
+
+		if len(events.events) != 1 || events.events[0].UserID != 1 {
+			t.Fatalf("security events = %+v, want a lockout of user 1", events.events)
+		}
+		_, err := svc.SignIn(ctx, &dto.LoginRequest{Email: "user@shop.test", Password: "secret"})
+		if !errors.Is(err, usecase.ErrTooManyAttempts) {
+			t.Fatalf("SignIn() of the locked account error = %v, want %v", err, usecase.ErrTooManyAttempts)
+		}
+	})
+
+	t.Run("signs in the account", func(t *testing.T) {
+		svc, _, _ := newService()
+		res, err := svc.SignIn(ctx, &dto.LoginRequest{Email: " USER@shop.Test ", Password: "secret"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := svc.tokenEnhancer.Verify(res.AccessToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims.UserID != 1 {
+			t.Errorf("signed in user = %d, want 1", claims.UserID)
+		}
+	})
+}
+
+func TestSignInWithOIDCLockout(t *testing.T) {
+	ctx := context.Background()
+	svc, attempts, _ := newLoginGuardTestService(libconfig.Login{})
+	svc.oidcService = &resolvingOIDCService{userID: 1}
+	svc.userRepository = &fakeUserRepository{users: map[uint64]*entity.User{1: {ID: 1, Email: "User@Shop.test", Active: true}}}
+	_ = attempts.StoreLockout(ctx, &entity.Lockout{Email: "user@shop.test", Level: 1, LockedUntil: time.Now().Add(time.Minute)}, 60)
+
+	_, err := svc.SignInWithOIDC(ctx, &dto.OIDCCallbackRequest{IPAddress: "10.0.0.1"})
+	if !errors.Is(err, usecase.ErrTooManyAttempts) {
+		t.Fatalf("SignInWithOIDC() of a locked account error = %v, want %v", err, usecase.ErrTooManyAttempts)
+	}
+}
+
+// resolvingOIDCService resolves every callback to the same user
+type resolvingOIDCService struct {
+	usecase.OIDCUseCase
+	userID uint64
+}
+
+func (svc *resolvingOIDCService) ResolveUser(context.Context, *dto.OIDCCallbackRequest) (uint64, error) {
+	return svc.userID, nil
+}
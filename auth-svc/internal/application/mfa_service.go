@@ -0,0 +1,232 @@
+package application
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/usecase"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/utils"
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/constant"
+	"github.com/Chengxufeng1994/go-saga-example/common/model"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMFAIssuer     = "go-saga-example"
+	totpSkew             = 1
+	recoveryCodeCount    = 10
+	recoveryCodeByteSize = 5
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type MFAService struct {
+	logger         *log.Entry
+	userRepository repository.UserRepository
+	mfaRepository  repository.MFARepository
+	issuer         string
+	encryptionKey  string
+}
+
+func NewMFAService(
+	userRepository repository.UserRepository,
+	mfaRepository repository.MFARepository,
+	mfaCfg libconfig.MFA,
+) usecase.MFAUseCase {
+	svc := &MFAService{
+		logger:         config.ContextLogger.WithFields(log.Fields{"type": "service:MFAService"}),
+		userRepository: userRepository,
+		mfaRepository:  mfaRepository,
+		issuer:         mfaCfg.Issuer,
+		encryptionKey:  mfaCfg.EncryptionKey,
+	}
+	if svc.issuer == "" {
+		svc.issuer = defaultMFAIssuer
+	}
+
+	return svc
+}
+
+// getUserMFA returns the mfa settings of the user, or nil when never enrolled
+func (svc *MFAService) getUserMFA(ctx context.Context, userID uint64) (*entity.UserMFA, error) {
+	mfa, err := svc.mfaRepository.GetUserMFA(ctx, userID)
+	if err != nil {
+		var nfErr *repository.ErrNotFound
+		if errors.As(err, &nfErr) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return mfa, nil
+}
+
+// Enroll implements usecase.MFAUseCase. Enrolling again before confirming
+// replaces the pending secret.
+func (svc *MFAService) Enroll(ctx context.Context) (*dto.MFAEnrollResponse, error) {
+	userId := ctx.Value(constant.CtxUserKey).(uint64)
+	mfa, err := svc.getUserMFA(ctx, userId)
+	if err != nil {
+		return nil, model.NewAppError("Enroll", "app.mfa.get_user_mfa.error", nil, "").Wrap(err)
+	}
+	if mfa != nil && mfa.Enabled {
+		return nil, model.NewAppError("Enroll", "app.mfa.already_enabled.error", nil, "").Wrap(usecase.ErrMFAAlreadyEnabled)
+	}
+
+	user, err := svc.userRepository.GetUserByID(ctx, userId)
+	if err != nil {
+		return nil, model.NewAppError("Enroll", "app.user.get_by_id.error", nil, "").Wrap(err)
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, model.NewAppError("Enroll", "app.mfa.gen_secret.error", nil, "").Wrap(err)
+	}
+	encrypted, err := utils.Encrypt(svc.encryptionKey, secret)
+	if err != nil {
+		return nil, model.NewAppError("Enroll", "app.mfa.encrypt_secret.error", nil, "").Wrap(err)
+	}
+	if err := svc.mfaRepository.SaveUserMFA(ctx, &entity.UserMFA{
+		UserID:          userId,
+		EncryptedSecret: encrypted,
+	}); err != nil {
+		return nil, model.NewAppError("Enroll", "app.mfa.save_user_mfa.error", nil, "").Wrap(err)
+	}
+
+	return &dto.MFAEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(svc.issuer, user.Email, secret),
+	}, nil
+}
+
+// Confirm implements usecase.MFAUseCase. The recovery codes are only ever
+// returned here, just their hashes are stored.
+func (svc *MFAService) Confirm(ctx context.Context, req *dto.MFACodeRequest) (*dto.MFAConfirmResponse, error) {
+	userId := ctx.Value(constant.CtxUserKey).(uint64)
+	mfa, err := svc.getUserMFA(ctx, userId)
+	if err != nil {
+		return nil, model.NewAppError("Confirm", "app.mfa.get_user_mfa.error", nil, "").Wrap(err)
+	}
+	if mfa == nil {
+		return nil, model.NewAppError("Confirm", "app.mfa.not_enrolled.error", nil, "").Wrap(usecase.ErrMFANotEnrolled)
+	}
+	if mfa.Enabled {
+		return nil, model.NewAppError("Confirm", "app.mfa.already_enabled.error", nil, "").Wrap(usecase.ErrMFAAlreadyEnabled)
+	}
+
+	secret, err := utils.Decrypt(svc.encryptionKey, mfa.EncryptedSecret)
+	if err != nil {
+		return nil, model.NewAppError("Confirm", "app.mfa.decrypt_secret.error", nil, "").Wrap(err)
+	}
+	step, ok := utils.ValidateTOTP(secret, req.Code, time.Now(), totpSkew)
+	if !ok {
+		return nil, model.NewAppError("Confirm", "app.mfa.invalid_code.error", nil, "").Wrap(usecase.ErrInvalidMFACode)
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, model.NewAppError("Confirm", "app.mfa.gen_recovery_code.error", nil, "").Wrap(err)
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	if err := svc.mfaRepository.EnableUserMFA(ctx, userId, step, hashes); err != nil {
+		return nil, model.NewAppError("Confirm", "app.mfa.enable_user_mfa.error", nil, "").Wrap(err)
+	}
+
+	return &dto.MFAConfirmResponse{RecoveryCodes: codes}, nil
+}
+
+// Disable implements usecase.MFAUseCase.
+func (svc *MFAService) Disable(ctx context.Context, req *dto.MFACodeRequest) (string, error) {
+	userId := ctx.Value(constant.CtxUserKey).(uint64)
+	if err := svc.VerifyCode(ctx, userId, req.Code); err != nil {
+		return "", model.NewAppError("Disable", "app.mfa.verify_code.error", nil, "").Wrap(err)
+	}
+
+	if err := svc.mfaRepository.DeleteUserMFA(ctx, userId); err != nil {
+		return "", model.NewAppError("Disable", "app.mfa.delete_user_mfa.error", nil, "").Wrap(err)
+	}
+	return "Ok", nil
+}
+
+// IsEnabled implements usecase.MFAUseCase.
+func (svc *MFAService) IsEnabled(ctx context.Context, userID uint64) (bool, error) {
+	mfa, err := svc.getUserMFA(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return mfa != nil && mfa.Enabled, nil
+}
+
+// VerifyCode implements usecase.MFAUseCase. The code is either a totp code,
+// which is accepted once per time step, or a single use recovery code.
+func (svc *MFAService) VerifyCode(ctx context.Context, userID uint64, code string) error {
+	mfa, err := svc.getUserMFA(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if mfa == nil || !mfa.Enabled {
+		return usecase.ErrMFANotEnrolled
+	}
+
+	if len(code) != 6 {
+		used, err := svc.mfaRepository.UseRecoveryCode(ctx, userID, hashRecoveryCode(code))
+		if err != nil {
+			return err
+		}
+		if !used {
+			return usecase.ErrInvalidMFACode
+		}
+		svc.logger.WithField("user_id", userID).Info("recovery code used")
+		return nil
+	}
+
+	secret, err := utils.Decrypt(svc.encryptionKey, mfa.EncryptedSecret)
+	if err != nil {
+		return err
+	}
+	step, ok := utils.ValidateTOTP(secret, code, time.Now(), totpSkew)
+	if !ok {
+		return usecase.ErrInvalidMFACode
+	}
+	used, err := svc.mfaRepository.UseTimeStep(ctx, userID, step)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return usecase.ErrInvalidMFACode
+	}
+	return nil
+}
+
+// generateRecoveryCode returns a random code formatted as XXXX-XXXX
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeByteSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := recoveryCodeEncoding.EncodeToString(b)
+	return code[:4] + "-" + code[4:], nil
+}
+
+// hashRecoveryCode normalizes the code so it matches however it is typed
+func hashRecoveryCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,292 @@
+package application
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/usecase"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/utils"
+	"github.com/Chengxufeng1994/go-saga-example/common/constant"
+	"github.com/Chengxufeng1994/go-saga-example/common/model"
+	"github.com/Chengxufeng1994/go-saga-example/common/rbac"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultOIDCStateExpires = 600
+	oidcRandomSize          = 32
+)
+
+type OIDCService struct {
+	logger              *log.Entry
+	providers           map[string]repository.IdentityProvider
+	identityRepository  repository.IdentityRepository
+	oidcStateRepository repository.OIDCStateRepository
+	userRepository      repository.UserRepository
+	roleRepository      repository.RoleRepository
+	adminEmails         []string
+	cookieSecret        []byte
+	stateExpires        int
+}
+
+func NewOIDCService(
+	providers map[string]repository.IdentityProvider,
+	identityRepository repository.IdentityRepository,
+	oidcStateRepository repository.OIDCStateRepository,
+	userRepository repository.UserRepository,
+	roleRepository repository.RoleRepository,
+	adminEmails []string,
+	cookieSecret []byte,
+	stateExpires int,
+) usecase.OIDCUseCase {
+	svc := &OIDCService{
+		logger:              config.ContextLogger.WithFields(log.Fields{"type": "service:OIDCService"}),
+		providers:           providers,
+		identityRepository:  identityRepository,
+		oidcStateRepository: oidcStateRepository,
+		userRepository:      userRepository,
+		roleRepository:      roleRepository,
+		adminEmails:         adminEmails,
+		cookieSecret:        cookieSecret,
+		stateExpires:        stateExpires,
+	}
+	if svc.stateExpires == 0 {
+		svc.stateExpires = defaultOIDCStateExpires
+	}
+
+	return svc
+}
+
+// signState signs the state id for the cookie of the browser which started
+// the sign in
+func (svc *OIDCService) signState(id string) string {
+	mac := hmac.New(sha256.New, svc.cookieSecret)
+	mac.Write([]byte(id))
+	return id + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// stateBound reports whether the callback of state is followed by the
+// browser which started the sign in, so a callback of another sign in can
+// not be forced onto it
+func (svc *OIDCService) stateBound(state, cookie string) bool {
+	id, _, _ := strings.Cut(cookie, ".")
+	return id == state && hmac.Equal([]byte(cookie), []byte(svc.signState(id)))
+}
+
+// Authorize implements usecase.OIDCUseCase. The returned url redirects to the
+// provider, the state, nonce and code verifier stay behind for the callback.
+func (svc *OIDCService) Authorize(ctx context.Context, providerName string) (*dto.OIDCAuthorization, error) {
+	provider, ok := svc.providers[providerName]
+	if !ok {
+		return nil, model.NewAppError("Authorize", "app.oidc.get_provider.error", nil, "").Wrap(usecase.ErrUnknownProvider)
+	}
+
+	values := make([]string, 3)
+	for i := range values {
+		value, err := randomOIDCValue()
+		if err != nil {
+			return nil, model.NewAppError("Authorize", "app.oidc.gen_state.error", nil, "").Wrap(err)
+		}
+		values[i] = value
+	}
+	state := &entity.OIDCState{
+		ID:           values[0],
+		Provider:     providerName,
+		Nonce:        values[1],
+		CodeVerifier: values[2],
+		ExpiresAt:    time.Now().Add(time.Duration(svc.stateExpires) * time.Second),
+	}
+	if err := svc.oidcStateRepository.StoreState(ctx, state); err != nil {
+		return nil, model.NewAppError("Authorize", "app.oidc.store_state.error", nil, "").Wrap(err)
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state.ID, state.Nonce, codeChallenge(state.CodeVerifier))
+	if err != nil {
+		return nil, model.NewAppError("Authorize", "app.oidc.auth_code_url.error", nil, "").Wrap(err)
+	}
+	return &dto.OIDCAuthorization{
+		URL:         authURL,
+		StateCookie: svc.signState(state.ID),
+		ExpiresIn:   svc.stateExpires,
+	}, nil
+}
+
+// ResolveUser implements usecase.OIDCUseCase. A known identity signs in its
+// user, an unknown one is linked to the account of its email when the
+// provider verified the email, otherwise a new account is created for it.
+func (svc *OIDCService) ResolveUser(ctx context.Context, req *dto.OIDCCallbackRequest) (uint64, error) {
+	if !svc.stateBound(req.State, req.StateCookie) {
+		return 0, model.NewAppError("ResolveUser", "app.oidc.check_state_cookie.error", nil, "").Wrap(usecase.ErrInvalidOIDCState)
+	}
+	state, err := svc.oidcStateRepository.ConsumeState(ctx, req.State)
+	if err != nil {
+		var nfErr *repository.ErrNotFound
+		if errors.As(err, &nfErr) {
+			return 0, model.NewAppError("ResolveUser", "app.oidc.consume_state.error", nil, "").Wrap(usecase.ErrInvalidOIDCState)
+		}
+		return 0, model.NewAppError("ResolveUser", "app.oidc.consume_state.error", nil, "").Wrap(err)
+	}
+	if state.Provider != req.Provider {
+		return 0, model.NewAppError("ResolveUser", "app.oidc.consume_state.error", nil, "").Wrap(usecase.ErrInvalidOIDCState)
+	}
+	provider, ok := svc.providers[req.Provider]
+	if !ok {
+		return 0, model.NewAppError("ResolveUser", "app.oidc.get_provider.error", nil, "").Wrap(usecase.ErrUnknownProvider)
+	}
+
+	logger := svc.logger.WithField("provider", req.Provider)
+	if req.Error != "" {
+		logger.WithField("error", req.Error).Warnf("sign in rejected: %s", req.ErrorDescription)
+		return 0, model.NewAppError("ResolveUser", "app.oidc.rejected.error", nil, req.Error).Wrap(usecase.ErrOIDCSignInFailed)
+	}
+
+	external, err := provider.Exchange(ctx, req.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		// the cause is only logged, it is of no use to the user
+		logger.WithError(err).Error("exchange code")
+		return 0, model.NewAppError("ResolveUser", "app.oidc.exchange.error", nil, "").Wrap(usecase.ErrOIDCSignInFailed)
+	}
+
+	identity, err := svc.identityRepository.GetIdentity(ctx, external.Provider, external.Subject)
+	if err == nil {
+		return identity.UserID, nil
+	}
+	var nfErr *repository.ErrNotFound
+	if !errors.As(err, &nfErr) {
+		return 0, model.NewAppError("ResolveUser", "app.oidc.get_identity.error", nil, "").Wrap(err)
+	}
+
+	userID, err := svc.linkUser(ctx, external)
+	if err != nil {
+		return 0, model.NewAppError("ResolveUser", "app.oidc.link_user.error", nil, "").Wrap(err)
+	}
+	logger.WithField("user_id", userID).Info("identity linked")
+	return userID, nil
+}
+
+// linkUser links the external identity to the account of its email, which
+// is created when there is none yet
+func (svc *OIDCService) linkUser(ctx context.Context, external *entity.ExternalIdentity) (uint64, error) {
+	if external.Email == "" {
+		return 0, usecase.ErrOIDCSignInFailed
+	}
+
+	var userID uint64
+	user, err := svc.userRepository.GetUserByEmail(ctx, external.Email)
+	if err == nil {
+		// an unverified email would let anyone take over the account
+		if !external.EmailVerified {
+			return 0, usecase.ErrIdentityConflict
+		}
+		if !user.EmailVerified {
+			if err := svc.userRepository.MarkEmailVerified(ctx, user.ID); err != nil {
+				return 0, err
+			}
+		}
+		userID = user.ID
+	} else {
+		var nfErr *repository.ErrNotFound
+		if !errors.As(err, &nfErr) {
+			return 0, err
+		}
+		if userID, err = svc.createUser(ctx, external); err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = svc.identityRepository.CreateIdentity(ctx, &entity.UserIdentity{
+		UserID:   userID,
+		Provider: external.Provider,
+		Subject:  external.Subject,
+		Email:    external.Email,
+	})
+	if err != nil {
+		// a concurrent callback of the same identity linked it first
+		var iiErr *repository.ErrInvalidInput
+		if errors.As(err, &iiErr) {
+			identity, err := svc.identityRepository.GetIdentity(ctx, external.Provider, external.Subject)
+			if err != nil {
+				return 0, err
+			}
+			return identity.UserID, nil
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+// createUser creates the account of an external identity, it has no usable
+// password until one is set through a password reset
+func (svc *OIDCService) createUser(ctx context.Context, external *entity.ExternalIdentity) (uint64, error) {
+	hashedPassword, err := utils.HashedPassword(uuid.NewString())
+	if err != nil {
+		return 0, err
+	}
+
+	user, err := svc.userRepository.CreateUser(ctx, &entity.User{
+		Active:        true,
+		EmailVerified: external.EmailVerified,
+		FirstName:     external.FirstName,
+		LastName:      external.LastName,
+		Email:         external.Email,
+		Password:      hashedPassword,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	roles := []string{rbac.RoleCustomer}
+	if external.EmailVerified && slices.Contains(svc.adminEmails, user.Email) {
+		roles = append(roles, rbac.RoleAdmin)
+	}
+	if err := svc.roleRepository.SetUserRoles(ctx, user.ID, roles); err != nil {
+		return 0, err
+	}
+	return user.ID, nil
+}
+
+// ListIdentities implements usecase.OIDCUseCase.
+func (svc *OIDCService) ListIdentities(ctx context.Context) ([]*dto.Identity, error) {
+	userId := ctx.Value(constant.CtxUserKey).(uint64)
+	identities, err := svc.identityRepository.ListIdentities(ctx, userId)
+	if err != nil {
+		return nil, model.NewAppError("ListIdentities", "app.oidc.list_identities.error", nil, "").Wrap(err)
+	}
+
+	res := make([]*dto.Identity, 0, len(identities))
+	for _, identity := range identities {
+		res = append(res, &dto.Identity{
+			ID:        identity.ID,
+			Provider:  identity.Provider,
+			Email:     identity.Email,
+			CreatedAt: identity.CreatedAt,
+		})
+	}
+	return res, nil
+}
+
+func randomOIDCValue() (string, error) {
+	b := make([]byte, oidcRandomSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// codeChallenge is the S256 PKCE challenge of the verifier
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
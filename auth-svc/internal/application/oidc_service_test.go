@@ -0,0 +1,108 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/usecase"
+)
+
+// fakeOIDCStateRepository keeps states in memory, consuming removes them
+type fakeOIDCStateRepository struct {
+	states map[string]*entity.OIDCState
+}
+
+func (r *fakeOIDCStateRepository) StoreState(_ context.Context, state *entity.OIDCState) error {
+	r.states[state.ID] = state
+	return nil
+}
+
+func (r *fakeOIDCStateRepository) ConsumeState(_ context.Context, id string) (*entity.OIDCState, error) {
+	state, ok := r.states[id]
+	if !ok {
+		return nil, repository.NewErrNotFound("OIDCState", id)
+	}
+	delete(r.states, id)
+	return state, nil
+}
+
+// fakeIdentityProvider redirects to a fixed url, unused methods panic
+type fakeIdentityProvider struct {
+	repository.IdentityProvider
+}
+
+func (p *fakeIdentityProvider) AuthCodeURL(_ context.Context, state, _, _ string) (string, error) {
+	return "https://idp.test/authorize?state=" + state, nil
+}
+
+func TestOIDCStateBinding(t *testing.T) {
+	ctx := context.Background()
+	states := &fakeOIDCStateRepository{states: map[string]*entity.OIDCState{}}
+	svc := &OIDCService{
+		logger:              config.ContextLogger,
+		providers:           map[string]repository.IdentityProvider{"mock": &fakeIdentityProvider{}},
+		oidcStateRepository: states,
+		cookieSecret:        []byte("cookie-secret"),
+		stateExpires:        60,
+	}
+	other := &OIDCService{cookieSecret: []byte("other-secret")}
+
+	authorize := func(t *testing.T) *dto.OIDCAuthorization {
+		authorization, err := svc.Authorize(ctx, "mock")
+		if err != nil {
+			t.Fatalf("Authorize() error = %v", err)
+		}
+		return authorization
+	}
+	stateOf := func(authorization *dto.OIDCAuthorization) string {
+		_, state, _ := strings.Cut(authorization.URL, "state=")
+		return state
+	}
+	tests := []struct {
+		name   string
+		cookie func(victim, attacker *dto.OIDCAuthorization) string
+	}{
+		{"no cookie", func(_, _ *dto.OIDCAuthorization) string { return "" }},
+		{"cookie of another sign in", func(victim, _ *dto.OIDCAuthorization) string { return victim.StateCookie }},
+		{"unsigned state", func(_, attacker *dto.OIDCAuthorization) string { return stateOf(attacker) }},
+		{"signed with another secret", func(_, attacker *dto.OIDCAuthorization) string { return other.signState(stateOf(attacker)) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			victim, attacker := authorize(t), authorize(t)
+			_, err := svc.ResolveUser(ctx, &dto.OIDCCallbackRequest{
+				Provider:    "mock",
+				State:       stateOf(attacker),
+				StateCookie: tt.cookie(victim, attacker),
+			})
+			if !errors.Is(err, usecase.ErrInvalidOIDCState) {
+				t.Fatalf("ResolveUser() error = %v, want %v", err, usecase.ErrInvalidOIDCState)
+			}
+			if _, ok := states.states[stateOf(attacker)]; !ok {
+				t.Error("state consumed by a callback of another browser")
+			}
+		})
+	}
+
+	// the browser which started the sign in gets past the state check, the
+	// provider rejected this one
+	authorization := authorize(t)
+	_, err := svc.ResolveUser(ctx, &dto.OIDCCallbackRequest{
+		Provider:    "mock",
+		State:       stateOf(authorization),
+		StateCookie: authorization.StateCookie,
+		Error:       "access_denied",
+	})
+	if !errors.Is(err, usecase.ErrOIDCSignInFailed) {
+		t.Fatalf("ResolveUser() error = %v, want %v", err, usecase.ErrOIDCSignInFailed)
+	}
+	if len(states.states) != 2*len(tests) {
+		t.Errorf("%d states left, want the bound state consumed", len(states.states))
+	}
+}
@@ -0,0 +1,69 @@
+package application
+
+import (
+	"context"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
+	log "github.com/sirupsen/logrus"
+)
+
+const userEventBatchSize = 100
+
+// UserEventRelay publishes the user events recorded in the outbox. An event
+// is removed once published, so it is published at least once and consumers
+// drop the duplicates by the event id.
+type UserEventRelay struct {
+	logger              *log.Entry
+	outboxRepository    repository.UserEventOutboxRepository
+	userEventRepository repository.UserEventRepository
+}
+
+func NewUserEventRelay(outboxRepository repository.UserEventOutboxRepository, userEventRepository repository.UserEventRepository) *UserEventRelay {
+	return &UserEventRelay{
+		logger:              config.ContextLogger.WithFields(log.Fields{"type": "service:UserEventRelay"}),
+		outboxRepository:    outboxRepository,
+		userEventRepository: userEventRepository,
+	}
+}
+
+// Flush publishes the pending events in the order they were recorded, it
+// stops at the first event which failed to publish
+func (r *UserEventRelay) Flush(ctx context.Context) error {
+	for {
+		events, err := r.outboxRepository.ListUserEvents(ctx, userEventBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, evt := range events {
+			if err := r.userEventRepository.PublishUserDeleted(ctx, evt); err != nil {
+				return err
+			}
+			if err := r.outboxRepository.RemoveUserEvent(ctx, evt.ID); err != nil {
+				return err
+			}
+		}
+		if len(events) < userEventBatchSize {
+			return nil
+		}
+	}
+}
+
+// Start flushes the outbox every period until ctx is done
+func (r *UserEventRelay) Start(ctx context.Context, period time.Duration) {
+	go func() {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := r.Flush(ctx); err != nil {
+					r.logger.WithError(err).Error("publish user events")
+				}
+			}
+		}
+	}()
+}
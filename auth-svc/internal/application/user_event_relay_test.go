@@ -0,0 +1,89 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+)
+
+// fakeUserEventOutbox keeps the recorded events in order
+type fakeUserEventOutbox struct {
+	events []*entity.UserDeletedEvent
+}
+
+func (o *fakeUserEventOutbox) ListUserEvents(_ context.Context, limit int) ([]*entity.UserDeletedEvent, error) {
+	return o.events[:min(limit, len(o.events))], nil
+}
+
+func (o *fakeUserEventOutbox) RemoveUserEvent(_ context.Context, id string) error {
+	for i, evt := range o.events {
+		if evt.ID == id {
+			o.events = append(o.events[:i:i], o.events[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+// fakeUserEventPublisher fails the events of failUserID
+type fakeUserEventPublisher struct {
+	published  []string
+	failUserID uint64
+}
+
+func (p *fakeUserEventPublisher) PublishUserDeleted(_ context.Context, evt *entity.UserDeletedEvent) error {
+	if evt.UserID == p.failUserID {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, evt.ID)
+	return nil
+}
+
+func TestUserEventRelayFlush(t *testing.T) {
+	newOutbox := func(n int) *fakeUserEventOutbox {
+		outbox := &fakeUserEventOutbox{}
+		for i := 1; i <= n; i++ {
+			outbox.events = append(outbox.events, &entity.UserDeletedEvent{ID: fmt.Sprintf("evt-%d", i), UserID: uint64(i)})
+		}
+		return outbox
+	}
+	tests := []struct {
+		name          string
+		events        int
+		failUserID    uint64
+		wantErr       bool
+		wantPublished int
+		wantPending   int
+	}{
+		{"empty outbox", 0, 0, false, 0, 0},
+		{"several batches", userEventBatchSize + 5, 0, false, userEventBatchSize + 5, 0},
+		{"publish failed", 5, 3, true, 2, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outbox := newOutbox(tt.events)
+			publisher := &fakeUserEventPublisher{failUserID: tt.failUserID}
+			relay := &UserEventRelay{logger: config.ContextLogger, outboxRepository: outbox, userEventRepository: publisher}
+
+			if err := relay.Flush(context.Background()); (err != nil) != tt.wantErr {
+				t.Fatalf("Flush() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(publisher.published) != tt.wantPublished {
+				t.Errorf("published %d events, want %d", len(publisher.published), tt.wantPublished)
+			}
+			for i, id := range publisher.published {
+				if want := fmt.Sprintf("evt-%d", i+1); id != want {
+					t.Fatalf("published[%d] = %s, want %s", i, id, want)
+				}
+			}
+			// an event which failed to publish stays until the next flush
+			if len(outbox.events) != tt.wantPending {
+				t.Errorf("%d events pending, want %d", len(outbox.events), tt.wantPending)
+			}
+		})
+	}
+}
@@ -3,24 +3,47 @@ package application
 import (
 	"context"
 	"errors"
+	"slices"
+	"time"
 
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/repository"
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/usecase"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/utils"
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/constant"
 	"github.com/Chengxufeng1994/go-saga-example/common/model"
 	log "github.com/sirupsen/logrus"
 )
 
 type UserService struct {
-	userRepository repository.UserRepository
-	logger         *log.Entry
+	userRepository         repository.UserRepository
+	roleRepository         repository.RoleRepository
+	sessionRepository      repository.SessionRepository
+	loginAttemptRepository repository.LoginAttemptRepository
+	accountService         usecase.AccountUseCase
+	loginCfg               libconfig.Login
+	logger                 *log.Entry
 }
 
-func NewUserService(userRepository repository.UserRepository) usecase.UserUseCase {
+func NewUserService(
+	userRepository repository.UserRepository,
+	roleRepository repository.RoleRepository,
+	sessionRepository repository.SessionRepository,
+	loginAttemptRepository repository.LoginAttemptRepository,
+	accountService usecase.AccountUseCase,
+	loginCfg libconfig.Login,
+) usecase.UserUseCase {
 	return &UserService{
-		userRepository: userRepository,
-		logger:         config.ContextLogger.WithFields(log.Fields{"type": "service:UserService"}),
+		userRepository:         userRepository,
+		roleRepository:         roleRepository,
+		sessionRepository:      sessionRepository,
+		loginAttemptRepository: loginAttemptRepository,
+		accountService:         accountService,
+		loginCfg:               withLoginDefaults(loginCfg),
+		logger:                 config.ContextLogger.WithFields(log.Fields{"type": "service:UserService"}),
 	}
 }
 
@@ -49,3 +72,211 @@ func (svc *UserService) GetUserByID(ctx context.Context, id uint64) (*dto.User,
 		PhoneNumber: user.PhoneNumber,
 	}, nil
 }
+
+// SetUserRoles implements usecase.UserUseCase.
+func (svc *UserService) SetUserRoles(ctx context.Context, id uint64, req *dto.UserRolesRequest) (*dto.UserRoles, error) {
+	if _, err := svc.userRepository.GetUserByID(ctx, id); err != nil {
+		return nil, model.NewAppError("SetUserRoles", "app.user.get_by_id.error", nil, "").Wrap(err)
+	}
+
+	roles := slices.Clone(req.Roles)
+	slices.Sort(roles)
+	roles = slices.Compact(roles)
+	if err := svc.roleRepository.SetUserRoles(ctx, id, roles); err != nil {
+		return nil, model.NewAppError("SetUserRoles", "app.user.set_roles.error", nil, "").Wrap(err)
+	}
+
+	// the new roles take effect on the next sign in or token refresh
+	return &dto.UserRoles{UserID: id, Roles: roles}, nil
+}
+
+// GetUserLockout implements usecase.UserUseCase.
+func (svc *UserService) GetUserLockout(ctx context.Context, id uint64) (*dto.UserLockout, error) {
+	user, err := svc.userRepository.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, model.NewAppError("GetUserLockout", "app.user.get_by_id.error", nil, "").Wrap(err)
+	}
+
+	email := normalizeEmail(user.Email)
+	failures, err := svc.loginAttemptRepository.CountFailures(ctx, email, svc.loginCfg.Window)
+	if err != nil {
+		return nil, model.NewAppError("GetUserLockout", "app.user.count_login_failures.error", nil, "").Wrap(err)
+	}
+	lockout, err := getLockout(ctx, svc.loginAttemptRepository, email)
+	if err != nil {
+		return nil, model.NewAppError("GetUserLockout", "app.user.get_lockout.error", nil, "").Wrap(err)
+	}
+
+	res := &dto.UserLockout{
+		UserID:         user.ID,
+		Email:          user.Email,
+		RecentFailures: failures,
+	}
+	if lockout != nil {
+		res.Lockouts = lockout.Level
+		if lockout.Locked(time.Now()) {
+			res.Locked = true
+			res.LockedUntil = &lockout.LockedUntil
+		}
+	}
+	return res, nil
+}
+
+// UnlockUser implements usecase.UserUseCase. The lockout level is reset as well.
+func (svc *UserService) UnlockUser(ctx context.Context, id uint64) (string, error) {
+	user, err := svc.userRepository.GetUserByID(ctx, id)
+	if err != nil {
+		return "", model.NewAppError("UnlockUser", "app.user.get_by_id.error", nil, "").Wrap(err)
+	}
+
+	if err := svc.loginAttemptRepository.RemoveLockout(ctx, normalizeEmail(user.Email)); err != nil {
+		return "", model.NewAppError("UnlockUser", "app.user.remove_lockout.error", nil, "").Wrap(err)
+	}
+	return "Ok", nil
+}
+
+// GetProfile implements usecase.UserUseCase.
+func (svc *UserService) GetProfile(ctx context.Context) (*dto.User, error) {
+	userId := ctx.Value(constant.CtxUserKey).(uint64)
+	return svc.GetUserByID(ctx, userId)
+}
+
+// UpdateProfile implements usecase.UserUseCase. A changed email requires the
+// password and is only switched to once the link sent to it is followed, the
+// rest of the profile is updated right away.
+func (svc *UserService) UpdateProfile(ctx context.Context, req *dto.UserProfileRequest) (*dto.User, error) {
+	userId := ctx.Value(constant.CtxUserKey).(uint64)
+	user, err := svc.userRepository.GetUserByID(ctx, userId)
+	if err != nil {
+		return nil, model.NewAppError("UpdateProfile", "app.user.get_by_id.error", nil, "").Wrap(err)
+	}
+
+	if req.Email != nil && normalizeEmail(*req.Email) != normalizeEmail(user.Email) {
+		if _, err := svc.confirmPassword(ctx, userId, req.Password); err != nil {
+			return nil, model.NewAppError("UpdateProfile", "app.user.confirm_password.error", nil, "").Wrap(err)
+		}
+		if _, err := svc.accountService.RequestEmailChange(ctx, &dto.EmailRequest{Email: *req.Email}); err != nil {
+			return nil, model.NewAppError("UpdateProfile", "app.account.request_email_change.error", nil, "").Wrap(err)
+		}
+	}
+	if req.FirstName != nil {
+		user.FirstName = *req.FirstName
+	}
+	if req.LastName != nil {
+		user.LastName = *req.LastName
+	}
+	if req.Address != nil {
+		user.Address = *req.Address
+	}
+	if req.PhoneNumber != nil {
+		user.PhoneNumber = *req.PhoneNumber
+	}
+
+	updated, err := svc.userRepository.UpdateProfile(ctx, user)
+	if err != nil {
+		var iiErr *repository.ErrInvalidInput
+		if errors.As(err, &iiErr) {
+			return nil, model.NewAppError("UpdateProfile", "app.user.update_profile.error", nil, "").Wrap(usecase.ErrProfileConflict)
+		}
+		return nil, model.NewAppError("UpdateProfile", "app.user.update_profile.error", nil, "").Wrap(err)
+	}
+
+	return newUserDTO(updated), nil
+}
+
+// ChangePassword implements usecase.UserUseCase. Every other session is
+// signed out.
+func (svc *UserService) ChangePassword(ctx context.Context, req *dto.ChangePasswordRequest) (string, error) {
+	userId := ctx.Value(constant.CtxUserKey).(uint64)
+	sessionId, _ := ctx.Value(constant.CtxSessionKey).(string)
+	if _, err := svc.confirmPassword(ctx, userId, req.OldPassword); err != nil {
+		return "", model.NewAppError("ChangePassword", "app.user.confirm_password.error", nil, "").Wrap(err)
+	}
+
+	hashedPassword, err := utils.HashedPassword(req.NewPassword)
+	if err != nil {
+		return "", model.NewAppError("ChangePassword", "app.user.hash_password.error", nil, "").Wrap(err)
+	}
+	if err := svc.userRepository.UpdatePassword(ctx, userId, hashedPassword); err != nil {
+		return "", model.NewAppError("ChangePassword", "app.user.update_password.error", nil, "").Wrap(err)
+	}
+
+	sessions, err := svc.sessionRepository.ListSessions(ctx, userId)
+	if err != nil {
+		return "", model.NewAppError("ChangePassword", "app.auth.list_sessions.error", nil, "").Wrap(err)
+	}
+	for _, session := range sessions {
+		if session.ID == sessionId {
+			continue
+		}
+		if err := svc.sessionRepository.RemoveSession(ctx, userId, session.ID); err != nil {
+			return "", model.NewAppError("ChangePassword", "app.auth.remove_session.error", nil, "").Wrap(err)
+		}
+	}
+	return "Ok", nil
+}
+
+// DeactivateAccount implements usecase.UserUseCase. The account is signed
+// out everywhere and is reactivated by signing in again.
+func (svc *UserService) DeactivateAccount(ctx context.Context, req *dto.ConfirmPasswordRequest) (string, error) {
+	userId := ctx.Value(constant.CtxUserKey).(uint64)
+	if _, err := svc.confirmPassword(ctx, userId, req.Password); err != nil {
+		return "", model.NewAppError("DeactivateAccount", "app.user.confirm_password.error", nil, "").Wrap(err)
+	}
+
+	if err := svc.userRepository.SetActive(ctx, userId, false); err != nil {
+		return "", model.NewAppError("DeactivateAccount", "app.user.set_active.error", nil, "").Wrap(err)
+	}
+	if err := svc.sessionRepository.RemoveSessions(ctx, userId); err != nil {
+		return "", model.NewAppError("DeactivateAccount", "app.auth.remove_sessions.error", nil, "").Wrap(err)
+	}
+	return "Ok", nil
+}
+
+// DeleteAccount implements usecase.UserUseCase. The deletion is recorded in
+// the user event outbox along with the delete and published from there, the
+// order and payment services anonymize the data they hold of the user.
+func (svc *UserService) DeleteAccount(ctx context.Context, req *dto.ConfirmPasswordRequest) (string, error) {
+	userId := ctx.Value(constant.CtxUserKey).(uint64)
+	user, err := svc.confirmPassword(ctx, userId, req.Password)
+	if err != nil {
+		return "", model.NewAppError("DeleteAccount", "app.user.confirm_password.error", nil, "").Wrap(err)
+	}
+
+	if err := svc.userRepository.DeleteUser(ctx, userId); err != nil {
+		return "", model.NewAppError("DeleteAccount", "app.user.delete.error", nil, "").Wrap(err)
+	}
+	if err := svc.sessionRepository.RemoveSessions(ctx, userId); err != nil {
+		svc.logger.WithError(err).Error("remove sessions")
+	}
+	if err := svc.loginAttemptRepository.RemoveLockout(ctx, normalizeEmail(user.Email)); err != nil {
+		svc.logger.WithError(err).Error("remove lockout")
+	}
+	return "Ok", nil
+}
+
+// confirmPassword makes sure the caller knows the password of the account
+func (svc *UserService) confirmPassword(ctx context.Context, userID uint64, password string) (*entity.User, error) {
+	user, err := svc.userRepository.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.ComparePassword([]byte(password), []byte(user.Password)); err != nil {
+		return nil, usecase.ErrIncorrectPassword
+	}
+	return user, nil
+}
+
+func newUserDTO(user *entity.User) *dto.User {
+	return &dto.User{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Active:      user.Active,
+		FirstName:   user.FirstName,
+		LastName:    user.LastName,
+		Email:       user.Email,
+		Address:     user.Address,
+		PhoneNumber: user.PhoneNumber,
+	}
+}
@@ -0,0 +1,16 @@
+package entity
+
+type AccountTokenPurpose string
+
+const (
+	AccountTokenEmailVerification AccountTokenPurpose = "email_verification"
+	AccountTokenPasswordReset     AccountTokenPurpose = "password_reset"
+	AccountTokenEmailChange       AccountTokenPurpose = "email_change"
+)
+
+// email change entity, the new email is switched to once the link sent to it
+// is followed
+type EmailChange struct {
+	UserID uint64 `json:"user_id"`
+	Email  string `json:"email"`
+}
@@ -0,0 +1,32 @@
+package entity
+
+import "time"
+
+// user identity entity, a user signed in through an external oidc provider
+type UserIdentity struct {
+	ID        uint64
+	UserID    uint64
+	Provider  string
+	Subject   string
+	Email     string
+	CreatedAt time.Time
+}
+
+// external identity entity, the verified id token claims of a provider
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+}
+
+// oidc state entity, kept from redirecting to the provider until its callback
+type OIDCState struct {
+	ID           string    `json:"id"`
+	Provider     string    `json:"provider"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"code_verifier"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
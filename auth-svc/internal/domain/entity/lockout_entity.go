@@ -0,0 +1,15 @@
+package entity
+
+import "time"
+
+// account lockout entity, Level counts the lockouts so far and is kept after
+// the lock expires so repeated lockouts last longer
+type Lockout struct {
+	Email       string    `json:"email"`
+	Level       int       `json:"level"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+func (l *Lockout) Locked(now time.Time) bool {
+	return now.Before(l.LockedUntil)
+}
@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+// user mfa entity, the TOTP secret is encrypted
+type UserMFA struct {
+	UserID          uint64
+	EncryptedSecret string
+	Enabled         bool
+	LastUsedStep    int64
+	ConfirmedAt     *time.Time
+}
+
+// mfa challenge entity, issued by sign in until the second factor is verified
+type MFAChallenge struct {
+	ID        string    `json:"id"`
+	UserID    uint64    `json:"user_id"`
+	UserAgent string    `json:"user_agent"`
+	IPAddress string    `json:"ip_address"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,8 @@
+package entity
+
+// notification entity, an email sent to a user
+type Notification struct {
+	To      string
+	Subject string
+	Body    string
+}
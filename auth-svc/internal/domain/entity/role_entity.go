@@ -0,0 +1,7 @@
+package entity
+
+// role entity
+type Role struct {
+	Name        string
+	Permissions []string
+}
@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+type SecurityEventType string
+
+const (
+	// SecurityEventRefreshTokenReuse a rotated out refresh token was presented again
+	SecurityEventRefreshTokenReuse SecurityEventType = "refresh_token_reuse"
+	// SecurityEventAccountLocked too many failed sign in attempts locked the account
+	SecurityEventAccountLocked SecurityEventType = "account_locked"
+)
+
+// security event entity
+type SecurityEvent struct {
+	Type       SecurityEventType
+	UserID     uint64
+	SessionID  string
+	IPAddress  string
+	UserAgent  string
+	OccurredAt time.Time
+}
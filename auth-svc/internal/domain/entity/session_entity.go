@@ -0,0 +1,15 @@
+package entity
+
+import "time"
+
+// session entity, one per signed in device. The session is also the
+// family of its refresh tokens, only the latest one may be used.
+type Session struct {
+	ID             string    `json:"id"`
+	UserID         uint64    `json:"user_id"`
+	RefreshTokenID string    `json:"refresh_token_id"`
+	UserAgent      string    `json:"user_agent"`
+	IPAddress      string    `json:"ip_address"`
+	CreatedAt      time.Time `json:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// UserEventDeleted is the type of the user deleted event
+const UserEventDeleted = "user_deleted"
+
+// user deleted event entity, ID identifies the event to its consumers
+type UserDeletedEvent struct {
+	ID        string
+	UserID    uint64
+	DeletedAt time.Time
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+)
+
+// account token repository, tokens are single use
+type AccountTokenRepository interface {
+	StoreAccountToken(context.Context, entity.AccountTokenPurpose, string, uint64, int) error
+	ConsumeAccountToken(context.Context, entity.AccountTokenPurpose, string) (uint64, error)
+	StoreEmailChange(context.Context, string, *entity.EmailChange, int) error
+	ConsumeEmailChange(context.Context, string) (*entity.EmailChange, error)
+}
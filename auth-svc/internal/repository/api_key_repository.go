@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+)
+
+// api key repository
+type APIKeyRepository interface {
+	CreateAPIKey(context.Context, *entity.APIKey) (*entity.APIKey, error)
+	ListAPIKeys(context.Context, uint64) ([]*entity.APIKey, error)
+	GetAPIKeyByHash(context.Context, string) (*entity.APIKey, error)
+	DeleteAPIKey(context.Context, uint64, uint64) error
+	TouchAPIKey(context.Context, uint64, time.Time) error
+}
@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+)
+
+// identity repository
+type IdentityRepository interface {
+	CreateIdentity(context.Context, *entity.UserIdentity) (*entity.UserIdentity, error)
+	GetIdentity(ctx context.Context, provider, subject string) (*entity.UserIdentity, error)
+	ListIdentities(context.Context, uint64) ([]*entity.UserIdentity, error)
+}
+
+// oidc state repository, a state can only be consumed once
+type OIDCStateRepository interface {
+	StoreState(context.Context, *entity.OIDCState) error
+	ConsumeState(context.Context, string) (*entity.OIDCState, error)
+}
+
+// IdentityProvider is an external oidc provider users can sign in with
+type IdentityProvider interface {
+	Name() string
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*entity.ExternalIdentity, error)
+}
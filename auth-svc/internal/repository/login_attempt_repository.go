@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+)
+
+// login attempt repository, attempts are counted in a sliding window of seconds
+type LoginAttemptRepository interface {
+	RecordIPAttempt(context.Context, string, int) (int64, error)
+	RecordFailure(context.Context, string, int) (int64, error)
+	CountFailures(context.Context, string, int) (int64, error)
+	ResetFailures(context.Context, string) error
+	GetLockout(context.Context, string) (*entity.Lockout, error)
+	StoreLockout(context.Context, *entity.Lockout, int) error
+	RemoveLockout(context.Context, string) error
+}
@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+)
+
+// mfa repository
+type MFARepository interface {
+	GetUserMFA(context.Context, uint64) (*entity.UserMFA, error)
+	SaveUserMFA(context.Context, *entity.UserMFA) error
+	EnableUserMFA(context.Context, uint64, int64, []string) error
+	DeleteUserMFA(context.Context, uint64) error
+	UseTimeStep(context.Context, uint64, int64) (bool, error)
+	UseRecoveryCode(context.Context, uint64, string) (bool, error)
+}
+
+// mfa challenge repository
+type MFAChallengeRepository interface {
+	StoreChallenge(context.Context, *entity.MFAChallenge) error
+	GetChallenge(context.Context, string) (*entity.MFAChallenge, error)
+	RemoveChallenge(context.Context, string) error
+	// CountAttempt atomically counts a code attempt on the challenge and
+	// returns the number of attempts including this one
+	CountAttempt(context.Context, *entity.MFAChallenge) (int, error)
+}
@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+)
+
+// Notifier delivers notifications to users
+type Notifier interface {
+	Notify(context.Context, *entity.Notification) error
+}
@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+)
+
+// role repository
+type RoleRepository interface {
+	GetUserRoles(context.Context, uint64) ([]*entity.Role, error)
+	SetUserRoles(context.Context, uint64, []string) error
+}
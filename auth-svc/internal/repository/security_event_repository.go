@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+)
+
+// security event repository
+type SecurityEventRepository interface {
+	PublishSecurityEvent(context.Context, *entity.SecurityEvent) error
+}
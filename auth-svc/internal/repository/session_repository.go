@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+)
+
+// session repository
+type SessionRepository interface {
+	CreateSession(context.Context, *entity.Session, int) error
+	GetSession(context.Context, string) (*entity.Session, error)
+	// RotateSession replaces the session only if it is still bound to the
+	// given refresh token id, it reports false when another request rotated it first
+	RotateSession(context.Context, *entity.Session, string, int) (bool, error)
+	ListSessions(context.Context, uint64) ([]*entity.Session, error)
+	RemoveSession(context.Context, uint64, string) error
+	RemoveSessions(context.Context, uint64) error
+}
@@ -0,0 +1,8 @@
+package repository
+
+import "github.com/Chengxufeng1994/go-saga-example/common/token"
+
+// signing key repository, it stores the keyring shared by every replica
+type SigningKeyRepository interface {
+	token.KeyStore
+}
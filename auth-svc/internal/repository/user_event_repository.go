@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/domain/entity"
+)
+
+// user event repository, publishes user lifecycle events to other services
+type UserEventRepository interface {
+	PublishUserDeleted(context.Context, *entity.UserDeletedEvent) error
+}
+
+// user event outbox repository, keeps the events recorded with a change
+// until they are published
+type UserEventOutboxRepository interface {
+	ListUserEvents(context.Context, int) ([]*entity.UserDeletedEvent, error)
+	RemoveUserEvent(context.Context, string) error
+}
@@ -11,5 +11,11 @@ type UserRepository interface {
 	WithTx(fn GormOption) error
 	CreateUser(context.Context, *entity.User) (*entity.User, error)
 	GetUserByID(context.Context, uint64) (*entity.User, error)
+	// GetUserByEmail ignores the case of the email
 	GetUserByEmail(context.Context, string) (*entity.User, error)
+	MarkEmailVerified(context.Context, uint64) error
+	UpdatePassword(context.Context, uint64, string) error
+	UpdateProfile(context.Context, *entity.User) (*entity.User, error)
+	SetActive(context.Context, uint64, bool) error
+	DeleteUser(context.Context, uint64) error
 }
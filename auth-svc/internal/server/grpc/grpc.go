@@ -2,6 +2,7 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"time"
@@ -23,15 +24,17 @@ type GrpcServer struct {
 	application     string
 	bootstrapConfig *bootstrap.BootstrapConfig
 	authService     usecase.AuthUseCase
+	apiKeyService   usecase.APIKeyUseCase
 	Srv             *grpc.Server
 	pb.UnimplementedAuthServiceServer
 }
 
-func New(bootstrapConfig *bootstrap.BootstrapConfig, authService usecase.AuthUseCase) *GrpcServer {
+func New(bootstrapConfig *bootstrap.BootstrapConfig, authService usecase.AuthUseCase, apiKeyService usecase.APIKeyUseCase) *GrpcServer {
 	grpcSrv := &GrpcServer{
 		application:     bootstrapConfig.Application,
 		bootstrapConfig: bootstrapConfig,
 		authService:     authService,
+		apiKeyService:   apiKeyService,
 	}
 
 	opts := []grpc.ServerOption{
@@ -67,7 +70,31 @@ func (s *GrpcServer) VerifyToken(ctx context.Context, req *pb.VerifyTokenRequest
 	}
 
 	return &pb.VerifyTokenResponse{
-		UserId: resp.UserId,
+		UserId:      resp.UserId,
+		SessionId:   resp.SessionId,
+		Roles:       resp.Roles,
+		Permissions: resp.Permissions,
+	}, nil
+}
+
+func (s *GrpcServer) VerifyAPIKey(ctx context.Context, req *pb.VerifyAPIKeyRequest) (*pb.VerifyTokenResponse, error) {
+	resp, err := s.apiKeyService.VerifyAPIKey(ctx, &dto.VerifyAPIKeyRequest{APIKey: req.ApiKey})
+	if err != nil {
+		// unknown, revoked and expired keys are the caller's fault, anything
+		// else is ours
+		if errors.Is(err, usecase.ErrInvalidAPIKey) {
+			return nil, status.Error(codes.Unauthenticated, usecase.ErrInvalidAPIKey.Error())
+		}
+		return nil, status.Errorf(
+			codes.Internal,
+			fmt.Sprintf("internal error: %v", err),
+		)
+	}
+
+	return &pb.VerifyTokenResponse{
+		UserId:      resp.UserId,
+		Roles:       resp.Roles,
+		Permissions: resp.Permissions,
 	}, nil
 }
 
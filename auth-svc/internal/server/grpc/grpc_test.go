@@ -0,0 +1,48 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/usecase"
+	"github.com/Chengxufeng1994/go-saga-example/common/model"
+	"github.com/Chengxufeng1994/go-saga-example/common/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeAPIKeyService fails every verification with err
+type fakeAPIKeyService struct {
+	usecase.APIKeyUseCase
+	err error
+}
+
+func (s *fakeAPIKeyService) VerifyAPIKey(context.Context, *dto.VerifyAPIKeyRequest) (*dto.VerifyTokenResponse, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &dto.VerifyTokenResponse{UserId: 1}, nil
+}
+
+func TestVerifyAPIKeyStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{
+		{"valid key", nil, codes.OK},
+		{"invalid key", model.NewAppError("VerifyAPIKey", "app.api_key.invalid.error", nil, "").Wrap(usecase.ErrInvalidAPIKey), codes.Unauthenticated},
+		{"database down", model.NewAppError("VerifyAPIKey", "app.api_key.get.error", nil, "").Wrap(errors.New("connection refused")), codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &GrpcServer{apiKeyService: &fakeAPIKeyService{err: tt.err}}
+			_, err := srv.VerifyAPIKey(context.Background(), &pb.VerifyAPIKeyRequest{ApiKey: "sk_key"})
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("VerifyAPIKey() code = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
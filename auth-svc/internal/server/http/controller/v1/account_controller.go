@@ -0,0 +1,116 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/usecase"
+	"github.com/Chengxufeng1994/go-saga-example/common/response"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+type AccountController struct {
+	logger         *log.Entry
+	accountService usecase.AccountUseCase
+}
+
+func NewAccountController(accountService usecase.AccountUseCase) *AccountController {
+	logger := config.ContextLogger.WithField("type", "controller:AccountController")
+
+	return &AccountController{
+		logger:         logger,
+		accountService: accountService,
+	}
+}
+
+func (ctrl *AccountController) SendEmailVerification(c *gin.Context) {
+	var req dto.EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.logger.WithError(err).Error("json marshal")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+
+	resp, err := ctrl.accountService.SendEmailVerification(c.Request.Context(), &req)
+	ctrl.respond(c, "SendEmailVerification", resp, err)
+}
+
+func (ctrl *AccountController) VerifyEmail(c *gin.Context) {
+	var req dto.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.logger.WithError(err).Error("json marshal")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+
+	resp, err := ctrl.accountService.VerifyEmail(c.Request.Context(), &req)
+	ctrl.respond(c, "VerifyEmail", resp, err)
+}
+
+func (ctrl *AccountController) ConfirmEmailChange(c *gin.Context) {
+	var req dto.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.logger.WithError(err).Error("json marshal")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+
+	resp, err := ctrl.accountService.ConfirmEmailChange(c.Request.Context(), &req)
+	ctrl.respond(c, "ConfirmEmailChange", resp, err)
+}
+
+func (ctrl *AccountController) RequestPasswordReset(c *gin.Context) {
+	var req dto.EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.logger.WithError(err).Error("json marshal")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+
+	resp, err := ctrl.accountService.RequestPasswordReset(c.Request.Context(), &req)
+	ctrl.respond(c, "RequestPasswordReset", resp, err)
+}
+
+func (ctrl *AccountController) ResetPassword(c *gin.Context) {
+	var req dto.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.logger.WithError(err).Error("json marshal")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+
+	resp, err := ctrl.accountService.ResetPassword(c.Request.Context(), &req)
+	ctrl.respond(c, "ResetPassword", resp, err)
+}
+
+func (ctrl *AccountController) respond(c *gin.Context, where string, resp string, err error) {
+	if err != nil {
+		ctrl.logger.WithError(err).Error(where)
+		code := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, usecase.ErrInvalidAccountToken):
+			code = http.StatusBadRequest
+		case errors.Is(err, usecase.ErrProfileConflict):
+			code = http.StatusConflict
+		}
+		c.AbortWithStatusJSON(code,
+			response.ErrorResponse{
+				BaseResponse: &response.BaseResponse{
+					Code:    code,
+					Message: err.Error(),
+				},
+				Detail: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK,
+		response.SuccessResponse{
+			BaseResponse: &response.BaseResponse{
+				Code:    http.StatusOK,
+				Message: "success",
+			},
+			Data: resp})
+}
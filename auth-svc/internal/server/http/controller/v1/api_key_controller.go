@@ -0,0 +1,86 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/usecase"
+	"github.com/Chengxufeng1994/go-saga-example/common/response"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+type APIKeyController struct {
+	logger        *log.Entry
+	apiKeyService usecase.APIKeyUseCase
+}
+
+func NewAPIKeyController(apiKeyService usecase.APIKeyUseCase) *APIKeyController {
+	logger := config.ContextLogger.WithField("type", "controller:APIKeyController")
+
+	return &APIKeyController{
+		logger:        logger,
+		apiKeyService: apiKeyService,
+	}
+}
+
+func (ctrl *APIKeyController) CreateAPIKey(c *gin.Context) {
+	var req dto.APIKeyCreationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.logger.WithError(err).Error("json marshal")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+
+	resp, err := ctrl.apiKeyService.CreateAPIKey(c.Request.Context(), &req)
+	ctrl.respond(c, "CreateAPIKey", http.StatusCreated, resp, err)
+}
+
+func (ctrl *APIKeyController) ListAPIKeys(c *gin.Context) {
+	resp, err := ctrl.apiKeyService.ListAPIKeys(c.Request.Context())
+	ctrl.respond(c, "ListAPIKeys", http.StatusOK, resp, err)
+}
+
+func (ctrl *APIKeyController) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		ctrl.logger.WithError(err).Error("strconv parse uint")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+
+	resp, err := ctrl.apiKeyService.RevokeAPIKey(c.Request.Context(), id)
+	ctrl.respond(c, "RevokeAPIKey", http.StatusOK, resp, err)
+}
+
+func (ctrl *APIKeyController) respond(c *gin.Context, where string, status int, resp any, err error) {
+	if err != nil {
+		ctrl.logger.WithError(err).Error(where)
+		code := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, usecase.ErrScopeNotGranted):
+			code = http.StatusForbidden
+		case errors.Is(err, usecase.ErrAPIKeyNotFound):
+			code = http.StatusNotFound
+		}
+		c.AbortWithStatusJSON(code,
+			response.ErrorResponse{
+				BaseResponse: &response.BaseResponse{
+					Code:    code,
+					Message: err.Error(),
+				},
+				Detail: err.Error()})
+		return
+	}
+
+	c.JSON(status,
+		response.SuccessResponse{
+			BaseResponse: &response.BaseResponse{
+				Code:    status,
+				Message: "success",
+			},
+			Data: resp})
+}
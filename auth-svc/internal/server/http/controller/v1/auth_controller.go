@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
@@ -62,14 +63,23 @@ func (ctrl *AuthController) SignIn(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, err)
 		return
 	}
+	req.UserAgent = c.Request.UserAgent()
+	req.IPAddress = c.ClientIP()
 
 	resp, err := ctrl.authService.SignIn(c.Request.Context(), &req)
 	if err != nil {
 		ctrl.logger.WithError(err).Error("SignIn")
-		c.AbortWithStatusJSON(http.StatusBadRequest,
+		code := http.StatusBadRequest
+		switch {
+		case errors.Is(err, usecase.ErrInvalidCredentials):
+			code = http.StatusUnauthorized
+		case errors.Is(err, usecase.ErrTooManyAttempts):
+			code = http.StatusTooManyRequests
+		}
+		c.AbortWithStatusJSON(code,
 			response.ErrorResponse{
 				BaseResponse: &response.BaseResponse{
-					Code:    http.StatusBadRequest,
+					Code:    code,
 					Message: err.Error(),
 				},
 				Detail: err.Error()})
@@ -138,3 +148,103 @@ func (ctrl *AuthController) Refresh(c *gin.Context) {
 			},
 			Data: resp})
 }
+
+func (ctrl *AuthController) ListSessions(c *gin.Context) {
+	resp, err := ctrl.authService.ListSessions(c.Request.Context())
+	if err != nil {
+		ctrl.logger.WithError(err).Error("ListSessions")
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			response.ErrorResponse{
+				BaseResponse: &response.BaseResponse{
+					Code:    http.StatusBadRequest,
+					Message: err.Error(),
+				},
+				Detail: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK,
+		response.SuccessResponse{
+			BaseResponse: &response.BaseResponse{
+				Code:    http.StatusOK,
+				Message: "success",
+			},
+			Data: resp})
+}
+
+func (ctrl *AuthController) RevokeSession(c *gin.Context) {
+	resp, err := ctrl.authService.RevokeSession(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		ctrl.logger.WithError(err).Error("RevokeSession")
+		code := http.StatusBadRequest
+		if errors.Is(err, usecase.ErrSessionNotFound) {
+			code = http.StatusNotFound
+		}
+		c.AbortWithStatusJSON(code,
+			response.ErrorResponse{
+				BaseResponse: &response.BaseResponse{
+					Code:    code,
+					Message: err.Error(),
+				},
+				Detail: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK,
+		response.SuccessResponse{
+			BaseResponse: &response.BaseResponse{
+				Code:    http.StatusOK,
+				Message: "success",
+			},
+			Data: resp})
+}
+
+func (ctrl *AuthController) VerifyMFA(c *gin.Context) {
+	var req dto.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.logger.WithError(err).Error("json marshal")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+
+	resp, err := ctrl.authService.VerifyMFA(c.Request.Context(), &req)
+	if err != nil {
+		ctrl.logger.WithError(err).Error("VerifyMFA")
+		code := mfaErrorStatus(err)
+		c.AbortWithStatusJSON(code,
+			response.ErrorResponse{
+				BaseResponse: &response.BaseResponse{
+					Code:    code,
+					Message: err.Error(),
+				},
+				Detail: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK,
+		response.SuccessResponse{
+			BaseResponse: &response.BaseResponse{
+				Code:    http.StatusOK,
+				Message: "success",
+			},
+			Data: resp})
+}
+
+func (ctrl *AuthController) JWKS(c *gin.Context) {
+	resp, err := ctrl.authService.JWKS(c.Request.Context())
+	if err != nil {
+		ctrl.logger.WithError(err).Error("JWKS")
+		c.AbortWithStatusJSON(http.StatusInternalServerError,
+			response.ErrorResponse{
+				BaseResponse: &response.BaseResponse{
+					Code:    http.StatusInternalServerError,
+					Message: err.Error(),
+				},
+				Detail: err.Error()})
+		return
+	}
+
+	// the key set is served as is, verifiers expect the RFC 7517 format
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, resp)
+}
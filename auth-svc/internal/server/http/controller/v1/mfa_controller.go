@@ -0,0 +1,93 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/usecase"
+	"github.com/Chengxufeng1994/go-saga-example/common/response"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+type MFAController struct {
+	logger     *log.Entry
+	mfaService usecase.MFAUseCase
+}
+
+func NewMFAController(mfaService usecase.MFAUseCase) *MFAController {
+	logger := config.ContextLogger.WithField("type", "controller:MFAController")
+
+	return &MFAController{
+		logger:     logger,
+		mfaService: mfaService,
+	}
+}
+
+func (ctrl *MFAController) Enroll(c *gin.Context) {
+	resp, err := ctrl.mfaService.Enroll(c.Request.Context())
+	ctrl.respond(c, "Enroll", resp, err)
+}
+
+func (ctrl *MFAController) Confirm(c *gin.Context) {
+	var req dto.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.logger.WithError(err).Error("json marshal")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+
+	resp, err := ctrl.mfaService.Confirm(c.Request.Context(), &req)
+	ctrl.respond(c, "Confirm", resp, err)
+}
+
+func (ctrl *MFAController) Disable(c *gin.Context) {
+	var req dto.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.logger.WithError(err).Error("json marshal")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+
+	resp, err := ctrl.mfaService.Disable(c.Request.Context(), &req)
+	ctrl.respond(c, "Disable", resp, err)
+}
+
+func (ctrl *MFAController) respond(c *gin.Context, where string, resp any, err error) {
+	if err != nil {
+		ctrl.logger.WithError(err).Error(where)
+		code := mfaErrorStatus(err)
+		c.AbortWithStatusJSON(code,
+			response.ErrorResponse{
+				BaseResponse: &response.BaseResponse{
+					Code:    code,
+					Message: err.Error(),
+				},
+				Detail: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK,
+		response.SuccessResponse{
+			BaseResponse: &response.BaseResponse{
+				Code:    http.StatusOK,
+				Message: "success",
+			},
+			Data: resp})
+}
+
+// mfaErrorStatus maps mfa errors to their http status code
+func mfaErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrInvalidMFACode), errors.Is(err, usecase.ErrInvalidMFAChallenge):
+		return http.StatusUnauthorized
+	case errors.Is(err, usecase.ErrMFAAlreadyEnabled):
+		return http.StatusConflict
+	case errors.Is(err, usecase.ErrMFANotEnrolled):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
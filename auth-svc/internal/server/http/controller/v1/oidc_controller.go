@@ -0,0 +1,116 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/usecase"
+	"github.com/Chengxufeng1994/go-saga-example/common/response"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// oidcStateCookie binds a started sign in to the browser, it is only sent to
+// the oidc endpoints
+const (
+	oidcStateCookie     = "oidc_state"
+	oidcStateCookiePath = "/api/v1/auth/oidc"
+)
+
+type OIDCController struct {
+	logger      *log.Entry
+	authService usecase.AuthUseCase
+	oidcService usecase.OIDCUseCase
+}
+
+func NewOIDCController(authService usecase.AuthUseCase, oidcService usecase.OIDCUseCase) *OIDCController {
+	logger := config.ContextLogger.WithField("type", "controller:OIDCController")
+
+	return &OIDCController{
+		logger:      logger,
+		authService: authService,
+		oidcService: oidcService,
+	}
+}
+
+// Authorize redirects the browser to the sign in page of the provider
+func (ctrl *OIDCController) Authorize(c *gin.Context) {
+	authorization, err := ctrl.oidcService.Authorize(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		ctrl.respond(c, "Authorize", nil, err)
+		return
+	}
+
+	// lax, the provider redirects back with a top level navigation
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, authorization.StateCookie, authorization.ExpiresIn, oidcStateCookiePath, "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, authorization.URL)
+}
+
+// Callback is where the provider redirects back to, it responds with the
+// same token pair or mfa challenge as signing in with a password
+func (ctrl *OIDCController) Callback(c *gin.Context) {
+	var req dto.OIDCCallbackRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		ctrl.logger.WithError(err).Error("query bind")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+	req.Provider = c.Param("provider")
+	req.StateCookie, _ = c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, oidcStateCookiePath, "", c.Request.TLS != nil, true)
+	req.UserAgent = c.Request.UserAgent()
+	req.IPAddress = c.ClientIP()
+
+	resp, err := ctrl.authService.SignInWithOIDC(c.Request.Context(), &req)
+	ctrl.respond(c, "Callback", resp, err)
+}
+
+func (ctrl *OIDCController) ListIdentities(c *gin.Context) {
+	resp, err := ctrl.oidcService.ListIdentities(c.Request.Context())
+	ctrl.respond(c, "ListIdentities", resp, err)
+}
+
+func (ctrl *OIDCController) respond(c *gin.Context, where string, resp any, err error) {
+	if err != nil {
+		ctrl.logger.WithError(err).Error(where)
+		code := oidcErrorStatus(err)
+		c.AbortWithStatusJSON(code,
+			response.ErrorResponse{
+				BaseResponse: &response.BaseResponse{
+					Code:    code,
+					Message: err.Error(),
+				},
+				Detail: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK,
+		response.SuccessResponse{
+			BaseResponse: &response.BaseResponse{
+				Code:    http.StatusOK,
+				Message: "success",
+			},
+			Data: resp})
+}
+
+// oidcErrorStatus maps oidc sign in errors to their http status code
+func oidcErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecase.ErrUnknownProvider):
+		return http.StatusNotFound
+	case errors.Is(err, usecase.ErrInvalidOIDCState):
+		return http.StatusBadRequest
+	case errors.Is(err, usecase.ErrOIDCSignInFailed), errors.Is(err, usecase.ErrEmailNotVerified):
+		return http.StatusUnauthorized
+	case errors.Is(err, usecase.ErrIdentityConflict):
+		return http.StatusConflict
+	case errors.Is(err, usecase.ErrTooManyAttempts):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
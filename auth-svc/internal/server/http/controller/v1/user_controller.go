@@ -1,10 +1,12 @@
 package v1
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/usecase"
 	"github.com/Chengxufeng1994/go-saga-example/common/response"
 	"github.com/gin-gonic/gin"
@@ -56,3 +58,182 @@ func (ctrl *UserController) GetUserByID(c *gin.Context) {
 			},
 			Data: user})
 }
+
+func (ctrl *UserController) SetUserRoles(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		ctrl.logger.WithError(err).Error("strconv parse uint")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+
+	var req dto.UserRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.logger.WithError(err).Error("json marshal")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+
+	roles, err := ctrl.userService.SetUserRoles(c.Request.Context(), id, &req)
+	if err != nil {
+		ctrl.logger.WithError(err).Error("SetUserRoles")
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			response.ErrorResponse{
+				BaseResponse: &response.BaseResponse{
+					Code:    http.StatusBadRequest,
+					Message: err.Error(),
+				},
+				Detail: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK,
+		response.SuccessResponse{
+			BaseResponse: &response.BaseResponse{
+				Code:    http.StatusOK,
+				Message: "success",
+			},
+			Data: roles})
+}
+
+func (ctrl *UserController) GetUserLockout(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		ctrl.logger.WithError(err).Error("strconv parse uint")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+
+	lockout, err := ctrl.userService.GetUserLockout(c.Request.Context(), id)
+	if err != nil {
+		ctrl.logger.WithError(err).Error("GetUserLockout")
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			response.ErrorResponse{
+				BaseResponse: &response.BaseResponse{
+					Code:    http.StatusBadRequest,
+					Message: err.Error(),
+				},
+				Detail: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK,
+		response.SuccessResponse{
+			BaseResponse: &response.BaseResponse{
+				Code:    http.StatusOK,
+				Message: "success",
+			},
+			Data: lockout})
+}
+
+func (ctrl *UserController) UnlockUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		ctrl.logger.WithError(err).Error("strconv parse uint")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+
+	resp, err := ctrl.userService.UnlockUser(c.Request.Context(), id)
+	if err != nil {
+		ctrl.logger.WithError(err).Error("UnlockUser")
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			response.ErrorResponse{
+				BaseResponse: &response.BaseResponse{
+					Code:    http.StatusBadRequest,
+					Message: err.Error(),
+				},
+				Detail: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK,
+		response.SuccessResponse{
+			BaseResponse: &response.BaseResponse{
+				Code:    http.StatusOK,
+				Message: "success",
+			},
+			Data: resp})
+}
+
+func (ctrl *UserController) GetProfile(c *gin.Context) {
+	user, err := ctrl.userService.GetProfile(c.Request.Context())
+	ctrl.respond(c, "GetProfile", user, err)
+}
+
+func (ctrl *UserController) UpdateProfile(c *gin.Context) {
+	var req dto.UserProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.logger.WithError(err).Error("json marshal")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+
+	user, err := ctrl.userService.UpdateProfile(c.Request.Context(), &req)
+	ctrl.respond(c, "UpdateProfile", user, err)
+}
+
+func (ctrl *UserController) ChangePassword(c *gin.Context) {
+	var req dto.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.logger.WithError(err).Error("json marshal")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+
+	resp, err := ctrl.userService.ChangePassword(c.Request.Context(), &req)
+	ctrl.respond(c, "ChangePassword", resp, err)
+}
+
+func (ctrl *UserController) DeactivateAccount(c *gin.Context) {
+	var req dto.ConfirmPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.logger.WithError(err).Error("json marshal")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+
+	resp, err := ctrl.userService.DeactivateAccount(c.Request.Context(), &req)
+	ctrl.respond(c, "DeactivateAccount", resp, err)
+}
+
+func (ctrl *UserController) DeleteAccount(c *gin.Context) {
+	var req dto.ConfirmPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctrl.logger.WithError(err).Error("json marshal")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+
+	resp, err := ctrl.userService.DeleteAccount(c.Request.Context(), &req)
+	ctrl.respond(c, "DeleteAccount", resp, err)
+}
+
+func (ctrl *UserController) respond(c *gin.Context, where string, resp any, err error) {
+	if err != nil {
+		ctrl.logger.WithError(err).Error(where)
+		code := http.StatusBadRequest
+		switch {
+		case errors.Is(err, usecase.ErrIncorrectPassword):
+			code = http.StatusForbidden
+		case errors.Is(err, usecase.ErrProfileConflict):
+			code = http.StatusConflict
+		}
+		c.AbortWithStatusJSON(code,
+			response.ErrorResponse{
+				BaseResponse: &response.BaseResponse{
+					Code:    code,
+					Message: err.Error(),
+				},
+				Detail: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK,
+		response.SuccessResponse{
+			BaseResponse: &response.BaseResponse{
+				Code:    http.StatusOK,
+				Message: "success",
+			},
+			Data: resp})
+}
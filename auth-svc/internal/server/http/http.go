@@ -18,6 +18,11 @@ import (
 
 func NewGinEngine(bootstrapCfg *bootstrap.BootstrapConfig) *gin.Engine {
 	engine := gin.New()
+	// the sign in rate limits count by client ip, so forwarded headers are
+	// only believed from the configured proxies
+	if err := engine.SetTrustedProxies(bootstrapCfg.HTTP.TrustedProxies); err != nil {
+		config.ContextLogger.WithError(err).Fatal("trusted proxies error")
+	}
 	engine.Use(gin.Logger())
 	engine.Use(gin.Recovery())
 	engine.Use(middleware.CORS())
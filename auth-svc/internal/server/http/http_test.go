@@ -0,0 +1,49 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/bootstrap"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestGinEngineClientIP(t *testing.T) {
+	logger := log.New()
+	logger.Out = io.Discard
+	config.ContextLogger = log.NewEntry(logger)
+	gin.SetMode(gin.TestMode)
+
+	engine := NewGinEngine(&bootstrap.BootstrapConfig{
+		Application: "auth_test",
+		HTTP:        bootstrap.HTTP{TrustedProxies: []string{"10.0.0.0/8"}},
+	})
+	engine.GET("/ip", func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       string
+	}{
+		{"forwarded by a trusted proxy", "10.1.2.3:1234", "203.0.113.7"},
+		{"forwarded header set by the client", "198.51.100.1:1234", "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", "203.0.113.7")
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, req)
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("ClientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
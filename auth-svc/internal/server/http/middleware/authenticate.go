@@ -53,7 +53,11 @@ func (authenticator *JwtAuthenticator) Auth() gin.HandlerFunc {
 			return
 		}
 
-		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), constant.CtxUserKey, verifyTokenResponse.UserId))
+		ctx := context.WithValue(c.Request.Context(), constant.CtxUserKey, verifyTokenResponse.UserId)
+		ctx = context.WithValue(ctx, constant.CtxSessionKey, verifyTokenResponse.SessionId)
+		ctx = context.WithValue(ctx, constant.CtxRolesKey, verifyTokenResponse.Roles)
+		ctx = context.WithValue(ctx, constant.CtxPermissionsKey, verifyTokenResponse.Permissions)
+		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 	}
 }
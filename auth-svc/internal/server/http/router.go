@@ -6,6 +6,7 @@ import (
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/application"
 	v1 "github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/server/http/controller/v1"
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/internal/server/http/middleware"
+	"github.com/Chengxufeng1994/go-saga-example/common/rbac"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -40,6 +41,14 @@ func (r *Router) RegisterRoutes() {
 
 	authController := v1.NewAuthController(r.app.AuthService)
 	userController := v1.NewUserController(r.app.UserService)
+	accountController := v1.NewAccountController(r.app.AccountService)
+	mfaController := v1.NewMFAController(r.app.MFAService)
+	apiKeyController := v1.NewAPIKeyController(r.app.APIKeyService)
+	oidcController := v1.NewOIDCController(r.app.AuthService, r.app.OIDCService)
+
+	// public keys for verifying the issued tokens
+	r.engine.GET("/.well-known/jwks.json", authController.JWKS)
+
 	v1Group := r.engine.Group("/api/v1")
 	authGroup := v1Group.Group("/auth")
 	{
@@ -47,11 +56,36 @@ func (r *Router) RegisterRoutes() {
 		authGroup.POST("/signin", authController.SignIn)
 		authGroup.POST("/signout", jwtAuthenticator.Auth(), authController.SignOut)
 		authGroup.POST("/refresh", authController.Refresh)
+		authGroup.GET("/sessions", jwtAuthenticator.Auth(), authController.ListSessions)
+		authGroup.DELETE("/sessions/:id", jwtAuthenticator.Auth(), authController.RevokeSession)
+		authGroup.POST("/verify-email/request", accountController.SendEmailVerification)
+		authGroup.POST("/verify-email", accountController.VerifyEmail)
+		authGroup.POST("/email/confirm", accountController.ConfirmEmailChange)
+		authGroup.POST("/password/forgot", accountController.RequestPasswordReset)
+		authGroup.POST("/password/reset", accountController.ResetPassword)
+		authGroup.POST("/mfa/enroll", jwtAuthenticator.Auth(), mfaController.Enroll)
+		authGroup.POST("/mfa/confirm", jwtAuthenticator.Auth(), mfaController.Confirm)
+		authGroup.POST("/mfa/disable", jwtAuthenticator.Auth(), mfaController.Disable)
+		authGroup.POST("/mfa/verify", authController.VerifyMFA)
+		authGroup.POST("/api-keys", jwtAuthenticator.Auth(), apiKeyController.CreateAPIKey)
+		authGroup.GET("/api-keys", jwtAuthenticator.Auth(), apiKeyController.ListAPIKeys)
+		authGroup.DELETE("/api-keys/:id", jwtAuthenticator.Auth(), apiKeyController.RevokeAPIKey)
+		authGroup.GET("/oidc/:provider/authorize", oidcController.Authorize)
+		authGroup.GET("/oidc/:provider/callback", oidcController.Callback)
+		authGroup.GET("/identities", jwtAuthenticator.Auth(), oidcController.ListIdentities)
 	}
 
 	userGroup := v1Group.Group("/user")
 	userGroup.Use(jwtAuthenticator.Auth())
 	{
-		userGroup.GET("/:id", userController.GetUserByID)
+		userGroup.GET("/me", userController.GetProfile)
+		userGroup.PATCH("/me", userController.UpdateProfile)
+		userGroup.PUT("/me/password", userController.ChangePassword)
+		userGroup.POST("/me/deactivate", userController.DeactivateAccount)
+		userGroup.DELETE("/me", userController.DeleteAccount)
+		userGroup.GET("/:id", rbac.RequirePermission(rbac.PermissionUserManage), userController.GetUserByID)
+		userGroup.PUT("/:id/roles", rbac.RequirePermission(rbac.PermissionUserManage), userController.SetUserRoles)
+		userGroup.GET("/:id/lockout", rbac.RequirePermission(rbac.PermissionUserManage), userController.GetUserLockout)
+		userGroup.DELETE("/:id/lockout", rbac.RequirePermission(rbac.PermissionUserManage), userController.UnlockUser)
 	}
 }
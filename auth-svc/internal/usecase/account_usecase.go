@@ -0,0 +1,17 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+)
+
+// AccountUseCase defines email verification, email change and password reset interface
+type AccountUseCase interface {
+	SendEmailVerification(context.Context, *dto.EmailRequest) (string, error)
+	VerifyEmail(context.Context, *dto.VerifyEmailRequest) (string, error)
+	RequestEmailChange(context.Context, *dto.EmailRequest) (string, error)
+	ConfirmEmailChange(context.Context, *dto.VerifyEmailRequest) (string, error)
+	RequestPasswordReset(context.Context, *dto.EmailRequest) (string, error)
+	ResetPassword(context.Context, *dto.ResetPasswordRequest) (string, error)
+}
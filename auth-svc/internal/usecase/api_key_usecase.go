@@ -0,0 +1,15 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+)
+
+// APIKeyUseCase defines api key management and verification interface
+type APIKeyUseCase interface {
+	CreateAPIKey(context.Context, *dto.APIKeyCreationRequest) (*dto.APIKeyCreated, error)
+	ListAPIKeys(context.Context) ([]*dto.APIKey, error)
+	RevokeAPIKey(context.Context, uint64) (string, error)
+	VerifyAPIKey(context.Context, *dto.VerifyAPIKeyRequest) (*dto.VerifyTokenResponse, error)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/common/token"
 )
 
 // UserUseCase defines user data related interface
@@ -13,4 +14,9 @@ type AuthUseCase interface {
 	SignOut(context.Context) (string, error)
 	VerifyToken(context.Context, *dto.VerifyTokenRequest) (*dto.VerifyTokenResponse, error)
 	RefreshToken(context.Context, *dto.RefreshTokenRequest) (*dto.RefreshTokenResponse, error)
+	ListSessions(context.Context) ([]*dto.Session, error)
+	RevokeSession(context.Context, string) (string, error)
+	VerifyMFA(context.Context, *dto.MFAVerifyRequest) (*dto.LoginResponse, error)
+	SignInWithOIDC(context.Context, *dto.OIDCCallbackRequest) (*dto.LoginResponse, error)
+	JWKS(context.Context) (*token.JWKS, error)
 }
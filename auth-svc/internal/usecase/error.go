@@ -1,9 +1,36 @@
 package usecase
 
-import "strings"
+import (
+	"errors"
+	"strings"
+)
 
 const maxErrorLength = 1024
 
+var (
+	ErrSessionRevoked      = errors.New("session has been revoked")
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrInvalidTokenType    = errors.New("invalid token type")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+	ErrInvalidAccountToken = errors.New("invalid or expired token")
+	ErrEmailNotVerified    = errors.New("email has not been verified")
+	ErrMFAAlreadyEnabled   = errors.New("mfa is already enabled")
+	ErrMFANotEnrolled      = errors.New("mfa is not enrolled")
+	ErrInvalidMFACode      = errors.New("invalid mfa code")
+	ErrInvalidMFAChallenge = errors.New("invalid or expired mfa challenge")
+	ErrInvalidCredentials  = errors.New("invalid email or password")
+	ErrTooManyAttempts     = errors.New("too many sign in attempts, try again later")
+	ErrProfileConflict     = errors.New("email or phone number is already in use")
+	ErrIncorrectPassword   = errors.New("incorrect password")
+	ErrInvalidAPIKey       = errors.New("invalid or expired api key")
+	ErrAPIKeyNotFound      = errors.New("api key not found")
+	ErrScopeNotGranted     = errors.New("scope is not granted to the user")
+	ErrUnknownProvider     = errors.New("unknown identity provider")
+	ErrInvalidOIDCState    = errors.New("invalid or expired oidc state")
+	ErrOIDCSignInFailed    = errors.New("sign in with the identity provider failed")
+	ErrIdentityConflict    = errors.New("an account with this email already exists")
+)
+
 type AppError struct {
 	Id            string `json:"id"`
 	Message       string `json:"message"`              // Message to be display to the end user without debugging information
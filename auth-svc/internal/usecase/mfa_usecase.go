@@ -0,0 +1,16 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+)
+
+// MFAUseCase defines totp multi-factor authentication interface
+type MFAUseCase interface {
+	Enroll(context.Context) (*dto.MFAEnrollResponse, error)
+	Confirm(context.Context, *dto.MFACodeRequest) (*dto.MFAConfirmResponse, error)
+	Disable(context.Context, *dto.MFACodeRequest) (string, error)
+	IsEnabled(context.Context, uint64) (bool, error)
+	VerifyCode(context.Context, uint64, string) error
+}
@@ -0,0 +1,14 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+)
+
+// OIDCUseCase defines sign in through external oidc providers interface
+type OIDCUseCase interface {
+	Authorize(ctx context.Context, provider string) (*dto.OIDCAuthorization, error)
+	ResolveUser(context.Context, *dto.OIDCCallbackRequest) (uint64, error)
+	ListIdentities(context.Context) ([]*dto.Identity, error)
+}
@@ -9,4 +9,12 @@ import (
 // UserUseCase defines user data related interface
 type UserUseCase interface {
 	GetUserByID(context.Context, uint64) (*dto.User, error)
+	SetUserRoles(context.Context, uint64, *dto.UserRolesRequest) (*dto.UserRoles, error)
+	GetUserLockout(context.Context, uint64) (*dto.UserLockout, error)
+	UnlockUser(context.Context, uint64) (string, error)
+	GetProfile(context.Context) (*dto.User, error)
+	UpdateProfile(context.Context, *dto.UserProfileRequest) (*dto.User, error)
+	ChangePassword(context.Context, *dto.ChangePasswordRequest) (string, error)
+	DeactivateAccount(context.Context, *dto.ConfirmPasswordRequest) (string, error)
+	DeleteAccount(context.Context, *dto.ConfirmPasswordRequest) (string, error)
 }
@@ -5,6 +5,10 @@ import (
 	"github.com/spf13/viper"
 )
 
+// EnvironmentDevelopment is the environment of a local development setup,
+// dev-only adapters such as the payment simulator refuse any other
+const EnvironmentDevelopment = "development"
+
 type BootstrapConfig struct {
 	Application string `mapstructure:"application"`
 	Environment string `mapstructure:"environment"`
@@ -19,6 +23,9 @@ type HTTP struct {
 	ReadTimeout  string `mapstructure:"read_timeout"`
 	WriteTimeout string `mapstructure:"write_timeout"`
 	IdleTimeout  string `mapstructure:"idle_timeout"`
+	// TrustedProxies are the CIDRs whose X-Forwarded-For header is believed,
+	// none by default so the client ip is the remote address
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 }
 
 type Grpc struct {
@@ -0,0 +1,294 @@
+// Package bus publishes and subscribes typed protobuf messages on watermill.
+// It takes care of what every handler used to repeat: the codec, the
+// correlation id, the trace context and the handler header of replies.
+package bus
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/codec"
+	"github.com/Chengxufeng1994/go-saga-example/common/constant"
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+	"go.opentelemetry.io/otel"
+	"google.golang.org/protobuf/proto"
+)
+
+const tracerName = "bus"
+
+type correlationIDKey struct{}
+
+// Bus sends the messages of a router
+type Bus struct {
+	router            *message.Router
+	publisher         message.Publisher
+	subscriber        message.Subscriber
+	handlerSubscriber func(handler string) message.Subscriber
+	topicPublishers   map[string]message.Publisher
+	codec             codec.Codec
+}
+
+// New returns the bus of router, the published messages are encoded with c.
+// A bus which only publishes has neither router nor subscriber.
+func New(router *message.Router, publisher message.Publisher, subscriber message.Subscriber, c codec.Codec) *Bus {
+	return &Bus{
+		router:     router,
+		publisher:  publisher,
+		subscriber: subscriber,
+		codec:      c,
+	}
+}
+
+// SetHandlerSubscriber lets handlers consume from a subscriber of their own,
+// handlers f returns nil for use the subscriber of the bus
+func (b *Bus) SetHandlerSubscriber(f func(handler string) message.Subscriber) {
+	b.handlerSubscriber = f
+}
+
+// SetTopicPublisher publishes the events of topic which reacting handlers
+// return with publisher, for topics of another broker backend
+func (b *Bus) SetTopicPublisher(topic string, publisher message.Publisher) {
+	if b.topicPublishers == nil {
+		b.topicPublishers = map[string]message.Publisher{}
+	}
+	b.topicPublishers[topic] = publisher
+}
+
+// subscriberOf returns the subscriber of a handler
+func (b *Bus) subscriberOf(handler string) message.Subscriber {
+	if b.handlerSubscriber != nil {
+		if sub := b.handlerSubscriber(handler); sub != nil {
+			return sub
+		}
+	}
+	return b.subscriber
+}
+
+// Router returns the router the handlers are added to
+func (b *Bus) Router() *message.Router {
+	return b.router
+}
+
+type publishOptions struct {
+	uuid          string
+	correlationID string
+	handler       string
+	metadata      map[string]string
+}
+
+type PublishOption func(*publishOptions)
+
+// WithUUID sets the uuid of the message, which consumers deduplicate by
+func WithUUID(uuid string) PublishOption {
+	return func(o *publishOptions) {
+		o.uuid = uuid
+	}
+}
+
+// WithCorrelationID overrides the correlation id taken from the context
+func WithCorrelationID(correlationID string) PublishOption {
+	return func(o *publishOptions) {
+		o.correlationID = correlationID
+	}
+}
+
+// WithHandler sets the handler header, which tells replies apart
+func WithHandler(handler string) PublishOption {
+	return func(o *publishOptions) {
+		o.handler = handler
+	}
+}
+
+// WithMetadata sets an additional metadata entry
+func WithMetadata(key, value string) PublishOption {
+	return func(o *publishOptions) {
+		if o.metadata == nil {
+			o.metadata = map[string]string{}
+		}
+		o.metadata[key] = value
+	}
+}
+
+// ContextWithCorrelationID returns a copy of ctx carrying the correlation id
+func ContextWithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, correlationID)
+}
+
+// CorrelationID returns the correlation id of the message being handled
+func CorrelationID(ctx context.Context) string {
+	correlationID, _ := ctx.Value(correlationIDKey{}).(string)
+	return correlationID
+}
+
+// NewMessage encodes m into a message carrying the correlation id and span
+// context of ctx. A message without correlation id starts a new one.
+func NewMessage[T proto.Message](ctx context.Context, b *Bus, m T, opts ...PublishOption) (*message.Message, error) {
+	o := publishOptions{correlationID: CorrelationID(ctx)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.uuid == "" {
+		o.uuid = watermill.NewUUID()
+	}
+	msg := message.NewMessage(o.uuid, nil)
+	payload, err := codec.Encode(b.codec, m, msg.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	msg.Payload = payload
+
+	if o.correlationID == "" {
+		o.correlationID = watermill.NewUUID()
+	}
+	middleware.SetCorrelationID(o.correlationID, msg)
+	if o.handler != "" {
+		msg.Metadata.Set(constant.HandlerHeader, o.handler)
+	}
+	for key, value := range o.metadata {
+		msg.Metadata.Set(key, value)
+	}
+	SetSpanContext(ctx, msg)
+	return msg, nil
+}
+
+// Publish publishes m to topic
+func Publish[T proto.Message](ctx context.Context, b *Bus, topic string, m T, opts ...PublishOption) error {
+	msg, err := NewMessage(ctx, b, m, opts...)
+	if err != nil {
+		return err
+	}
+	return b.publisher.Publish(topic, msg)
+}
+
+// Decode decodes the payload of msg into m, whichever codec and schema
+// version it was published with
+func Decode(msg *message.Message, m proto.Message) error {
+	return codec.Decode(msg.Payload, msg.Metadata, m)
+}
+
+// Subscribe handles the messages of topic as T
+func Subscribe[T proto.Message](b *Bus, name, topic string, handle func(ctx context.Context, m T) error) {
+	SubscribeMessage(b, name, topic, func(ctx context.Context, msg *message.Message) error {
+		m := newMessage[T]()
+		if err := Decode(msg, m); err != nil {
+			return err
+		}
+		return handle(ctx, m)
+	})
+}
+
+// SubscribeMessage handles the raw messages of topic, for topics which carry
+// several message types told apart by their metadata
+func SubscribeMessage(b *Bus, name, topic string, handle func(ctx context.Context, msg *message.Message) error) {
+	b.router.AddNoPublisherHandler(name, topic, b.subscriberOf(name), func(msg *message.Message) error {
+		ctx, end := handlerContext(name, msg)
+		defer end()
+		return handle(ctx, msg)
+	})
+}
+
+// Handle handles the messages of topic as T and publishes the reply to
+// replyTopic under the handler header replyHandler, with the partition keys
+// of the message
+func Handle[T, R proto.Message](b *Bus, name, topic, replyTopic, replyHandler string, handle func(ctx context.Context, m T) (R, error)) {
+	b.router.AddHandler(name, topic, b.subscriberOf(name), replyTopic, b.publisher, func(msg *message.Message) ([]*message.Message, error) {
+		ctx, end := handlerContext(name, msg)
+		defer end()
+
+		m := newMessage[T]()
+		if err := Decode(msg, m); err != nil {
+			return nil, err
+		}
+		reply, err := handle(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+
+		replyMsg, err := NewMessage(ctx, b, reply, WithHandler(replyHandler))
+		if err != nil {
+			return nil, err
+		}
+		copyPartitionKeys(msg, replyMsg)
+		return []*message.Message{replyMsg}, nil
+	})
+}
+
+// Event is a message a reacting handler publishes to Topic
+type Event struct {
+	Topic   string
+	Message proto.Message
+	Options []PublishOption
+}
+
+// React handles the messages of topic as T and publishes the events the
+// handler returns, with the partition keys of the message unless an option
+// sets them. The events are the output of the handler, the deduplication
+// stores them and a redelivered message publishes them again under the same
+// uuid. The participants of a choreographed saga react to each other this
+// way.
+func React[T proto.Message](b *Bus, name, topic string, handle func(ctx context.Context, m T) ([]Event, error)) {
+	b.router.AddHandler(name, topic, b.subscriberOf(name), "", eventPublisher{b}, func(msg *message.Message) ([]*message.Message, error) {
+		ctx, end := handlerContext(name, msg)
+		defer end()
+
+		m := newMessage[T]()
+		if err := Decode(msg, m); err != nil {
+			return nil, err
+		}
+		events, err := handle(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+
+		out := make([]*message.Message, 0, len(events))
+		for _, evt := range events {
+			opts := append([]PublishOption{WithMetadata(constant.EventTopicHeader, evt.Topic)}, evt.Options...)
+			eventMsg, err := NewMessage(ctx, b, evt.Message, opts...)
+			if err != nil {
+				return nil, err
+			}
+			copyPartitionKeys(msg, eventMsg)
+			out = append(out, eventMsg)
+		}
+		return out, nil
+	})
+}
+
+// eventPublisher publishes the events of reacting handlers to the topic
+// they carry, in order
+type eventPublisher struct {
+	b *Bus
+}
+
+func (p eventPublisher) Publish(_ string, messages ...*message.Message) error {
+	for _, msg := range messages {
+		topic := msg.Metadata.Get(constant.EventTopicHeader)
+		publisher, ok := p.b.topicPublishers[topic]
+		if !ok {
+			publisher = p.b.publisher
+		}
+		if err := publisher.Publish(topic, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p eventPublisher) Close() error {
+	return p.b.publisher.Close()
+}
+
+// handlerContext continues the trace of msg in a span of the handler
+func handlerContext(name string, msg *message.Message) (context.Context, func()) {
+	ctx := ExtractSpanContext(msg.Context(), msg)
+	ctx = ContextWithCorrelationID(ctx, middleware.MessageCorrelationID(msg))
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "event."+name)
+	return ctx, func() { span.End() }
+}
+
+func newMessage[T proto.Message]() T {
+	var zero T
+	return zero.ProtoReflect().New().Interface().(T)
+}
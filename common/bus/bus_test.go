@@ -0,0 +1,82 @@
+package bus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/codec"
+	"github.com/Chengxufeng1994/go-saga-example/common/constant"
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// TestReact delivers a message twice to a deduplicated reacting handler, the
+// redelivery publishes the events of the first delivery to their topics
+func TestReact(t *testing.T) {
+	pubSub := &queuePubSub{queues: map[string][]*message.Message{}}
+	results := &queuePubSub{queues: map[string][]*message.Message{}}
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router.AddMiddleware(Deduplicate(newMemDedupStore(), watermill.NopLogger{}))
+	b := New(router, pubSub, pubSub, codec.JSON)
+	b.SetTopicPublisher("results", results)
+
+	runs := 0
+	React(b, "react", "in", func(ctx context.Context, m *wrapperspb.StringValue) ([]Event, error) {
+		runs++
+		return []Event{
+			{Topic: "results", Message: wrapperspb.String(m.Value + " result")},
+			{Topic: "next", Message: wrapperspb.String(m.Value + " next"), Options: []PublishOption{WithPartitionKeys(7, 1)}},
+		}, nil
+	})
+
+	var deliveries []*message.Message
+	for i := 0; i < 2; i++ {
+		msg, err := NewMessage(context.Background(), b, wrapperspb.String("reserved"), WithUUID("m1"), WithMetadata(constant.UserIDHeader, "3"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubSub.queues["in"] = append(pubSub.queues["in"], msg)
+		deliveries = append(deliveries, msg)
+	}
+
+	go router.Run(context.Background())
+	<-router.Running()
+	select {
+	case <-deliveries[1].Acked():
+	case <-time.After(5 * time.Second):
+		t.Fatal("redelivery not acked")
+	}
+	router.Close()
+
+	if runs != 1 {
+		t.Errorf("handler runs = %d, want 1", runs)
+	}
+	tests := []struct {
+		topic     string
+		published []*message.Message
+		want      string
+		userID    string
+	}{
+		{"results", results.queues["results"], "reserved result", "3"},
+		{"next", pubSub.queues["next"], "reserved next", "7"},
+	}
+	for _, tt := range tests {
+		if len(tt.published) != 2 || tt.published[0].UUID != tt.published[1].UUID {
+			t.Errorf("%s got %v, want the same event twice", tt.topic, tt.published)
+			continue
+		}
+		got := &wrapperspb.StringValue{}
+		if err := Decode(tt.published[0], got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Value != tt.want || tt.published[0].Metadata.Get(constant.UserIDHeader) != tt.userID {
+			t.Errorf("%s got %q of user %s, want %q of user %s", tt.topic, got.Value,
+				tt.published[0].Metadata.Get(constant.UserIDHeader), tt.want, tt.userID)
+		}
+	}
+}
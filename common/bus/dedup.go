@@ -0,0 +1,105 @@
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// ErrMessageInProgress is returned for a message another delivery is still
+// handling, the message is nacked and redelivered later
+var ErrMessageInProgress = errors.New("message is being processed")
+
+// DedupStore remembers the messages a handler processed and the replies it
+// produced for them. The claim is not part of the transaction of the
+// handler: a handler whose work committed but whose message could not be
+// completed runs again once the claim timed out, so handlers stay idempotent
+// on their business keys.
+type DedupStore interface {
+	// Claim claims the message for the handler. A message processed before
+	// is not claimed, its replies are returned instead.
+	Claim(ctx context.Context, handler, uuid string) (replies []*message.Message, claimed bool, err error)
+	// Complete records the replies of a claimed message
+	Complete(ctx context.Context, handler, uuid string, replies []*message.Message) error
+	// Release drops the claim of a message whose handler failed, so the
+	// redelivery handles it again
+	Release(ctx context.Context, handler, uuid string) error
+}
+
+// Deduplicate handles every message once per handler. A redelivered message
+// is acked with the replies of the first delivery, which keep their uuid so
+// the consumers of the replies drop them in turn.
+func Deduplicate(store DedupStore, logger watermill.LoggerAdapter) message.HandlerMiddleware {
+	return func(h message.HandlerFunc) message.HandlerFunc {
+		return func(msg *message.Message) ([]*message.Message, error) {
+			ctx := msg.Context()
+			handler := message.HandlerNameFromCtx(ctx)
+			fields := watermill.LogFields{"handler": handler, "message_uuid": msg.UUID}
+
+			replies, claimed, err := store.Claim(ctx, handler, msg.UUID)
+			if err != nil {
+				return nil, err
+			}
+			if !claimed {
+				logger.Info("Duplicate message, replaying the replies", fields)
+				return replies, nil
+			}
+
+			replies, err = h(msg)
+			// the timeout middleware cancels the context of a slow handler,
+			// the claim is settled regardless
+			ctx = context.WithoutCancel(ctx)
+			if err != nil {
+				if releaseErr := store.Release(ctx, handler, msg.UUID); releaseErr != nil {
+					logger.Error("Cannot release message", releaseErr, fields)
+				}
+				return nil, err
+			}
+			// the work is done, failing now would only run it again once the
+			// claim timed out
+			if err := store.Complete(ctx, handler, msg.UUID, replies); err != nil {
+				logger.Error("Cannot complete message", err, fields)
+			}
+			return replies, nil
+		}
+	}
+}
+
+type storedMessage struct {
+	UUID     string            `json:"uuid"`
+	Metadata map[string]string `json:"metadata"`
+	Payload  []byte            `json:"payload"`
+}
+
+// EncodeReplies encodes replies for a DedupStore
+func EncodeReplies(replies []*message.Message) ([]byte, error) {
+	stored := make([]storedMessage, 0, len(replies))
+	for _, reply := range replies {
+		stored = append(stored, storedMessage{
+			UUID:     reply.UUID,
+			Metadata: reply.Metadata,
+			Payload:  reply.Payload,
+		})
+	}
+	return json.Marshal(stored)
+}
+
+// DecodeReplies decodes the replies encoded by EncodeReplies
+func DecodeReplies(data []byte) ([]*message.Message, error) {
+	var stored []storedMessage
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	replies := make([]*message.Message, 0, len(stored))
+	for _, s := range stored {
+		reply := message.NewMessage(s.UUID, s.Payload)
+		for key, value := range s.Metadata {
+			reply.Metadata.Set(key, value)
+		}
+		replies = append(replies, reply)
+	}
+	return replies, nil
+}
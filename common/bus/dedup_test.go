@@ -0,0 +1,124 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// memDedupStore keeps the processed messages in memory, the context errors
+// it was settled with are recorded
+type memDedupStore struct {
+	mu        sync.Mutex
+	pending   map[string]bool
+	replies   map[string][]*message.Message
+	settleErr []error
+}
+
+func newMemDedupStore() *memDedupStore {
+	return &memDedupStore{pending: map[string]bool{}, replies: map[string][]*message.Message{}}
+}
+
+func (s *memDedupStore) Claim(ctx context.Context, handler, uuid string) ([]*message.Message, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if replies, ok := s.replies[uuid]; ok {
+		return replies, false, nil
+	}
+	if s.pending[uuid] {
+		return nil, false, ErrMessageInProgress
+	}
+	s.pending[uuid] = true
+	return nil, true, nil
+}
+
+func (s *memDedupStore) Complete(ctx context.Context, handler, uuid string, replies []*message.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settleErr = append(s.settleErr, ctx.Err())
+	delete(s.pending, uuid)
+	s.replies[uuid] = replies
+	return nil
+}
+
+func (s *memDedupStore) Release(ctx context.Context, handler, uuid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settleErr = append(s.settleErr, ctx.Err())
+	delete(s.pending, uuid)
+	return nil
+}
+
+func TestDeduplicate(t *testing.T) {
+	errHandler := errors.New("handler failed")
+	tests := []struct {
+		name        string
+		deliveries  []error
+		pending     bool
+		wantRuns    int
+		wantErrs    []error
+		wantReplies int
+	}{
+		{"first delivery", []error{nil}, false, 1, []error{nil}, 1},
+		{"redelivery replays the replies", []error{nil, nil}, false, 1, []error{nil, nil}, 1},
+		{"failed delivery is handled again", []error{errHandler, nil}, false, 2, []error{errHandler, nil}, 1},
+		{"delivery in progress elsewhere", []error{nil}, true, 0, []error{ErrMessageInProgress}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemDedupStore()
+			if tt.pending {
+				store.pending["m1"] = true
+			}
+			runs := 0
+			h := Deduplicate(store, watermill.NopLogger{})(func(msg *message.Message) ([]*message.Message, error) {
+				err := tt.deliveries[runs]
+				runs++
+				if err != nil {
+					return nil, err
+				}
+				return []*message.Message{message.NewMessage("r1", []byte("reply"))}, nil
+			})
+
+			var replies []*message.Message
+			for i := range tt.deliveries {
+				var err error
+				replies, err = h(message.NewMessage("m1", nil))
+				if !errors.Is(err, tt.wantErrs[i]) {
+					t.Fatalf("delivery %d error = %v, want %v", i, err, tt.wantErrs[i])
+				}
+			}
+			if runs != tt.wantRuns {
+				t.Errorf("handler runs = %d, want %d", runs, tt.wantRuns)
+			}
+			if len(replies) != tt.wantReplies || (len(replies) == 1 && replies[0].UUID != "r1") {
+				t.Errorf("replies = %v, want %d replies of the first delivery", replies, tt.wantReplies)
+			}
+		})
+	}
+}
+
+// TestDeduplicateCancelledContext settles the claim of a handler whose
+// context the timeout middleware cancelled
+func TestDeduplicateCancelledContext(t *testing.T) {
+	for _, handlerErr := range []error{nil, errors.New("handler failed")} {
+		store := newMemDedupStore()
+		h := Deduplicate(store, watermill.NopLogger{})(func(msg *message.Message) ([]*message.Message, error) {
+			return nil, handlerErr
+		})
+
+		msg := message.NewMessage("m1", nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		msg.SetContext(ctx)
+		cancel()
+		h(msg)
+
+		if len(store.settleErr) != 1 || store.settleErr[0] != nil {
+			t.Errorf("handler error %v settled with context errors %v, want a live context", handlerErr, store.settleErr)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/constant"
+	"github.com/Chengxufeng1994/go-saga-example/common/event"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// Keys a router can be partitioned by
+const (
+	PartitionByUser     = "user"
+	PartitionByPurchase = "purchase"
+)
+
+// partitionKeyHeaders are the metadata carrying the partition keys, replies
+// inherit them from the message they answer
+var partitionKeyHeaders = map[string]string{
+	PartitionByUser:     constant.UserIDHeader,
+	PartitionByPurchase: constant.PurchaseIDHeader,
+}
+
+// Partition splits a topic into partition topics by the value of a header
+type Partition struct {
+	Header     string
+	Partitions int
+	// Assigned partitions consumed, all when empty
+	Assigned []int
+}
+
+// Partitioner maps the partitioned topics to their partition
+type Partitioner map[string]Partition
+
+// NewPartitioner returns the partitioner of the configured routers
+func NewPartitioner(routers map[string]config.Partitioning) (Partitioner, error) {
+	p := Partitioner{}
+	for router, cfg := range routers {
+		topics, ok := event.RouterTopics[router]
+		if !ok {
+			return nil, fmt.Errorf("unknown router: %s", router)
+		}
+		header, ok := partitionKeyHeaders[cfg.Key]
+		if !ok {
+			return nil, fmt.Errorf("unknown partition key of router %s: %s", router, cfg.Key)
+		}
+		if cfg.Partitions < 1 {
+			return nil, fmt.Errorf("router %s needs at least one partition", router)
+		}
+		for _, assigned := range cfg.Assigned {
+			if assigned < 0 || assigned >= cfg.Partitions {
+				return nil, fmt.Errorf("router %s has no partition %d", router, assigned)
+			}
+		}
+		for _, topic := range topics {
+			p[topic] = Partition{
+				Header:     header,
+				Partitions: cfg.Partitions,
+				Assigned:   cfg.Assigned,
+			}
+		}
+	}
+	return p, nil
+}
+
+// PartitionTopic returns the topic of a partition
+func PartitionTopic(topic string, partition int) string {
+	return fmt.Sprintf("%s_p%d", topic, partition)
+}
+
+// topic returns the partition topic msg is published to
+func (p Partition) topic(topic string, msg *message.Message) string {
+	h := fnv.New32a()
+	h.Write([]byte(msg.Metadata.Get(p.Header)))
+	return PartitionTopic(topic, int(h.Sum32()%uint32(p.Partitions)))
+}
+
+// topics returns the partition topics consumed
+func (p Partition) topics(topic string) []string {
+	var topics []string
+	if len(p.Assigned) == 0 {
+		for i := 0; i < p.Partitions; i++ {
+			topics = append(topics, PartitionTopic(topic, i))
+		}
+		return topics
+	}
+	for _, i := range p.Assigned {
+		topics = append(topics, PartitionTopic(topic, i))
+	}
+	return topics
+}
+
+// copyPartitionKeys copies the partition keys of msg which reply has not set
+func copyPartitionKeys(msg, reply *message.Message) {
+	for _, header := range partitionKeyHeaders {
+		if value := msg.Metadata.Get(header); value != "" && reply.Metadata.Get(header) == "" {
+			reply.Metadata.Set(header, value)
+		}
+	}
+}
+
+// WithPartitionKeys sets the keys a partitioned router orders the message by
+func WithPartitionKeys(userID, purchaseID uint64) PublishOption {
+	return func(o *publishOptions) {
+		WithMetadata(constant.UserIDHeader, strconv.FormatUint(userID, 10))(o)
+		WithMetadata(constant.PurchaseIDHeader, strconv.FormatUint(purchaseID, 10))(o)
+	}
+}
+
+type partitionedPublisher struct {
+	publisher   message.Publisher
+	partitioner Partitioner
+}
+
+// NewPartitionedPublisher publishes the messages of partitioned topics to
+// the partition topic of their key
+func NewPartitionedPublisher(publisher message.Publisher, partitioner Partitioner) message.Publisher {
+	if len(partitioner) == 0 {
+		return publisher
+	}
+	return &partitionedPublisher{
+		publisher:   publisher,
+		partitioner: partitioner,
+	}
+}
+
+func (p *partitionedPublisher) Publish(topic string, messages ...*message.Message) error {
+	partition, ok := p.partitioner[topic]
+	if !ok {
+		return p.publisher.Publish(topic, messages...)
+	}
+	// one by one, publishing keeps the order of the messages of a key
+	for _, msg := range messages {
+		if err := p.publisher.Publish(partition.topic(topic, msg), msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *partitionedPublisher) Close() error {
+	return p.publisher.Close()
+}
+
+type partitionedSubscriber struct {
+	subscriber  message.Subscriber
+	partitioner Partitioner
+}
+
+// NewPartitionedSubscriber subscribes to the assigned partition topics of
+// partitioned topics. A partition topic delivers its next message once the
+// previous one is acked, so partitions run in parallel and each in order.
+func NewPartitionedSubscriber(subscriber message.Subscriber, partitioner Partitioner) message.Subscriber {
+	if len(partitioner) == 0 {
+		return subscriber
+	}
+	return &partitionedSubscriber{
+		subscriber:  subscriber,
+		partitioner: partitioner,
+	}
+}
+
+func (s *partitionedSubscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	partition, ok := s.partitioner[topic]
+	if !ok {
+		return s.subscriber.Subscribe(ctx, topic)
+	}
+
+	out := make(chan *message.Message)
+	var wg sync.WaitGroup
+	for _, partitionTopic := range partition.topics(topic) {
+		messages, err := s.subscriber.Subscribe(ctx, partitionTopic)
+		if err != nil {
+			return nil, err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range messages {
+				select {
+				case out <- msg:
+				case <-ctx.Done():
+					msg.Nack()
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out, nil
+}
+
+func (s *partitionedSubscriber) Close() error {
+	return s.subscriber.Close()
+}
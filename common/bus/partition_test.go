@@ -0,0 +1,185 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/constant"
+	"github.com/Chengxufeng1994/go-saga-example/common/event"
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestNewPartitioner(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Partitioning
+		router  string
+		wantErr bool
+	}{
+		{"partitioned by purchase", config.Partitioning{Key: PartitionByPurchase, Partitions: 8}, event.OrderRouter, false},
+		{"assigned partitions", config.Partitioning{Key: PartitionByUser, Partitions: 4, Assigned: []int{0, 3}}, event.OrderRouter, false},
+		{"unknown router", config.Partitioning{Key: PartitionByPurchase, Partitions: 8}, "cart", true},
+		{"unknown key", config.Partitioning{Key: "order", Partitions: 8}, event.OrderRouter, true},
+		{"no partition", config.Partitioning{Key: PartitionByPurchase}, event.OrderRouter, true},
+		{"assigned out of range", config.Partitioning{Key: PartitionByPurchase, Partitions: 4, Assigned: []int{4}}, event.OrderRouter, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPartitioner(map[string]config.Partitioning{tt.router: tt.cfg})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewPartitioner() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for _, topic := range event.RouterTopics[tt.router] {
+				if p[topic].Partitions != tt.cfg.Partitions {
+					t.Errorf("topic %s partitions = %d, want %d", topic, p[topic].Partitions, tt.cfg.Partitions)
+				}
+			}
+		})
+	}
+}
+
+// TestPartitionTopic pins the partition of a few keys, a different hash
+// would move the keys of running sagas to other partitions
+func TestPartitionTopic(t *testing.T) {
+	tests := []struct {
+		key        string
+		partitions int
+		want       int
+	}{
+		{"1", 8, 4},
+		{"2", 8, 5},
+		{"7", 8, 6},
+		{"42", 8, 3},
+		{"1001", 8, 7},
+		{"1001", 4, 3},
+		{"", 8, 5},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%q of %d", tt.key, tt.partitions), func(t *testing.T) {
+			msg := message.NewMessage(watermill.NewUUID(), nil)
+			msg.Metadata.Set(constant.PurchaseIDHeader, tt.key)
+			p := Partition{Header: constant.PurchaseIDHeader, Partitions: tt.partitions}
+			if got, want := p.topic("create_order", msg), PartitionTopic("create_order", tt.want); got != want {
+				t.Errorf("topic() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// queuePubSub queues the messages of each topic and, like NATS, delivers
+// the next message of a topic once the previous one is acked
+type queuePubSub struct {
+	queues map[string][]*message.Message
+}
+
+func (q *queuePubSub) Publish(topic string, messages ...*message.Message) error {
+	q.queues[topic] = append(q.queues[topic], messages...)
+	return nil
+}
+
+func (q *queuePubSub) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	out := make(chan *message.Message)
+	queue := q.queues[topic]
+	go func() {
+		defer close(out)
+		for _, msg := range queue {
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case <-msg.Acked():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (q *queuePubSub) Close() error { return nil }
+
+// TestPartitionedDelivery publishes the messages of several purchases and
+// checks each purchase is delivered in order from its partition
+func TestPartitionedDelivery(t *testing.T) {
+	tests := []struct {
+		name     string
+		assigned []int
+		// purchases expected from the assigned partitions
+		want []string
+	}{
+		{"all partitions", nil, []string{"1", "2", "7", "42"}},
+		{"assigned partitions", []int{4, 6}, []string{"1", "7"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pubSub := &queuePubSub{queues: map[string][]*message.Message{}}
+			partitioner, err := NewPartitioner(map[string]config.Partitioning{
+				event.OrderRouter: {Key: PartitionByPurchase, Partitions: 8, Assigned: tt.assigned},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			publisher := NewPartitionedPublisher(pubSub, partitioner)
+			subscriber := NewPartitionedSubscriber(pubSub, partitioner)
+
+			const perPurchase = 5
+			purchases := []string{"1", "2", "7", "42"}
+			for seq := 0; seq < perPurchase; seq++ {
+				for _, purchaseID := range purchases {
+					msg := message.NewMessage(watermill.NewUUID(), []byte(strconv.Itoa(seq)))
+					msg.Metadata.Set(constant.PurchaseIDHeader, purchaseID)
+					if err := publisher.Publish(event.CreateOrderTopic, msg); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			messages, err := subscriber.Subscribe(ctx, event.CreateOrderTopic)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			next := map[string]int{}
+			timeout := time.After(5 * time.Second)
+			for received := 0; received < len(tt.want)*perPurchase; received++ {
+				select {
+				case msg := <-messages:
+					purchaseID := msg.Metadata.Get(constant.PurchaseIDHeader)
+					if seq := string(msg.Payload); seq != strconv.Itoa(next[purchaseID]) {
+						t.Errorf("purchase %s got message %s, want %d", purchaseID, seq, next[purchaseID])
+					}
+					next[purchaseID]++
+					msg.Ack()
+				case <-timeout:
+					t.Fatalf("received %v, want %d messages of %v", next, perPurchase, tt.want)
+				}
+			}
+
+			for topic := range pubSub.queues {
+				if topic == event.CreateOrderTopic {
+					t.Errorf("message published to the partitioned topic %s", topic)
+				}
+			}
+			if len(next) != len(tt.want) {
+				t.Errorf("received purchases %v, want %v", next, tt.want)
+			}
+			for _, purchaseID := range tt.want {
+				if next[purchaseID] != perPurchase {
+					t.Errorf("purchase %s got %d messages, want %d", purchaseID, next[purchaseID], perPurchase)
+				}
+			}
+		})
+	}
+}
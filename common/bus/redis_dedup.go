@@ -0,0 +1,79 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisDedupKeyPrefix = "dedup:"
+	// redisDedupPending marks a claimed message, stored replies are a json array
+	redisDedupPending = "pending"
+)
+
+// RedisDedupStore claims messages with SETNX, the claim expires after the
+// claim timeout and the replies after the ttl
+type RedisDedupStore struct {
+	client       redis.UniversalClient
+	ttl          time.Duration
+	claimTimeout time.Duration
+}
+
+func NewRedisDedupStore(client redis.UniversalClient, ttl, claimTimeout time.Duration) *RedisDedupStore {
+	return &RedisDedupStore{
+		client:       client,
+		ttl:          ttl,
+		claimTimeout: claimTimeout,
+	}
+}
+
+// Claim implements DedupStore.
+func (s *RedisDedupStore) Claim(ctx context.Context, handler, uuid string) ([]*message.Message, bool, error) {
+	key := redisDedupKey(handler, uuid)
+	claimed, err := s.client.SetNX(ctx, key, redisDedupPending, s.claimTimeout).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if claimed {
+		return nil, true, nil
+	}
+
+	value, err := s.client.Get(ctx, key).Result()
+	if err != nil {
+		// the claim expired in between
+		if errors.Is(err, redis.Nil) {
+			return nil, false, ErrMessageInProgress
+		}
+		return nil, false, err
+	}
+	if value == redisDedupPending {
+		return nil, false, ErrMessageInProgress
+	}
+	replies, err := DecodeReplies([]byte(value))
+	if err != nil {
+		return nil, false, err
+	}
+	return replies, false, nil
+}
+
+// Complete implements DedupStore.
+func (s *RedisDedupStore) Complete(ctx context.Context, handler, uuid string, replies []*message.Message) error {
+	value, err := EncodeReplies(replies)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, redisDedupKey(handler, uuid), value, s.ttl).Err()
+}
+
+// Release implements DedupStore.
+func (s *RedisDedupStore) Release(ctx context.Context, handler, uuid string) error {
+	return s.client.Del(ctx, redisDedupKey(handler, uuid)).Err()
+}
+
+func redisDedupKey(handler, uuid string) string {
+	return redisDedupKeyPrefix + handler + ":" + uuid
+}
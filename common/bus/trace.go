@@ -0,0 +1,43 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/constant"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	TraceContext        = propagation.TraceContext{}
+	TraceparentHeader   = TraceContext.Fields()[0]
+	W3CSupportedVersion = 0
+)
+
+// SetSpanContext set span context to the message
+func SetSpanContext(ctx context.Context, msg *message.Message) {
+	msg.Metadata.Set(string(constant.CtxSpanKey), spanContextToW3C(ctx))
+}
+
+// ExtractSpanContext returns a copy of ctx continuing the trace of msg
+func ExtractSpanContext(ctx context.Context, msg *message.Message) context.Context {
+	carrier := make(propagation.HeaderCarrier)
+	carrier.Set(TraceparentHeader, msg.Metadata.Get(string(constant.CtxSpanKey)))
+	return TraceContext.Extract(ctx, carrier)
+}
+
+func spanContextToW3C(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ""
+	}
+	// Clear all flags other than the trace-context supported sampling bit.
+	flags := sc.TraceFlags() & trace.FlagsSampled
+	return fmt.Sprintf("%.2x-%s-%s-%s",
+		W3CSupportedVersion,
+		sc.TraceID(),
+		sc.SpanID(),
+		flags)
+}
@@ -0,0 +1,159 @@
+// Package codec encodes the protobuf messages exchanged over the brokers. The
+// content type and schema version travel in the message metadata so
+// consumers can decode messages of producers running an older version, and
+// leave the ones of a newer version alone.
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// ContentTypeKey is the metadata key of the content type of the payload
+	ContentTypeKey = "Content-Type"
+	// SchemaVersionKey is the metadata key of the schema version of the payload
+	SchemaVersionKey = "Schema-Version"
+
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeJSON     = "application/json"
+
+	// SchemaVersion is the version of the messages encoded by this build.
+	// Version 1 messages carry no metadata and are encoding/json encoded.
+	// Adding fields keeps the version, fields unknown to a build are ignored.
+	SchemaVersion = 2
+)
+
+var (
+	ErrUnsupportedContentType   = errors.New("unsupported content type")
+	ErrUnsupportedSchemaVersion = errors.New("unsupported schema version")
+)
+
+// Metadata is implemented by the metadata of watermill messages
+type Metadata interface {
+	Get(key string) string
+	Set(key, value string)
+}
+
+// Codec marshals protobuf messages to a payload
+type Codec interface {
+	ContentType() string
+	Marshal(proto.Message) ([]byte, error)
+	Unmarshal([]byte, proto.Message) error
+}
+
+var (
+	// Protobuf encodes the protobuf binary wire format, the default
+	Protobuf Codec = protobufCodec{}
+	// JSON encodes the protobuf JSON mapping, for topics read by people
+	JSON Codec = jsonCodec{}
+	// legacyJSON decodes version 1 messages
+	legacyJSON Codec = legacyJSONCodec{}
+)
+
+type protobufCodec struct{}
+
+func (protobufCodec) ContentType() string { return ContentTypeProtobuf }
+
+func (protobufCodec) Marshal(m proto.Message) ([]byte, error) { return proto.Marshal(m) }
+
+func (protobufCodec) Unmarshal(b []byte, m proto.Message) error { return proto.Unmarshal(b, m) }
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string { return ContentTypeJSON }
+
+func (jsonCodec) Marshal(m proto.Message) ([]byte, error) {
+	return protojson.MarshalOptions{UseProtoNames: true}.Marshal(m)
+}
+
+func (jsonCodec) Unmarshal(b []byte, m proto.Message) error {
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(b, m)
+}
+
+// legacyJSONCodec uses encoding/json over the generated structs, which is how
+// version 1 messages were encoded. Its timestamps are objects of seconds and
+// nanos, which protojson does not accept.
+type legacyJSONCodec struct{}
+
+func (legacyJSONCodec) ContentType() string { return "" }
+
+func (legacyJSONCodec) Marshal(m proto.Message) ([]byte, error) { return json.Marshal(m) }
+
+func (legacyJSONCodec) Unmarshal(b []byte, m proto.Message) error { return json.Unmarshal(b, m) }
+
+// New returns the codec of name, protobuf when empty
+func New(name string) (Codec, error) {
+	switch name {
+	case "", "protobuf", ContentTypeProtobuf:
+		return Protobuf, nil
+	case "json", ContentTypeJSON:
+		return JSON, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedContentType, name)
+	}
+}
+
+// ForContentType returns the codec of a received message, messages without
+// content type are version 1 messages
+func ForContentType(contentType string) (Codec, error) {
+	switch contentType {
+	case ContentTypeProtobuf:
+		return Protobuf, nil
+	case ContentTypeJSON:
+		return JSON, nil
+	case "":
+		return legacyJSON, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedContentType, contentType)
+	}
+}
+
+// Encode marshals m with c and records how in md
+func Encode(c Codec, m proto.Message, md Metadata) ([]byte, error) {
+	payload, err := c.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	md.Set(ContentTypeKey, c.ContentType())
+	md.Set(SchemaVersionKey, strconv.Itoa(SchemaVersion))
+	return payload, nil
+}
+
+// Decode unmarshals payload into m with the codec named by md. Messages of a
+// schema version newer than this build are rejected, so they are redelivered
+// until an upgraded consumer handles them.
+func Decode(payload []byte, md Metadata, m proto.Message) error {
+	version, err := schemaVersion(md)
+	if err != nil {
+		return err
+	}
+	if version > SchemaVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedSchemaVersion, version)
+	}
+
+	c, err := ForContentType(md.Get(ContentTypeKey))
+	if err != nil {
+		return err
+	}
+	return c.Unmarshal(payload, m)
+}
+
+// schemaVersion returns the schema version recorded in md, version 1
+// messages record none
+func schemaVersion(md Metadata) (int, error) {
+	value := md.Get(SchemaVersionKey)
+	if value == "" {
+		return 1, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedSchemaVersion, value)
+	}
+	return version, nil
+}
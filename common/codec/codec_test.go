@@ -0,0 +1,119 @@
+package codec
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/pb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type metadata map[string]string
+
+func (m metadata) Get(key string) string { return m[key] }
+
+func (m metadata) Set(key, value string) { m[key] = value }
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Codec
+		wantErr error
+	}{
+		{"", Protobuf, nil},
+		{"json", JSON, nil},
+		{ContentTypeJSON, JSON, nil},
+		{"protobuf", Protobuf, nil},
+		{ContentTypeProtobuf, Protobuf, nil},
+		{"avro", nil, ErrUnsupportedContentType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.name)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("New() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("New() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	want := &pb.RollbackResponse{UserId: 7, PurchaseId: 9, Success: true, Error: "none"}
+	for _, c := range []Codec{JSON, Protobuf} {
+		t.Run(c.ContentType(), func(t *testing.T) {
+			md := metadata{}
+			payload, err := Encode(c, want, md)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if md[ContentTypeKey] != c.ContentType() || md[SchemaVersionKey] != "2" {
+				t.Errorf("Encode() metadata = %v", md)
+			}
+
+			var got pb.RollbackResponse
+			if err := Decode(payload, md, &got); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !proto.Equal(&got, want) {
+				t.Errorf("Decode() = %v, want %v", &got, want)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		md      metadata
+		want    *pb.RollbackResponse
+		wantErr error
+	}{
+		{
+			name:    "version 1 message without metadata",
+			payload: []byte(`{"user_id":7,"purchase_id":9,"success":true,"timestamp":{"seconds":5}}`),
+			md:      metadata{},
+			want:    &pb.RollbackResponse{UserId: 7, PurchaseId: 9, Success: true, Timestamp: &timestamppb.Timestamp{Seconds: 5}},
+		},
+		{
+			name:    "fields added to the schema",
+			payload: []byte(`{"user_id":"7","purchase_id":"9","reason":"new"}`),
+			md:      metadata{ContentTypeKey: ContentTypeJSON, SchemaVersionKey: "2"},
+			want:    &pb.RollbackResponse{UserId: 7, PurchaseId: 9},
+		},
+		{
+			name:    "newer schema version",
+			payload: []byte(`{"user_id":"7","purchase_id":"9"}`),
+			md:      metadata{ContentTypeKey: ContentTypeJSON, SchemaVersionKey: "3"},
+			wantErr: ErrUnsupportedSchemaVersion,
+		},
+		{
+			name:    "invalid schema version",
+			payload: []byte(`{"user_id":"7","purchase_id":"9"}`),
+			md:      metadata{ContentTypeKey: ContentTypeJSON, SchemaVersionKey: "two"},
+			wantErr: ErrUnsupportedSchemaVersion,
+		},
+		{
+			name:    "unsupported content type",
+			payload: []byte(`<xml/>`),
+			md:      metadata{ContentTypeKey: "application/xml"},
+			wantErr: ErrUnsupportedContentType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got pb.RollbackResponse
+			err := Decode(tt.payload, tt.md, &got)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decode() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.want != nil && !proto.Equal(&got, tt.want) {
+				t.Errorf("Decode() = %v, want %v", &got, tt.want)
+			}
+		})
+	}
+}
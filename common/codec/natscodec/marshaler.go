@@ -0,0 +1,39 @@
+// Package natscodec carries codec encoded messages over NATS.
+package natscodec
+
+import (
+	"github.com/Chengxufeng1994/go-saga-example/common/codec"
+	"github.com/ThreeDotsLabs/watermill-nats/v2/pkg/nats"
+	"github.com/ThreeDotsLabs/watermill/message"
+	nc "github.com/nats-io/nats.go"
+)
+
+// Marshaler sends codec encoded messages as plain NATS messages, the metadata
+// as headers and the payload as is, so consumers in any language can read
+// them. Version 1 messages carry no content type and keep the Go only gob
+// envelope they were sent in.
+type Marshaler struct {
+	headers nats.NATSMarshaler
+	legacy  nats.GobMarshaler
+}
+
+func NewMarshaler() nats.MarshalerUnmarshaler {
+	return &Marshaler{}
+}
+
+// Marshal implements nats.Marshaler.
+func (m *Marshaler) Marshal(topic string, msg *message.Message) (*nc.Msg, error) {
+	if msg.Metadata.Get(codec.ContentTypeKey) != "" {
+		return m.headers.Marshal(topic, msg)
+	}
+	return m.legacy.Marshal(topic, msg)
+}
+
+// Unmarshal implements nats.Unmarshaler. Gob envelopes carry no headers of
+// their own, the content type header tells the envelopes apart.
+func (m *Marshaler) Unmarshal(natsMsg *nc.Msg) (*message.Message, error) {
+	if natsMsg.Header.Get(codec.ContentTypeKey) == "" {
+		return m.legacy.Unmarshal(natsMsg)
+	}
+	return m.headers.Unmarshal(natsMsg)
+}
@@ -0,0 +1,63 @@
+package natscodec
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/codec"
+	"github.com/Chengxufeng1994/go-saga-example/common/pb"
+	"github.com/ThreeDotsLabs/watermill-nats/v2/pkg/nats"
+	"github.com/ThreeDotsLabs/watermill/message"
+	nc "github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestUnmarshalSchemaVersions(t *testing.T) {
+	want := &pb.CapturePaymentResponse{
+		UserId:     7,
+		PurchaseId: 9,
+		Success:    true,
+		Timestamp:  &timestamppb.Timestamp{Seconds: 1700000000, Nanos: 5},
+	}
+
+	// version 1 producers sent encoding/json payloads in gob envelopes
+	legacyPayload, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := nats.GobMarshaler{}.Marshal("topic", message.NewMessage("1", legacyPayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current := message.NewMessage("2", nil)
+	current.Payload, err = codec.Encode(codec.Protobuf, want, current.Metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	marshaler := NewMarshaler()
+	currentMsg, err := marshaler.Marshal("topic", current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := currentMsg.Header.Get(codec.SchemaVersionKey); got != "2" {
+		t.Errorf("version 2 message schema version header = %q, want 2", got)
+	}
+
+	for name, natsMsg := range map[string]*nc.Msg{"version 1": legacy, "version 2": currentMsg} {
+		t.Run(name, func(t *testing.T) {
+			msg, err := marshaler.Unmarshal(natsMsg)
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			var got pb.CapturePaymentResponse
+			if err := codec.Decode(msg.Payload, msg.Metadata, &got); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !proto.Equal(&got, want) {
+				t.Errorf("Decode() = %v, want %v", &got, want)
+			}
+		})
+	}
+}
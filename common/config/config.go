@@ -1,19 +1,32 @@
 package config
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/ThreeDotsLabs/watermill"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
 type ApplicationConfig struct {
-	LogConfig      Log            `mapstructure:"log"`
-	PostgresConfig PostgresConfig `mapstructure:"postgres"`
-	RedisConfig    RedisConfig    `mapstructure:"redis"`
-	NatsConfig     NatsConfig     `mapstructure:"nats"`
-	JaegerConfig   JaegerConfig   `mapstructure:"jaeger"`
-	JWTConfig      JWTConfig      `mapstructure:"jwt"`
-	RpcEndpoints   RpcEndpoints   `mapstructure:"rpc_endpoints"`
+	LogConfig       Log            `mapstructure:"log"`
+	PostgresConfig  PostgresConfig `mapstructure:"postgres"`
+	RedisConfig     RedisConfig    `mapstructure:"redis"`
+	NatsConfig      NatsConfig     `mapstructure:"nats"`
+	JaegerConfig    JaegerConfig   `mapstructure:"jaeger"`
+	JWTConfig       JWTConfig      `mapstructure:"jwt"`
+	RpcEndpoints    RpcEndpoints   `mapstructure:"rpc_endpoints"`
+	PaymentGateway  PaymentGateway `mapstructure:"payment_gateway"`
+	CurrencyConfig  Currency       `mapstructure:"currency"`
+	SagaConfig      Saga           `mapstructure:"saga"`
+	RBACConfig      RBAC           `mapstructure:"rbac"`
+	AccountConfig   Account        `mapstructure:"account"`
+	MFAConfig       MFA            `mapstructure:"mfa"`
+	LoginConfig     Login          `mapstructure:"login"`
+	OIDCConfig      OIDC           `mapstructure:"oidc"`
+	MessagingConfig Messaging      `mapstructure:"messaging"`
 }
 
 type Log struct {
@@ -73,9 +86,35 @@ type NatsSubscriber struct {
 }
 
 type JWTConfig struct {
-	Secret              string `mapstructure:"secret"`
-	AccessTokenExpires  int    `mapstructure:"access_token_expires"`
-	RefreshTokenExpires int    `mapstructure:"refresh_token_expires"`
+	Secret string `mapstructure:"secret"`
+	// AccessTokenExpires is the lifetime of the access tokens auth-svc
+	// issues, and the oldest access token a service verifying locally accepts
+	AccessTokenExpires  int `mapstructure:"access_token_expires"`
+	RefreshTokenExpires int `mapstructure:"refresh_token_expires"`
+	// Algorithm is HS256 (default, signed with Secret), RS256 or EdDSA
+	Algorithm string         `mapstructure:"algorithm"`
+	Keys      []JWTKey       `mapstructure:"keys"`
+	Rotation  JWTKeyRotation `mapstructure:"rotation"`
+	// JWKSURL makes the services verify tokens locally with the keys
+	// published by auth-svc instead of calling VerifyToken
+	JWKSURL      string `mapstructure:"jwks_url"`
+	JWKSCacheTTL int    `mapstructure:"jwks_cache_ttl"`
+}
+
+// JWTKey is a PEM encoded PKCS #8 private key, later keys supersede earlier ones
+type JWTKey struct {
+	ID   string `mapstructure:"id"`
+	File string `mapstructure:"file"`
+}
+
+// JWTKeyRotation generates a new signing key every Interval seconds. Keys are
+// published PrePublish seconds before they sign and kept for Retention seconds
+// after they were superseded. Only the keyring stored by auth-svc rotates,
+// configured key files never do.
+type JWTKeyRotation struct {
+	Interval   int `mapstructure:"interval"`
+	PrePublish int `mapstructure:"pre_publish"`
+	Retention  int `mapstructure:"retention"`
 }
 
 type RpcEndpoints struct {
@@ -83,6 +122,371 @@ type RpcEndpoints struct {
 	ProductServiceHost string `mapstructure:"product_service_host"`
 }
 
+type PaymentGateway struct {
+	Provider  string                    `mapstructure:"provider"`
+	Simulator PaymentSimulator          `mapstructure:"simulator"`
+	Webhooks  map[string]PaymentWebhook `mapstructure:"webhooks"`
+}
+
+// PaymentWebhook holds the shared secret a provider signs its webhooks with
+// and how many seconds old a signed webhook may be.
+type PaymentWebhook struct {
+	Secret    string `mapstructure:"secret"`
+	Tolerance int    `mapstructure:"tolerance"`
+}
+
+// PaymentSimulator configures the local payment gateway simulator. Rules are
+// evaluated in order and the first match decides the outcome; a payment that
+// matches no rule is approved.
+type PaymentSimulator struct {
+	Latency int                    `mapstructure:"latency"`
+	Timeout int                    `mapstructure:"timeout"`
+	Rules   []PaymentSimulatorRule `mapstructure:"rules"`
+	// Webhook is where the simulator confirms captures, voids and refunds
+	// when the saga awaits the payment confirmation
+	Webhook PaymentSimulatorWebhook `mapstructure:"webhook"`
+}
+
+type PaymentSimulatorWebhook struct {
+	URL   string `mapstructure:"url"`
+	Delay int    `mapstructure:"delay"`
+}
+
+type PaymentSimulatorRule struct {
+	Operation string `mapstructure:"operation"`
+	CardToken string `mapstructure:"card_token"`
+	MinAmount int64  `mapstructure:"min_amount"`
+	MaxAmount int64  `mapstructure:"max_amount"`
+	Outcome   string `mapstructure:"outcome"`
+}
+
+// Currency configures the base currency product prices are expressed in and
+// the exchange rates used to convert them.
+type Currency struct {
+	Base         string       `mapstructure:"base"`
+	ExchangeRate ExchangeRate `mapstructure:"exchange_rate"`
+}
+
+type ExchangeRate struct {
+	Provider string `mapstructure:"provider"`
+	File     string `mapstructure:"file"`
+}
+
+// Modes of the purchase saga
+const (
+	// SagaOrchestration has the orchestrator command the participants
+	SagaOrchestration = "orchestration"
+	// SagaChoreography has the participants react to each other's events
+	SagaChoreography = "choreography"
+)
+
+type Saga struct {
+	// Mode of the purchase saga, orchestration when empty. Every service
+	// needs the same mode.
+	Mode string `mapstructure:"mode"`
+	// AwaitPaymentConfirmation completes the saga only once the payment
+	// provider confirmed the capture through a webhook
+	AwaitPaymentConfirmation bool       `mapstructure:"await_payment_confirmation"`
+	Capture                  Capture    `mapstructure:"capture"`
+	EventStore               EventStore `mapstructure:"event_store"`
+}
+
+// DefaultCaptureAttempts bounds the captures of an authorization unless
+// configured otherwise
+const DefaultCaptureAttempts = 3
+
+// Capture retries a failed capture of an authorization before the purchase
+// is compensated and the authorization voided, both saga modes retry alike
+type Capture struct {
+	// MaxAttempts of a capture, DefaultCaptureAttempts when 0
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// Interval in milliseconds before the first retry, doubled for every
+	// further one. The retries wait in the handler of the failed capture,
+	// all of them have to fit in its ack wait.
+	Interval int `mapstructure:"interval"`
+}
+
+// Attempts returns how often a capture is attempted
+func (c Capture) Attempts() int {
+	if c.MaxAttempts <= 0 {
+		return DefaultCaptureAttempts
+	}
+	return c.MaxAttempts
+}
+
+// Wait waits out the backoff before the attempt, the first one does not wait
+func (c Capture) Wait(ctx context.Context, attempt int) error {
+	if attempt < 2 || c.Interval <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(c.Interval) * time.Millisecond << (attempt - 2))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Choreographed tells whether the participants run the saga without the
+// orchestrator
+func (s Saga) Choreographed() bool {
+	return s.Mode == SagaChoreography
+}
+
+// Validate rejects an unknown mode, the service would otherwise run the
+// saga in orchestration while its peers expect another mode
+func (s Saga) Validate() error {
+	switch s.Mode {
+	case "", SagaOrchestration, SagaChoreography:
+		return nil
+	default:
+		return fmt.Errorf("unknown saga mode: %q", s.Mode)
+	}
+}
+
+type EventStore struct {
+	// SnapshotInterval is the number of events between the snapshots of a
+	// stream, 0 disables snapshots
+	SnapshotInterval int `mapstructure:"snapshot_interval"`
+	// PollInterval in milliseconds the projections look for the events
+	// appended by other instances
+	PollInterval int `mapstructure:"poll_interval"`
+	// BatchSize is the number of events a projection applies per transaction
+	BatchSize int `mapstructure:"batch_size"`
+}
+
+// Broker backends a topic group can be carried over
+const (
+	BackendNATS      = "nats"
+	BackendRedis     = "redis"
+	BackendKafka     = "kafka"
+	BackendGoChannel = "gochannel"
+)
+
+// Stores of the processed messages
+const (
+	DedupPostgres = "postgres"
+	DedupRedis    = "redis"
+	DedupNone     = "none"
+)
+
+type Messaging struct {
+	// Codec of the published messages, protobuf or json, protobuf when
+	// empty. Consumers decode either whatever the codec they publish with.
+	Codec string `mapstructure:"codec"`
+	// Transports maps a topic group to its broker backend, groups which
+	// are not listed keep their default
+	Transports map[string]string `mapstructure:"transports"`
+	Kafka      Kafka             `mapstructure:"kafka"`
+	Dedup      Dedup             `mapstructure:"dedup"`
+	// Partitioning of the routers whose messages are handled in order,
+	// publishers to the topics of a router need the same entry
+	Partitioning map[string]Partitioning `mapstructure:"partitioning"`
+	Router       Router                  `mapstructure:"router"`
+}
+
+// Backend returns the broker backend of the topic group
+func (m Messaging) Backend(group, fallback string) string {
+	if backend := m.Transports[group]; backend != "" {
+		return backend
+	}
+	return fallback
+}
+
+type Kafka struct {
+	Brokers       []string `mapstructure:"brokers"`
+	ConsumerGroup string   `mapstructure:"consumer_group"`
+}
+
+// Dedup remembers the processed messages of each handler so a redelivered
+// message is not handled twice
+type Dedup struct {
+	// Store is postgres, redis or none, the default depends on the service
+	Store string `mapstructure:"store"`
+	// TTL in seconds a processed message is remembered
+	TTL int `mapstructure:"ttl"`
+	// ClaimTimeout in seconds after which a message whose handler never
+	// finished is handled again
+	ClaimTimeout int `mapstructure:"claim_timeout"`
+}
+
+// Partitioning splits the topics of a router by a partition key. Messages of
+// a key are handled in order, different partitions run in parallel.
+//
+// Keys are hashed onto the partition topics, so enabling partitioning or
+// changing Partitions sends the next messages of a key to another topic than
+// the ones still queued. Nothing consumes the previous topics after the
+// change: drain them first, with purchases stopped, and roll the new entry
+// out to every publisher and consumer of the router at once.
+type Partitioning struct {
+	// Key is user or purchase
+	Key        string `mapstructure:"key"`
+	Partitions int    `mapstructure:"partitions"`
+	// Assigned partitions this instance consumes, all when empty. Each
+	// partition has to be assigned to a single instance to stay in order.
+	Assigned []int `mapstructure:"assigned"`
+}
+
+// Router tunes the handlers of the event router. Handlers override the
+// defaults by handler name, field by field.
+type Router struct {
+	// CloseTimeout in seconds the router waits for running handlers on close
+	CloseTimeout int                `mapstructure:"close_timeout"`
+	Defaults     Handler            `mapstructure:"defaults"`
+	Handlers     map[string]Handler `mapstructure:"handlers"`
+}
+
+// Handler returns the tuning of the handler on top of base
+func (r Router) Handler(base Handler, name string) Handler {
+	return base.Merge(r.Defaults).Merge(r.Handlers[name])
+}
+
+// Handler tunes a router handler, settings left out are inherited
+type Handler struct {
+	// Timeout in milliseconds handling a message may take
+	Timeout        int            `mapstructure:"timeout"`
+	Retry          Retry          `mapstructure:"retry"`
+	CircuitBreaker CircuitBreaker `mapstructure:"circuit_breaker"`
+	Throttle       Throttle       `mapstructure:"throttle"`
+	Consumer       Consumer       `mapstructure:"consumer"`
+}
+
+// Merge returns h with the settings set in o, field by field
+func (h Handler) Merge(o Handler) Handler {
+	override(&h.Timeout, o.Timeout)
+	override(&h.Retry.MaxRetries, o.Retry.MaxRetries)
+	override(&h.Retry.InitialInterval, o.Retry.InitialInterval)
+	override(&h.Retry.MaxInterval, o.Retry.MaxInterval)
+	override(&h.Retry.Multiplier, o.Retry.Multiplier)
+	override(&h.Retry.MaxElapsedTime, o.Retry.MaxElapsedTime)
+	override(&h.Retry.RandomizationFactor, o.Retry.RandomizationFactor)
+	override(&h.CircuitBreaker.ConsecutiveFailures, o.CircuitBreaker.ConsecutiveFailures)
+	override(&h.CircuitBreaker.MaxRequests, o.CircuitBreaker.MaxRequests)
+	override(&h.CircuitBreaker.Interval, o.CircuitBreaker.Interval)
+	override(&h.CircuitBreaker.Timeout, o.CircuitBreaker.Timeout)
+	override(&h.Throttle.PerSecond, o.Throttle.PerSecond)
+	override(&h.Consumer.AckWait, o.Consumer.AckWait)
+	override(&h.Consumer.MaxDeliver, o.Consumer.MaxDeliver)
+	override(&h.Consumer.MaxAckPending, o.Consumer.MaxAckPending)
+	override(&h.Consumer.DeliverPolicy, o.Consumer.DeliverPolicy)
+	return h
+}
+
+// override sets v to o unless o is the zero value
+func override[T comparable](v *T, o T) {
+	var zero T
+	if o != zero {
+		*v = o
+	}
+}
+
+// Retry retries a failed message with exponential backoff, intervals are in
+// milliseconds
+type Retry struct {
+	MaxRetries          int     `mapstructure:"max_retries"`
+	InitialInterval     int     `mapstructure:"initial_interval"`
+	MaxInterval         int     `mapstructure:"max_interval"`
+	Multiplier          float64 `mapstructure:"multiplier"`
+	MaxElapsedTime      int     `mapstructure:"max_elapsed_time"`
+	RandomizationFactor float64 `mapstructure:"randomization_factor"`
+}
+
+// CircuitBreaker fails fast once a handler keeps failing, disabled while
+// ConsecutiveFailures is 0
+type CircuitBreaker struct {
+	ConsecutiveFailures uint32 `mapstructure:"consecutive_failures"`
+	// MaxRequests let through while half open
+	MaxRequests uint32 `mapstructure:"max_requests"`
+	// Interval in seconds the failure counts are cleared in while closed
+	Interval int `mapstructure:"interval"`
+	// Timeout in seconds the breaker stays open
+	Timeout int `mapstructure:"timeout"`
+}
+
+// Throttle limits the messages a handler handles per second, 0 for no limit
+type Throttle struct {
+	PerSecond int64 `mapstructure:"per_second"`
+}
+
+// Consumer tunes the JetStream consumer of a handler
+type Consumer struct {
+	// AckWait in seconds before an unacked message is redelivered
+	AckWait int `mapstructure:"ack_wait"`
+	// MaxDeliver attempts of a message, 0 for no limit
+	MaxDeliver    int `mapstructure:"max_deliver"`
+	MaxAckPending int `mapstructure:"max_ack_pending"`
+	// DeliverPolicy of a new consumer: all, new or last
+	DeliverPolicy string `mapstructure:"deliver_policy"`
+}
+
+type RBAC struct {
+	// AdminEmails are granted the admin role once they verified their email address
+	AdminEmails []string `mapstructure:"admin_emails"`
+}
+
+type Account struct {
+	// RequireEmailVerification blocks sign in until the email is verified
+	RequireEmailVerification  bool   `mapstructure:"require_email_verification"`
+	VerificationTokenExpires  int    `mapstructure:"verification_token_expires"`
+	PasswordResetTokenExpires int    `mapstructure:"password_reset_token_expires"`
+	LinkBaseURL               string `mapstructure:"link_base_url"`
+	// TokenSecret signs the verification and password reset tokens
+	TokenSecret string   `mapstructure:"token_secret"`
+	Notifier    Notifier `mapstructure:"notifier"`
+}
+
+// Notifier delivers account emails, provider is console (default) or file
+type Notifier struct {
+	Provider string `mapstructure:"provider"`
+	File     string `mapstructure:"file"`
+}
+
+// MFA configures TOTP multi-factor authentication, secrets are encrypted at
+// rest with EncryptionKey
+type MFA struct {
+	Issuer           string `mapstructure:"issuer"`
+	EncryptionKey    string `mapstructure:"encryption_key"`
+	ChallengeExpires int    `mapstructure:"challenge_expires"`
+}
+
+// Login configures the sign in rate limits. Attempts are counted in a
+// sliding window of Window seconds per client ip and failures per account.
+// Every LockoutThreshold failures lock the account, starting at
+// LockoutDuration seconds and doubling for every lockout up to
+// MaxLockoutDuration.
+type Login struct {
+	Window             int `mapstructure:"window"`
+	IPLimit            int `mapstructure:"ip_limit"`
+	LockoutThreshold   int `mapstructure:"lockout_threshold"`
+	LockoutDuration    int `mapstructure:"lockout_duration"`
+	MaxLockoutDuration int `mapstructure:"max_lockout_duration"`
+}
+
+// OIDC configures sign in through external OpenID Connect providers with
+// the authorization code flow and PKCE
+type OIDC struct {
+	// StateExpires is how long in seconds a started sign in can be completed
+	StateExpires int `mapstructure:"state_expires"`
+	// CookieSecret signs the cookie binding a started sign in to the browser
+	// which started it
+	CookieSecret string         `mapstructure:"cookie_secret"`
+	Providers    []OIDCProvider `mapstructure:"providers"`
+}
+
+// OIDCProvider is discovered from Issuer, RedirectURL has to point at the
+// callback endpoint of the provider
+type OIDCProvider struct {
+	Name         string   `mapstructure:"name"`
+	Issuer       string   `mapstructure:"issuer"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	RedirectURL  string   `mapstructure:"redirect_url"`
+	Scopes       []string `mapstructure:"scopes"`
+}
+
 func init() {
 	log.SetFormatter(&log.TextFormatter{FullTimestamp: true, DisableColors: false, TimestampFormat: "2006-01-02 15:04:05"})
 }
@@ -104,6 +508,10 @@ func LoadApplicationConfig(path string) *ApplicationConfig {
 		panic(err)
 	}
 
+	if err := config.SagaConfig.Validate(); err != nil {
+		panic(err)
+	}
+
 	if config.RedisConfig.Subscriber.ConsumerID == "" {
 		config.RedisConfig.Subscriber.ConsumerID = watermill.NewShortUUID()
 	}
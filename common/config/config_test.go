@@ -0,0 +1,112 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRouterHandler(t *testing.T) {
+	base := Handler{
+		Timeout:        30000,
+		Retry:          Retry{MaxRetries: 3, InitialInterval: 100, Multiplier: 2},
+		CircuitBreaker: CircuitBreaker{ConsecutiveFailures: 5, Timeout: 30},
+		Consumer:       Consumer{AckWait: 30, MaxDeliver: 10},
+	}
+	router := Router{
+		Defaults: Handler{Retry: Retry{MaxRetries: 5}},
+		Handlers: map[string]Handler{
+			"create_payment": {
+				Retry:    Retry{InitialInterval: 500},
+				Throttle: Throttle{PerSecond: 20},
+				Consumer: Consumer{AckWait: 60},
+			},
+		},
+	}
+	tests := []struct {
+		name    string
+		handler string
+		want    Handler
+	}{
+		{
+			name:    "defaults",
+			handler: "create_order",
+			want: Handler{
+				Timeout:        30000,
+				Retry:          Retry{MaxRetries: 5, InitialInterval: 100, Multiplier: 2},
+				CircuitBreaker: CircuitBreaker{ConsecutiveFailures: 5, Timeout: 30},
+				Consumer:       Consumer{AckWait: 30, MaxDeliver: 10},
+			},
+		},
+		{
+			name:    "handler keeps the fields it leaves out",
+			handler: "create_payment",
+			want: Handler{
+				Timeout:        30000,
+				Retry:          Retry{MaxRetries: 5, InitialInterval: 500, Multiplier: 2},
+				CircuitBreaker: CircuitBreaker{ConsecutiveFailures: 5, Timeout: 30},
+				Throttle:       Throttle{PerSecond: 20},
+				Consumer:       Consumer{AckWait: 60, MaxDeliver: 10},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := router.Handler(base, tt.handler); got != tt.want {
+				t.Errorf("Handler() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSagaValidate(t *testing.T) {
+	tests := []struct {
+		mode    string
+		wantErr bool
+	}{
+		{"", false},
+		{SagaOrchestration, false},
+		{SagaChoreography, false},
+		{"Choreography", true},
+		{"choreographed", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			if err := (Saga{Mode: tt.mode}).Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCaptureWait(t *testing.T) {
+	capture := Capture{Interval: 20}
+	if got := capture.Attempts(); got != DefaultCaptureAttempts {
+		t.Errorf("Attempts() = %d, want %d", got, DefaultCaptureAttempts)
+	}
+
+	tests := []struct {
+		attempt int
+		min     time.Duration
+	}{
+		{1, 0},
+		{2, 20 * time.Millisecond},
+		{3, 40 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		start := time.Now()
+		if err := capture.Wait(context.Background(), tt.attempt); err != nil {
+			t.Fatalf("Wait(%d) error = %v", tt.attempt, err)
+		}
+		if got := time.Since(start); got < tt.min || got > tt.min+time.Second {
+			t.Errorf("Wait(%d) waited %s, want %s", tt.attempt, got, tt.min)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := (Capture{Interval: 60000}).Wait(ctx, 2); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait() of a canceled context error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -3,11 +3,18 @@ package constant
 type key string
 
 const (
-	CtxUserKey key = "ctx_user_key"
-	CtxSpanKey key = "span_ctx_key"
+	CtxUserKey    key = "ctx_user_key"
+	CtxSessionKey key = "ctx_session_key"
+	// CtxRolesKey and CtxPermissionsKey hold the []string granted to the user
+	CtxRolesKey       key = "ctx_roles_key"
+	CtxPermissionsKey key = "ctx_permissions_key"
+	CtxSpanKey        key = "span_ctx_key"
 
 	// HandlerHeader identifies a handler in the ReplyTopic
 	HandlerHeader = "Handler"
+	// EventTopicHeader carries the topic of an event returned by a reacting
+	// handler
+	EventTopicHeader = "Event-Topic"
 	// UpdateProductInventoryHandler identifier
 	UpdateProductInventoryHandler = "update_product_inventory_handler"
 	// RollbackProductInventoryHandler identifier
@@ -20,7 +27,14 @@ const (
 	CreatePaymentHandler = "create_payment_handler"
 	// RollbackPaymentHandler identifier
 	RollbackPaymentHandler = "rollback_payment_handler"
+	// CapturePaymentHandler identifier
+	CapturePaymentHandler = "capture_payment_handler"
 
 	//
 	JaegerHeader = "Uber-Trace-Id"
+	// APIKeyHeader carries the api key of machine clients
+	APIKeyHeader = "X-API-Key"
+	// UserIDHeader and PurchaseIDHeader carry the partition keys of saga messages
+	UserIDHeader     = "User-Id"
+	PurchaseIDHeader = "Purchase-Id"
 )
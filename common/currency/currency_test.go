@@ -0,0 +1,47 @@
+package currency
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  int64
+		from    string
+		to      string
+		rate    *big.Rat
+		want    int64
+		wantErr error
+	}{
+		{"same currency", 12345, "TWD", "TWD", big.NewRat(1, 1), 12345, nil},
+		{"two to zero minor units", 1000, "USD", "JPY", big.NewRat(15012, 100), 1501, nil},
+		{"zero to two minor units", 1500, "JPY", "USD", big.NewRat(666, 100000), 999, nil},
+		{"two to three minor units", 100, "USD", "KWD", big.NewRat(307, 1000), 307, nil},
+		{"rounds half up", 5, "TWD", "USD", big.NewRat(1, 10), 1, nil},
+		{"rounds down below half", 4, "TWD", "USD", big.NewRat(1, 10), 0, nil},
+		{"rounds negative half away from zero", -5, "TWD", "USD", big.NewRat(1, 10), -1, nil},
+		{"unknown from", 100, "XXX", "USD", big.NewRat(1, 1), 0, ErrUnknownCurrency},
+		{"unknown to", 100, "USD", "usd", big.NewRat(1, 1), 0, ErrUnknownCurrency},
+		{"overflow", 1 << 62, "JPY", "KWD", big.NewRat(10, 1), 0, ErrAmountOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Convert(tt.amount, tt.from, tt.to, tt.rate)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Convert() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Convert() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if got := Normalize(" twd "); got != "TWD" || !IsValid(got) {
+		t.Errorf("Normalize() = %q, want a valid TWD", got)
+	}
+}
@@ -19,4 +19,51 @@ const (
 	CreatePaymentTopic = "payment_create"
 	// Rollback Order Topic
 	RollbackPaymentTopic = "payment_rollback"
+	// Capture Payment Topic
+	CapturePaymentTopic = "payment_capture"
+	// PaymentStatusTopic is the topic payment status changes confirmed by the provider are published to
+	PaymentStatusTopic = "payment_status"
+	// UserDeletedTopic is the topic deleted user accounts are published to
+	UserDeletedTopic = "user_deleted"
 )
+
+// Topics of the choreographed saga, the participants react to each other's
+// events instead of the commands of the orchestrator
+const (
+	// InventoryReservedTopic is followed by the creation of the order
+	InventoryReservedTopic = "product_inventory_reserved"
+	// OrderCreatedTopic is followed by the creation of the payment
+	OrderCreatedTopic = "order_created"
+	// OrderCreationFailedTopic is followed by the rollback of the inventory
+	OrderCreationFailedTopic = "order_creation_failed"
+	// PaymentFailedTopic is followed by the rollback of the order
+	PaymentFailedTopic = "payment_failed"
+	// OrderRolledBackTopic is followed by the rollback of the inventory
+	OrderRolledBackTopic = "order_rolled_back"
+)
+
+// Topic groups select the broker backend of their topics
+const (
+	// SagaTopicGroup carries the purchases, the saga commands and their replies
+	SagaTopicGroup = "saga"
+	// ResultTopicGroup carries the purchase results
+	ResultTopicGroup = "result"
+)
+
+// Routers consuming the saga topics, partitioning is configured per router
+const (
+	OrchestratorRouter = "orchestrator"
+	ProductRouter      = "product"
+	OrderRouter        = "order"
+	PaymentRouter      = "payment"
+)
+
+// RouterTopics are the topics partitioned along with their router. The
+// purchases and payment status changes stay with the orchestrator router
+// while product and payment consume them in a choreographed saga.
+var RouterTopics = map[string][]string{
+	OrchestratorRouter: {PurchaseTopic, ReplyTopic, PaymentStatusTopic},
+	ProductRouter:      {UpdateProductInventoryTopic, RollbackProductInventoryTopic, OrderCreationFailedTopic, OrderRolledBackTopic},
+	OrderRouter:        {CreateOrderTopic, RollbackOrderTopic, InventoryReservedTopic, PaymentFailedTopic},
+	PaymentRouter:      {CreatePaymentTopic, RollbackPaymentTopic, CapturePaymentTopic, OrderCreatedTopic},
+}
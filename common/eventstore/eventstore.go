@@ -0,0 +1,56 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrConcurrencyConflict is returned when a stream is no longer at the
+// version its events were decided on, the caller reloads it and tries again
+var ErrConcurrencyConflict = errors.New("eventstore: stream version conflict")
+
+// Event of a stream. Version counts the events of its stream from 1,
+// Position orders every event of the store.
+type Event struct {
+	StreamID   string
+	StreamType string
+	Version    int64
+	Type       string
+	Data       []byte
+	Metadata   map[string]string
+	Position   uint64
+	RecordedAt time.Time
+}
+
+// Snapshot is the state of a stream at a version, so loading a long stream
+// only folds the events after it
+type Snapshot struct {
+	StreamID  string
+	Version   int64
+	Data      []byte
+	CreatedAt time.Time
+}
+
+// Store keeps an append-only stream of events per aggregate
+type Store interface {
+	// Append appends events to a stream which is at expectedVersion, 0 for a
+	// new stream. Version and Position of the events are set.
+	Append(ctx context.Context, streamID string, expectedVersion int64, events ...*Event) error
+	// Load returns the events of a stream after a version
+	Load(ctx context.Context, streamID string, afterVersion int64) ([]*Event, error)
+	// LoadSnapshot returns the latest snapshot of a stream, nil when it has none
+	LoadSnapshot(ctx context.Context, streamID string) (*Snapshot, error)
+	SaveSnapshot(ctx context.Context, snapshot *Snapshot) error
+}
+
+// Projection builds a read model from the events of the store
+type Projection interface {
+	// Name identifies the checkpoint of the projection
+	Name() string
+	// Handle applies evt to the read model in tx, the transaction moving
+	// the checkpoint past evt
+	Handle(ctx context.Context, tx *gorm.DB, evt *Event) error
+}
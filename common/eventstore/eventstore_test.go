@@ -0,0 +1,131 @@
+package eventstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestAppend(t *testing.T) {
+	tests := []struct {
+		name            string
+		existing        int
+		expectedVersion int64
+		wantErr         error
+	}{
+		{"new stream", 0, 0, nil},
+		{"stream at the expected version", 2, 2, nil},
+		{"new stream which exists", 2, 0, ErrConcurrencyConflict},
+		{"stream behind", 2, 1, ErrConcurrencyConflict},
+		{"stream ahead", 2, 3, ErrConcurrencyConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewMemoryStore()
+			for i := 0; i < tt.existing; i++ {
+				if err := store.Append(ctx, "s", int64(i), &Event{Type: "existing"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			evt := &Event{Type: "appended"}
+			err := store.Append(ctx, "s", tt.expectedVersion, evt)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Append() error = %v, want %v", err, tt.wantErr)
+			}
+			events, _ := store.Load(ctx, "s", 0)
+			if err != nil {
+				if len(events) != tt.existing {
+					t.Errorf("stream has %d events after a conflict, want %d", len(events), tt.existing)
+				}
+				return
+			}
+			if evt.Version != tt.expectedVersion+1 || len(events) != tt.existing+1 {
+				t.Errorf("Append() version = %d with %d events, want %d", evt.Version, len(events), tt.expectedVersion+1)
+			}
+		})
+	}
+}
+
+// TestAppendConcurrently appends the events of writers racing on one stream,
+// each reloads the stream after a conflict like the repositories do
+func TestAppendConcurrently(t *testing.T) {
+	const writers = 16
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	var wg sync.WaitGroup
+	conflicts := make(chan int, writers)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			conflicted := 0
+			for {
+				events, err := store.Load(ctx, "s", 0)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				err = store.Append(ctx, "s", int64(len(events)), &Event{Type: fmt.Sprint(w)})
+				if !errors.Is(err, ErrConcurrencyConflict) {
+					if err != nil {
+						t.Error(err)
+					}
+					conflicts <- conflicted
+					return
+				}
+				conflicted++
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(conflicts)
+
+	for conflicted := range conflicts {
+		// every conflict is another writer's append
+		if conflicted >= writers {
+			t.Errorf("writer conflicted %d times, want less than %d", conflicted, writers)
+		}
+	}
+	events, err := store.Load(ctx, "s", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != writers {
+		t.Fatalf("stream has %d events, want %d", len(events), writers)
+	}
+	seen := map[string]bool{}
+	for i, evt := range events {
+		if evt.Version != int64(i+1) {
+			t.Errorf("event %d has version %d", i, evt.Version)
+		}
+		if i > 0 && evt.Position <= events[i-1].Position {
+			t.Errorf("event %d at position %d after %d", i, evt.Position, events[i-1].Position)
+		}
+		if seen[evt.Type] {
+			t.Errorf("writer %s appended twice", evt.Type)
+		}
+		seen[evt.Type] = true
+	}
+}
+
+func TestSaveSnapshot(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for _, version := range []int64{20, 40, 30} {
+		if err := store.SaveSnapshot(ctx, &Snapshot{StreamID: "s", Version: version}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshot, err := store.LoadSnapshot(ctx, "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot == nil || snapshot.Version != 40 {
+		t.Errorf("LoadSnapshot() = %+v, want version 40", snapshot)
+	}
+}
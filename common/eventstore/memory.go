@@ -0,0 +1,89 @@
+package eventstore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store in memory, for tests and a single process which
+// can lose its events
+type MemoryStore struct {
+	mu        sync.Mutex
+	streams   map[string][]*Event
+	snapshots map[string]*Snapshot
+	position  uint64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		streams:   map[string][]*Event{},
+		snapshots: map[string]*Snapshot{},
+	}
+}
+
+// Append implements Store.
+func (s *MemoryStore) Append(ctx context.Context, streamID string, expectedVersion int64, events ...*Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if int64(len(s.streams[streamID])) != expectedVersion {
+		return ErrConcurrencyConflict
+	}
+	now := time.Now()
+	for i, evt := range events {
+		s.position++
+		evt.StreamID = streamID
+		evt.Version = expectedVersion + int64(i) + 1
+		evt.Position = s.position
+		evt.RecordedAt = now
+		stored := *evt
+		s.streams[streamID] = append(s.streams[streamID], &stored)
+	}
+	return nil
+}
+
+// Load implements Store.
+func (s *MemoryStore) Load(ctx context.Context, streamID string, afterVersion int64) ([]*Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stream := s.streams[streamID]
+	if afterVersion >= int64(len(stream)) {
+		return nil, nil
+	}
+	events := make([]*Event, 0, int64(len(stream))-afterVersion)
+	for _, evt := range stream[afterVersion:] {
+		loaded := *evt
+		events = append(events, &loaded)
+	}
+	return events, nil
+}
+
+// LoadSnapshot implements Store.
+func (s *MemoryStore) LoadSnapshot(ctx context.Context, streamID string) (*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot, ok := s.snapshots[streamID]
+	if !ok {
+		return nil, nil
+	}
+	loaded := *snapshot
+	return &loaded, nil
+}
+
+// SaveSnapshot implements Store. A snapshot older than the stored one is
+// dropped.
+func (s *MemoryStore) SaveSnapshot(ctx context.Context, snapshot *Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stored, ok := s.snapshots[snapshot.StreamID]; ok && stored.Version >= snapshot.Version {
+		return nil
+	}
+	saved := *snapshot
+	saved.CreatedAt = time.Now()
+	s.snapshots[snapshot.StreamID] = &saved
+	return nil
+}
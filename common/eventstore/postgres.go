@@ -0,0 +1,237 @@
+package eventstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// appendLockKey is the advisory lock the appends of every stream take
+// turns on
+const appendLockKey = 7_310_254_118
+
+// EventRecord data model of an event, rows are only ever appended
+type EventRecord struct {
+	Position   uint64    `gorm:"primaryKey"`
+	StreamID   string    `gorm:"type:varchar(128);not null;uniqueIndex:idx_event_store_stream_version"`
+	StreamType string    `gorm:"type:varchar(64);not null;index"`
+	Version    int64     `gorm:"not null;uniqueIndex:idx_event_store_stream_version"`
+	Type       string    `gorm:"type:varchar(128);not null"`
+	Data       []byte    `gorm:"type:jsonb;not null"`
+	Metadata   []byte    `gorm:"type:jsonb"`
+	RecordedAt time.Time `gorm:"not null"`
+}
+
+func (EventRecord) TableName() string {
+	return "event_store_events"
+}
+
+// SnapshotRecord data model of the latest snapshot of a stream
+type SnapshotRecord struct {
+	StreamID  string `gorm:"type:varchar(128);primaryKey"`
+	Version   int64  `gorm:"not null"`
+	Data      []byte `gorm:"type:jsonb;not null"`
+	CreatedAt time.Time
+}
+
+func (SnapshotRecord) TableName() string {
+	return "event_store_snapshots"
+}
+
+// CheckpointRecord data model of the position a projection handled
+type CheckpointRecord struct {
+	Projection string `gorm:"type:varchar(128);primaryKey"`
+	Position   uint64 `gorm:"not null"`
+	UpdatedAt  time.Time
+}
+
+func (CheckpointRecord) TableName() string {
+	return "event_store_checkpoints"
+}
+
+// PostgresStore is a Store in postgres. Appends take turns on an advisory
+// lock, so positions become visible in order and a projection reading past
+// a position never misses an event committed after it.
+//
+// The lock is global, not per stream: appends to different streams wait for
+// each other too, so the store takes about one append per commit latency.
+// That is plenty for the purchase sagas, a few appends per purchase, but
+// not for high volume streams. A lock per stream would let positions commit
+// out of order, projections would then have to read only below the oldest
+// running transaction instead of relying on the lock.
+type PostgresStore struct {
+	db *gorm.DB
+
+	mu        sync.Mutex
+	listeners []chan struct{}
+}
+
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{
+		db: db,
+	}
+}
+
+// Append implements Store.
+func (s *PostgresStore) Append(ctx context.Context, streamID string, expectedVersion int64, events ...*Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	records := make([]*EventRecord, 0, len(events))
+	now := time.Now()
+	for i, evt := range events {
+		var metadata []byte
+		if len(evt.Metadata) > 0 {
+			var err error
+			if metadata, err = json.Marshal(evt.Metadata); err != nil {
+				return err
+			}
+		}
+		records = append(records, &EventRecord{
+			StreamID:   streamID,
+			StreamType: evt.StreamType,
+			Version:    expectedVersion + int64(i) + 1,
+			Type:       evt.Type,
+			Data:       evt.Data,
+			Metadata:   metadata,
+			RecordedAt: now,
+		})
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", appendLockKey).Error; err != nil {
+			return err
+		}
+		var version int64
+		if err := tx.Model(&EventRecord{}).
+			Select("COALESCE(MAX(version), 0)").
+			Where("stream_id = ?", streamID).
+			Scan(&version).Error; err != nil {
+			return err
+		}
+		if version != expectedVersion {
+			return ErrConcurrencyConflict
+		}
+		if err := tx.Create(&records).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrConcurrencyConflict
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, evt := range events {
+		evt.StreamID = streamID
+		evt.Version = records[i].Version
+		evt.Position = records[i].Position
+		evt.RecordedAt = records[i].RecordedAt
+	}
+	s.notify()
+	return nil
+}
+
+// Load implements Store.
+func (s *PostgresStore) Load(ctx context.Context, streamID string, afterVersion int64) ([]*Event, error) {
+	var records []EventRecord
+	if err := s.db.WithContext(ctx).
+		Where("stream_id = ? AND version > ?", streamID, afterVersion).
+		Order("version").
+		Find(&records).Error; err != nil {
+		return nil, err
+	}
+	return toEvents(records)
+}
+
+// LoadSnapshot implements Store.
+func (s *PostgresStore) LoadSnapshot(ctx context.Context, streamID string) (*Snapshot, error) {
+	var record SnapshotRecord
+	if err := s.db.WithContext(ctx).Where("stream_id = ?", streamID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &Snapshot{
+		StreamID:  record.StreamID,
+		Version:   record.Version,
+		Data:      record.Data,
+		CreatedAt: record.CreatedAt,
+	}, nil
+}
+
+// SaveSnapshot implements Store. A snapshot older than the stored one is
+// dropped.
+func (s *PostgresStore) SaveSnapshot(ctx context.Context, snapshot *Snapshot) error {
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "stream_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"version", "data", "created_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "event_store_snapshots.version < excluded.version"},
+		}},
+	}).Create(&SnapshotRecord{
+		StreamID: snapshot.StreamID,
+		Version:  snapshot.Version,
+		Data:     snapshot.Data,
+	}).Error
+}
+
+// listen returns a channel signalled after events were appended
+func (s *PostgresStore) listen() chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch := make(chan struct{}, 1)
+	s.listeners = append(s.listeners, ch)
+	return ch
+}
+
+func (s *PostgresStore) notify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range s.listeners {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func toEvents(records []EventRecord) ([]*Event, error) {
+	events := make([]*Event, 0, len(records))
+	for i := range records {
+		evt, err := toEvent(&records[i])
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, evt)
+	}
+	return events, nil
+}
+
+func toEvent(record *EventRecord) (*Event, error) {
+	var metadata map[string]string
+	if len(record.Metadata) > 0 {
+		if err := json.Unmarshal(record.Metadata, &metadata); err != nil {
+			return nil, err
+		}
+	}
+	return &Event{
+		StreamID:   record.StreamID,
+		StreamType: record.StreamType,
+		Version:    record.Version,
+		Type:       record.Type,
+		Data:       record.Data,
+		Metadata:   metadata,
+		Position:   record.Position,
+		RecordedAt: record.RecordedAt,
+	}, nil
+}
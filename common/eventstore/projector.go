@@ -0,0 +1,149 @@
+package eventstore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Projector feeds the events of a store to its projections. A projection
+// catches up from its checkpoint in batches whenever this process appended
+// events, and every poll interval for the events other instances appended.
+type Projector struct {
+	store        *PostgresStore
+	logger       *logrus.Entry
+	pollInterval time.Duration
+	batchSize    int
+	projections  []Projection
+
+	closing   chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+func NewProjector(store *PostgresStore, logger *logrus.Entry, pollInterval time.Duration, batchSize int) *Projector {
+	return &Projector{
+		store:        store,
+		logger:       logger,
+		pollInterval: pollInterval,
+		batchSize:    batchSize,
+		closing:      make(chan struct{}),
+	}
+}
+
+// Register adds projections, they start with Run
+func (p *Projector) Register(projections ...Projection) {
+	p.projections = append(p.projections, projections...)
+}
+
+// Run runs the projections until Close
+func (p *Projector) Run() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, projection := range p.projections {
+		p.wg.Add(1)
+		go func(projection Projection) {
+			defer p.wg.Done()
+			p.run(ctx, projection)
+		}(projection)
+	}
+
+	<-p.closing
+	cancel()
+	p.wg.Wait()
+	return nil
+}
+
+// Close stops the projections once their current batch is done
+func (p *Projector) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.closing)
+	})
+	p.wg.Wait()
+	return nil
+}
+
+func (p *Projector) run(ctx context.Context, projection Projection) {
+	appended := p.store.listen()
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	logger := p.logger.WithField("projection", projection.Name())
+	for {
+		for {
+			handled, err := p.catchUp(ctx, projection)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.WithError(err).Error("projection catch up")
+				}
+				break
+			}
+			if handled < p.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-appended:
+		case <-ticker.C:
+		}
+	}
+}
+
+// catchUp applies a batch of events after the checkpoint of the projection.
+// The checkpoint row stays locked while the batch is applied, so instances
+// running the same projection never apply an event twice.
+func (p *Projector) catchUp(ctx context.Context, projection Projection) (int, error) {
+	handled := 0
+	err := p.store.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		checkpoint := CheckpointRecord{Projection: projection.Name()}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&checkpoint).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("projection = ?", projection.Name()).
+			First(&checkpoint).Error; err != nil {
+			return err
+		}
+
+		var records []EventRecord
+		if err := tx.Where("position > ?", checkpoint.Position).
+			Order("position").
+			Limit(p.batchSize).
+			Find(&records).Error; err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			return nil
+		}
+
+		for i := range records {
+			evt, err := toEvent(&records[i])
+			if err != nil {
+				return err
+			}
+			if err := projection.Handle(ctx, tx, evt); err != nil {
+				return fmt.Errorf("event %d: %w", evt.Position, err)
+			}
+		}
+		handled = len(records)
+		return tx.Model(&CheckpointRecord{}).
+			Where("projection = ?", projection.Name()).
+			Updates(map[string]any{
+				"position":   records[len(records)-1].Position,
+				"updated_at": time.Now(),
+			}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return handled, nil
+}
@@ -0,0 +1,300 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v5.26.1
+// source: saga.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	SagaAdminService_ListSagas_FullMethodName       = "/saga.SagaAdminService/ListSagas"
+	SagaAdminService_GetSaga_FullMethodName         = "/saga.SagaAdminService/GetSaga"
+	SagaAdminService_GetSagaTimeline_FullMethodName = "/saga.SagaAdminService/GetSagaTimeline"
+	SagaAdminService_RetrySaga_FullMethodName       = "/saga.SagaAdminService/RetrySaga"
+	SagaAdminService_CompensateSaga_FullMethodName  = "/saga.SagaAdminService/CompensateSaga"
+	SagaAdminService_ResolveSaga_FullMethodName     = "/saga.SagaAdminService/ResolveSaga"
+)
+
+// SagaAdminServiceClient is the client API for SagaAdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SagaAdminServiceClient interface {
+	ListSagas(ctx context.Context, in *ListSagasRequest, opts ...grpc.CallOption) (*ListSagasResponse, error)
+	GetSaga(ctx context.Context, in *GetSagaRequest, opts ...grpc.CallOption) (*GetSagaResponse, error)
+	GetSagaTimeline(ctx context.Context, in *GetSagaTimelineRequest, opts ...grpc.CallOption) (*SagaTimeline, error)
+	// publishes the last command of the saga again
+	RetrySaga(ctx context.Context, in *RetrySagaRequest, opts ...grpc.CallOption) (*RetrySagaResponse, error)
+	// rolls back the saga from its current step
+	CompensateSaga(ctx context.Context, in *CompensateSagaRequest, opts ...grpc.CallOption) (*CompensateSagaResponse, error)
+	// closes a saga an operator took care of
+	ResolveSaga(ctx context.Context, in *ResolveSagaRequest, opts ...grpc.CallOption) (*ResolveSagaResponse, error)
+}
+
+type sagaAdminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSagaAdminServiceClient(cc grpc.ClientConnInterface) SagaAdminServiceClient {
+	return &sagaAdminServiceClient{cc}
+}
+
+func (c *sagaAdminServiceClient) ListSagas(ctx context.Context, in *ListSagasRequest, opts ...grpc.CallOption) (*ListSagasResponse, error) {
+	out := new(ListSagasResponse)
+	err := c.cc.Invoke(ctx, SagaAdminService_ListSagas_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sagaAdminServiceClient) GetSaga(ctx context.Context, in *GetSagaRequest, opts ...grpc.CallOption) (*GetSagaResponse, error) {
+	out := new(GetSagaResponse)
+	err := c.cc.Invoke(ctx, SagaAdminService_GetSaga_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sagaAdminServiceClient) GetSagaTimeline(ctx context.Context, in *GetSagaTimelineRequest, opts ...grpc.CallOption) (*SagaTimeline, error) {
+	out := new(SagaTimeline)
+	err := c.cc.Invoke(ctx, SagaAdminService_GetSagaTimeline_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sagaAdminServiceClient) RetrySaga(ctx context.Context, in *RetrySagaRequest, opts ...grpc.CallOption) (*RetrySagaResponse, error) {
+	out := new(RetrySagaResponse)
+	err := c.cc.Invoke(ctx, SagaAdminService_RetrySaga_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sagaAdminServiceClient) CompensateSaga(ctx context.Context, in *CompensateSagaRequest, opts ...grpc.CallOption) (*CompensateSagaResponse, error) {
+	out := new(CompensateSagaResponse)
+	err := c.cc.Invoke(ctx, SagaAdminService_CompensateSaga_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sagaAdminServiceClient) ResolveSaga(ctx context.Context, in *ResolveSagaRequest, opts ...grpc.CallOption) (*ResolveSagaResponse, error) {
+	out := new(ResolveSagaResponse)
+	err := c.cc.Invoke(ctx, SagaAdminService_ResolveSaga_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SagaAdminServiceServer is the server API for SagaAdminService service.
+// All implementations must embed UnimplementedSagaAdminServiceServer
+// for forward compatibility
+type SagaAdminServiceServer interface {
+	ListSagas(context.Context, *ListSagasRequest) (*ListSagasResponse, error)
+	GetSaga(context.Context, *GetSagaRequest) (*GetSagaResponse, error)
+	GetSagaTimeline(context.Context, *GetSagaTimelineRequest) (*SagaTimeline, error)
+	// publishes the last command of the saga again
+	RetrySaga(context.Context, *RetrySagaRequest) (*RetrySagaResponse, error)
+	// rolls back the saga from its current step
+	CompensateSaga(context.Context, *CompensateSagaRequest) (*CompensateSagaResponse, error)
+	// closes a saga an operator took care of
+	ResolveSaga(context.Context, *ResolveSagaRequest) (*ResolveSagaResponse, error)
+	mustEmbedUnimplementedSagaAdminServiceServer()
+}
+
+// UnimplementedSagaAdminServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedSagaAdminServiceServer struct {
+}
+
+func (UnimplementedSagaAdminServiceServer) ListSagas(context.Context, *ListSagasRequest) (*ListSagasResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSagas not implemented")
+}
+func (UnimplementedSagaAdminServiceServer) GetSaga(context.Context, *GetSagaRequest) (*GetSagaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSaga not implemented")
+}
+func (UnimplementedSagaAdminServiceServer) GetSagaTimeline(context.Context, *GetSagaTimelineRequest) (*SagaTimeline, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSagaTimeline not implemented")
+}
+func (UnimplementedSagaAdminServiceServer) RetrySaga(context.Context, *RetrySagaRequest) (*RetrySagaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RetrySaga not implemented")
+}
+func (UnimplementedSagaAdminServiceServer) CompensateSaga(context.Context, *CompensateSagaRequest) (*CompensateSagaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompensateSaga not implemented")
+}
+func (UnimplementedSagaAdminServiceServer) ResolveSaga(context.Context, *ResolveSagaRequest) (*ResolveSagaResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResolveSaga not implemented")
+}
+func (UnimplementedSagaAdminServiceServer) mustEmbedUnimplementedSagaAdminServiceServer() {}
+
+// UnsafeSagaAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SagaAdminServiceServer will
+// result in compilation errors.
+type UnsafeSagaAdminServiceServer interface {
+	mustEmbedUnimplementedSagaAdminServiceServer()
+}
+
+func RegisterSagaAdminServiceServer(s grpc.ServiceRegistrar, srv SagaAdminServiceServer) {
+	s.RegisterService(&SagaAdminService_ServiceDesc, srv)
+}
+
+func _SagaAdminService_ListSagas_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSagasRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SagaAdminServiceServer).ListSagas(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SagaAdminService_ListSagas_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SagaAdminServiceServer).ListSagas(ctx, req.(*ListSagasRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SagaAdminService_GetSaga_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSagaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SagaAdminServiceServer).GetSaga(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SagaAdminService_GetSaga_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SagaAdminServiceServer).GetSaga(ctx, req.(*GetSagaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SagaAdminService_GetSagaTimeline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSagaTimelineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SagaAdminServiceServer).GetSagaTimeline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SagaAdminService_GetSagaTimeline_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SagaAdminServiceServer).GetSagaTimeline(ctx, req.(*GetSagaTimelineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SagaAdminService_RetrySaga_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RetrySagaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SagaAdminServiceServer).RetrySaga(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SagaAdminService_RetrySaga_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SagaAdminServiceServer).RetrySaga(ctx, req.(*RetrySagaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SagaAdminService_CompensateSaga_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompensateSagaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SagaAdminServiceServer).CompensateSaga(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SagaAdminService_CompensateSaga_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SagaAdminServiceServer).CompensateSaga(ctx, req.(*CompensateSagaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SagaAdminService_ResolveSaga_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResolveSagaRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SagaAdminServiceServer).ResolveSaga(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SagaAdminService_ResolveSaga_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SagaAdminServiceServer).ResolveSaga(ctx, req.(*ResolveSagaRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SagaAdminService_ServiceDesc is the grpc.ServiceDesc for SagaAdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SagaAdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "saga.SagaAdminService",
+	HandlerType: (*SagaAdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListSagas",
+			Handler:    _SagaAdminService_ListSagas_Handler,
+		},
+		{
+			MethodName: "GetSaga",
+			Handler:    _SagaAdminService_GetSaga_Handler,
+		},
+		{
+			MethodName: "GetSagaTimeline",
+			Handler:    _SagaAdminService_GetSagaTimeline_Handler,
+		},
+		{
+			MethodName: "RetrySaga",
+			Handler:    _SagaAdminService_RetrySaga_Handler,
+		},
+		{
+			MethodName: "CompensateSaga",
+			Handler:    _SagaAdminService_CompensateSaga_Handler,
+		},
+		{
+			MethodName: "ResolveSaga",
+			Handler:    _SagaAdminService_ResolveSaga_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "saga.proto",
+}
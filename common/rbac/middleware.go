@@ -0,0 +1,24 @@
+package rbac
+
+import (
+	"net/http"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/constant"
+	"github.com/Chengxufeng1994/go-saga-example/common/model"
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission aborts requests of users lacking any of the permissions,
+// it must run after the authentication middleware of the service
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted, _ := c.Request.Context().Value(constant.CtxPermissionsKey).([]string)
+		if !HasPermission(granted, permissions...) {
+			err := model.NewAppError("PermissionMiddleware", "app.auth.permission_denied.error", nil, "")
+			c.AbortWithStatusJSON(http.StatusForbidden, err)
+			return
+		}
+
+		c.Next()
+	}
+}
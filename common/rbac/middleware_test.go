@@ -0,0 +1,44 @@
+package rbac
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/constant"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		granted []string
+		want    int
+	}{
+		{"all granted", []string{PermissionLedgerRead, PermissionSagaRead}, http.StatusOK},
+		{"one missing", []string{PermissionLedgerRead}, http.StatusForbidden},
+		{"none granted", nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := gin.New()
+			engine.Use(func(c *gin.Context) {
+				if tt.granted != nil {
+					ctx := context.WithValue(c.Request.Context(), constant.CtxPermissionsKey, tt.granted)
+					c.Request = c.Request.WithContext(ctx)
+				}
+			})
+			engine.GET("/", RequirePermission(PermissionLedgerRead, PermissionSagaRead), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package rbac
+
+const (
+	// RoleAdmin operates the shop
+	RoleAdmin = "admin"
+	// RoleCustomer is granted to every user on sign up
+	RoleCustomer = "customer"
+	// RoleSupport looks into the purchases of customers
+	RoleSupport = "support"
+	// RoleFinance reconciles payments
+	RoleFinance = "finance"
+)
+
+const (
+	// PermissionCatalogWrite creates and updates products
+	PermissionCatalogWrite = "catalog:write"
+	// PermissionInventoryAdjust adjusts product inventory outside a purchase
+	PermissionInventoryAdjust = "inventory:adjust"
+	// PermissionDLQReplay replays messages from the dead letter queue
+	PermissionDLQReplay = "dlq:replay"
+	// PermissionUserManage assigns roles to users
+	PermissionUserManage = "user:manage"
+	// PermissionSagaRead reads the timeline of purchase sagas
+	PermissionSagaRead = "saga:read"
+	// PermissionSagaManage retries, compensates and resolves purchase sagas
+	PermissionSagaManage = "saga:manage"
+	// PermissionLedgerRead reads the payment ledger of any purchase
+	PermissionLedgerRead = "ledger:read"
+)
+
+// DefaultRoles are the roles and their permissions seeded by auth-svc
+var DefaultRoles = map[string][]string{
+	RoleAdmin: {
+		PermissionCatalogWrite,
+		PermissionInventoryAdjust,
+		PermissionDLQReplay,
+		PermissionUserManage,
+		PermissionSagaRead,
+		PermissionSagaManage,
+		PermissionLedgerRead,
+	},
+	RoleCustomer: {},
+	RoleSupport: {
+		PermissionSagaRead,
+	},
+	RoleFinance: {
+		PermissionLedgerRead,
+	},
+}
+
+// HasPermission reports whether the granted permissions contain all the required ones
+func HasPermission(granted []string, required ...string) bool {
+	for _, r := range required {
+		found := false
+		for _, g := range granted {
+			if g == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
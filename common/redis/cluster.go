@@ -73,6 +73,22 @@ func (c *ClusterClient) Get(ctx context.Context, key string, dst interface{}) (b
 	return true, nil
 }
 
+// GetDel gets the value for the given key and deletes the key.
+func (c *ClusterClient) GetDel(ctx context.Context, key string, dst interface{}) (bool, error) {
+	val, err := c.client.GetDel(ctx, key).Result()
+	switch {
+	case err == redis.Nil:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	if err := json.Unmarshal([]byte(val), dst); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // Set stores the given value for the given key along with a
 func (c *ClusterClient) Set(ctx context.Context, key string, val interface{}, ttl int) error {
 	dat, err := json.Marshal(val)
@@ -93,6 +109,53 @@ func (c *ClusterClient) Del(ctx context.Context, key string) error {
 	return nil
 }
 
+// SAdd adds the members to the set stored at key.
+func (c *ClusterClient) SAdd(ctx context.Context, key string, members ...string) error {
+	return c.client.SAdd(ctx, key, toArgs(members)...).Err()
+}
+
+// SRem removes the members from the set stored at key.
+func (c *ClusterClient) SRem(ctx context.Context, key string, members ...string) error {
+	return c.client.SRem(ctx, key, toArgs(members)...).Err()
+}
+
+// SMembers returns all the members of the set stored at key.
+func (c *ClusterClient) SMembers(ctx context.Context, key string) ([]string, error) {
+	return c.client.SMembers(ctx, key).Result()
+}
+
+// Expire sets a timeout in seconds on key.
+func (c *ClusterClient) Expire(ctx context.Context, key string, ttl int) error {
+	return c.client.Expire(ctx, key, time.Duration(ttl)*time.Second).Err()
+}
+
+// Incr increments the number stored at key, a missing key starts at 0.
+func (c *ClusterClient) Incr(ctx context.Context, key string) (int64, error) {
+	return c.client.Incr(ctx, key).Result()
+}
+
+// ZAdd adds the member with the score to the sorted set stored at key.
+func (c *ClusterClient) ZAdd(ctx context.Context, key string, score float64, member string) error {
+	return c.client.ZAdd(ctx, key, redis.Z{Score: score, Member: member}).Err()
+}
+
+// ZRemRangeByScore removes the members of the sorted set stored at key with
+// a score between min and max.
+func (c *ClusterClient) ZRemRangeByScore(ctx context.Context, key string, min, max string) error {
+	return c.client.ZRemRangeByScore(ctx, key, min, max).Err()
+}
+
+// ZCard returns the number of members of the sorted set stored at key.
+func (c *ClusterClient) ZCard(ctx context.Context, key string) (int64, error) {
+	return c.client.ZCard(ctx, key).Result()
+}
+
+// Eval runs the lua script atomically against the given keys, all keys have
+// to hash to the same slot.
+func (c *ClusterClient) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	return redis.NewScript(script).Run(ctx, c.client, keys, args...).Result()
+}
+
 // Ping check redis connection
 func (c *ClusterClient) Ping() error {
 	ctx := context.Background()
@@ -121,3 +184,11 @@ func (c *ClusterClient) Close() error {
 func getServerAddrs(addrs string) []string {
 	return strings.Split(addrs, delimiter)
 }
+
+func toArgs(members []string) []interface{} {
+	args := make([]interface{}, len(members))
+	for i, member := range members {
+		args[i] = member
+	}
+	return args
+}
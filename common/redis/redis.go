@@ -4,8 +4,18 @@ import "context"
 
 type RedisCache interface {
 	Get(ctx context.Context, key string, dst interface{}) (bool, error)
+	GetDel(ctx context.Context, key string, dst interface{}) (bool, error)
 	Set(ctx context.Context, key string, val interface{}, ttl int) error
 	Del(ctx context.Context, key string) error
+	SAdd(ctx context.Context, key string, members ...string) error
+	SRem(ctx context.Context, key string, members ...string) error
+	SMembers(ctx context.Context, key string) ([]string, error)
+	Expire(ctx context.Context, key string, ttl int) error
+	Incr(ctx context.Context, key string) (int64, error)
+	ZAdd(ctx context.Context, key string, score float64, member string) error
+	ZRemRangeByScore(ctx context.Context, key string, min, max string) error
+	ZCard(ctx context.Context, key string) (int64, error)
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
 	Ping() error
 	Close() error
 }
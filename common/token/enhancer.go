@@ -2,5 +2,15 @@ package token
 
 type Enhancer interface {
 	Sign(claims *Claims) (string, error)
+	Verifier
+}
+
+// Verifier verifies signed tokens without being able to sign them
+type Verifier interface {
 	Verify(value string) (*Claims, error)
 }
+
+// JWKSProvider is implemented by enhancers which publish their verification keys
+type JWKSProvider interface {
+	JWKS() *JWKS
+}
@@ -0,0 +1,301 @@
+package token
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const rsaKeySize = 2048
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrUnknownKey           = errors.New("unknown signing key")
+	ErrNoActiveKey          = errors.New("no active signing key")
+)
+
+// PublicKey is a verification key identified by its kid
+type PublicKey struct {
+	ID        string
+	Algorithm string
+	Key       crypto.PublicKey
+}
+
+// KeySet looks up verification keys by their kid
+type KeySet interface {
+	PublicKey(kid string) (*PublicKey, error)
+}
+
+// SigningKey is a private key of the keyring which becomes the signing key at ActiveAt
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+	ActiveAt   time.Time
+}
+
+func (k *SigningKey) PublicKey() *PublicKey {
+	return &PublicKey{ID: k.ID, Algorithm: k.Method.Alg(), Key: k.PrivateKey.Public()}
+}
+
+// SigningMethod returns the asymmetric signing method of alg
+func SigningMethod(alg string) (jwt.SigningMethod, error) {
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		return jwt.SigningMethodRS256, nil
+	case jwt.SigningMethodEdDSA.Alg():
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, alg)
+	}
+}
+
+// GenerateSigningKey creates a fresh key for the signing method
+func GenerateSigningKey(method jwt.SigningMethod, activeAt time.Time) (*SigningKey, error) {
+	var (
+		signer crypto.Signer
+		err    error
+	)
+	switch method {
+	case jwt.SigningMethodRS256:
+		signer, err = rsa.GenerateKey(rand.Reader, rsaKeySize)
+	case jwt.SigningMethodEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, method.Alg())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{Method: method, PrivateKey: signer, ActiveAt: activeAt}
+	key.ID, err = Thumbprint(key.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// LoadSigningKey reads a PEM encoded PKCS #8 RSA or Ed25519 private key,
+// the kid defaults to the key thumbprint
+func LoadSigningKey(id, file string) (*SigningKey, error) {
+	dat, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParseSigningKey(id, dat)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return key, nil
+}
+
+// ParseSigningKey decodes a PEM encoded PKCS #8 RSA or Ed25519 private key,
+// the kid defaults to the key thumbprint
+func ParseSigningKey(id string, dat []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(dat)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{ID: id}
+	switch signer := privateKey.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.PrivateKey = jwt.SigningMethodRS256, signer
+	case ed25519.PrivateKey:
+		key.Method, key.PrivateKey = jwt.SigningMethodEdDSA, signer
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedAlgorithm, privateKey)
+	}
+	if key.ID == "" {
+		if key.ID, err = Thumbprint(key.PublicKey()); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// EncodeSigningKey encodes the private key as PEM encoded PKCS #8, the
+// format read by ParseSigningKey
+func EncodeSigningKey(key *SigningKey) ([]byte, error) {
+	dat, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: dat}), nil
+}
+
+// KeyStore persists the keys of a keyring, replicas sharing a store sign and
+// publish the same keys and keep them across restarts
+type KeyStore interface {
+	// LoadKeys returns the stored keys in the order they became active
+	LoadKeys(ctx context.Context) ([]*SigningKey, error)
+	// AddKey stores key as the successor of the key previousID, empty for the
+	// first key. It reports false when another key already succeeded it.
+	AddKey(ctx context.Context, key *SigningKey, previousID string) (bool, error)
+	// RemoveKey deletes a retired key
+	RemoveKey(ctx context.Context, id string) error
+}
+
+// Keyring holds the signing keys in the order they became active. Rotated
+// keys are published ahead of their activation so that verifiers already
+// cached them, and retired keys are kept until the tokens they signed expired.
+type Keyring struct {
+	mu         sync.RWMutex
+	method     jwt.SigningMethod
+	keys       []*SigningKey
+	prePublish time.Duration
+	retention  time.Duration
+}
+
+func NewKeyring(method jwt.SigningMethod, prePublish, retention time.Duration) *Keyring {
+	return &Keyring{
+		method:     method,
+		prePublish: prePublish,
+		retention:  retention,
+	}
+}
+
+// Add appends a key, it must activate after the keys already in the keyring
+func (k *Keyring) Add(key *SigningKey) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = append(k.keys, key)
+}
+
+// Active returns the newest key which is already active
+func (k *Keyring) Active() (*SigningKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := time.Now()
+	for i := len(k.keys) - 1; i >= 0; i-- {
+		if !k.keys[i].ActiveAt.After(now) {
+			return k.keys[i], nil
+		}
+	}
+	return nil, ErrNoActiveKey
+}
+
+// PublicKey implements KeySet.
+func (k *Keyring) PublicKey(kid string) (*PublicKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	for _, key := range k.keys {
+		if key.ID == kid {
+			return key.PublicKey(), nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// JWKS returns the public keys of every key in the keyring
+func (k *Keyring) JWKS() *JWKS {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	jwks := &JWKS{Keys: make([]JWK, 0, len(k.keys))}
+	for _, key := range k.keys {
+		jwk, err := NewJWK(key.PublicKey())
+		if err != nil {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
+
+// Sync replaces the keys with the keys of the store and rotates once the
+// newest key is older than the interval, a zero interval never rotates. The
+// store decides between replicas rotating at the same time, the losers pick
+// up the winning key on the next sync.
+func (k *Keyring) Sync(ctx context.Context, store KeyStore, interval time.Duration) error {
+	keys, err := store.LoadKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var (
+		activeAt   time.Time
+		previousID string
+	)
+	switch {
+	case len(keys) == 0:
+		// the first key signs right away, there is nothing to verify yet
+		activeAt = now
+	case interval > 0 && !keys[len(keys)-1].ActiveAt.Add(interval).After(now.Add(k.prePublish)):
+		activeAt, previousID = now.Add(k.prePublish), keys[len(keys)-1].ID
+	}
+	if !activeAt.IsZero() {
+		key, err := GenerateSigningKey(k.method, activeAt)
+		if err != nil {
+			return err
+		}
+		if _, err := store.AddKey(ctx, key, previousID); err != nil {
+			return err
+		}
+		if keys, err = store.LoadKeys(ctx); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range keys {
+		if key.Method != k.method {
+			return fmt.Errorf("stored key %s is not a %s key", key.ID, k.method.Alg())
+		}
+	}
+
+	retained := make([]*SigningKey, 0, len(keys))
+	for i, key := range keys {
+		// a key retires once its successor becomes active
+		if i+1 < len(keys) && now.Sub(keys[i+1].ActiveAt) > k.retention {
+			if err := store.RemoveKey(ctx, key.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		retained = append(retained, key)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = retained
+	return nil
+}
+
+// StartSync syncs the keyring with the store every period until ctx is done.
+// The period has to be shorter than the pre-publish window so that every
+// replica publishes a rotated key before it signs.
+func (k *Keyring) StartSync(ctx context.Context, store KeyStore, interval, period time.Duration, onError func(error)) {
+	go func() {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := k.Sync(ctx, store, interval); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,109 @@
+package token
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrMissingKeyID      = errors.New("missing kid header")
+	ErrAlgorithmMismatch = errors.New("token algorithm does not match the key")
+	ErrTokenTooOld       = errors.New("token is older than the accepted age")
+)
+
+// KeyringEnhancer signs with the active key of the keyring and sets the kid header
+type KeyringEnhancer struct {
+	keyring *Keyring
+	Verifier
+}
+
+// NewKeyringEnhancer signs tokens with RS256 or EdDSA depending on the keys of the keyring
+func NewKeyringEnhancer(keyring *Keyring) Enhancer {
+	return &KeyringEnhancer{keyring: keyring, Verifier: NewKeySetVerifier(keyring)}
+}
+
+func (enhancer *KeyringEnhancer) Sign(claims *Claims) (string, error) {
+	key, err := enhancer.keyring.Active()
+	if err != nil {
+		return "", err
+	}
+
+	tokenClaims := jwt.NewWithClaims(key.Method, claims)
+	tokenClaims.Header["kid"] = key.ID
+	return tokenClaims.SignedString(key.PrivateKey)
+}
+
+// JWKS implements JWKSProvider.
+func (enhancer *KeyringEnhancer) JWKS() *JWKS {
+	return enhancer.keyring.JWKS()
+}
+
+// KeySetVerifier verifies RS256 and EdDSA tokens with the key named by their kid header
+type KeySetVerifier struct {
+	keySet KeySet
+}
+
+func NewKeySetVerifier(keySet KeySet) Verifier {
+	return &KeySetVerifier{keySet: keySet}
+}
+
+func (verifier *KeySetVerifier) Verify(value string) (*Claims, error) {
+	parser := jwt.NewParser(jwt.WithValidMethods(ValidMethods))
+	tokenClaims, err := parser.ParseWithClaims(value, &Claims{}, KeyFunc(verifier.keySet))
+	if err != nil {
+		return nil, err
+	}
+
+	return tokenClaims.Claims.(*Claims), nil
+}
+
+// maxAgeVerifier rejects the tokens of its verifier issued too long ago
+type maxAgeVerifier struct {
+	Verifier
+	maxAge time.Duration
+}
+
+// WithMaxAge bounds the age of the tokens verifier accepts, a bound of zero
+// accepts any. Services verifying tokens locally do not see revoked
+// sessions, the bound caps how long those stay usable there whatever
+// lifetime the tokens were issued with.
+func WithMaxAge(verifier Verifier, maxAge time.Duration) Verifier {
+	if maxAge <= 0 {
+		return verifier
+	}
+	return &maxAgeVerifier{Verifier: verifier, maxAge: maxAge}
+}
+
+func (verifier *maxAgeVerifier) Verify(value string) (*Claims, error) {
+	claims, err := verifier.Verifier.Verify(value)
+	if err != nil {
+		return nil, err
+	}
+	if claims.IssuedAt == nil || time.Since(claims.IssuedAt.Time) > verifier.maxAge {
+		return nil, ErrTokenTooOld
+	}
+	return claims, nil
+}
+
+// ValidMethods are the algorithms keys of a KeySet can verify
+var ValidMethods = []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+
+// KeyFunc looks up the key named by the kid header of a token in keySet
+func KeyFunc(keySet KeySet) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, ErrMissingKeyID
+		}
+		key, err := keySet.PublicKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, ErrAlgorithmMismatch
+		}
+		return key.Key, nil
+	}
+}
@@ -0,0 +1,307 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// memoryKeyStore is a KeyStore which enforces a single successor per key like the database does
+type memoryKeyStore struct {
+	mu       sync.Mutex
+	keys     []*SigningKey
+	previous map[string]bool
+}
+
+func newMemoryKeyStore() *memoryKeyStore {
+	return &memoryKeyStore{previous: map[string]bool{}}
+}
+
+func (s *memoryKeyStore) LoadKeys(context.Context) ([]*SigningKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*SigningKey(nil), s.keys...), nil
+}
+
+func (s *memoryKeyStore) AddKey(_ context.Context, key *SigningKey, previousID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.previous[previousID] {
+		return false, nil
+	}
+	// round trip the key like a persistent store does
+	dat, err := EncodeSigningKey(key)
+	if err != nil {
+		return false, err
+	}
+	stored, err := ParseSigningKey(key.ID, dat)
+	if err != nil {
+		return false, err
+	}
+	stored.ActiveAt = key.ActiveAt
+	s.previous[previousID] = true
+	s.keys = append(s.keys, stored)
+	return true, nil
+}
+
+func (s *memoryKeyStore) RemoveKey(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, key := range s.keys {
+		if key.ID == id {
+			s.keys = append(s.keys[:i], s.keys[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func TestSigningKeyEncoding(t *testing.T) {
+	for _, method := range []jwt.SigningMethod{jwt.SigningMethodRS256, jwt.SigningMethodEdDSA} {
+		t.Run(method.Alg(), func(t *testing.T) {
+			key, err := GenerateSigningKey(method, time.Now())
+			if err != nil {
+				t.Fatalf("GenerateSigningKey() error = %v", err)
+			}
+			dat, err := EncodeSigningKey(key)
+			if err != nil {
+				t.Fatalf("EncodeSigningKey() error = %v", err)
+			}
+			parsed, err := ParseSigningKey("", dat)
+			if err != nil {
+				t.Fatalf("ParseSigningKey() error = %v", err)
+			}
+			if parsed.ID != key.ID || parsed.Method != method {
+				t.Errorf("ParseSigningKey() = %s %s, want %s %s", parsed.ID, parsed.Method.Alg(), key.ID, method.Alg())
+			}
+		})
+	}
+
+	if _, err := ParseSigningKey("", []byte("not a key")); err == nil {
+		t.Error("ParseSigningKey() of garbage succeeded")
+	}
+}
+
+func TestKeyringSyncSharesKeys(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryKeyStore()
+	first := NewKeyring(jwt.SigningMethodEdDSA, time.Minute, time.Hour)
+	second := NewKeyring(jwt.SigningMethodEdDSA, time.Minute, time.Hour)
+
+	if err := first.Sync(ctx, store, time.Hour); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if err := second.Sync(ctx, store, time.Hour); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	// the first key signs right away and both replicas use it
+	signed, err := NewKeyringEnhancer(first).Sign(&Claims{UserID: 1})
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	claims, err := NewKeyringEnhancer(second).Verify(signed)
+	if err != nil {
+		t.Fatalf("Verify() with the other replica error = %v", err)
+	}
+	if claims.UserID != 1 {
+		t.Errorf("Verify() user = %d, want 1", claims.UserID)
+	}
+
+	// a restarted replica keeps verifying tokens signed before the restart
+	restarted := NewKeyring(jwt.SigningMethodEdDSA, time.Minute, time.Hour)
+	if err := restarted.Sync(ctx, store, time.Hour); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if _, err := NewKeyringEnhancer(restarted).Verify(signed); err != nil {
+		t.Fatalf("Verify() after restart error = %v", err)
+	}
+	if keys, _ := store.LoadKeys(ctx); len(keys) != 1 {
+		t.Errorf("stored keys = %d, want 1", len(keys))
+	}
+}
+
+func TestKeyringSyncRotation(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryKeyStore()
+	old, err := GenerateSigningKey(jwt.SigningMethodEdDSA, time.Now().Add(-2*time.Hour))
+	if err != nil {
+		t.Fatalf("GenerateSigningKey() error = %v", err)
+	}
+	if _, err := store.AddKey(ctx, old, ""); err != nil {
+		t.Fatalf("AddKey() error = %v", err)
+	}
+
+	// replicas rotating the same due key at once agree on a single successor
+	keyrings := make([]*Keyring, 5)
+	var wg sync.WaitGroup
+	for i := range keyrings {
+		keyrings[i] = NewKeyring(jwt.SigningMethodEdDSA, time.Minute, time.Hour)
+		wg.Add(1)
+		go func(k *Keyring) {
+			defer wg.Done()
+			if err := k.Sync(ctx, store, time.Hour); err != nil {
+				t.Errorf("Sync() error = %v", err)
+			}
+		}(keyrings[i])
+	}
+	wg.Wait()
+
+	keys, _ := store.LoadKeys(ctx)
+	if len(keys) != 2 {
+		t.Fatalf("stored keys = %d, want 2", len(keys))
+	}
+	for _, k := range keyrings {
+		if got := k.JWKS().Keys; len(got) != 2 || got[1].Kid != keys[1].ID {
+			t.Errorf("JWKS() = %+v, want the rotated key %s published", got, keys[1].ID)
+		}
+		// the successor is published ahead of its activation
+		active, err := k.Active()
+		if err != nil || active.ID != old.ID {
+			t.Errorf("Active() = %v, %v, want %s", active, err, old.ID)
+		}
+	}
+
+	// a key which is not due yet is not rotated again
+	if err := keyrings[0].Sync(ctx, store, time.Hour); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if keys, _ := store.LoadKeys(ctx); len(keys) != 2 {
+		t.Errorf("stored keys = %d, want 2", len(keys))
+	}
+}
+
+func TestKeyringSyncRetention(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryKeyStore()
+	now := time.Now()
+	var previousID string
+	for _, activeAt := range []time.Time{now.Add(-3 * time.Hour), now.Add(-2 * time.Hour), now.Add(-time.Minute)} {
+		key, err := GenerateSigningKey(jwt.SigningMethodEdDSA, activeAt)
+		if err != nil {
+			t.Fatalf("GenerateSigningKey() error = %v", err)
+		}
+		if _, err := store.AddKey(ctx, key, previousID); err != nil {
+			t.Fatalf("AddKey() error = %v", err)
+		}
+		previousID = key.ID
+	}
+
+	k := NewKeyring(jwt.SigningMethodEdDSA, time.Minute, 90*time.Minute)
+	if err := k.Sync(ctx, store, 0); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	// the first key was superseded two hours ago, longer than the retention
+	keys, _ := store.LoadKeys(ctx)
+	if len(keys) != 2 || len(k.JWKS().Keys) != 2 {
+		t.Fatalf("keys = %d stored, %d published, want 2", len(keys), len(k.JWKS().Keys))
+	}
+	if active, _ := k.Active(); active.ID != previousID {
+		t.Errorf("Active() = %s, want %s", active.ID, previousID)
+	}
+}
+
+func TestKeyringSyncRejectsOtherAlgorithm(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryKeyStore()
+	if err := NewKeyring(jwt.SigningMethodEdDSA, 0, 0).Sync(ctx, store, 0); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if err := NewKeyring(jwt.SigningMethodRS256, 0, 0).Sync(ctx, store, 0); err == nil {
+		t.Fatal("Sync() with keys of another algorithm succeeded")
+	}
+}
+
+func TestKeySetVerifierRejects(t *testing.T) {
+	k := NewKeyring(jwt.SigningMethodEdDSA, 0, 0)
+	key, err := GenerateSigningKey(jwt.SigningMethodEdDSA, time.Now())
+	if err != nil {
+		t.Fatalf("GenerateSigningKey() error = %v", err)
+	}
+	k.Add(key)
+	verifier := NewKeySetVerifier(k)
+
+	other, err := GenerateSigningKey(jwt.SigningMethodEdDSA, time.Now())
+	if err != nil {
+		t.Fatalf("GenerateSigningKey() error = %v", err)
+	}
+	unknown := jwt.NewWithClaims(jwt.SigningMethodEdDSA, &Claims{})
+	unknown.Header["kid"] = other.ID
+	missing := jwt.NewWithClaims(jwt.SigningMethodEdDSA, &Claims{})
+	forged := jwt.NewWithClaims(jwt.SigningMethodEdDSA, &Claims{})
+	forged.Header["kid"] = key.ID
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{})
+	hmac.Header["kid"] = key.ID
+
+	tests := []struct {
+		name    string
+		token   *jwt.Token
+		signKey interface{}
+		wantErr error
+	}{
+		{"unknown kid", unknown, other.PrivateKey, ErrUnknownKey},
+		{"missing kid", missing, key.PrivateKey, ErrMissingKeyID},
+		{"signed by another key", forged, other.PrivateKey, nil},
+		{"symmetric algorithm", hmac, []byte("secret"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := tt.token.SignedString(tt.signKey)
+			if err != nil {
+				t.Fatalf("SignedString() error = %v", err)
+			}
+			_, err = verifier.Verify(signed)
+			if err == nil {
+				t.Fatal("Verify() succeeded")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithMaxAge(t *testing.T) {
+	k := NewKeyring(jwt.SigningMethodEdDSA, 0, 0)
+	key, err := GenerateSigningKey(jwt.SigningMethodEdDSA, time.Now())
+	if err != nil {
+		t.Fatalf("GenerateSigningKey() error = %v", err)
+	}
+	k.Add(key)
+	enhancer := NewKeyringEnhancer(k)
+
+	tests := []struct {
+		name     string
+		issuedAt *jwt.NumericDate
+		maxAge   time.Duration
+		wantErr  error
+	}{
+		{"fresh", jwt.NewNumericDate(time.Now()), time.Minute, nil},
+		{"older than the bound", jwt.NewNumericDate(time.Now().Add(-2 * time.Minute)), time.Minute, ErrTokenTooOld},
+		{"not issued at", nil, time.Minute, ErrTokenTooOld},
+		{"no bound", jwt.NewNumericDate(time.Now().Add(-time.Hour)), 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := enhancer.Sign(&Claims{UserID: 1, RegisteredClaims: jwt.RegisteredClaims{
+				IssuedAt:  tt.issuedAt,
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			}})
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			claims, err := WithMaxAge(NewKeySetVerifier(k), tt.maxAge).Verify(signed)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && claims.UserID != 1 {
+				t.Errorf("Verify() user = %d, want 1", claims.UserID)
+			}
+		})
+	}
+}
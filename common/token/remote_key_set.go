@@ -0,0 +1,118 @@
+package token
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// minRefreshInterval throttles refetching the JWKS for unknown kids
+	minRefreshInterval = 10 * time.Second
+	fetchTimeout       = 5 * time.Second
+)
+
+// RemoteKeySet caches the keys published on a JWKS endpoint. Keys are
+// refetched once the cache expired or when a token names an unknown kid,
+// which is how keys published by a rotation are picked up.
+type RemoteKeySet struct {
+	mu        sync.Mutex
+	url       string
+	ttl       time.Duration
+	client    *http.Client
+	keys      map[string]*PublicKey
+	fetchedAt time.Time
+	// fetch is the request in flight, concurrent lookups wait for it
+	// instead of holding the lock during the request
+	fetch *keyFetch
+}
+
+type keyFetch struct {
+	done chan struct{}
+	err  error
+}
+
+func NewRemoteKeySet(url string, ttl time.Duration) KeySet {
+	return &RemoteKeySet{
+		url:    url,
+		ttl:    ttl,
+		client: &http.Client{Timeout: fetchTimeout},
+		keys:   map[string]*PublicKey{},
+	}
+}
+
+// PublicKey implements KeySet.
+func (s *RemoteKeySet) PublicKey(kid string) (*PublicKey, error) {
+	s.mu.Lock()
+	since := time.Since(s.fetchedAt)
+	key, ok := s.keys[kid]
+	if ok && since < s.ttl {
+		s.mu.Unlock()
+		return key, nil
+	}
+	fetch := s.fetch
+	if fetch == nil && !ok && since < minRefreshInterval {
+		s.mu.Unlock()
+		return nil, ErrUnknownKey
+	}
+	if fetch == nil {
+		fetch = &keyFetch{done: make(chan struct{})}
+		s.fetch, s.fetchedAt = fetch, time.Now()
+		s.mu.Unlock()
+
+		keys, err := s.fetchKeys()
+
+		s.mu.Lock()
+		if err == nil {
+			s.keys = keys
+		}
+		fetch.err, s.fetch = err, nil
+		close(fetch.done)
+	}
+	s.mu.Unlock()
+	<-fetch.done
+
+	if fetch.err != nil {
+		// keep serving cached keys while the endpoint is unavailable
+		if ok {
+			return key, nil
+		}
+		return nil, fetch.err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok = s.keys[kid]; !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+func (s *RemoteKeySet) fetchKeys() (map[string]*PublicKey, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	var jwks JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		key, err := jwk.PublicKey()
+		if err != nil {
+			// skip keys of algorithms we cannot verify
+			continue
+		}
+		keys[key.ID] = key
+	}
+	return keys, nil
+}
@@ -0,0 +1,121 @@
+package token
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestRemoteKeySet(t *testing.T) {
+	keyring := NewKeyring(jwt.SigningMethodEdDSA, 0, 0)
+	first, err := GenerateSigningKey(jwt.SigningMethodEdDSA, time.Now())
+	if err != nil {
+		t.Fatalf("GenerateSigningKey() error = %v", err)
+	}
+	keyring.Add(first)
+
+	var (
+		fetches   atomic.Int32
+		available atomic.Bool
+	)
+	available.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if !available.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(keyring.JWKS())
+	}))
+	defer srv.Close()
+
+	keySet := NewRemoteKeySet(srv.URL, time.Hour).(*RemoteKeySet)
+	if key, err := keySet.PublicKey(first.ID); err != nil || key.ID != first.ID {
+		t.Fatalf("PublicKey() = %v, %v, want %s", key, err, first.ID)
+	}
+	if _, err := keySet.PublicKey(first.ID); err != nil || fetches.Load() != 1 {
+		t.Fatalf("cached PublicKey() error = %v after %d fetches, want 1 fetch", err, fetches.Load())
+	}
+
+	// unknown kids refetch at most once per minRefreshInterval
+	rotated, err := GenerateSigningKey(jwt.SigningMethodEdDSA, time.Now())
+	if err != nil {
+		t.Fatalf("GenerateSigningKey() error = %v", err)
+	}
+	keyring.Add(rotated)
+	if _, err := keySet.PublicKey(rotated.ID); !errors.Is(err, ErrUnknownKey) || fetches.Load() != 1 {
+		t.Fatalf("PublicKey() within the refresh interval error = %v after %d fetches", err, fetches.Load())
+	}
+	keySet.fetchedAt = time.Now().Add(-minRefreshInterval)
+	if key, err := keySet.PublicKey(rotated.ID); err != nil || key.ID != rotated.ID {
+		t.Fatalf("PublicKey() of the rotated key = %v, %v", key, err)
+	}
+
+	// expired keys are still served while the endpoint is down
+	available.Store(false)
+	keySet.fetchedAt = time.Now().Add(-2 * time.Hour)
+	if key, err := keySet.PublicKey(first.ID); err != nil || key.ID != first.ID {
+		t.Fatalf("PublicKey() while the endpoint is down = %v, %v", key, err)
+	}
+	keySet.fetchedAt = time.Now().Add(-minRefreshInterval)
+	if _, err := keySet.PublicKey("unknown"); err == nil || errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("PublicKey() of an unknown kid while the endpoint is down error = %v, want the fetch error", err)
+	}
+}
+
+func TestRemoteKeySetConcurrentFetch(t *testing.T) {
+	keyring := NewKeyring(jwt.SigningMethodEdDSA, 0, 0)
+	key, err := GenerateSigningKey(jwt.SigningMethodEdDSA, time.Now())
+	if err != nil {
+		t.Fatalf("GenerateSigningKey() error = %v", err)
+	}
+	keyring.Add(key)
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		<-release
+		_ = json.NewEncoder(w).Encode(keyring.JWKS())
+	}))
+	defer srv.Close()
+
+	keySet := NewRemoteKeySet(srv.URL, time.Hour).(*RemoteKeySet)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := keySet.PublicKey(key.ID); err != nil {
+				t.Errorf("PublicKey() error = %v", err)
+			}
+		}()
+	}
+
+	// the lock is free while the request is in flight
+	time.Sleep(50 * time.Millisecond)
+	locked := make(chan struct{})
+	go func() {
+		keySet.mu.Lock()
+		keySet.mu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("lock held during the fetch")
+	}
+
+	close(release)
+	wg.Wait()
+	if fetches.Load() != 1 {
+		t.Errorf("fetches = %d, want 1", fetches.Load())
+	}
+}
@@ -3,7 +3,10 @@ package token
 import "github.com/golang-jwt/jwt/v4"
 
 type Claims struct {
-	UserID    uint64
-	IsRefresh bool
+	UserID      uint64
+	SessionID   string
+	Roles       []string
+	Permissions []string
+	IsRefresh   bool
 	jwt.RegisteredClaims
 }
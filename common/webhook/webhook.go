@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of the timestamp
+	// and the body
+	SignatureHeader = "X-Webhook-Signature"
+	// TimestampHeader carries the unix seconds the webhook was signed at
+	TimestampHeader = "X-Webhook-Timestamp"
+
+	// DefaultTolerance is how old a signed webhook may be before it is
+	// rejected as a replay
+	DefaultTolerance = 5 * time.Minute
+)
+
+var (
+	// ErrInvalidSignature is webhook signature mismatch error
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrTimestampOutOfTolerance is webhook signed outside of the tolerance error
+	ErrTimestampOutOfTolerance = errors.New("webhook timestamp out of tolerance")
+)
+
+// Sign returns the signature of a webhook body signed at timestamp
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	return "sha256=" + hex.EncodeToString(mac(secret, strconv.FormatInt(timestamp.Unix(), 10), payload))
+}
+
+// Verify checks the signature of a webhook body and that it was signed
+// within tolerance of now. The signature may omit the "sha256=" prefix.
+func Verify(secret, signature, timestamp string, payload []byte, now time.Time, tolerance time.Duration) error {
+	if secret == "" || signature == "" || timestamp == "" {
+		return ErrInvalidSignature
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal(mac(secret, timestamp, payload), expected) {
+		return ErrInvalidSignature
+	}
+
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	if age := now.Sub(time.Unix(signedAt, 0)); age > tolerance || age < -tolerance {
+		return ErrTimestampOutOfTolerance
+	}
+	return nil
+}
+
+func mac(secret, timestamp string, payload []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(payload)
+	return h.Sum(nil)
+}
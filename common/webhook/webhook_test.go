@@ -0,0 +1,47 @@
+package webhook
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	const secret = "webhook-secret"
+	payload := []byte(`{"id":"evt_1","type":"payment.captured","amount":1000}`)
+	signedAt := time.Unix(1700000000, 0)
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	signature := Sign(secret, signedAt, payload)
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		timestamp string
+		payload   []byte
+		now       time.Time
+		wantErr   error
+	}{
+		{"valid", secret, signature, timestamp, payload, signedAt.Add(time.Minute), nil},
+		{"valid without prefix", secret, strings.TrimPrefix(signature, "sha256="), timestamp, payload, signedAt, nil},
+		{"wrong secret", "other-secret", signature, timestamp, payload, signedAt, ErrInvalidSignature},
+		{"tampered payload", secret, signature, timestamp, []byte(`{"id":"evt_1","type":"payment.captured","amount":1}`), signedAt, ErrInvalidSignature},
+		{"tampered timestamp", secret, signature, strconv.FormatInt(signedAt.Unix()+1, 10), payload, signedAt, ErrInvalidSignature},
+		{"not hex", secret, "sha256=zz", timestamp, payload, signedAt, ErrInvalidSignature},
+		{"missing signature", secret, "", timestamp, payload, signedAt, ErrInvalidSignature},
+		{"missing timestamp", secret, signature, "", payload, signedAt, ErrInvalidSignature},
+		{"no secret configured", "", signature, timestamp, payload, signedAt, ErrInvalidSignature},
+		{"replayed", secret, signature, timestamp, payload, signedAt.Add(DefaultTolerance + time.Second), ErrTimestampOutOfTolerance},
+		{"signed in the future", secret, signature, timestamp, payload, signedAt.Add(-DefaultTolerance - time.Second), ErrTimestampOutOfTolerance},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.signature, tt.timestamp, tt.payload, tt.now, 0)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -4,4 +4,6 @@ CREATE DATABASE products;
 
 CREATE DATABASE orders;
 
-CREATE DATABASE payments;
\ No newline at end of file
+CREATE DATABASE payments;
+
+CREATE DATABASE sagas;
\ No newline at end of file
@@ -20,6 +20,14 @@ func main() {
 	appCfg := di.InitApplicationConfig("config/orchestrator")
 	config.InitLogger(appCfg, bootCfg)
 
+	migrator, err := di.InitializeMigrator(bootCfg.Application, appCfg)
+	if err != nil {
+		log.Fatal("initialize migrator error:", err)
+	}
+	if err := migrator.Migrate(); err != nil {
+		log.Fatal("migrate error:", err)
+	}
+
 	srv := di.InitializeOrchestratorServer(appCfg, bootCfg)
 
 	go func() {
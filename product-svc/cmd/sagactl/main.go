@@ -0,0 +1,203 @@
+// sagactl inspects and re-drives the purchase sagas through the admin api
+// of the orchestrator. It authenticates with the access token in
+// SAGACTL_TOKEN, or -token, of a user granted saga:manage.
+//
+//	go run ./cmd/sagactl list -status FAILED
+//	go run ./cmd/sagactl show 42
+//	go run ./cmd/sagactl retry 42
+//	go run ./cmd/sagactl compensate 42
+//	go run ./cmd/sagactl resolve -note "refunded by support" 42
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const usage = `usage: sagactl [-addr host:port] [-token token] <command> [flags] [purchase id]
+
+commands:
+  list        list the latest sagas, -status filters by status
+  show        show the timeline of a saga
+  retry       publish the last command of a saga again when it got no reply
+  compensate  roll back a saga from its current step
+  resolve     mark a saga as resolved by hand, -note says how
+`
+
+func main() {
+	addr := flag.String("addr", "localhost:9016", "grpc address of the orchestrator")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout of the request")
+	token := flag.String("token", os.Getenv("SAGACTL_TOKEN"), "access token granted saga:manage, defaults to $SAGACTL_TOKEN")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *token == "" {
+		fatal(errors.New("no access token, set SAGACTL_TOKEN or -token"))
+	}
+
+	conn, err := grpc.Dial(*addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		fatal(err)
+	}
+	defer conn.Close()
+	client := pb.NewSagaAdminServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+*token)
+
+	command, args := flag.Arg(0), flag.Args()[1:]
+	switch command {
+	case "list":
+		err = list(ctx, client, args)
+	case "show":
+		err = show(ctx, client, args)
+	case "retry":
+		err = retry(ctx, client, args)
+	case "compensate":
+		err = compensate(ctx, client, args)
+	case "resolve":
+		err = resolve(ctx, client, args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func list(ctx context.Context, client pb.SagaAdminServiceClient, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	status := fs.String("status", "", "RUNNING, SUCCEEDED, COMPENSATING, COMPENSATED, FAILED or RESOLVED, all when empty")
+	limit := fs.Int("limit", 50, "number of sagas")
+	fs.Parse(args)
+
+	resp, err := client.ListSagas(ctx, &pb.ListSagasRequest{
+		Status: *status,
+		Limit:  int32(*limit),
+	})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PURCHASE\tUSER\tSTATUS\tSTEP\tUPDATED")
+	for _, saga := range resp.Sagas {
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\n", saga.PurchaseId, saga.UserId, saga.Status, saga.Step, formatTime(saga.UpdatedAt))
+	}
+	return w.Flush()
+}
+
+func show(ctx context.Context, client pb.SagaAdminServiceClient, args []string) error {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	payload := fs.Bool("payload", false, "print the payload of the messages")
+	fs.Parse(args)
+	purchaseID, err := purchaseIDArg(fs)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.GetSaga(ctx, &pb.GetSagaRequest{PurchaseId: purchaseID})
+	if err != nil {
+		return err
+	}
+
+	saga := resp.Saga
+	fmt.Printf("purchase %d of user %d: %s at %s\ncorrelation id %s, started %s\n\n",
+		saga.PurchaseId, saga.UserId, saga.Status, saga.Step, saga.CorrelationId, formatTime(saga.CreatedAt))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tKIND\tSTEP\tSTATUS\tTOPIC\tMESSAGE\tTRACE\tERROR")
+	for _, evt := range resp.Events {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			formatTime(evt.CreatedAt), evt.Kind, evt.Step, evt.Status, evt.Topic, evt.MessageUuid, evt.TraceId, evt.Error)
+		if *payload && evt.Payload != "" {
+			fmt.Fprintf(w, "\t%s\n", evt.Payload)
+		}
+	}
+	return w.Flush()
+}
+
+func retry(ctx context.Context, client pb.SagaAdminServiceClient, args []string) error {
+	fs := flag.NewFlagSet("retry", flag.ExitOnError)
+	fs.Parse(args)
+	purchaseID, err := purchaseIDArg(fs)
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.RetrySaga(ctx, &pb.RetrySagaRequest{PurchaseId: purchaseID}); err != nil {
+		return err
+	}
+	fmt.Printf("saga %d: last command published again\n", purchaseID)
+	return nil
+}
+
+func compensate(ctx context.Context, client pb.SagaAdminServiceClient, args []string) error {
+	fs := flag.NewFlagSet("compensate", flag.ExitOnError)
+	fs.Parse(args)
+	purchaseID, err := purchaseIDArg(fs)
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.CompensateSaga(ctx, &pb.CompensateSagaRequest{PurchaseId: purchaseID}); err != nil {
+		return err
+	}
+	fmt.Printf("saga %d: compensation started\n", purchaseID)
+	return nil
+}
+
+func resolve(ctx context.Context, client pb.SagaAdminServiceClient, args []string) error {
+	fs := flag.NewFlagSet("resolve", flag.ExitOnError)
+	note := fs.String("note", "", "how the saga was resolved")
+	fs.Parse(args)
+	purchaseID, err := purchaseIDArg(fs)
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.ResolveSaga(ctx, &pb.ResolveSagaRequest{PurchaseId: purchaseID, Note: *note}); err != nil {
+		return err
+	}
+	fmt.Printf("saga %d: resolved\n", purchaseID)
+	return nil
+}
+
+func purchaseIDArg(fs *flag.FlagSet) (uint64, error) {
+	if fs.NArg() != 1 {
+		return 0, fmt.Errorf("%s needs a purchase id", fs.Name())
+	}
+	return strconv.ParseUint(fs.Arg(0), 10, 64)
+}
+
+func formatTime(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+	return ts.AsTime().Local().Format(time.RFC3339)
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "sagactl:", err)
+	os.Exit(1)
+}
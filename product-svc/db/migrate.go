@@ -1,25 +1,45 @@
 package db
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"time"
 
+	"github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/currency"
+	"github.com/Chengxufeng1994/go-saga-example/common/eventstore"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/db/model"
+	adapterrepository "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/adapter/repository"
+	domainevent "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// priceMinorUnitsMigration converts the product prices, kept in whole units
+// of the base currency before payments became multi-currency, to minor units
+const priceMinorUnitsMigration = "product_price_minor_units"
+
+// sagaStreamsMigration appends the state of the sagas, kept in the sagas
+// table before it became the projection of their streams, to the streams
+const sagaStreamsMigration = "saga_streams"
+
 var ErrInvalidApplication = errors.New("Invalid application name")
 
 // Migrator the database migrator instance
 type Migrator struct {
-	app string
-	db  *gorm.DB
+	app          string
+	baseCurrency string
+	db           *gorm.DB
 }
 
 // NewMigrator returns a Migrator
-func NewMigrator(app string, db *gorm.DB) *Migrator {
+func NewMigrator(app string, appCfg *config.ApplicationConfig, db *gorm.DB) *Migrator {
 	return &Migrator{
-		app: app,
-		db:  db,
+		app:          app,
+		baseCurrency: currency.Normalize(appCfg.CurrencyConfig.Base),
+		db:           db,
 	}
 }
 
@@ -27,12 +47,102 @@ func NewMigrator(app string, db *gorm.DB) *Migrator {
 func (m *Migrator) Migrate() error {
 	switch m.app {
 	case "order":
-		return m.db.AutoMigrate(&model.Order{})
+		return m.db.AutoMigrate(&model.Order{}, &model.ProcessedMessage{})
 	case "payment":
-		return m.db.AutoMigrate(&model.Payment{})
+		return m.db.AutoMigrate(&model.Payment{}, &model.PaymentStatusHistory{}, &model.PaymentWebhookEvent{}, &model.JournalEntry{}, &model.LedgerPosting{}, &model.ProcessedMessage{})
 	case "product":
-		return m.db.AutoMigrate(&model.Product{}, &model.Idempotency{})
+		if err := m.db.AutoMigrate(&model.Product{}, &model.Idempotency{}, &model.ProcessedMessage{}, &model.DataMigration{}); err != nil {
+			return err
+		}
+		return m.migratePriceMinorUnits()
+	case "orchestrator":
+		if err := m.db.AutoMigrate(&model.Saga{}, &model.SagaEvent{}, &model.DataMigration{}, &eventstore.EventRecord{}, &eventstore.SnapshotRecord{}, &eventstore.CheckpointRecord{}); err != nil {
+			return err
+		}
+		return m.migrateSagaStreams()
 	default:
 		return ErrInvalidApplication
 	}
 }
+
+// migratePriceMinorUnits scales the prices of the existing products to minor
+// units of the base currency, once
+func (m *Migrator) migratePriceMinorUnits() error {
+	units, err := currency.MinorUnits(m.baseCurrency)
+	if err != nil {
+		return fmt.Errorf("currency.base: %w", err)
+	}
+
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.DataMigration{
+			Name:      priceMinorUnitsMigration,
+			AppliedAt: time.Now(),
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		scale := int64(1)
+		for i := 0; i < units; i++ {
+			scale *= 10
+		}
+		return tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Model(&model.Product{}).Update("price", gorm.Expr("price * ?", scale)).Error
+	})
+}
+
+// migrateSagaStreams appends the start and the status of every saga in the
+// sagas table to its stream, once
+func (m *Migrator) migrateSagaStreams() error {
+	ctx := context.Background()
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.DataMigration{
+			Name:      sagaStreamsMigration,
+			AppliedAt: time.Now(),
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		var sagas []model.Saga
+		if err := tx.Find(&sagas).Error; err != nil {
+			return err
+		}
+		store := eventstore.NewPostgresStore(tx)
+		for _, saga := range sagas {
+			streamID := adapterrepository.PurchaseSagaStreamID(saga.PurchaseID)
+			var version int64
+			if err := tx.Model(&eventstore.EventRecord{}).
+				Select("COALESCE(MAX(version), 0)").
+				Where("stream_id = ?", streamID).
+				Scan(&version).Error; err != nil {
+				return err
+			}
+
+			started, err := json.Marshal(&domainevent.PurchaseSagaStartedEvent{
+				UserID:        saga.UserID,
+				PurchaseID:    saga.PurchaseID,
+				CorrelationID: saga.CorrelationID,
+				Timestamp:     saga.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+			status, err := json.Marshal(&domainevent.PurchaseSagaStatusChangedEvent{
+				PurchaseID: saga.PurchaseID,
+				Step:       saga.Step,
+				Status:     saga.Status,
+				Timestamp:  saga.UpdatedAt,
+			})
+			if err != nil {
+				return err
+			}
+			if err := store.Append(ctx, streamID, version,
+				&eventstore.Event{StreamType: adapterrepository.PurchaseSagaStreamType, Type: adapterrepository.PurchaseSagaStartedType, Data: started},
+				&eventstore.Event{StreamType: adapterrepository.PurchaseSagaStreamType, Type: adapterrepository.PurchaseSagaStatusChangedType, Data: status},
+			); err != nil {
+				return fmt.Errorf("saga %d: %w", saga.PurchaseID, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// JournalEntry data model, rows are only ever appended
+type JournalEntry struct {
+	ID            uint64 `gorm:"primarykey"`
+	PurchaseID    uint64 `gorm:"index;not null"`
+	Operation     string `gorm:"type:varchar(32);not null"`
+	TransactionID string
+	CreatedAt     time.Time
+}
+
+// LedgerPosting data model, rows are only ever appended
+type LedgerPosting struct {
+	ID             uint64 `gorm:"primarykey"`
+	JournalEntryID uint64 `gorm:"index;not null"`
+	PurchaseID     uint64 `gorm:"index;not null"`
+	Account        string `gorm:"type:varchar(32);index;not null"`
+	CurrencyCode   string `gorm:"type:varchar(3);not null"`
+	Amount         int64  `gorm:"not null"`
+	CreatedAt      time.Time
+}
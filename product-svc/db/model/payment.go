@@ -1,6 +1,10 @@
 package model
 
-import "github.com/Chengxufeng1994/go-saga-example/common/model"
+import (
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/model"
+)
 
 // Payment data model
 type Payment struct {
@@ -8,4 +12,38 @@ type Payment struct {
 	UserID       uint64 `gorm:"index;not null"`
 	CurrencyCode string `gorm:"not null"`
 	Amount       int64  `gorm:"not null"`
+	// payments created before authorizations existed were charged right away
+	Status string `gorm:"type:varchar(32);not null;default:'captured'"`
+	// amount before currency conversion
+	OriginalCurrencyCode string `gorm:"type:varchar(3)"`
+	OriginalAmount       int64
+	ExchangeRate         string `gorm:"type:varchar(32)"`
+	RefundedAmount       int64  `gorm:"not null;default:0"`
+	// gateway references
+	AuthorizationID string
+	CaptureID       string
+	// CorrelationID of the saga which created the payment
+	CorrelationID string
+}
+
+// PaymentStatusHistory data model, rows are only ever appended
+type PaymentStatusHistory struct {
+	ID            uint64 `gorm:"primarykey"`
+	PaymentID     uint64 `gorm:"index;not null"`
+	Status        string `gorm:"type:varchar(32);not null"`
+	TransactionID string
+	CreatedAt     time.Time
+}
+
+// PaymentWebhookEvent data model, keeps processed provider events for
+// deduplication by event and by gateway transaction, as a provider may send
+// a transaction again under a new event id
+type PaymentWebhookEvent struct {
+	ID            uint64 `gorm:"primarykey"`
+	Provider      string `gorm:"type:varchar(64);not null;uniqueIndex:idx_provider_event_id;uniqueIndex:idx_provider_transaction_id"`
+	EventID       string `gorm:"type:varchar(128);not null;uniqueIndex:idx_provider_event_id"`
+	TransactionID string `gorm:"type:varchar(128);not null;default:'';uniqueIndex:idx_provider_transaction_id,where:transaction_id <> ''"`
+	Type          string `gorm:"type:varchar(64);not null"`
+	PaymentID     uint64 `gorm:"index;not null"`
+	CreatedAt     time.Time
 }
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// ProcessedMessage data model, a message claimed by a handler and the
+// replies it produced once processed
+type ProcessedMessage struct {
+	Handler     string     `gorm:"type:varchar(256);primaryKey"`
+	MessageUUID string     `gorm:"type:varchar(64);primaryKey"`
+	Replies     []byte     `gorm:"type:bytea"`
+	ClaimedAt   time.Time  `gorm:"not null"`
+	CompletedAt *time.Time `gorm:"index"`
+}
@@ -1,6 +1,10 @@
 package model
 
-import "github.com/Chengxufeng1994/go-saga-example/common/model"
+import (
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/model"
+)
 
 // product data model
 type Product struct {
@@ -9,7 +13,8 @@ type Product struct {
 	Description string `gorm:"type:text;not null"`
 	BrandName   string `gorm:"type:varchar(256);not null"`
 	Inventory   int64  `gorm:"not null"`
-	Price       int64  `gorm:"not null"`
+	// Price in minor units of the base currency
+	Price int64 `gorm:"not null"`
 }
 
 // Idempotency data model
@@ -19,3 +24,9 @@ type Idempotency struct {
 	Amount     int64  `gorm:"not null"`
 	Rollbacked bool   `gorm:"not null"`
 }
+
+// DataMigration data model, a data migration applied once
+type DataMigration struct {
+	Name      string    `gorm:"type:varchar(128);primaryKey"`
+	AppliedAt time.Time `gorm:"not null"`
+}
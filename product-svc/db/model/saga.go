@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// Saga data model, the read model of the purchase sagas projected from
+// their streams in the event store
+type Saga struct {
+	PurchaseID    uint64    `gorm:"primaryKey;autoIncrement:false"`
+	UserID        uint64    `gorm:"index;not null"`
+	CorrelationID string    `gorm:"type:varchar(64)"`
+	Status        string    `gorm:"type:varchar(32);index;not null"`
+	Step          string    `gorm:"type:varchar(64);not null"`
+	Version       int64     `gorm:"not null;default:0"`
+	CreatedAt     time.Time `gorm:"autoCreateTime:false"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime:false"`
+}
+
+// SagaEvent data model, rows are only ever appended
+type SagaEvent struct {
+	ID          uint64 `gorm:"primarykey"`
+	PurchaseID  uint64 `gorm:"index;not null"`
+	Kind        string `gorm:"type:varchar(16);not null"`
+	Step        string `gorm:"type:varchar(64)"`
+	Status      string `gorm:"type:varchar(32)"`
+	Topic       string `gorm:"type:varchar(128)"`
+	Handler     string `gorm:"type:varchar(128)"`
+	MessageUUID string `gorm:"type:varchar(64)"`
+	Payload     []byte `gorm:"type:jsonb"`
+	Error       string
+	TraceID     string `gorm:"type:varchar(32)"`
+	CreatedAt   time.Time
+}
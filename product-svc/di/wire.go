@@ -8,13 +8,20 @@ import (
 	"github.com/Chengxufeng1994/go-saga-example/common/config"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/db"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/adapter/broker"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/adapter/exchangerate"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/adapter/gateway"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/adapter/projection"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/adapter/repository"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/application"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure"
 	infrabroker "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/broker"
+	infraeventstore "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/eventstore"
+	infragrpc "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/grpc"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/grpc/client"
+	infragrpcorchestrator "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/grpc/orchestrator"
 	infragrpcproduct "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/grpc/product"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http/middleware"
+	httporchestrator "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http/orchestrator"
 	httporder "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http/order"
 	httppayment "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http/payment"
 	httpproduct "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http/product"
@@ -51,10 +58,15 @@ func InitializeProductServer(appCfg *config.ApplicationConfig, bootCfg *bootstra
 		observe.NewTracer,
 
 		infrabroker.InitializeRouter,
-		infrabroker.NewNATSPublisher,
-		infrabroker.NewNATSSubscriber,
+		infrabroker.NewDedupStore,
+		infrabroker.NewTxPublisher,
+		infrabroker.NewTxSubscriber,
+		infrabroker.NewCodec,
+		infrabroker.NewBus,
 		infragrpcproduct.NewGrpcProductServer,
+		infrabroker.NewResultPublisher,
 		broker.NewSagaProductController,
+		broker.NewChoreographedProductController,
 		broker.NewProductEventRouter,
 
 		repository.NewGormProductRepository,
@@ -83,9 +95,14 @@ func InitializeOrderServer(appCfg *config.ApplicationConfig, bootCfg *bootstrap.
 		observe.NewTracer,
 
 		infrabroker.InitializeRouter,
-		infrabroker.NewNATSPublisher,
-		infrabroker.NewNATSSubscriber,
+		infrabroker.NewDedupStore,
+		infrabroker.NewTxPublisher,
+		infrabroker.NewTxSubscriber,
+		infrabroker.NewCodec,
+		infrabroker.NewBus,
+		infrabroker.NewResultPublisher,
 		broker.NewSagaOrderController,
+		broker.NewChoreographedOrderController,
 		broker.NewOrderEventRouter,
 
 		repository.NewGormOrderRepository,
@@ -115,12 +132,21 @@ func InitializePaymentServer(appCfg *config.ApplicationConfig, bootCfg *bootstra
 		observe.NewTracer,
 
 		infrabroker.InitializeRouter,
-		infrabroker.NewNATSPublisher,
-		infrabroker.NewNATSSubscriber,
+		infrabroker.NewDedupStore,
+		infrabroker.NewTxPublisher,
+		infrabroker.NewTxSubscriber,
+		infrabroker.NewCodec,
+		infrabroker.NewBus,
+		infrabroker.NewResultPublisher,
 		broker.NewSagaPaymentController,
+		broker.NewChoreographedPaymentController,
+		broker.NewPaymentStatusPublisher,
 		broker.NewPaymentEventRouter,
 
 		repository.NewGormPaymentRepository,
+		repository.NewGormLedgerRepository,
+		gateway.NewPaymentGateway,
+		exchangerate.NewExchangeRateProvider,
 
 		client.NewAuthConn,
 		application.NewAuthService,
@@ -144,13 +170,32 @@ func InitializeOrchestratorServer(appCfg *config.ApplicationConfig, bootCfg *boo
 		observe.NewTracer,
 
 		infrabroker.InitializeRouter,
-		infrabroker.NewNATSPublisher,
-		infrabroker.NewNATSSubscriber,
-		infrabroker.NewRedisPublisher,
+		infrabroker.NewOrchestratorDedupStore,
+		infrabroker.NewTxPublisher,
+		infrabroker.NewTxSubscriber,
+		infrabroker.NewCodec,
+		infrabroker.NewBus,
+		infrabroker.NewResultPublisher,
+		db.NewDatabase,
+		repository.NewGormSagaRepository,
+		infraeventstore.NewEventStore,
+		repository.NewEventSourcedPurchaseSagaRepository,
+		projection.NewPurchaseSagaView,
+		infraeventstore.NewProjector,
 		application.NewOrchestratorService,
 		broker.NewPurchaseResultPublisher,
 		broker.NewSagaOrchestratorController,
 		broker.NewOrchestratorEventRouter,
+		infragrpcorchestrator.NewGrpcSagaAdminServer,
+		application.NewOrchestratorApplication,
+
+		client.NewAuthConn,
+		application.NewAuthService,
+		middleware.NewJwtAuthenticator,
+		infragrpc.NewAuthenticator,
+		httporchestrator.NewGinEngine,
+		httporchestrator.NewRouter,
+		httporchestrator.New,
 
 		infrastructure.NewOrchestratorServer,
 	)
@@ -11,13 +11,20 @@ import (
 	"github.com/Chengxufeng1994/go-saga-example/common/config"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/db"
 	broker2 "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/adapter/broker"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/adapter/exchangerate"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/adapter/gateway"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/adapter/projection"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/adapter/repository"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/application"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/broker"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/eventstore"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/grpc"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/grpc/client"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/grpc/orchestrator"
 	product2 "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/grpc/product"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http/middleware"
+	orchestrator2 "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http/orchestrator"
 	product3 "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http/order"
 	product4 "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http/payment"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http/product"
@@ -38,7 +45,7 @@ func InitBootstrapConfig(path string) *bootstrap.BootstrapConfig {
 
 func InitializeMigrator(app string, appCfg *config.ApplicationConfig) (*db.Migrator, error) {
 	gormDB := db.NewDatabase(appCfg)
-	migrator := db.NewMigrator(app, gormDB)
+	migrator := db.NewMigrator(app, appCfg, gormDB)
 	return migrator, nil
 }
 
@@ -46,20 +53,25 @@ func InitializeProductServer(appCfg *config.ApplicationConfig, bootCfg *bootstra
 	engine := product.NewGinEngine(bootCfg)
 	gormDB := db.NewDatabase(appCfg)
 	productRepository := repository.NewGormProductRepository(gormDB)
-	productUseCase := application.NewProductService(productRepository)
+	productUseCase := application.NewProductService(appCfg, productRepository)
 	productApplication := application.NewProductApplication(productUseCase)
 	authConn := client.NewAuthConn(appCfg)
-	authUseCase := application.NewAuthService(authConn)
+	authUseCase := application.NewAuthService(appCfg, authConn)
 	jwtAuthenticator := middleware.NewJwtAuthenticator(authUseCase)
 	router := product.NewRouter(engine, productApplication, jwtAuthenticator)
 	httpServer := product.New(bootCfg, engine, router)
 	grpcProductServer := product2.NewGrpcProductServer(bootCfg, productUseCase)
-	messageRouter := broker.InitializeRouter(bootCfg)
-	natsPublisher := broker.NewNATSPublisher(bootCfg, appCfg)
-	natsSubscriber := broker.NewNATSSubscriber(bootCfg, appCfg)
+	dedupStore := broker.NewDedupStore(appCfg, gormDB)
+	messageRouter := broker.InitializeRouter(bootCfg, appCfg, dedupStore)
+	natsPublisher := broker.NewTxPublisher(bootCfg, appCfg)
+	natsSubscriber := broker.NewTxSubscriber(bootCfg, appCfg)
 	sagaProductUseCase := application.NewSagaProductService(productRepository)
 	sagaProductController := broker2.NewSagaProductController(sagaProductUseCase)
-	eventRouter := broker2.NewProductEventRouter(messageRouter, natsPublisher, natsSubscriber, sagaProductController)
+	codec := broker.NewCodec(appCfg)
+	resultPublisher := broker.NewResultPublisher(bootCfg, appCfg)
+	choreographedProductController := broker2.NewChoreographedProductController(sagaProductUseCase)
+	bus := broker.NewBus(bootCfg, appCfg, messageRouter, natsPublisher, natsSubscriber, resultPublisher, codec)
+	eventRouter := broker2.NewProductEventRouter(appCfg, bus, sagaProductController, choreographedProductController)
 	tracerProvider := observe.NewTracer(bootCfg, appCfg)
 	productServer := infrastructure.NewProductServer(httpServer, grpcProductServer, eventRouter, tracerProvider)
 	return productServer
@@ -73,16 +85,21 @@ func InitializeOrderServer(appCfg *config.ApplicationConfig, bootCfg *bootstrap.
 	orderUseCase := application.NewOrderService(orderRepository)
 	orderApplication := application.NewOrderApplication(orderUseCase)
 	authConn := client.NewAuthConn(appCfg)
-	authUseCase := application.NewAuthService(authConn)
+	authUseCase := application.NewAuthService(appCfg, authConn)
 	jwtAuthenticator := middleware.NewJwtAuthenticator(authUseCase)
 	router := product3.NewRouter(engine, orderApplication, jwtAuthenticator)
 	httpServer := product3.New(bootCfg, engine, router)
-	messageRouter := broker.InitializeRouter(bootCfg)
-	natsPublisher := broker.NewNATSPublisher(bootCfg, appCfg)
-	natsSubscriber := broker.NewNATSSubscriber(bootCfg, appCfg)
+	dedupStore := broker.NewDedupStore(appCfg, gormDB)
+	messageRouter := broker.InitializeRouter(bootCfg, appCfg, dedupStore)
+	natsPublisher := broker.NewTxPublisher(bootCfg, appCfg)
+	natsSubscriber := broker.NewTxSubscriber(bootCfg, appCfg)
 	sagaOrderUseCase := application.NewSagaOrderService(orderRepository)
 	sagaOrderController := broker2.NewSagaOrderController(sagaOrderUseCase)
-	eventRouter := broker2.NewOrderEventRouter(messageRouter, natsPublisher, natsSubscriber, sagaOrderController)
+	codec := broker.NewCodec(appCfg)
+	resultPublisher := broker.NewResultPublisher(bootCfg, appCfg)
+	choreographedOrderController := broker2.NewChoreographedOrderController(sagaOrderUseCase)
+	bus := broker.NewBus(bootCfg, appCfg, messageRouter, natsPublisher, natsSubscriber, resultPublisher, codec)
+	eventRouter := broker2.NewOrderEventRouter(appCfg, bus, sagaOrderController, choreographedOrderController)
 	tracerProvider := observe.NewTracer(bootCfg, appCfg)
 	orderServer := infrastructure.NewOrderServer(httpServer, eventRouter, tracerProvider)
 	return orderServer
@@ -92,34 +109,61 @@ func InitializePaymentServer(appCfg *config.ApplicationConfig, bootCfg *bootstra
 	engine := product4.NewGinEngine(bootCfg)
 	gormDB := db.NewDatabase(appCfg)
 	paymentRepository := repository.NewGormPaymentRepository(gormDB)
-	paymentUseCase := application.NewPaymentService(paymentRepository)
+	natsPublisher := broker.NewTxPublisher(bootCfg, appCfg)
+	codec := broker.NewCodec(appCfg)
+	paymentEventRepository := broker2.NewPaymentStatusPublisher(natsPublisher, codec)
+	ledgerRepository := repository.NewGormLedgerRepository(gormDB)
+	paymentUseCase := application.NewPaymentService(appCfg, paymentRepository, paymentEventRepository, ledgerRepository)
 	paymentApplication := application.NewPaymentApplication(paymentUseCase)
 	authConn := client.NewAuthConn(appCfg)
-	authUseCase := application.NewAuthService(authConn)
+	authUseCase := application.NewAuthService(appCfg, authConn)
 	jwtAuthenticator := middleware.NewJwtAuthenticator(authUseCase)
 	router := product4.NewRouter(engine, paymentApplication, jwtAuthenticator)
 	httpServer := product4.New(bootCfg, engine, router)
-	messageRouter := broker.InitializeRouter(bootCfg)
-	natsPublisher := broker.NewNATSPublisher(bootCfg, appCfg)
-	natsSubscriber := broker.NewNATSSubscriber(bootCfg, appCfg)
-	sagaPaymentUseCase := application.NewSagaPaymentService(paymentRepository)
+	dedupStore := broker.NewDedupStore(appCfg, gormDB)
+	messageRouter := broker.InitializeRouter(bootCfg, appCfg, dedupStore)
+	natsSubscriber := broker.NewTxSubscriber(bootCfg, appCfg)
+	paymentGateway := gateway.NewPaymentGateway(bootCfg, appCfg)
+	exchangeRateProvider := exchangerate.NewExchangeRateProvider(appCfg)
+	sagaPaymentUseCase := application.NewSagaPaymentService(paymentRepository, paymentGateway, exchangeRateProvider)
 	sagaPaymentController := broker2.NewSagaPaymentController(sagaPaymentUseCase)
-	eventRouter := broker2.NewPaymentEventRouter(messageRouter, natsPublisher, natsSubscriber, sagaPaymentController)
+	resultPublisher := broker.NewResultPublisher(bootCfg, appCfg)
+	choreographedPaymentController := broker2.NewChoreographedPaymentController(appCfg, sagaPaymentUseCase)
+	bus := broker.NewBus(bootCfg, appCfg, messageRouter, natsPublisher, natsSubscriber, resultPublisher, codec)
+	eventRouter := broker2.NewPaymentEventRouter(appCfg, bus, sagaPaymentController, choreographedPaymentController)
 	tracerProvider := observe.NewTracer(bootCfg, appCfg)
 	paymentServer := infrastructure.NewPaymentServer(httpServer, eventRouter, tracerProvider)
 	return paymentServer
 }
 
 func InitializeOrchestratorServer(appCfg *config.ApplicationConfig, bootCfg *bootstrap.BootstrapConfig) *infrastructure.OrchestratorServer {
-	router := broker.InitializeRouter(bootCfg)
-	natsPublisher := broker.NewNATSPublisher(bootCfg, appCfg)
-	natsSubscriber := broker.NewNATSSubscriber(bootCfg, appCfg)
-	redisPublisher := broker.NewRedisPublisher(bootCfg, appCfg)
-	purchaseResultRepository := broker2.NewPurchaseResultPublisher(redisPublisher)
-	orchestratorUseCase := application.NewOrchestratorService(natsPublisher, purchaseResultRepository)
+	engine := orchestrator2.NewGinEngine(bootCfg)
+	natsPublisher := broker.NewTxPublisher(bootCfg, appCfg)
+	codec := broker.NewCodec(appCfg)
+	resultPublisher := broker.NewResultPublisher(bootCfg, appCfg)
+	purchaseResultRepository := broker2.NewPurchaseResultPublisher(resultPublisher, codec)
+	gormDB := db.NewDatabase(appCfg)
+	sagaRepository := repository.NewGormSagaRepository(gormDB)
+	postgresStore := eventstore.NewEventStore(gormDB)
+	purchaseSagaRepository := repository.NewEventSourcedPurchaseSagaRepository(appCfg, postgresStore)
+	dedupStore := broker.NewOrchestratorDedupStore(appCfg)
+	messageRouter := broker.InitializeRouter(bootCfg, appCfg, dedupStore)
+	natsSubscriber := broker.NewTxSubscriber(bootCfg, appCfg)
+	bus := broker.NewBus(bootCfg, appCfg, messageRouter, natsPublisher, natsSubscriber, resultPublisher, codec)
+	orchestratorUseCase := application.NewOrchestratorService(appCfg, bus, purchaseResultRepository, sagaRepository, purchaseSagaRepository)
+	orchestratorApplication := application.NewOrchestratorApplication(orchestratorUseCase)
+	authConn := client.NewAuthConn(appCfg)
+	authUseCase := application.NewAuthService(appCfg, authConn)
+	jwtAuthenticator := middleware.NewJwtAuthenticator(authUseCase)
+	router := orchestrator2.NewRouter(engine, orchestratorApplication, jwtAuthenticator)
+	httpServer := orchestrator2.New(bootCfg, engine, router)
+	authenticator := grpc.NewAuthenticator(authUseCase)
+	grpcSagaAdminServer := orchestrator.NewGrpcSagaAdminServer(bootCfg, orchestratorUseCase, authenticator)
 	sagaOrchestratorController := broker2.NewSagaOrchestratorController(orchestratorUseCase)
-	eventRouter := broker2.NewOrchestratorEventRouter(router, natsPublisher, natsSubscriber, sagaOrchestratorController)
+	eventRouter := broker2.NewOrchestratorEventRouter(appCfg, bus, sagaOrchestratorController)
+	purchaseSagaView := projection.NewPurchaseSagaView()
+	projector := eventstore.NewProjector(appCfg, postgresStore, purchaseSagaView)
 	tracerProvider := observe.NewTracer(bootCfg, appCfg)
-	orchestratorServer := infrastructure.NewOrchestratorServer(eventRouter, tracerProvider)
+	orchestratorServer := infrastructure.NewOrchestratorServer(httpServer, grpcSagaAdminServer, eventRouter, projector, tracerProvider)
 	return orchestratorServer
 }
@@ -1,8 +1,68 @@
 package dto
 
+import "time"
+
 type Payment struct {
-	ID           uint64 `json:"id"`
-	UserID       uint64 `json:"user_id"`
+	ID                   uint64                `json:"id"`
+	UserID               uint64                `json:"user_id"`
+	CurrencyCode         string                `json:"currency_code"`
+	Amount               int64                 `json:"amount"`
+	OriginalCurrencyCode string                `json:"original_currency_code"`
+	OriginalAmount       int64                 `json:"original_amount"`
+	ExchangeRate         string                `json:"exchange_rate"`
+	Status               string                `json:"status"`
+	StatusHistory        []PaymentStatusChange `json:"status_history"`
+}
+
+// PaymentStatusChange payload
+type PaymentStatusChange struct {
+	Status        string    `json:"status"`
+	TransactionID string    `json:"transaction_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// PaymentWebhookEvent is the JSON body payment providers post to the webhook endpoint
+type PaymentWebhookEvent struct {
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	AuthorizationID string `json:"authorization_id"`
+	TransactionID   string `json:"transaction_id"`
+	Amount          int64  `json:"amount"`
+}
+
+type PaymentWebhookResponse struct {
+	EventID   string `json:"event_id"`
+	PaymentID uint64 `json:"payment_id"`
+	Status    string `json:"status"`
+	Duplicate bool   `json:"duplicate"`
+}
+
+// PaymentLedger is the ledger of a purchase
+type PaymentLedger struct {
+	PurchaseID uint64           `json:"purchase_id"`
+	Balances   []AccountBalance `json:"balances"`
+	Entries    []JournalEntry   `json:"entries"`
+}
+
+// AccountBalance payload
+type AccountBalance struct {
+	Account      string `json:"account"`
+	CurrencyCode string `json:"currency_code"`
+	Balance      int64  `json:"balance"`
+}
+
+// JournalEntry payload
+type JournalEntry struct {
+	ID            uint64          `json:"id"`
+	Operation     string          `json:"operation"`
+	TransactionID string          `json:"transaction_id"`
+	Postings      []LedgerPosting `json:"postings"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// LedgerPosting payload, debits are positive and credits negative
+type LedgerPosting struct {
+	Account      string `json:"account"`
 	CurrencyCode string `json:"currency_code"`
 	Amount       int64  `json:"amount"`
 }
@@ -3,12 +3,13 @@ package dto
 import "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
 
 type Product struct {
-	ID          uint64 `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	BrandName   string `json:"brand_name"`
-	Price       int64  `json:"price"`
-	Inventory   int64  `json:"inventory"`
+	ID           uint64 `json:"id"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	BrandName    string `json:"brand_name"`
+	Price        int64  `json:"price"`
+	CurrencyCode string `json:"currency_code"`
+	Inventory    int64  `json:"inventory"`
 }
 
 type ProductCreationRequest struct {
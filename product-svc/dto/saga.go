@@ -0,0 +1,66 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SagaTimeline is what happened to a purchase saga, step by step
+type SagaTimeline struct {
+	PurchaseID    uint64     `json:"purchase_id"`
+	UserID        uint64     `json:"user_id"`
+	CorrelationID string     `json:"correlation_id"`
+	Status        string     `json:"status"`
+	Step          string     `json:"step"`
+	StartedAt     time.Time  `json:"started_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Steps         []SagaStep `json:"steps"`
+	// Actions are the operator actions taken on the saga
+	Actions []SagaAction `json:"actions"`
+}
+
+// SagaStep payload
+type SagaStep struct {
+	Step string `json:"step"`
+	// Status is the last purchase result of the step
+	Status string `json:"status"`
+	// Retries counts the commands sent again to a topic of the step
+	Retries  int           `json:"retries"`
+	Attempts []SagaAttempt `json:"attempts"`
+	Results  []SagaResult  `json:"results"`
+}
+
+// SagaAttempt is a command sent and the reply received to it
+type SagaAttempt struct {
+	Command *SagaMessage `json:"command,omitempty"`
+	Reply   *SagaMessage `json:"reply,omitempty"`
+}
+
+// SagaMessage payload
+type SagaMessage struct {
+	Topic       string          `json:"topic"`
+	Handler     string          `json:"handler,omitempty"`
+	MessageUUID string          `json:"message_uuid"`
+	Status      string          `json:"status,omitempty"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	TraceID     string          `json:"trace_id,omitempty"`
+	Timestamp   time.Time       `json:"timestamp"`
+}
+
+// SagaResult is a purchase result published for a step
+type SagaResult struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	TraceID   string    `json:"trace_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// SagaAction is an operator action
+type SagaAction struct {
+	Action    string          `json:"action"`
+	Step      string          `json:"step"`
+	Topic     string          `json:"topic,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
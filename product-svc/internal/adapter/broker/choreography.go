@@ -0,0 +1,73 @@
+package broker
+
+import (
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/bus"
+	"github.com/Chengxufeng1994/go-saga-example/common/event"
+	"github.com/Chengxufeng1994/go-saga-example/common/pb"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	domainevent "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/broker"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// choreographyLogger is the logger of a choreographed participant
+func choreographyLogger(participant string) *logrus.Entry {
+	return config.ContextLogger.WithFields(logrus.Fields{"type": "choreography:" + participant})
+}
+
+// purchaseResult is the event of a purchase result, the choreographed
+// participants publish the results the orchestrator publishes
+func purchaseResult(userID, purchaseID uint64, step, status string) bus.Event {
+	return bus.Event{
+		Topic:   event.PurchaseResultTopic,
+		Message: EncodeDomainPurchaseResult(domainevent.NewPurchaseResultEvent(userID, purchaseID, step, status)),
+	}
+}
+
+// rollbackResults are the results of the rollback of a step, rolled back
+// and then rollback failed when err is set
+func rollbackResults(userID, purchaseID uint64, step string, err error) []bus.Event {
+	results := []bus.Event{purchaseResult(userID, purchaseID, step, domainevent.StatusRollbacked)}
+	if err != nil {
+		results = append(results, purchaseResult(userID, purchaseID, step, domainevent.StatusRollbackFailed))
+	}
+	return results
+}
+
+func stepSucceeded(topic string, purchaseID uint64, purchase *pb.Purchase) bus.Event {
+	return bus.Event{
+		Topic: topic,
+		Message: &pb.PurchaseStepSucceeded{
+			PurchaseId: purchaseID,
+			Purchase:   purchase,
+			Timestamp:  timestamppb.New(time.Now()),
+		},
+		Options: []bus.PublishOption{bus.WithPartitionKeys(purchase.GetOrder().GetUserId(), purchaseID)},
+	}
+}
+
+func stepFailed(topic string, userID, purchaseID uint64, reason string) bus.Event {
+	return bus.Event{
+		Topic: topic,
+		Message: &pb.PurchaseStepFailed{
+			UserId:     userID,
+			PurchaseId: purchaseID,
+			Error:      reason,
+			Timestamp:  timestamppb.New(time.Now()),
+		},
+		Options: []bus.PublishOption{bus.WithPartitionKeys(userID, purchaseID)},
+	}
+}
+
+// decodeStepPurchase decodes the purchase carried by a step event
+func decodeStepPurchase(evt *pb.PurchaseStepSucceeded) *entity.Purchase {
+	return broker.DecodeCreatePurchaseCommand(&pb.CreatePurchaseCommand{
+		PurchaseId: evt.PurchaseId,
+		Purchase:   evt.Purchase,
+		Timestamp:  evt.Timestamp,
+	})
+}
@@ -0,0 +1,362 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/bus"
+	"github.com/Chengxufeng1994/go-saga-example/common/codec"
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/event"
+	"github.com/Chengxufeng1994/go-saga-example/common/pb"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/application"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
+	infrabroker "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/broker"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/repository"
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+	"github.com/sirupsen/logrus"
+)
+
+// sagaCalls records the steps the fake participants ran, the calls in fail
+// fail
+type sagaCalls struct {
+	mu    sync.Mutex
+	fail  map[string]bool
+	calls []string
+}
+
+func (c *sagaCalls) record(call string, purchaseID uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, fmt.Sprintf("%s %d", call, purchaseID))
+	if c.fail[call] {
+		return errors.New(call + " failed")
+	}
+	return nil
+}
+
+func (c *sagaCalls) list() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.calls...)
+}
+
+type fakeSagaProductUseCase struct{ *sagaCalls }
+
+func (f fakeSagaProductUseCase) UpdateProductInventory(ctx context.Context, idempotencyKey uint64, purchasedItems *[]valueobject.PurchasedItem) error {
+	return f.record("reserve inventory", idempotencyKey)
+}
+
+func (f fakeSagaProductUseCase) RollbackProductInventory(ctx context.Context, idempotencyKey uint64) error {
+	return f.record("release inventory", idempotencyKey)
+}
+
+type fakeSagaOrderUseCase struct{ *sagaCalls }
+
+func (f fakeSagaOrderUseCase) ExecuteCreateOrder(ctx context.Context, order *entity.Order) error {
+	return f.record("create order", order.ID)
+}
+
+func (f fakeSagaOrderUseCase) RollbackCreateOrder(ctx context.Context, orderID uint64) error {
+	return f.record("rollback order", orderID)
+}
+
+func (f fakeSagaOrderUseCase) AnonymizeUser(ctx context.Context, userID uint64) error { return nil }
+
+type fakeSagaPaymentUseCase struct{ *sagaCalls }
+
+func (f fakeSagaPaymentUseCase) ExecuteCreatePayment(ctx context.Context, payment *entity.Payment) error {
+	return f.record("create payment", payment.ID)
+}
+
+func (f fakeSagaPaymentUseCase) CapturePayment(ctx context.Context, paymentID uint64) error {
+	return f.record("capture payment", paymentID)
+}
+
+func (f fakeSagaPaymentUseCase) RollbackCreatePayment(ctx context.Context, paymentID uint64) error {
+	return f.record("rollback payment", paymentID)
+}
+
+func (f fakeSagaPaymentUseCase) AnonymizeUser(ctx context.Context, userID uint64) error { return nil }
+
+// memSagaRepository keeps the timelines of the orchestrated sagas
+type memSagaRepository struct {
+	mu     sync.Mutex
+	events []*entity.SagaEvent
+}
+
+func (r *memSagaRepository) ListSagas(ctx context.Context, status entity.SagaStatus, limit int) ([]*entity.Saga, error) {
+	return nil, nil
+}
+
+func (r *memSagaRepository) AppendSagaEvent(ctx context.Context, evt *entity.SagaEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, evt)
+	return nil
+}
+
+func (r *memSagaRepository) ListSagaEvents(ctx context.Context, purchaseID uint64) ([]*entity.SagaEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []*entity.SagaEvent
+	for _, evt := range r.events {
+		if evt.PurchaseID == purchaseID {
+			events = append(events, evt)
+		}
+	}
+	return events, nil
+}
+
+// memPurchaseSagaRepository keeps the saga streams of the orchestrator
+type memPurchaseSagaRepository struct {
+	mu      sync.Mutex
+	streams map[uint64][]any
+}
+
+func (r *memPurchaseSagaRepository) Load(ctx context.Context, purchaseID uint64) (*entity.PurchaseSaga, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	saga := entity.NewPurchaseSaga(purchaseID)
+	for _, evt := range r.streams[purchaseID] {
+		saga.Apply(evt)
+	}
+	return saga, nil
+}
+
+func (r *memPurchaseSagaRepository) Save(ctx context.Context, saga *entity.PurchaseSaga) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changes := saga.Changes()
+	if int64(len(r.streams[saga.PurchaseID])) != saga.Version-int64(len(changes)) {
+		return repository.ErrConcurrencyConflict
+	}
+	r.streams[saga.PurchaseID] = append(r.streams[saga.PurchaseID], changes...)
+	saga.ClearChanges()
+	return nil
+}
+
+// purchaseResults records the statuses of the purchase results by step
+type purchaseResults struct {
+	mu       sync.Mutex
+	count    int
+	statuses map[pb.PurchaseStep][]pb.PurchaseStatus
+}
+
+func (r *purchaseResults) Publish(topic string, messages ...*message.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, msg := range messages {
+		var result pb.PurchaseResult
+		if err := bus.Decode(msg, &result); err != nil {
+			return err
+		}
+		r.statuses[result.Step] = append(r.statuses[result.Step], result.Status)
+		r.count++
+	}
+	return nil
+}
+
+func (r *purchaseResults) Close() error { return nil }
+
+func (r *purchaseResults) list() (int, string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count, fmt.Sprint(r.statuses)
+}
+
+// TestGoChannelSaga runs the product, order and payment routers on the
+// GoChannel of one process, with the orchestrator or choreographed, and
+// checks both modes publish the same results. The results are compared
+// step by step: the orchestrator commands the rollbacks at once, a
+// choreographed saga runs them one after the other.
+func TestGoChannelSaga(t *testing.T) {
+	config.ContextLogger = logrus.NewEntry(logrus.New())
+
+	inventory, order, payment := pb.PurchaseStep_STEP_UPDATE_PRODUCT_INVENTORY, pb.PurchaseStep_STEP_CREATE_ORDER, pb.PurchaseStep_STEP_CREATE_PAYMENT
+	execute, success, failed := pb.PurchaseStatus_STATUS_EXUCUTE, pb.PurchaseStatus_STATUS_SUCCESS, pb.PurchaseStatus_STATUS_FAILED
+	rolledBack, rollbackFailed := pb.PurchaseStatus_STATUS_ROLLBACKED, pb.PurchaseStatus_STATUS_ROLLBACK_FAIL
+
+	tests := []struct {
+		name        string
+		fail        []string
+		wantResults map[pb.PurchaseStep][]pb.PurchaseStatus
+		wantCalls   []string
+	}{
+		{
+			name: "purchase succeeds",
+			wantResults: map[pb.PurchaseStep][]pb.PurchaseStatus{
+				inventory: {execute, success}, order: {execute, success}, payment: {execute, success},
+			},
+			wantCalls: []string{"reserve inventory 1", "create order 1", "create payment 1", "capture payment 1"},
+		},
+		{
+			name: "inventory fails",
+			fail: []string{"reserve inventory"},
+			wantResults: map[pb.PurchaseStep][]pb.PurchaseStatus{
+				inventory: {execute, failed, rolledBack},
+			},
+			wantCalls: []string{"reserve inventory 1", "release inventory 1"},
+		},
+		{
+			name: "order fails",
+			fail: []string{"create order"},
+			wantResults: map[pb.PurchaseStep][]pb.PurchaseStatus{
+				inventory: {execute, success, rolledBack}, order: {execute, failed, rolledBack},
+			},
+			wantCalls: []string{"reserve inventory 1", "create order 1", "rollback order 1", "release inventory 1"},
+		},
+		{
+			name: "payment fails",
+			fail: []string{"create payment"},
+			wantResults: map[pb.PurchaseStep][]pb.PurchaseStatus{
+				inventory: {execute, success, rolledBack}, order: {execute, success, rolledBack}, payment: {execute, failed, rolledBack},
+			},
+			wantCalls: []string{
+				"reserve inventory 1", "create order 1", "create payment 1",
+				"rollback payment 1", "rollback order 1", "release inventory 1",
+			},
+		},
+		{
+			name: "capture fails",
+			fail: []string{"capture payment"},
+			wantResults: map[pb.PurchaseStep][]pb.PurchaseStatus{
+				inventory: {execute, success, rolledBack}, order: {execute, success, rolledBack}, payment: {execute, failed, rolledBack},
+			},
+			wantCalls: []string{
+				"reserve inventory 1", "create order 1", "create payment 1",
+				"capture payment 1", "capture payment 1", "capture payment 1",
+				"rollback payment 1", "rollback order 1", "release inventory 1",
+			},
+		},
+		{
+			name: "order rollback fails",
+			fail: []string{"create payment", "rollback order"},
+			wantResults: map[pb.PurchaseStep][]pb.PurchaseStatus{
+				inventory: {execute, success, rolledBack}, order: {execute, success, rolledBack, rollbackFailed}, payment: {execute, failed, rolledBack},
+			},
+			wantCalls: []string{
+				"reserve inventory 1", "create order 1", "create payment 1",
+				"rollback payment 1", "rollback order 1", "release inventory 1",
+			},
+		},
+	}
+	for _, mode := range []string{libconfig.SagaOrchestration, libconfig.SagaChoreography} {
+		for _, tt := range tests {
+			t.Run(mode+"/"+tt.name, func(t *testing.T) {
+				logger := watermill.NopLogger{}
+				pubSub := gochannel.NewGoChannel(gochannel.Config{}, logger)
+				defer pubSub.Close()
+
+				appCfg := &libconfig.ApplicationConfig{SagaConfig: libconfig.Saga{Mode: mode, Capture: libconfig.Capture{Interval: 5}}}
+				calls := &sagaCalls{fail: map[string]bool{}}
+				for _, call := range tt.fail {
+					calls.fail[call] = true
+				}
+				results := &purchaseResults{statuses: map[pb.PurchaseStep][]pb.PurchaseStatus{}}
+				newBus := func() *bus.Bus {
+					router, err := message.NewRouter(message.RouterConfig{}, logger)
+					if err != nil {
+						t.Fatal(err)
+					}
+					b := bus.New(router, pubSub, pubSub, codec.JSON)
+					b.SetTopicPublisher(event.PurchaseResultTopic, results)
+					return b
+				}
+
+				productService := fakeSagaProductUseCase{calls}
+				orderService := fakeSagaOrderUseCase{calls}
+				paymentService := fakeSagaPaymentUseCase{calls}
+				routers := []infrabroker.EventRouter{
+					NewProductEventRouter(appCfg, newBus(), NewSagaProductController(productService), NewChoreographedProductController(productService)),
+					NewOrderEventRouter(appCfg, newBus(), NewSagaOrderController(orderService), NewChoreographedOrderController(orderService)),
+					NewPaymentEventRouter(appCfg, newBus(), NewSagaPaymentController(paymentService), NewChoreographedPaymentController(appCfg, paymentService)),
+				}
+				if mode == libconfig.SagaOrchestration {
+					orchestratorBus := newBus()
+					orchestrator := application.NewOrchestratorService(appCfg, orchestratorBus, NewPurchaseResultPublisher(results, codec.JSON),
+						&memSagaRepository{}, &memPurchaseSagaRepository{streams: map[uint64][]any{}})
+					routers = append(routers, NewOrchestratorEventRouter(appCfg, orchestratorBus, NewSagaOrchestratorController(orchestrator)))
+				}
+				for _, r := range routers {
+					r := r
+					go func() {
+						if err := r.Run(); err != nil {
+							t.Error(err)
+						}
+					}()
+					defer r.GracefulShutdown()
+				}
+				for _, r := range routers {
+					<-eventBus(r).Router().Running()
+				}
+
+				if err := bus.Publish(context.Background(), newBus(), event.PurchaseTopic, &pb.CreatePurchaseCommand{
+					PurchaseId: 1,
+					Purchase: &pb.Purchase{
+						Order:   &pb.Order{UserId: 7, PurchasedItems: []*pb.PurchasedItem{{ProductId: 3, Amount: 2}}},
+						Payment: &pb.Payment{CurrencyCode: "TWD", Amount: 1000},
+					},
+				}); err != nil {
+					t.Fatal(err)
+				}
+
+				wantResults := 0
+				for _, statuses := range tt.wantResults {
+					wantResults += len(statuses)
+				}
+				timeout := time.After(5 * time.Second)
+				for {
+					count, _ := results.list()
+					if count >= wantResults && len(calls.list()) >= len(tt.wantCalls) {
+						break
+					}
+					select {
+					case <-time.After(10 * time.Millisecond):
+					case <-timeout:
+						_, got := results.list()
+						t.Fatalf("results %s, calls %v, want %d results and %d calls", got, calls.list(), wantResults, len(tt.wantCalls))
+					}
+				}
+				// results or calls published after the expected ones
+				time.Sleep(50 * time.Millisecond)
+
+				if _, got := results.list(); got != fmt.Sprint(tt.wantResults) {
+					t.Errorf("results = %s, want %v", got, tt.wantResults)
+				}
+				got := calls.list()
+				sort.Strings(got)
+				want := append([]string(nil), tt.wantCalls...)
+				sort.Strings(want)
+				if fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Errorf("calls = %v, want %v", got, want)
+				}
+			})
+		}
+	}
+}
+
+// eventBus returns the bus of an event router of this package
+func eventBus(r infrabroker.EventRouter) *bus.Bus {
+	switch r := r.(type) {
+	case *ProductEventRouter:
+		return r.bus
+	case *OrderEventRouter:
+		return r.bus
+	case *PaymentEventRouter:
+		return r.bus
+	case *OrchestratorEventRouter:
+		return r.bus
+	default:
+		panic(fmt.Sprintf("unknown event router %T", r))
+	}
+}
@@ -2,15 +2,15 @@ package broker
 
 import (
 	"context"
-	"log"
 
-	"github.com/Chengxufeng1994/go-saga-example/common/constant"
+	"github.com/Chengxufeng1994/go-saga-example/common/bus"
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
 	"github.com/Chengxufeng1994/go-saga-example/common/event"
+	"github.com/Chengxufeng1994/go-saga-example/common/pb"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/config"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/broker"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/usecase"
 	"github.com/ThreeDotsLabs/watermill/message"
-	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
-	"go.opentelemetry.io/otel/propagation"
 )
 
 type sagaOrchestratorController struct {
@@ -24,72 +24,73 @@ func NewSagaOrchestratorController(svc usecase.OrchestratorUseCase) *sagaOrchest
 }
 
 // handleTransaction start the purchase transaction
-func (ctrl sagaOrchestratorController) HandleTrx(msg *message.Message) error {
-	log.Println("handleTransaction received message", msg.UUID)
-	req, _, err := broker.DecodeCreatePurchaseCommand(msg.Payload)
-	if err != nil {
-		return err
-	}
-	correlationID := msg.Metadata.Get(middleware.CorrelationIDMetadataKey)
-	carrier := make(propagation.HeaderCarrier)
-	carrier.Set(broker.TraceparentHeader, msg.Metadata.Get(string(constant.CtxSpanKey)))
-	parentCtx := broker.TraceContext.Extract(context.Background(), carrier)
-	return ctrl.svc.HandleTrx(parentCtx, req, correlationID)
+func (ctrl sagaOrchestratorController) HandleTrx(ctx context.Context, cmd *pb.CreatePurchaseCommand) error {
+	return ctrl.svc.HandleTrx(ctx, broker.DecodeCreatePurchaseCommand(cmd), bus.CorrelationID(ctx))
+}
+
+// HandleReply gets the raw message, the reply type depends on its handler header
+func (ctrl sagaOrchestratorController) HandleReply(ctx context.Context, msg *message.Message) error {
+	return ctrl.svc.HandleReply(ctx, msg, bus.CorrelationID(ctx))
 }
 
-func (ctrl sagaOrchestratorController) HandleReply(msg *message.Message) error {
-	correlationID := msg.Metadata.Get(middleware.CorrelationIDMetadataKey)
-	carrier := make(propagation.HeaderCarrier)
-	carrier.Set(broker.TraceparentHeader, msg.Metadata.Get(string(constant.CtxSpanKey)))
-	parentCtx := broker.TraceContext.Extract(context.Background(), carrier)
-	return ctrl.svc.HandleReply(parentCtx, msg, correlationID)
+// HandlePaymentStatus handles payment status changes confirmed by the provider
+func (ctrl sagaOrchestratorController) HandlePaymentStatus(ctx context.Context, evt *pb.PaymentStatusChanged) error {
+	return ctrl.svc.HandlePaymentStatus(ctx, broker.DecodePaymentStatusChanged(evt), bus.CorrelationID(ctx))
 }
 
 type OrchestratorEventRouter struct {
-	router     *message.Router
-	publisher  broker.NatsPublisher
-	subscriber broker.NatsSubscriber
-	controller *sagaOrchestratorController
+	bus           *bus.Bus
+	choreographed bool
+	controller    *sagaOrchestratorController
 }
 
-func NewOrchestratorEventRouter(
-	router *message.Router,
-	publisher broker.NatsPublisher,
-	subscriber broker.NatsSubscriber,
-	controller *sagaOrchestratorController) broker.EventRouter {
+func NewOrchestratorEventRouter(appCfg *libconfig.ApplicationConfig, eventBus *bus.Bus, controller *sagaOrchestratorController) broker.EventRouter {
 	return &OrchestratorEventRouter{
-		router:     router,
-		publisher:  publisher,
-		subscriber: subscriber,
-		controller: controller,
+		bus:           eventBus,
+		choreographed: appCfg.SagaConfig.Choreographed(),
+		controller:    controller,
 	}
 }
 
 // RegisterHandlers implements broker.EventRouter.
+// A choreographed saga runs without the orchestrator, which keeps no log of
+// it. The orchestrator only lists the sagas it ran before and rejects the
+// operator actions.
 func (r *OrchestratorEventRouter) RegisterHandlers() {
+	if r.choreographed {
+		config.ContextLogger.Warn("saga is choreographed, the orchestrator handles no messages")
+		return
+	}
 
-	r.router.AddNoPublisherHandler(
+	bus.Subscribe(
+		r.bus,
 		"saga_orchestrator_handle_transaction_handler",
 		event.PurchaseTopic,
-		r.subscriber,
 		r.controller.HandleTrx,
 	)
 
-	r.router.AddNoPublisherHandler(
+	bus.SubscribeMessage(
+		r.bus,
 		"saga_orchestrator_handle_reply_handler",
 		event.ReplyTopic,
-		r.subscriber,
 		r.controller.HandleReply,
 	)
+
+	bus.Subscribe(
+		r.bus,
+		"saga_orchestrator_handle_payment_status_handler",
+		event.PaymentStatusTopic,
+		r.controller.HandlePaymentStatus,
+	)
 }
 
 // Run implements broker.EventRouter.
 func (r *OrchestratorEventRouter) Run() error {
 	r.RegisterHandlers()
-	return r.router.Run(context.Background())
+	return r.bus.Router().Run(context.Background())
 }
 
 // GracefulShutdown implements broker.EventRouter.
 func (r *OrchestratorEventRouter) GracefulShutdown() error {
-	return r.router.Close()
+	return r.bus.Router().Close()
 }
@@ -2,19 +2,17 @@ package broker
 
 import (
 	"context"
-	"encoding/json"
-	"log"
 	"time"
 
+	"github.com/Chengxufeng1994/go-saga-example/common/bus"
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
 	"github.com/Chengxufeng1994/go-saga-example/common/constant"
 	"github.com/Chengxufeng1994/go-saga-example/common/event"
 	"github.com/Chengxufeng1994/go-saga-example/common/pb"
+	domainevent "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/broker"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/usecase"
-	"github.com/ThreeDotsLabs/watermill"
-	"github.com/ThreeDotsLabs/watermill/message"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/propagation"
+	"github.com/sirupsen/logrus"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -28,25 +26,14 @@ func NewSagaOrderController(orderService usecase.SagaOrderUseCase) *sagaOrderCon
 	}
 }
 
-func (c *sagaOrderController) HandleExecuteCreateOrder(msg *message.Message) ([]*message.Message, error) {
-	log.Println("handleExecuteCreateOrder received message", msg.UUID)
-	carrier := make(propagation.HeaderCarrier)
-	carrier.Set(broker.TraceparentHeader, msg.Metadata.Get(string(constant.CtxSpanKey)))
-	parentCtx := broker.TraceContext.Extract(context.Background(), carrier)
-	tr := otel.Tracer("executeCreateOrder")
-	ctx, span := tr.Start(parentCtx, "event.ExecuteCreateOrder")
-	defer span.End()
+func (c *sagaOrderController) HandleExecuteCreateOrder(ctx context.Context, cmd *pb.CreatePurchaseCommand) (*pb.CreatePurchaseResponse, error) {
+	purchase := broker.DecodeCreatePurchaseCommand(cmd)
 
-	purchase, pbPurchase, err := broker.DecodeCreatePurchaseCommand(msg.Payload)
-	if err != nil {
-		return nil, err
-	}
-
-	reply := pb.CreatePurchaseResponse{
+	reply := &pb.CreatePurchaseResponse{
 		PurchaseId: purchase.ID,
-		Purchase:   pbPurchase,
+		Purchase:   cmd.Purchase,
 	}
-	err = c.orderService.ExecuteCreateOrder(ctx, purchase.Order)
+	err := c.orderService.ExecuteCreateOrder(ctx, purchase.Order)
 	if err != nil {
 		reply.Success = false
 		reply.Error = err.Error()
@@ -55,39 +42,15 @@ func (c *sagaOrderController) HandleExecuteCreateOrder(msg *message.Message) ([]
 		reply.Error = ""
 	}
 	reply.Timestamp = timestamppb.New(time.Now())
+	return reply, nil
+}
 
-	payload, err := json.Marshal(&reply)
-	if err != nil {
-		return nil, err
-	}
-
-	var replyMsgs []*message.Message
-	replyMsg := message.NewMessage(watermill.NewUUID(), payload)
-	replyMsg.Metadata.Set(constant.HandlerHeader, constant.CreateOrderHandler)
-	broker.SetSpanContext(ctx, replyMsg)
-	replyMsgs = append(replyMsgs, replyMsg)
-	return replyMsgs, nil
-}
-
-func (c *sagaOrderController) HandleRollbackCreateOrder(msg *message.Message) ([]*message.Message, error) {
-	log.Println("handleRollbackCreateOrder received message", msg.UUID)
-	carrier := make(propagation.HeaderCarrier)
-	carrier.Set(broker.TraceparentHeader, msg.Metadata.Get(string(constant.CtxSpanKey)))
-	parentCtx := broker.TraceContext.Extract(context.Background(), carrier)
-	tr := otel.Tracer("rollbackCreateOrder")
-	ctx, span := tr.Start(parentCtx, "event.RollbackCreateOrder")
-	defer span.End()
-
-	var cmd pb.RollbackCommand
-	if err := json.Unmarshal(msg.Payload, &cmd); err != nil {
-		return nil, err
-	}
-
-	reply := pb.RollbackResponse{
+func (c *sagaOrderController) HandleRollbackCreateOrder(ctx context.Context, cmd *pb.RollbackCommand) (*pb.RollbackResponse, error) {
+	reply := &pb.RollbackResponse{
 		UserId:     cmd.UserId,
 		PurchaseId: cmd.PurchaseId,
 	}
-	err := c.orderService.RollbackCreateOrder(parentCtx, cmd.PurchaseId)
+	err := c.orderService.RollbackCreateOrder(ctx, cmd.PurchaseId)
 	if err != nil {
 		reply.Success = false
 		reply.Error = err.Error()
@@ -96,65 +59,137 @@ func (c *sagaOrderController) HandleRollbackCreateOrder(msg *message.Message) ([
 		reply.Error = ""
 	}
 	reply.Timestamp = timestamppb.New(time.Now())
+	return reply, nil
+}
 
-	payload, err := json.Marshal(&reply)
-	if err != nil {
-		return nil, err
+// HandleUserDeleted detaches the orders of a user whose account was deleted
+func (c *sagaOrderController) HandleUserDeleted(ctx context.Context, evt *pb.UserDeleted) error {
+	return c.orderService.AnonymizeUser(ctx, evt.UserId)
+}
+
+// choreographedOrderController creates the order once the inventory is
+// reserved and rolls it back when the payment failed
+type choreographedOrderController struct {
+	logger       *logrus.Entry
+	orderService usecase.SagaOrderUseCase
+}
+
+func NewChoreographedOrderController(orderService usecase.SagaOrderUseCase) *choreographedOrderController {
+	return &choreographedOrderController{
+		logger:       choreographyLogger("order"),
+		orderService: orderService,
 	}
+}
+
+// HandleInventoryReserved creates the order of the purchase, the payment is
+// created once it is. A failed order is rolled back like the orchestrator
+// does, then the inventory is released.
+func (c *choreographedOrderController) HandleInventoryReserved(ctx context.Context, evt *pb.PurchaseStepSucceeded) ([]bus.Event, error) {
+	purchase := decodeStepPurchase(evt)
+	userID := purchase.Order.UserID
+	step := domainevent.StepCreateOrder
+
+	events := []bus.Event{purchaseResult(userID, purchase.ID, step, domainevent.StatusExecute)}
+	if err := c.orderService.ExecuteCreateOrder(ctx, purchase.Order); err != nil {
+		c.logger.WithError(err).Errorf("create order %v", purchase.ID)
+		events = append(events, purchaseResult(userID, purchase.ID, step, domainevent.StatusFailed))
+		events = append(events, c.rollback(ctx, userID, purchase.ID)...)
+		return append(events, stepFailed(event.OrderCreationFailedTopic, userID, purchase.ID, err.Error())), nil
+	}
+	return append(events,
+		purchaseResult(userID, purchase.ID, step, domainevent.StatusSucess),
+		stepSucceeded(event.OrderCreatedTopic, purchase.ID, evt.Purchase),
+	), nil
+}
 
-	var replyMsgs []*message.Message
-	replyMsg := message.NewMessage(watermill.NewUUID(), payload)
-	replyMsg.Metadata.Set(constant.HandlerHeader, constant.RollbackOrderHandler)
-	broker.SetSpanContext(ctx, replyMsg)
-	replyMsgs = append(replyMsgs, replyMsg)
-	return replyMsgs, nil
+// HandlePaymentFailed rolls back the order of the purchase. The inventory is
+// released even when the rollback failed, the order is then left to an
+// operator.
+func (c *choreographedOrderController) HandlePaymentFailed(ctx context.Context, evt *pb.PurchaseStepFailed) ([]bus.Event, error) {
+	events := c.rollback(ctx, evt.UserId, evt.PurchaseId)
+	return append(events, stepFailed(event.OrderRolledBackTopic, evt.UserId, evt.PurchaseId, evt.Error)), nil
+}
+
+func (c *choreographedOrderController) rollback(ctx context.Context, userID, purchaseID uint64) []bus.Event {
+	err := c.orderService.RollbackCreateOrder(ctx, purchaseID)
+	if err != nil {
+		c.logger.WithError(err).Errorf("rollback create order %v", purchaseID)
+	}
+	return rollbackResults(userID, purchaseID, domainevent.StepCreateOrder, err)
 }
 
 type OrderEventRouter struct {
-	router     *message.Router
-	publisher  broker.NatsPublisher
-	subscriber broker.NatsSubscriber
-	controller *sagaOrderController
+	bus                    *bus.Bus
+	choreographed          bool
+	controller             *sagaOrderController
+	choreographyController *choreographedOrderController
 }
 
-func NewOrderEventRouter(router *message.Router, publisher broker.NatsPublisher, subscriber broker.NatsSubscriber, controller *sagaOrderController) broker.EventRouter {
+func NewOrderEventRouter(appCfg *libconfig.ApplicationConfig, eventBus *bus.Bus, controller *sagaOrderController, choreographyController *choreographedOrderController) broker.EventRouter {
 	return &OrderEventRouter{
-		router:     router,
-		publisher:  publisher,
-		subscriber: subscriber,
-		controller: controller,
+		bus:                    eventBus,
+		choreographed:          appCfg.SagaConfig.Choreographed(),
+		controller:             controller,
+		choreographyController: choreographyController,
 	}
 }
 
 // RegisterHandlers implements broker.EventRouter.
 func (r *OrderEventRouter) RegisterHandlers() {
+	bus.Subscribe(
+		r.bus,
+		"order_user_deleted_handler",
+		event.UserDeletedTopic,
+		r.controller.HandleUserDeleted,
+	)
+
+	if r.choreographed {
+		r.registerChoreography()
+		return
+	}
 
-	r.router.AddHandler(
+	bus.Handle(
+		r.bus,
 		"saga_order_create_order_handler",
 		event.CreateOrderTopic,
-		r.subscriber,
 		event.ReplyTopic,
-		r.publisher,
+		constant.CreateOrderHandler,
 		r.controller.HandleExecuteCreateOrder,
 	)
 
-	r.router.AddHandler(
+	bus.Handle(
+		r.bus,
 		"saga_order_rollback_order_handler",
 		event.RollbackOrderTopic,
-		r.subscriber,
 		event.ReplyTopic,
-		r.publisher,
+		constant.RollbackOrderHandler,
 		r.controller.HandleRollbackCreateOrder,
 	)
 }
 
+func (r *OrderEventRouter) registerChoreography() {
+	bus.React(
+		r.bus,
+		"saga_order_handle_inventory_reserved_handler",
+		event.InventoryReservedTopic,
+		r.choreographyController.HandleInventoryReserved,
+	)
+
+	bus.React(
+		r.bus,
+		"saga_order_handle_payment_failed_handler",
+		event.PaymentFailedTopic,
+		r.choreographyController.HandlePaymentFailed,
+	)
+}
+
 // Run implements broker.EventRouter.
 func (r *OrderEventRouter) Run() error {
 	r.RegisterHandlers()
-	return r.router.Run(context.Background())
+	return r.bus.Router().Run(context.Background())
 }
 
 // GracefulShutdown implements broker.EventRouter.
 func (r *OrderEventRouter) GracefulShutdown() error {
-	return r.router.Close()
+	return r.bus.Router().Close()
 }
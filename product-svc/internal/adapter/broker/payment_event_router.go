@@ -2,19 +2,18 @@ package broker
 
 import (
 	"context"
-	"encoding/json"
-	"log"
 	"time"
 
+	"github.com/Chengxufeng1994/go-saga-example/common/bus"
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
 	"github.com/Chengxufeng1994/go-saga-example/common/constant"
 	"github.com/Chengxufeng1994/go-saga-example/common/event"
 	"github.com/Chengxufeng1994/go-saga-example/common/pb"
+	domainevent "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/broker"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/usecase"
-	"github.com/ThreeDotsLabs/watermill"
-	"github.com/ThreeDotsLabs/watermill/message"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/propagation"
+	"github.com/sirupsen/logrus"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -28,25 +27,15 @@ func NewSagaPaymentController(paymentService usecase.SagaPaymentUseCase) *sagaPa
 	}
 }
 
-func (c *sagaPaymentController) HandleExecuteCreatePayment(msg *message.Message) ([]*message.Message, error) {
-	log.Println("handleExecuteCreateOrder received message", msg.UUID)
-	carrier := make(propagation.HeaderCarrier)
-	carrier.Set(broker.TraceparentHeader, msg.Metadata.Get(string(constant.CtxSpanKey)))
-	parentCtx := broker.TraceContext.Extract(context.Background(), carrier)
-	tr := otel.Tracer("executeCreatePayment")
-	ctx, span := tr.Start(parentCtx, "event.ExecuteCreatePayment")
-	defer span.End()
+func (c *sagaPaymentController) HandleExecuteCreatePayment(ctx context.Context, cmd *pb.CreatePurchaseCommand) (*pb.CreatePurchaseResponse, error) {
+	purchase := broker.DecodeCreatePurchaseCommand(cmd)
 
-	purchase, pbPurchase, err := broker.DecodeCreatePurchaseCommand(msg.Payload)
-	if err != nil {
-		return nil, err
-	}
-
-	reply := pb.CreatePurchaseResponse{
+	reply := &pb.CreatePurchaseResponse{
 		PurchaseId: purchase.ID,
-		Purchase:   pbPurchase,
+		Purchase:   cmd.Purchase,
 	}
-	err = c.paymentService.ExecuteCreatePayment(ctx, purchase.Payment)
+	purchase.Payment.CorrelationID = bus.CorrelationID(ctx)
+	err := c.paymentService.ExecuteCreatePayment(ctx, purchase.Payment)
 	if err != nil {
 		reply.Success = false
 		reply.Error = err.Error()
@@ -55,39 +44,32 @@ func (c *sagaPaymentController) HandleExecuteCreatePayment(msg *message.Message)
 		reply.Error = ""
 	}
 	reply.Timestamp = timestamppb.New(time.Now())
+	return reply, nil
+}
 
-	payload, err := json.Marshal(&reply)
+func (c *sagaPaymentController) HandleRollbackCreatePayment(ctx context.Context, cmd *pb.RollbackCommand) (*pb.RollbackResponse, error) {
+	reply := &pb.RollbackResponse{
+		UserId:     cmd.UserId,
+		PurchaseId: cmd.PurchaseId,
+	}
+	err := c.paymentService.RollbackCreatePayment(ctx, cmd.PurchaseId)
 	if err != nil {
-		return nil, err
+		reply.Success = false
+		reply.Error = err.Error()
+	} else {
+		reply.Success = true
+		reply.Error = ""
 	}
-
-	var replyMsgs []*message.Message
-	replyMsg := message.NewMessage(watermill.NewUUID(), payload)
-	replyMsg.Metadata.Set(constant.HandlerHeader, constant.CreatePaymentHandler)
-	broker.SetSpanContext(ctx, replyMsg)
-	replyMsgs = append(replyMsgs, replyMsg)
-	return replyMsgs, nil
+	reply.Timestamp = timestamppb.New(time.Now())
+	return reply, nil
 }
 
-func (c *sagaPaymentController) HandleRollbackCreatePayment(msg *message.Message) ([]*message.Message, error) {
-	log.Println("handleRollbackCreatePayment received message", msg.UUID)
-	carrier := make(propagation.HeaderCarrier)
-	carrier.Set(broker.TraceparentHeader, msg.Metadata.Get(string(constant.CtxSpanKey)))
-	parentCtx := broker.TraceContext.Extract(context.Background(), carrier)
-	tr := otel.Tracer("rollbackCreatePayment")
-	ctx, span := tr.Start(parentCtx, "event.RollbackCreatePayment")
-	defer span.End()
-
-	var cmd pb.RollbackCommand
-	if err := json.Unmarshal(msg.Payload, &cmd); err != nil {
-		return nil, err
-	}
-
-	reply := pb.RollbackResponse{
+func (c *sagaPaymentController) HandleCapturePayment(ctx context.Context, cmd *pb.CapturePaymentCommand) (*pb.CapturePaymentResponse, error) {
+	reply := &pb.CapturePaymentResponse{
 		UserId:     cmd.UserId,
 		PurchaseId: cmd.PurchaseId,
 	}
-	err := c.paymentService.RollbackCreatePayment(parentCtx, cmd.PurchaseId)
+	err := c.paymentService.CapturePayment(ctx, cmd.PurchaseId)
 	if err != nil {
 		reply.Success = false
 		reply.Error = err.Error()
@@ -96,66 +78,181 @@ func (c *sagaPaymentController) HandleRollbackCreatePayment(msg *message.Message
 		reply.Error = ""
 	}
 	reply.Timestamp = timestamppb.New(time.Now())
+	return reply, nil
+}
+
+// HandleUserDeleted detaches the payments of a user whose account was deleted
+func (c *sagaPaymentController) HandleUserDeleted(ctx context.Context, evt *pb.UserDeleted) error {
+	return c.paymentService.AnonymizeUser(ctx, evt.UserId)
+}
+
+// choreographedPaymentController creates and captures the payment once the
+// order is created, a failed payment rolls back the order
+type choreographedPaymentController struct {
+	logger                   *logrus.Entry
+	awaitPaymentConfirmation bool
+	capture                  libconfig.Capture
+	paymentService           usecase.SagaPaymentUseCase
+}
+
+func NewChoreographedPaymentController(appCfg *libconfig.ApplicationConfig, paymentService usecase.SagaPaymentUseCase) *choreographedPaymentController {
+	return &choreographedPaymentController{
+		logger:                   choreographyLogger("payment"),
+		awaitPaymentConfirmation: appCfg.SagaConfig.AwaitPaymentConfirmation,
+		capture:                  appCfg.SagaConfig.Capture,
+		paymentService:           paymentService,
+	}
+}
 
-	payload, err := json.Marshal(&reply)
+// HandleOrderCreated creates the payment of the purchase and captures it.
+// The saga succeeds once the capture did unless it awaits the provider's
+// confirmation, a capture failing every attempt compensates it as the
+// orchestrator does.
+func (c *choreographedPaymentController) HandleOrderCreated(ctx context.Context, evt *pb.PurchaseStepSucceeded) ([]bus.Event, error) {
+	purchase := decodeStepPurchase(evt)
+	userID := purchase.Order.UserID
+	step := domainevent.StepCreatePayment
+
+	events := []bus.Event{purchaseResult(userID, purchase.ID, step, domainevent.StatusExecute)}
+	purchase.Payment.CorrelationID = bus.CorrelationID(ctx)
+	if err := c.paymentService.ExecuteCreatePayment(ctx, purchase.Payment); err != nil {
+		c.logger.WithError(err).Errorf("create payment %v", purchase.ID)
+		return append(events, c.fail(ctx, userID, purchase.ID, err.Error())...), nil
+	}
+
+	if err := c.capturePayment(ctx, purchase.ID); err != nil {
+		c.logger.WithError(err).Errorf("capture payment %v failed %d times, compensate", purchase.ID, c.capture.Attempts())
+		return append(events, c.fail(ctx, userID, purchase.ID, err.Error())...), nil
+	}
+	if !c.awaitPaymentConfirmation {
+		events = append(events, purchaseResult(userID, purchase.ID, step, domainevent.StatusSucess))
+	}
+	return events, nil
+}
+
+// capturePayment captures the authorization of the purchase, retrying with
+// the backoff the orchestrator retries with
+func (c *choreographedPaymentController) capturePayment(ctx context.Context, purchaseID uint64) error {
+	var err error
+	for attempt := 1; attempt <= c.capture.Attempts(); attempt++ {
+		if err = c.capture.Wait(ctx, attempt); err != nil {
+			return err
+		}
+		if err = c.paymentService.CapturePayment(ctx, purchaseID); err == nil {
+			return nil
+		}
+		c.logger.WithError(err).Errorf("capture payment %v, attempt %d", purchaseID, attempt)
+	}
+	return err
+}
+
+// HandlePaymentStatus completes the saga awaiting payment confirmation on a
+// confirmed capture and compensates it on a failed one
+func (c *choreographedPaymentController) HandlePaymentStatus(ctx context.Context, evt *pb.PaymentStatusChanged) ([]bus.Event, error) {
+	switch evt.Status {
+	case string(valueobject.PaymentCaptured):
+		return []bus.Event{purchaseResult(evt.UserId, evt.PurchaseId, domainevent.StepCreatePayment, domainevent.StatusSucess)}, nil
+	case valueobject.WebhookPaymentCaptureFailed:
+		return c.fail(ctx, evt.UserId, evt.PurchaseId, evt.EventType), nil
+	default:
+		return nil, nil
+	}
+}
+
+// fail rolls back the payment, the order is rolled back next
+func (c *choreographedPaymentController) fail(ctx context.Context, userID, purchaseID uint64, reason string) []bus.Event {
+	step := domainevent.StepCreatePayment
+	events := []bus.Event{purchaseResult(userID, purchaseID, step, domainevent.StatusFailed)}
+
+	err := c.paymentService.RollbackCreatePayment(ctx, purchaseID)
 	if err != nil {
-		return nil, err
+		c.logger.WithError(err).Errorf("rollback create payment %v", purchaseID)
 	}
-	var replyMsgs []*message.Message
-	replyMsg := message.NewMessage(watermill.NewUUID(), payload)
-	replyMsg.Metadata.Set(constant.HandlerHeader, constant.RollbackPaymentHandler)
-	broker.SetSpanContext(ctx, replyMsg)
-	replyMsgs = append(replyMsgs, replyMsg)
-	return replyMsgs, nil
+	events = append(events, rollbackResults(userID, purchaseID, step, err)...)
+	return append(events, stepFailed(event.PaymentFailedTopic, userID, purchaseID, reason))
 }
 
 type PaymentEventRouter struct {
-	router     *message.Router
-	publisher  broker.NatsPublisher
-	subscriber broker.NatsSubscriber
-	controller *sagaPaymentController
+	bus                    *bus.Bus
+	choreographed          bool
+	controller             *sagaPaymentController
+	choreographyController *choreographedPaymentController
 }
 
-func NewPaymentEventRouter(
-	router *message.Router,
-	publisher broker.NatsPublisher,
-	subscriber broker.NatsSubscriber,
-	controller *sagaPaymentController) broker.EventRouter {
+func NewPaymentEventRouter(appCfg *libconfig.ApplicationConfig, eventBus *bus.Bus, controller *sagaPaymentController, choreographyController *choreographedPaymentController) broker.EventRouter {
 	return &PaymentEventRouter{
-		router:     router,
-		publisher:  publisher,
-		subscriber: subscriber,
-		controller: controller,
+		bus:                    eventBus,
+		choreographed:          appCfg.SagaConfig.Choreographed(),
+		controller:             controller,
+		choreographyController: choreographyController,
 	}
 }
 
 func (r *PaymentEventRouter) RegisterHandlers() {
+	bus.Subscribe(
+		r.bus,
+		"payment_user_deleted_handler",
+		event.UserDeletedTopic,
+		r.controller.HandleUserDeleted,
+	)
+
+	if r.choreographed {
+		r.registerChoreography()
+		return
+	}
 
-	r.router.AddHandler(
+	bus.Handle(
+		r.bus,
 		"saga_payment_create_payment_handler",
 		event.CreatePaymentTopic,
-		r.subscriber,
 		event.ReplyTopic,
-		r.publisher,
+		constant.CreatePaymentHandler,
 		r.controller.HandleExecuteCreatePayment,
 	)
 
-	r.router.AddHandler(
+	bus.Handle(
+		r.bus,
 		"saga_payment_rollback_payment_handler",
 		event.RollbackPaymentTopic,
-		r.subscriber,
 		event.ReplyTopic,
-		r.publisher,
+		constant.RollbackPaymentHandler,
 		r.controller.HandleRollbackCreatePayment,
 	)
 
+	bus.Handle(
+		r.bus,
+		"saga_payment_capture_payment_handler",
+		event.CapturePaymentTopic,
+		event.ReplyTopic,
+		constant.CapturePaymentHandler,
+		r.controller.HandleCapturePayment,
+	)
+}
+
+func (r *PaymentEventRouter) registerChoreography() {
+	bus.React(
+		r.bus,
+		"saga_payment_handle_order_created_handler",
+		event.OrderCreatedTopic,
+		r.choreographyController.HandleOrderCreated,
+	)
+
+	if !r.choreographyController.awaitPaymentConfirmation {
+		return
+	}
+	bus.React(
+		r.bus,
+		"saga_payment_handle_payment_status_handler",
+		event.PaymentStatusTopic,
+		r.choreographyController.HandlePaymentStatus,
+	)
 }
 
 func (r *PaymentEventRouter) Run() error {
 	r.RegisterHandlers()
-	return r.router.Run(context.Background())
+	return r.bus.Router().Run(context.Background())
 }
 
 func (r *PaymentEventRouter) GracefulShutdown() error {
-	return r.router.Close()
+	return r.bus.Router().Close()
 }
@@ -0,0 +1,40 @@
+package broker
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/bus"
+	"github.com/Chengxufeng1994/go-saga-example/common/codec"
+	"github.com/Chengxufeng1994/go-saga-example/common/event"
+	"github.com/Chengxufeng1994/go-saga-example/common/pb"
+	domainevent "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/broker"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/repository"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type PaymentStatusPublisher struct {
+	topic string
+	bus   *bus.Bus
+}
+
+func NewPaymentStatusPublisher(publisher broker.NatsPublisher, c codec.Codec) repository.PaymentEventRepository {
+	return &PaymentStatusPublisher{
+		topic: event.PaymentStatusTopic,
+		bus:   bus.New(nil, publisher, nil, c),
+	}
+}
+
+// PublishPaymentStatusChanged implements repository.PaymentEventRepository.
+// Payments created before they kept the saga's correlation id get a new one.
+func (p *PaymentStatusPublisher) PublishPaymentStatusChanged(ctx context.Context, evt *domainevent.PaymentStatusChangedEvent) error {
+	return bus.Publish(ctx, p.bus, p.topic, &pb.PaymentStatusChanged{
+		UserId:     evt.UserID,
+		PurchaseId: evt.PurchaseID,
+		Status:     evt.Status,
+		Provider:   evt.Provider,
+		EventId:    evt.EventID,
+		EventType:  evt.EventType,
+		Timestamp:  timestamppb.New(evt.Timestamp),
+	}, bus.WithCorrelationID(evt.CorrelationID), bus.WithPartitionKeys(evt.UserID, evt.PurchaseID))
+}
@@ -2,19 +2,17 @@ package broker
 
 import (
 	"context"
-	"encoding/json"
-	"log"
 	"time"
 
+	"github.com/Chengxufeng1994/go-saga-example/common/bus"
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
 	"github.com/Chengxufeng1994/go-saga-example/common/constant"
 	"github.com/Chengxufeng1994/go-saga-example/common/event"
 	"github.com/Chengxufeng1994/go-saga-example/common/pb"
+	domainevent "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/broker"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/usecase"
-	"github.com/ThreeDotsLabs/watermill"
-	"github.com/ThreeDotsLabs/watermill/message"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/propagation"
+	"github.com/sirupsen/logrus"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -28,25 +26,14 @@ func NewSagaProductController(productService usecase.SagaProductUseCase) *sagaPr
 	}
 }
 
-func (c *sagaProductController) HandleUpdateProductInventory(msg *message.Message) ([]*message.Message, error) {
-	log.Println("handleUpdateProductInventory received message", msg.UUID)
-	carrier := make(propagation.HeaderCarrier)
-	carrier.Set(broker.TraceparentHeader, msg.Metadata.Get(string(constant.CtxSpanKey)))
-	parentCtx := broker.TraceContext.Extract(context.Background(), carrier)
-	tr := otel.Tracer("updateProductInventory")
-	ctx, span := tr.Start(parentCtx, "event.UpdateProductInventory")
-	defer span.End()
+func (c *sagaProductController) HandleUpdateProductInventory(ctx context.Context, cmd *pb.CreatePurchaseCommand) (*pb.CreatePurchaseResponse, error) {
+	purchase := broker.DecodeCreatePurchaseCommand(cmd)
 
-	purchase, pbPurchase, err := broker.DecodeCreatePurchaseCommand(msg.Payload)
-	if err != nil {
-		return nil, err
-	}
-
-	reply := pb.CreatePurchaseResponse{
+	reply := &pb.CreatePurchaseResponse{
 		PurchaseId: purchase.ID,
-		Purchase:   pbPurchase,
+		Purchase:   cmd.Purchase,
 	}
-	err = c.productService.UpdateProductInventory(ctx, purchase.ID, purchase.Order.PurchasedItems)
+	err := c.productService.UpdateProductInventory(ctx, purchase.ID, purchase.Order.PurchasedItems)
 	if err != nil {
 		reply.Success = false
 		reply.Error = err.Error()
@@ -55,35 +42,11 @@ func (c *sagaProductController) HandleUpdateProductInventory(msg *message.Messag
 		reply.Error = ""
 	}
 	reply.Timestamp = timestamppb.New(time.Now())
-
-	payload, err := json.Marshal(&reply)
-	if err != nil {
-		return nil, err
-	}
-
-	var replyMsgs []*message.Message
-	replyMsg := message.NewMessage(watermill.NewUUID(), payload)
-	replyMsg.Metadata.Set(constant.HandlerHeader, constant.UpdateProductInventoryHandler)
-	broker.SetSpanContext(ctx, replyMsg)
-	replyMsgs = append(replyMsgs, replyMsg)
-	return replyMsgs, nil
+	return reply, nil
 }
 
-func (c *sagaProductController) HandleRollbackProductInventory(msg *message.Message) ([]*message.Message, error) {
-	log.Println("handleRollbackProductInventory received message", msg.UUID)
-	carrier := make(propagation.HeaderCarrier)
-	carrier.Set(broker.TraceparentHeader, msg.Metadata.Get(string(constant.CtxSpanKey)))
-	parentCtx := broker.TraceContext.Extract(context.Background(), carrier)
-	tr := otel.Tracer("updateProductInventory")
-	ctx, span := tr.Start(parentCtx, "event.UpdateProductInventory")
-	defer span.End()
-
-	var cmd pb.RollbackCommand
-	if err := json.Unmarshal(msg.Payload, &cmd); err != nil {
-		return nil, err
-	}
-
-	reply := pb.RollbackResponse{
+func (c *sagaProductController) HandleRollbackProductInventory(ctx context.Context, cmd *pb.RollbackCommand) (*pb.RollbackResponse, error) {
+	reply := &pb.RollbackResponse{
 		UserId:     cmd.UserId,
 		PurchaseId: cmd.PurchaseId,
 	}
@@ -96,65 +59,126 @@ func (c *sagaProductController) HandleRollbackProductInventory(msg *message.Mess
 		reply.Error = ""
 	}
 	reply.Timestamp = timestamppb.New(time.Now())
+	return reply, nil
+}
+
+// choreographedProductController reserves the inventory of new purchases
+// and releases it when a later step failed
+type choreographedProductController struct {
+	logger         *logrus.Entry
+	productService usecase.SagaProductUseCase
+}
+
+func NewChoreographedProductController(productService usecase.SagaProductUseCase) *choreographedProductController {
+	return &choreographedProductController{
+		logger:         choreographyLogger("product"),
+		productService: productService,
+	}
+}
+
+// HandlePurchase reserves the inventory of a new purchase, the order is
+// created once it is. A failed reservation releases what it may have
+// reserved, as the orchestrator does.
+func (c *choreographedProductController) HandlePurchase(ctx context.Context, cmd *pb.CreatePurchaseCommand) ([]bus.Event, error) {
+	purchase := broker.DecodeCreatePurchaseCommand(cmd)
+	userID := purchase.Order.UserID
+	step := domainevent.StepUpdateProductInventory
+
+	events := []bus.Event{purchaseResult(userID, purchase.ID, step, domainevent.StatusExecute)}
+	if err := c.productService.UpdateProductInventory(ctx, purchase.ID, purchase.Order.PurchasedItems); err != nil {
+		c.logger.WithError(err).Errorf("update product inventory %v", purchase.ID)
+		events = append(events, purchaseResult(userID, purchase.ID, step, domainevent.StatusFailed))
+		return append(events, c.rollback(ctx, userID, purchase.ID)...), nil
+	}
+	return append(events,
+		purchaseResult(userID, purchase.ID, step, domainevent.StatusSucess),
+		stepSucceeded(event.InventoryReservedTopic, purchase.ID, cmd.Purchase),
+	), nil
+}
+
+// HandleRollback releases the inventory of a purchase whose order could not
+// be created or was rolled back
+func (c *choreographedProductController) HandleRollback(ctx context.Context, evt *pb.PurchaseStepFailed) ([]bus.Event, error) {
+	return c.rollback(ctx, evt.UserId, evt.PurchaseId), nil
+}
 
-	payload, err := json.Marshal(&reply)
+func (c *choreographedProductController) rollback(ctx context.Context, userID, purchaseID uint64) []bus.Event {
+	err := c.productService.RollbackProductInventory(ctx, purchaseID)
 	if err != nil {
-		return nil, err
+		c.logger.WithError(err).Errorf("rollback product inventory %v", purchaseID)
 	}
-	var replyMsgs []*message.Message
-	replyMsg := message.NewMessage(watermill.NewUUID(), payload)
-	replyMsg.Metadata.Set(constant.HandlerHeader, constant.RollbackProductInventoryHandler)
-	broker.SetSpanContext(ctx, replyMsg)
-	replyMsgs = append(replyMsgs, replyMsg)
-	return replyMsgs, nil
+	return rollbackResults(userID, purchaseID, domainevent.StepUpdateProductInventory, err)
 }
 
 type ProductEventRouter struct {
-	router     *message.Router
-	publisher  broker.NatsPublisher
-	subscriber broker.NatsSubscriber
-	controller *sagaProductController
+	bus                    *bus.Bus
+	choreographed          bool
+	controller             *sagaProductController
+	choreographyController *choreographedProductController
 }
 
-func NewProductEventRouter(
-	router *message.Router,
-	publisher broker.NatsPublisher,
-	subscriber broker.NatsSubscriber,
-	controller *sagaProductController) broker.EventRouter {
+func NewProductEventRouter(appCfg *libconfig.ApplicationConfig, eventBus *bus.Bus, controller *sagaProductController, choreographyController *choreographedProductController) broker.EventRouter {
 	return &ProductEventRouter{
-		router:     router,
-		publisher:  publisher,
-		subscriber: subscriber,
-		controller: controller,
+		bus:                    eventBus,
+		choreographed:          appCfg.SagaConfig.Choreographed(),
+		controller:             controller,
+		choreographyController: choreographyController,
 	}
 }
 
 func (r *ProductEventRouter) RegisterHandlers() {
+	if r.choreographed {
+		r.registerChoreography()
+		return
+	}
 
-	r.router.AddHandler(
+	bus.Handle(
+		r.bus,
 		"saga_product_update_product_inventory_handler",
 		event.UpdateProductInventoryTopic,
-		r.subscriber,
 		event.ReplyTopic,
-		r.publisher,
+		constant.UpdateProductInventoryHandler,
 		r.controller.HandleUpdateProductInventory,
 	)
 
-	r.router.AddHandler(
+	bus.Handle(
+		r.bus,
 		"saga_product_rollback_product_inventory_handler",
 		event.RollbackProductInventoryTopic,
-		r.subscriber,
 		event.ReplyTopic,
-		r.publisher,
+		constant.RollbackProductInventoryHandler,
 		r.controller.HandleRollbackProductInventory,
 	)
 }
 
+func (r *ProductEventRouter) registerChoreography() {
+	bus.React(
+		r.bus,
+		"saga_product_handle_purchase_handler",
+		event.PurchaseTopic,
+		r.choreographyController.HandlePurchase,
+	)
+
+	bus.React(
+		r.bus,
+		"saga_product_rollback_on_order_creation_failed_handler",
+		event.OrderCreationFailedTopic,
+		r.choreographyController.HandleRollback,
+	)
+
+	bus.React(
+		r.bus,
+		"saga_product_rollback_on_order_rolled_back_handler",
+		event.OrderRolledBackTopic,
+		r.choreographyController.HandleRollback,
+	)
+}
+
 func (r *ProductEventRouter) Run() error {
 	r.RegisterHandlers()
-	return r.router.Run(context.Background())
+	return r.bus.Router().Run(context.Background())
 }
 
 func (r *ProductEventRouter) GracefulShutdown() error {
-	return r.router.Close()
+	return r.bus.Router().Close()
 }
@@ -0,0 +1,27 @@
+package exchangerate
+
+import (
+	"fmt"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/repository"
+)
+
+const (
+	ProviderStatic = "static"
+)
+
+// NewExchangeRateProvider factory
+func NewExchangeRateProvider(appCfg *config.ApplicationConfig) repository.ExchangeRateProvider {
+	cfg := appCfg.CurrencyConfig.ExchangeRate
+	switch cfg.Provider {
+	case ProviderStatic, "":
+		provider, err := NewStaticExchangeRateProvider(cfg.File)
+		if err != nil {
+			panic(err)
+		}
+		return provider
+	default:
+		panic(fmt.Sprintf("unsupported exchange rate provider: %s", cfg.Provider))
+	}
+}
@@ -0,0 +1,72 @@
+package exchangerate
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/currency"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/repository"
+	"github.com/spf13/viper"
+)
+
+// StaticExchangeRateProvider serves fixed rates loaded from a file. Every
+// rate in the file is quoted against the file's base currency and cross
+// rates are derived from them.
+type StaticExchangeRateProvider struct {
+	base  string
+	rates map[string]*big.Rat
+}
+
+// NewStaticExchangeRateProvider factory
+func NewStaticExchangeRateProvider(path string) (repository.ExchangeRateProvider, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	base := currency.Normalize(v.GetString("base"))
+	if !currency.IsValid(base) {
+		return nil, fmt.Errorf("exchange rates %s: %w: %q", path, currency.ErrUnknownCurrency, base)
+	}
+
+	rates := map[string]*big.Rat{
+		base: big.NewRat(1, 1),
+	}
+	for code, value := range v.GetStringMapString("rates") {
+		code = currency.Normalize(code)
+		if !currency.IsValid(code) {
+			return nil, fmt.Errorf("exchange rates %s: %w: %q", path, currency.ErrUnknownCurrency, code)
+		}
+		rate, ok := new(big.Rat).SetString(value)
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("exchange rates %s: invalid rate %q for %s", path, value, code)
+		}
+		rates[code] = rate
+	}
+
+	return &StaticExchangeRateProvider{
+		base:  base,
+		rates: rates,
+	}, nil
+}
+
+// GetExchangeRate implements repository.ExchangeRateProvider.
+func (p *StaticExchangeRateProvider) GetExchangeRate(ctx context.Context, from, to string) (*valueobject.ExchangeRate, error) {
+	fromRate, ok := p.rates[from]
+	if !ok {
+		return nil, repository.NewErrNotFound("exchange_rate", from+"/"+to)
+	}
+	toRate, ok := p.rates[to]
+	if !ok {
+		return nil, repository.NewErrNotFound("exchange_rate", from+"/"+to)
+	}
+
+	return &valueobject.ExchangeRate{
+		From: from,
+		To:   to,
+		Rate: new(big.Rat).Quo(toRate, fromRate),
+	}, nil
+}
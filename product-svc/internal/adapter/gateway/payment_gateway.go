@@ -0,0 +1,36 @@
+package gateway
+
+import (
+	"fmt"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/bootstrap"
+	"github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/repository"
+)
+
+const (
+	ProviderSimulator = "simulator"
+)
+
+// NewPaymentGateway factory, the simulator is refused outside of development
+func NewPaymentGateway(bootCfg *bootstrap.BootstrapConfig, appCfg *config.ApplicationConfig) repository.PaymentGateway {
+	switch appCfg.PaymentGateway.Provider {
+	case ProviderSimulator, "":
+		if bootCfg.Environment != bootstrap.EnvironmentDevelopment {
+			panic(fmt.Sprintf("the payment simulator only runs in development, environment is %q", bootCfg.Environment))
+		}
+		simulator := &appCfg.PaymentGateway.Simulator
+		// a saga awaiting the payment confirmation only completes on the
+		// provider's webhook
+		var webhook *SimulatorWebhook
+		if appCfg.SagaConfig.AwaitPaymentConfirmation {
+			if simulator.Webhook.URL == "" {
+				panic("payment_gateway.simulator.webhook.url is required to await the payment confirmation")
+			}
+			webhook = NewSimulatorWebhook(&simulator.Webhook, appCfg.PaymentGateway.Webhooks[ProviderSimulator].Secret)
+		}
+		return NewSimulatorPaymentGateway(simulator, webhook)
+	default:
+		panic(fmt.Sprintf("unsupported payment gateway provider: %s", appCfg.PaymentGateway.Provider))
+	}
+}
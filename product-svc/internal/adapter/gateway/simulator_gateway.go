@@ -0,0 +1,271 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/config"
+	pkgconfig "github.com/Chengxufeng1994/go-saga-example/product-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/repository"
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/sirupsen/logrus"
+)
+
+// simulator outcomes
+const (
+	OutcomeApprove = "approve"
+	OutcomeDecline = "decline"
+	OutcomeTimeout = "timeout"
+	OutcomeHang    = "hang"
+)
+
+const defaultSimulatorTimeout = 3 * time.Second
+
+type authorizationState int
+
+const (
+	authorized authorizationState = iota
+	captured
+	voided
+)
+
+type simulatedAuthorization struct {
+	cardToken string
+	amount    int64
+	captureID string
+	captured  int64
+	refunded  int64
+	state     authorizationState
+}
+
+// SimulatorPaymentGateway is an in-memory payment gateway whose answers are
+// driven by config.PaymentSimulator rules. It is for development only: its
+// authorizations live in the process, so after a restart the captures,
+// voids and refunds of earlier payments fail with not found and their
+// webhooks never arrive, and replicas do not see each other's payments.
+type SimulatorPaymentGateway struct {
+	logger  *logrus.Entry
+	latency time.Duration
+	timeout time.Duration
+	rules   []config.PaymentSimulatorRule
+	// webhook confirms the operations when set
+	webhook *SimulatorWebhook
+
+	mu             sync.Mutex
+	authorizations map[string]*simulatedAuthorization
+	captures       map[string]string
+}
+
+// NewSimulatorPaymentGateway factory, webhook may be nil
+func NewSimulatorPaymentGateway(cfg *config.PaymentSimulator, webhook *SimulatorWebhook) repository.PaymentGateway {
+	timeout := time.Duration(cfg.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultSimulatorTimeout
+	}
+
+	return &SimulatorPaymentGateway{
+		logger:         pkgconfig.ContextLogger.WithFields(logrus.Fields{"type": "gateway:SimulatorPaymentGateway"}),
+		latency:        time.Duration(cfg.Latency) * time.Millisecond,
+		timeout:        timeout,
+		rules:          cfg.Rules,
+		webhook:        webhook,
+		authorizations: make(map[string]*simulatedAuthorization),
+		captures:       make(map[string]string),
+	}
+}
+
+// Authorize implements repository.PaymentGateway.
+func (g *SimulatorPaymentGateway) Authorize(ctx context.Context, payment *entity.Payment) (*valueobject.GatewayTransaction, error) {
+	if err := g.simulate(ctx, valueobject.GatewayAuthorize, payment.CardToken, payment.Amount); err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id := "auth_" + watermill.NewShortUUID()
+	g.authorizations[id] = &simulatedAuthorization{
+		cardToken: payment.CardToken,
+		amount:    payment.Amount,
+		state:     authorized,
+	}
+
+	return newTransaction(id, valueobject.GatewayAuthorize, payment.Amount), nil
+}
+
+// Capture implements repository.PaymentGateway.
+func (g *SimulatorPaymentGateway) Capture(ctx context.Context, authorizationID string, amount int64) (*valueobject.GatewayTransaction, error) {
+	auth, err := g.getAuthorization(authorizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.simulate(ctx, valueobject.GatewayCapture, auth.cardToken, amount); err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if auth.state != authorized {
+		return nil, fmt.Errorf("capture %s: %w", authorizationID, repository.ErrInvalidTransactionState)
+	}
+	if amount <= 0 || amount > auth.amount {
+		return nil, repository.NewErrInvalidInput("capture", "amount", amount)
+	}
+
+	id := "cap_" + watermill.NewShortUUID()
+	auth.captureID = id
+	auth.captured = amount
+	auth.state = captured
+	g.captures[id] = authorizationID
+
+	txn := newTransaction(id, valueobject.GatewayCapture, amount)
+	g.webhook.notify(authorizationID, txn)
+	return txn, nil
+}
+
+// Void implements repository.PaymentGateway.
+func (g *SimulatorPaymentGateway) Void(ctx context.Context, authorizationID string) (*valueobject.GatewayTransaction, error) {
+	auth, err := g.getAuthorization(authorizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.simulate(ctx, valueobject.GatewayVoid, auth.cardToken, auth.amount); err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if auth.state != authorized {
+		return nil, fmt.Errorf("void %s: %w", authorizationID, repository.ErrInvalidTransactionState)
+	}
+	auth.state = voided
+
+	txn := newTransaction("void_"+watermill.NewShortUUID(), valueobject.GatewayVoid, auth.amount)
+	g.webhook.notify(authorizationID, txn)
+	return txn, nil
+}
+
+// Refund implements repository.PaymentGateway.
+func (g *SimulatorPaymentGateway) Refund(ctx context.Context, captureID string, amount int64) (*valueobject.GatewayTransaction, error) {
+	g.mu.Lock()
+	authorizationID, ok := g.captures[captureID]
+	g.mu.Unlock()
+	if !ok {
+		return nil, repository.NewErrNotFound("capture", captureID)
+	}
+
+	auth, err := g.getAuthorization(authorizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.simulate(ctx, valueobject.GatewayRefund, auth.cardToken, amount); err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if amount <= 0 || auth.refunded+amount > auth.captured {
+		return nil, repository.NewErrInvalidInput("refund", "amount", amount)
+	}
+	auth.refunded += amount
+
+	txn := newTransaction("ref_"+watermill.NewShortUUID(), valueobject.GatewayRefund, amount)
+	g.webhook.notify(authorizationID, txn)
+	return txn, nil
+}
+
+func (g *SimulatorPaymentGateway) getAuthorization(authorizationID string) (*simulatedAuthorization, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	auth, ok := g.authorizations[authorizationID]
+	if !ok {
+		return nil, repository.NewErrNotFound("authorization", authorizationID)
+	}
+	return auth, nil
+}
+
+// simulate applies the configured latency and the outcome of the first
+// matching rule.
+func (g *SimulatorPaymentGateway) simulate(ctx context.Context, op valueobject.GatewayOperation, cardToken string, amount int64) error {
+	if err := sleep(ctx, g.latency); err != nil {
+		return err
+	}
+
+	outcome := g.match(op, cardToken, amount)
+	if outcome != OutcomeApprove {
+		g.logger.WithFields(logrus.Fields{
+			"operation": op,
+			"amount":    amount,
+			"outcome":   outcome,
+		}).Debug("simulated gateway failure")
+	}
+
+	switch outcome {
+	case OutcomeDecline:
+		return repository.ErrPaymentDeclined
+	case OutcomeTimeout:
+		if err := sleep(ctx, g.timeout); err != nil {
+			return err
+		}
+		return repository.ErrGatewayTimeout
+	case OutcomeHang:
+		<-ctx.Done()
+		return ctx.Err()
+	default:
+		return nil
+	}
+}
+
+func (g *SimulatorPaymentGateway) match(op valueobject.GatewayOperation, cardToken string, amount int64) string {
+	for _, rule := range g.rules {
+		if rule.Operation != "" && rule.Operation != string(op) {
+			continue
+		}
+		if rule.CardToken != "" && rule.CardToken != cardToken {
+			continue
+		}
+		if rule.MinAmount > 0 && amount < rule.MinAmount {
+			continue
+		}
+		if rule.MaxAmount > 0 && amount > rule.MaxAmount {
+			continue
+		}
+		return rule.Outcome
+	}
+	return OutcomeApprove
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func newTransaction(id string, op valueobject.GatewayOperation, amount int64) *valueobject.GatewayTransaction {
+	return &valueobject.GatewayTransaction{
+		ID:          id,
+		Operation:   op,
+		Amount:      amount,
+		ProcessedAt: time.Now(),
+	}
+}
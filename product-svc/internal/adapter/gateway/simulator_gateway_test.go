@@ -0,0 +1,238 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/bootstrap"
+	"github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/webhook"
+	pkgconfig "github.com/Chengxufeng1994/go-saga-example/product-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	logger := logrus.New()
+	logger.Out = io.Discard
+	pkgconfig.ContextLogger = logrus.NewEntry(logger)
+	m.Run()
+}
+
+func newTestSimulator(cfg config.PaymentSimulator) *SimulatorPaymentGateway {
+	return NewSimulatorPaymentGateway(&cfg, nil).(*SimulatorPaymentGateway)
+}
+
+func TestNewPaymentGatewayEnvironment(t *testing.T) {
+	tests := []struct {
+		name        string
+		environment string
+		wantPanic   bool
+	}{
+		{"development", bootstrap.EnvironmentDevelopment, false},
+		{"production", "production", true},
+		{"no environment", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); (r != nil) != tt.wantPanic {
+					t.Errorf("NewPaymentGateway() panic = %v, want panic %v", r, tt.wantPanic)
+				}
+			}()
+			NewPaymentGateway(&bootstrap.BootstrapConfig{Environment: tt.environment}, &config.ApplicationConfig{
+				PaymentGateway: config.PaymentGateway{Provider: ProviderSimulator},
+			})
+		})
+	}
+}
+
+func TestSimulatorMatch(t *testing.T) {
+	rules := []config.PaymentSimulatorRule{
+		{CardToken: "tok_decline", Outcome: OutcomeDecline},
+		{Operation: "capture", MinAmount: 1000, Outcome: OutcomeTimeout},
+		{Operation: "authorize", MinAmount: 500, MaxAmount: 900, Outcome: OutcomeHang},
+	}
+	g := newTestSimulator(config.PaymentSimulator{Rules: rules})
+
+	tests := []struct {
+		name      string
+		op        valueobject.GatewayOperation
+		cardToken string
+		amount    int64
+		want      string
+	}{
+		{"no rule matches", valueobject.GatewayAuthorize, "tok_visa", 100, OutcomeApprove},
+		{"card token on any operation", valueobject.GatewayRefund, "tok_decline", 100, OutcomeDecline},
+		{"first match wins", valueobject.GatewayCapture, "tok_decline", 5000, OutcomeDecline},
+		{"operation and min amount", valueobject.GatewayCapture, "tok_visa", 1000, OutcomeTimeout},
+		{"below min amount", valueobject.GatewayCapture, "tok_visa", 999, OutcomeApprove},
+		{"other operation", valueobject.GatewayVoid, "tok_visa", 1000, OutcomeApprove},
+		{"within amount range", valueobject.GatewayAuthorize, "tok_visa", 900, OutcomeHang},
+		{"above max amount", valueobject.GatewayAuthorize, "tok_visa", 901, OutcomeApprove},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := g.match(tt.op, tt.cardToken, tt.amount); got != tt.want {
+				t.Errorf("match() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimulatorOutcomes(t *testing.T) {
+	tests := []struct {
+		name    string
+		outcome string
+		wantErr error
+	}{
+		{"approve", OutcomeApprove, nil},
+		{"decline", OutcomeDecline, repository.ErrPaymentDeclined},
+		{"timeout", OutcomeTimeout, repository.ErrGatewayTimeout},
+		{"hang until the caller gives up", OutcomeHang, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestSimulator(config.PaymentSimulator{
+				Timeout: 1,
+				Rules:   []config.PaymentSimulatorRule{{Outcome: tt.outcome}},
+			})
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			txn, err := g.Authorize(ctx, &entity.Payment{CardToken: "tok_visa", Amount: 100})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authorize() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (txn == nil || txn.Amount != 100 || txn.Operation != valueobject.GatewayAuthorize) {
+				t.Fatalf("Authorize() = %+v", txn)
+			}
+		})
+	}
+}
+
+func TestSimulatorTransactionStates(t *testing.T) {
+	ctx := context.Background()
+	g := newTestSimulator(config.PaymentSimulator{})
+
+	auth, err := g.Authorize(ctx, &entity.Payment{CardToken: "tok_visa", Amount: 1000})
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+
+	var invalidInput *repository.ErrInvalidInput
+	if _, err := g.Capture(ctx, auth.ID, 1001); !errors.As(err, &invalidInput) {
+		t.Fatalf("Capture() above the authorized amount error = %v, want invalid input", err)
+	}
+	capture, err := g.Capture(ctx, auth.ID, 800)
+	if err != nil {
+		t.Fatalf("Capture() error = %v", err)
+	}
+	if _, err := g.Capture(ctx, auth.ID, 800); !errors.Is(err, repository.ErrInvalidTransactionState) {
+		t.Fatalf("second Capture() error = %v, want %v", err, repository.ErrInvalidTransactionState)
+	}
+	if _, err := g.Void(ctx, auth.ID); !errors.Is(err, repository.ErrInvalidTransactionState) {
+		t.Fatalf("Void() of a captured authorization error = %v, want %v", err, repository.ErrInvalidTransactionState)
+	}
+
+	refunds := []struct {
+		amount  int64
+		wantErr bool
+	}{
+		{300, false},
+		{500, false},
+		{1, true},
+		{0, true},
+	}
+	for _, r := range refunds {
+		_, err := g.Refund(ctx, capture.ID, r.amount)
+		if (err != nil) != r.wantErr {
+			t.Fatalf("Refund(%d) error = %v, wantErr %v", r.amount, err, r.wantErr)
+		}
+	}
+
+	var notFound *repository.ErrNotFound
+	if _, err := g.Refund(ctx, "cap_unknown", 100); !errors.As(err, &notFound) {
+		t.Fatalf("Refund() of an unknown capture error = %v, want not found", err)
+	}
+	if _, err := g.Capture(ctx, "auth_unknown", 100); !errors.As(err, &notFound) {
+		t.Fatalf("Capture() of an unknown authorization error = %v, want not found", err)
+	}
+}
+
+func TestSimulatorVoid(t *testing.T) {
+	ctx := context.Background()
+	g := newTestSimulator(config.PaymentSimulator{})
+
+	auth, err := g.Authorize(ctx, &entity.Payment{CardToken: "tok_visa", Amount: 1000})
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	void, err := g.Void(ctx, auth.ID)
+	if err != nil {
+		t.Fatalf("Void() error = %v", err)
+	}
+	if void.Amount != 1000 {
+		t.Errorf("Void() amount = %d, want 1000", void.Amount)
+	}
+	if _, err := g.Capture(ctx, auth.ID, 1000); !errors.Is(err, repository.ErrInvalidTransactionState) {
+		t.Fatalf("Capture() of a voided authorization error = %v, want %v", err, repository.ErrInvalidTransactionState)
+	}
+}
+
+func TestSimulatorWebhook(t *testing.T) {
+	const secret = "webhook-secret"
+	received := make(chan simulatorWebhookEvent, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, _ := io.ReadAll(r.Body)
+		err := webhook.Verify(secret, r.Header.Get(webhook.SignatureHeader), r.Header.Get(webhook.TimestampHeader), payload, time.Now(), 0)
+		if err != nil {
+			t.Errorf("Verify() error = %v", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var evt simulatorWebhookEvent
+		if err := json.Unmarshal(payload, &evt); err != nil {
+			t.Errorf("Unmarshal() error = %v", err)
+		}
+		received <- evt
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	hook := NewSimulatorWebhook(&config.PaymentSimulatorWebhook{URL: srv.URL, Delay: 1}, secret)
+	g := NewSimulatorPaymentGateway(&config.PaymentSimulator{}, hook)
+
+	auth, err := g.Authorize(ctx, &entity.Payment{CardToken: "tok_visa", Amount: 1000})
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	capture, err := g.Capture(ctx, auth.ID, 1000)
+	if err != nil {
+		t.Fatalf("Capture() error = %v", err)
+	}
+
+	select {
+	case evt := <-received:
+		want := simulatorWebhookEvent{
+			ID:              evt.ID,
+			Type:            valueobject.WebhookPaymentCaptured,
+			AuthorizationID: auth.ID,
+			TransactionID:   capture.ID,
+			Amount:          1000,
+		}
+		if evt != want || evt.ID == "" {
+			t.Errorf("webhook = %+v, want %+v", evt, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no webhook received")
+	}
+}
@@ -0,0 +1,127 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/webhook"
+	pkgconfig "github.com/Chengxufeng1994/go-saga-example/product-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWebhookDelay = 500 * time.Millisecond
+	webhookAttempts     = 5
+	webhookTimeout      = 5 * time.Second
+)
+
+// simulatorWebhookEvent is the body of the webhooks the simulator posts
+type simulatorWebhookEvent struct {
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	AuthorizationID string `json:"authorization_id"`
+	TransactionID   string `json:"transaction_id"`
+	Amount          int64  `json:"amount"`
+}
+
+// SimulatorWebhook confirms the operations of the simulator through signed
+// webhooks, the way a provider confirms them asynchronously
+type SimulatorWebhook struct {
+	logger *logrus.Entry
+	url    string
+	secret string
+	delay  time.Duration
+	client *http.Client
+}
+
+// NewSimulatorWebhook factory
+func NewSimulatorWebhook(cfg *config.PaymentSimulatorWebhook, secret string) *SimulatorWebhook {
+	delay := time.Duration(cfg.Delay) * time.Millisecond
+	if delay <= 0 {
+		delay = defaultWebhookDelay
+	}
+
+	return &SimulatorWebhook{
+		logger: pkgconfig.ContextLogger.WithFields(logrus.Fields{"type": "gateway:SimulatorWebhook"}),
+		url:    cfg.URL,
+		secret: secret,
+		delay:  delay,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// notify posts the webhook of a successful operation once the delay passed,
+// retrying until the payment service accepts it
+func (w *SimulatorWebhook) notify(authorizationID string, txn *valueobject.GatewayTransaction) {
+	if w == nil {
+		return
+	}
+
+	var eventType string
+	switch txn.Operation {
+	case valueobject.GatewayCapture:
+		eventType = valueobject.WebhookPaymentCaptured
+	case valueobject.GatewayVoid:
+		eventType = valueobject.WebhookPaymentVoided
+	case valueobject.GatewayRefund:
+		eventType = valueobject.WebhookPaymentRefunded
+	default:
+		return
+	}
+	payload, err := json.Marshal(&simulatorWebhookEvent{
+		ID:              "evt_" + watermill.NewShortUUID(),
+		Type:            eventType,
+		AuthorizationID: authorizationID,
+		TransactionID:   txn.ID,
+		Amount:          txn.Amount,
+	})
+	if err != nil {
+		w.logger.WithError(err).Error("encode webhook")
+		return
+	}
+
+	go func() {
+		backoff := w.delay
+		for attempt := 1; attempt <= webhookAttempts; attempt++ {
+			time.Sleep(backoff)
+			err := w.post(payload)
+			if err == nil {
+				return
+			}
+			w.logger.WithError(err).Warnf("post %s webhook of %s, attempt %d", eventType, authorizationID, attempt)
+			backoff *= 2
+		}
+	}()
+}
+
+func (w *SimulatorWebhook) post(payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(w.secret, now, payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook status %d", resp.StatusCode)
+	}
+	return nil
+}
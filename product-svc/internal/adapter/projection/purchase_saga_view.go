@@ -0,0 +1,82 @@
+package projection
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/eventstore"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/db/model"
+	adapterrepository "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/adapter/repository"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	domainevent "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
+	"gorm.io/gorm"
+)
+
+// PurchaseSagaView projects the purchase saga streams to the sagas table,
+// one row per saga, which operators list the sagas from
+type PurchaseSagaView struct{}
+
+func NewPurchaseSagaView() *PurchaseSagaView {
+	return &PurchaseSagaView{}
+}
+
+// Name implements eventstore.Projection.
+func (p *PurchaseSagaView) Name() string {
+	return "saga_view"
+}
+
+// Handle implements eventstore.Projection.
+func (p *PurchaseSagaView) Handle(ctx context.Context, tx *gorm.DB, evt *eventstore.Event) error {
+	if evt.StreamType != adapterrepository.PurchaseSagaStreamType {
+		return nil
+	}
+	sagaEvent, err := adapterrepository.DecodePurchaseSagaEvent(evt)
+	if err != nil {
+		return err
+	}
+
+	purchaseID := sagaPurchaseID(sagaEvent)
+
+	var row model.Saga
+	err = tx.WithContext(ctx).Where("purchase_id = ?", purchaseID).First(&row).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if row.Version >= evt.Version {
+		return nil
+	}
+
+	saga := entity.NewPurchaseSaga(purchaseID)
+	saga.UserID = row.UserID
+	saga.CorrelationID = row.CorrelationID
+	saga.Status = entity.SagaStatus(row.Status)
+	saga.Step = row.Step
+	saga.CreatedAt = row.CreatedAt
+	saga.UpdatedAt = row.UpdatedAt
+	saga.Apply(sagaEvent)
+
+	return tx.WithContext(ctx).Save(&model.Saga{
+		PurchaseID:    saga.PurchaseID,
+		UserID:        saga.UserID,
+		CorrelationID: saga.CorrelationID,
+		Status:        string(saga.Status),
+		Step:          saga.Step,
+		Version:       evt.Version,
+		CreatedAt:     saga.CreatedAt,
+		UpdatedAt:     saga.UpdatedAt,
+	}).Error
+}
+
+// sagaPurchaseID returns the purchase of an event of a purchase saga stream
+func sagaPurchaseID(evt any) uint64 {
+	switch evt := evt.(type) {
+	case *domainevent.PurchaseSagaStartedEvent:
+		return evt.PurchaseID
+	case *domainevent.PurchaseResultEvent:
+		return evt.PurchaseID
+	case *domainevent.PurchaseSagaStatusChangedEvent:
+		return evt.PurchaseID
+	default:
+		return 0
+	}
+}
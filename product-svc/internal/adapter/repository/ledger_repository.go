@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/db/model"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/repository"
+	"gorm.io/gorm"
+)
+
+type GormLedgerRepository struct {
+	db *gorm.DB
+}
+
+func NewGormLedgerRepository(db *gorm.DB) repository.LedgerRepository {
+	return &GormLedgerRepository{
+		db: db,
+	}
+}
+
+// ListJournalEntries implements repository.LedgerRepository.
+func (repo *GormLedgerRepository) ListJournalEntries(ctx context.Context, purchaseID uint64) ([]entity.JournalEntry, error) {
+	var entries []model.JournalEntry
+	if err := repo.db.WithContext(ctx).Model(&model.JournalEntry{}).Where("purchase_id = ?", purchaseID).Order("id").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	var postings []model.LedgerPosting
+	if err := repo.db.WithContext(ctx).Model(&model.LedgerPosting{}).Where("purchase_id = ?", purchaseID).Order("id").Find(&postings).Error; err != nil {
+		return nil, err
+	}
+
+	postingsByEntry := make(map[uint64][]valueobject.Posting)
+	for _, posting := range postings {
+		postingsByEntry[posting.JournalEntryID] = append(postingsByEntry[posting.JournalEntryID], valueobject.Posting{
+			Account:      valueobject.LedgerAccount(posting.Account),
+			CurrencyCode: posting.CurrencyCode,
+			Amount:       posting.Amount,
+		})
+	}
+
+	entities := make([]entity.JournalEntry, 0, len(entries))
+	for _, entry := range entries {
+		entities = append(entities, entity.JournalEntry{
+			ID:            entry.ID,
+			PurchaseID:    entry.PurchaseID,
+			Operation:     valueobject.GatewayOperation(entry.Operation),
+			TransactionID: entry.TransactionID,
+			Postings:      postingsByEntry[entry.ID],
+			CreatedAt:     entry.CreatedAt,
+		})
+	}
+	return entities, nil
+}
+
+// GetAccountBalances implements repository.LedgerRepository.
+func (repo *GormLedgerRepository) GetAccountBalances(ctx context.Context, purchaseID uint64) ([]valueobject.AccountBalance, error) {
+	var rows []struct {
+		Account      string
+		CurrencyCode string
+		Balance      int64
+	}
+	if err := repo.db.WithContext(ctx).Model(&model.LedgerPosting{}).
+		Select("account", "currency_code", "SUM(amount) AS balance").
+		Where("purchase_id = ?", purchaseID).
+		Group("account, currency_code").Order("account").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	balances := make([]valueobject.AccountBalance, 0, len(rows))
+	for _, row := range rows {
+		balances = append(balances, valueobject.AccountBalance{
+			Account:      valueobject.LedgerAccount(row.Account),
+			CurrencyCode: row.CurrencyCode,
+			Balance:      row.Balance,
+		})
+	}
+	return balances, nil
+}
+
+// postJournalEntry appends a balanced journal entry within the given transaction
+func postJournalEntry(tx *gorm.DB, entry *entity.JournalEntry) error {
+	if !entry.IsBalanced() {
+		return repository.ErrUnbalancedJournalEntry
+	}
+
+	row := model.JournalEntry{
+		PurchaseID:    entry.PurchaseID,
+		Operation:     string(entry.Operation),
+		TransactionID: entry.TransactionID,
+	}
+	if err := tx.Create(&row).Error; err != nil {
+		return err
+	}
+
+	postings := make([]model.LedgerPosting, 0, len(entry.Postings))
+	for _, posting := range entry.Postings {
+		postings = append(postings, model.LedgerPosting{
+			JournalEntryID: row.ID,
+			PurchaseID:     entry.PurchaseID,
+			Account:        string(posting.Account),
+			CurrencyCode:   posting.CurrencyCode,
+			Amount:         posting.Amount,
+		})
+	}
+	return tx.Create(&postings).Error
+}
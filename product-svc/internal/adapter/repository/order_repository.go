@@ -115,3 +115,8 @@ func (g *GormOrderRepository) DeleteOrder(ctx context.Context, orderID uint64) e
 	}
 	return nil
 }
+
+// AnonymizeOrders implements repository.OrderRepository.
+func (g *GormOrderRepository) AnonymizeOrders(ctx context.Context, userID uint64) error {
+	return g.db.WithContext(ctx).Model(&model.Order{}).Where("user_id = ?", userID).Update("user_id", 0).Error
+}
@@ -2,14 +2,17 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"strconv"
 
 	libcommon "github.com/Chengxufeng1994/go-saga-example/common/model"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/db/model"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/repository"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // PaymentRepositoryImpl implementation
@@ -24,42 +27,231 @@ func NewGormPaymentRepository(db *gorm.DB) repository.PaymentRepository {
 	}
 }
 
+var paymentColumns = []string{"id", "user_id", "currency_code", "amount", "original_currency_code", "original_amount", "exchange_rate", "status", "authorization_id", "capture_id", "correlation_id", "refunded_amount"}
+
 // GetPayment get an payment
 func (repo *GormPaymentRepository) GetPayment(ctx context.Context, paymentID uint64) (*entity.Payment, error) {
 	var payment model.Payment
-	if err := repo.db.Model(&model.Payment{}).Select("id", "customer_id", "currency_code", "amount").Where("id = ?", paymentID).First(&payment).WithContext(ctx).Error; err != nil {
+	if err := repo.db.WithContext(ctx).Model(&model.Payment{}).Select(paymentColumns).Where("id = ?", paymentID).First(&payment).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, repository.NewErrNotFound("payment", strconv.Itoa(int(paymentID)))
 		}
 		return nil, err
 	}
+	return toPaymentEntity(&payment), nil
+}
+
+// GetPaymentByAuthorizationID get an payment by its gateway authorization
+func (repo *GormPaymentRepository) GetPaymentByAuthorizationID(ctx context.Context, authorizationID string) (*entity.Payment, error) {
+	var payment model.Payment
+	if err := repo.db.WithContext(ctx).Model(&model.Payment{}).Select(paymentColumns).Where("authorization_id = ?", authorizationID).First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, repository.NewErrNotFound("payment", authorizationID)
+		}
+		return nil, err
+	}
+	return toPaymentEntity(&payment), nil
+}
+
+func toPaymentEntity(payment *model.Payment) *entity.Payment {
 	return &entity.Payment{
-		ID:           payment.ID,
-		UserID:       payment.UserID,
-		CurrencyCode: payment.CurrencyCode,
-		Amount:       payment.Amount,
-	}, nil
+		ID:                   payment.ID,
+		UserID:               payment.UserID,
+		CurrencyCode:         payment.CurrencyCode,
+		Amount:               payment.Amount,
+		OriginalCurrencyCode: payment.OriginalCurrencyCode,
+		OriginalAmount:       payment.OriginalAmount,
+		ExchangeRate:         payment.ExchangeRate,
+		Status:               valueobject.PaymentStatus(payment.Status),
+		AuthorizationID:      payment.AuthorizationID,
+		CaptureID:            payment.CaptureID,
+		CorrelationID:        payment.CorrelationID,
+		RefundedAmount:       payment.RefundedAmount,
+	}
 }
 
-// CreatePayment creates a payment
-func (repo *GormPaymentRepository) CreatePayment(ctx context.Context, payment *entity.Payment) error {
-	if err := repo.db.WithContext(ctx).Create(&model.Payment{
+// CreatePayment creates a payment together with its first status history entry
+func (repo *GormPaymentRepository) CreatePayment(ctx context.Context, payment *entity.Payment, entry *entity.JournalEntry) error {
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	if err := tx.Create(&model.Payment{
 		BaseModel: libcommon.BaseModel{
 			ID: payment.ID,
 		},
-		UserID:       payment.UserID,
-		CurrencyCode: payment.CurrencyCode,
-		Amount:       payment.Amount,
+		UserID:               payment.UserID,
+		CurrencyCode:         payment.CurrencyCode,
+		Amount:               payment.Amount,
+		OriginalCurrencyCode: payment.OriginalCurrencyCode,
+		OriginalAmount:       payment.OriginalAmount,
+		ExchangeRate:         payment.ExchangeRate,
+		Status:               string(payment.Status),
+		AuthorizationID:      payment.AuthorizationID,
+		CaptureID:            payment.CaptureID,
+		CorrelationID:        payment.CorrelationID,
+	}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Create(&model.PaymentStatusHistory{
+		PaymentID:     payment.ID,
+		Status:        string(payment.Status),
+		TransactionID: payment.AuthorizationID,
 	}).Error; err != nil {
+		tx.Rollback()
 		return err
 	}
-	return nil
+
+	if err := postJournalEntry(tx, entry); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// UpdatePaymentStatus moves a payment from one status to another and appends
+// the change to its status history. It fails with
+// repository.ErrInvalidTransactionState when the payment is not in the
+// expected status anymore.
+func (repo *GormPaymentRepository) UpdatePaymentStatus(ctx context.Context, paymentID uint64, transition *entity.PaymentTransition) error {
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return err
+	}
+
+	if err := transitPayment(tx, paymentID, transition); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
 }
 
-// DeletePayment deletes an payment
-func (repo *GormPaymentRepository) DeletePayment(ctx context.Context, paymentID uint64) error {
-	if err := repo.db.Exec("DELETE FROM payments where id = ?", paymentID).Error; err != nil {
+// transitPayment applies a payment transition within tx. A transition whose
+// gateway transaction is already recorded, by the reply of the gateway or by
+// the provider's webhook, is not applied again.
+func transitPayment(tx *gorm.DB, paymentID uint64, transition *entity.PaymentTransition) error {
+	transaction := transition.Transaction
+	if transaction.ID != "" {
+		var count int64
+		if err := tx.Model(&model.PaymentStatusHistory{}).Where("payment_id = ? AND transaction_id = ?", paymentID, transaction.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+	}
+
+	updates := map[string]any{
+		"status": string(transition.To),
+	}
+	query := tx.Model(&model.Payment{}).Where("id = ? AND status = ?", paymentID, string(transition.From))
+	switch transaction.Operation {
+	case valueobject.GatewayCapture:
+		if transaction.ID != "" {
+			updates["capture_id"] = transaction.ID
+		}
+	case valueobject.GatewayRefund:
+		// refunds of the same payment racing each other conflict
+		updates["refunded_amount"] = transition.RefundedAmount + transaction.Amount
+		query = query.Where("refunded_amount = ?", transition.RefundedAmount)
+	}
+
+	result := query.Updates(updates)
+	if err := result.Error; err != nil {
 		return err
 	}
-	return nil
+	if result.RowsAffected == 0 {
+		return repository.ErrInvalidTransactionState
+	}
+
+	if err := tx.Create(&model.PaymentStatusHistory{
+		PaymentID:     paymentID,
+		Status:        string(transition.To),
+		TransactionID: transaction.ID,
+	}).Error; err != nil {
+		return err
+	}
+
+	return postJournalEntry(tx, transition.Entry)
+}
+
+// ListPaymentStatusHistory lists the status changes of a payment, oldest first
+func (repo *GormPaymentRepository) ListPaymentStatusHistory(ctx context.Context, paymentID uint64) ([]valueobject.PaymentStatusChange, error) {
+	var rows []model.PaymentStatusHistory
+	if err := repo.db.WithContext(ctx).Model(&model.PaymentStatusHistory{}).Where("payment_id = ?", paymentID).Order("id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	history := make([]valueobject.PaymentStatusChange, 0, len(rows))
+	for _, row := range rows {
+		history = append(history, valueobject.PaymentStatusChange{
+			Status:        valueobject.PaymentStatus(row.Status),
+			TransactionID: row.TransactionID,
+			CreatedAt:     row.CreatedAt,
+		})
+	}
+	return history, nil
+}
+
+// SaveWebhookEvent records a provider event before applying its transition,
+// so of concurrent deliveries of one event, or of the events of one gateway
+// transaction, only the first applies it
+func (repo *GormPaymentRepository) SaveWebhookEvent(ctx context.Context, paymentID uint64, evt *valueobject.WebhookEvent, transition *entity.PaymentTransition) (bool, error) {
+	tx := repo.db.WithContext(ctx).Begin(&sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Error; err != nil {
+		return false, err
+	}
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.PaymentWebhookEvent{
+		Provider:      evt.Provider,
+		EventID:       evt.EventID,
+		TransactionID: evt.TransactionID,
+		Type:          evt.Type,
+		PaymentID:     paymentID,
+	})
+	if err := result.Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if result.RowsAffected != 1 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	if transition != nil {
+		if err := transitPayment(tx, paymentID, transition); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	return true, tx.Commit().Error
+}
+
+// AnonymizePayments implements repository.PaymentRepository.
+func (repo *GormPaymentRepository) AnonymizePayments(ctx context.Context, userID uint64) error {
+	return repo.db.WithContext(ctx).Model(&model.Payment{}).Where("user_id = ?", userID).Update("user_id", 0).Error
 }
@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/eventstore"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	domainevent "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/repository"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	PurchaseSagaStreamType = "purchase_saga"
+
+	// event types of the purchase saga stream
+	PurchaseSagaStartedType       = "PurchaseSagaStarted"
+	PurchaseResultType            = "PurchaseResult"
+	PurchaseSagaStatusChangedType = "PurchaseSagaStatusChanged"
+)
+
+// purchaseSagaSnapshot is the snapshot data of a purchase saga stream
+type purchaseSagaSnapshot struct {
+	PurchaseID    uint64            `json:"purchase_id"`
+	UserID        uint64            `json:"user_id"`
+	CorrelationID string            `json:"correlation_id"`
+	Status        string            `json:"status"`
+	Step          string            `json:"step"`
+	Steps         map[string]string `json:"steps"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+type EventSourcedPurchaseSagaRepository struct {
+	store            eventstore.Store
+	snapshotInterval int64
+}
+
+func NewEventSourcedPurchaseSagaRepository(appCfg *libconfig.ApplicationConfig, store *eventstore.PostgresStore) repository.PurchaseSagaRepository {
+	return &EventSourcedPurchaseSagaRepository{
+		store:            store,
+		snapshotInterval: int64(appCfg.SagaConfig.EventStore.SnapshotInterval),
+	}
+}
+
+// PurchaseSagaStreamID returns the stream of the purchase saga
+func PurchaseSagaStreamID(purchaseID uint64) string {
+	return fmt.Sprintf("%s-%d", PurchaseSagaStreamType, purchaseID)
+}
+
+// Load implements repository.PurchaseSagaRepository.
+func (r *EventSourcedPurchaseSagaRepository) Load(ctx context.Context, purchaseID uint64) (*entity.PurchaseSaga, error) {
+	streamID := PurchaseSagaStreamID(purchaseID)
+	saga := entity.NewPurchaseSaga(purchaseID)
+
+	snapshot, err := r.store.LoadSnapshot(ctx, streamID)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot != nil {
+		var state purchaseSagaSnapshot
+		if err := json.Unmarshal(snapshot.Data, &state); err != nil {
+			return nil, err
+		}
+		saga.UserID = state.UserID
+		saga.CorrelationID = state.CorrelationID
+		saga.Status = entity.SagaStatus(state.Status)
+		saga.Step = state.Step
+		saga.Steps = state.Steps
+		saga.CreatedAt = state.CreatedAt
+		saga.UpdatedAt = state.UpdatedAt
+		saga.Version = snapshot.Version
+	}
+
+	events, err := r.store.Load(ctx, streamID, saga.Version)
+	if err != nil {
+		return nil, err
+	}
+	for _, evt := range events {
+		sagaEvent, err := DecodePurchaseSagaEvent(evt)
+		if err != nil {
+			return nil, err
+		}
+		saga.Apply(sagaEvent)
+	}
+	return saga, nil
+}
+
+// Save implements repository.PurchaseSagaRepository.
+func (r *EventSourcedPurchaseSagaRepository) Save(ctx context.Context, saga *entity.PurchaseSaga) error {
+	changes := saga.Changes()
+	if len(changes) == 0 {
+		return nil
+	}
+
+	var metadata map[string]string
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		metadata = map[string]string{"trace_id": sc.TraceID().String()}
+	}
+	events := make([]*eventstore.Event, 0, len(changes))
+	for _, change := range changes {
+		eventType, err := purchaseSagaEventType(change)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(change)
+		if err != nil {
+			return err
+		}
+		events = append(events, &eventstore.Event{
+			StreamType: PurchaseSagaStreamType,
+			Type:       eventType,
+			Data:       data,
+			Metadata:   metadata,
+		})
+	}
+
+	streamID := PurchaseSagaStreamID(saga.PurchaseID)
+	expectedVersion := saga.Version - int64(len(changes))
+	if err := r.store.Append(ctx, streamID, expectedVersion, events...); err != nil {
+		return err
+	}
+	saga.ClearChanges()
+
+	// snapshot once the saga crossed an interval, a lost snapshot only
+	// makes the next load fold more results
+	if r.snapshotInterval > 0 && saga.Version/r.snapshotInterval > expectedVersion/r.snapshotInterval {
+		data, err := json.Marshal(&purchaseSagaSnapshot{
+			PurchaseID:    saga.PurchaseID,
+			UserID:        saga.UserID,
+			CorrelationID: saga.CorrelationID,
+			Status:        string(saga.Status),
+			Step:          saga.Step,
+			Steps:         saga.Steps,
+			CreatedAt:     saga.CreatedAt,
+			UpdatedAt:     saga.UpdatedAt,
+		})
+		if err == nil {
+			_ = r.store.SaveSnapshot(ctx, &eventstore.Snapshot{
+				StreamID: streamID,
+				Version:  saga.Version,
+				Data:     data,
+			})
+		}
+	}
+	return nil
+}
+
+func purchaseSagaEventType(evt any) (string, error) {
+	switch evt.(type) {
+	case *domainevent.PurchaseSagaStartedEvent:
+		return PurchaseSagaStartedType, nil
+	case *domainevent.PurchaseResultEvent:
+		return PurchaseResultType, nil
+	case *domainevent.PurchaseSagaStatusChangedEvent:
+		return PurchaseSagaStatusChangedType, nil
+	default:
+		return "", fmt.Errorf("unknown purchase saga event: %T", evt)
+	}
+}
+
+// DecodePurchaseSagaEvent decodes an event of a purchase saga stream
+func DecodePurchaseSagaEvent(evt *eventstore.Event) (any, error) {
+	var sagaEvent any
+	switch evt.Type {
+	case PurchaseSagaStartedType:
+		sagaEvent = &domainevent.PurchaseSagaStartedEvent{}
+	case PurchaseResultType:
+		sagaEvent = &domainevent.PurchaseResultEvent{}
+	case PurchaseSagaStatusChangedType:
+		sagaEvent = &domainevent.PurchaseSagaStatusChangedEvent{}
+	default:
+		return nil, fmt.Errorf("unknown purchase saga event type: %s", evt.Type)
+	}
+	if err := json.Unmarshal(evt.Data, sagaEvent); err != nil {
+		return nil, err
+	}
+	return sagaEvent, nil
+}
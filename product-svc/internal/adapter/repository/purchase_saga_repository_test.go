@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/eventstore"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	domainevent "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/repository"
+)
+
+func TestEventSourcedPurchaseSagaRepository(t *testing.T) {
+	tests := []struct {
+		name             string
+		snapshotInterval int64
+		wantSnapshot     int64
+	}{
+		{"without snapshots", 0, 0},
+		{"snapshot every event", 1, 4},
+		{"snapshot every 3 events", 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := eventstore.NewMemoryStore()
+			r := &EventSourcedPurchaseSagaRepository{store: store, snapshotInterval: tt.snapshotInterval}
+
+			// the saga is saved after every change, like the orchestrator does
+			changes := []func(*entity.PurchaseSaga){
+				func(s *entity.PurchaseSaga) { s.Start(7, "correlation") },
+				func(s *entity.PurchaseSaga) {
+					s.Record(domainevent.NewPurchaseResultEvent(7, 1, domainevent.StepCreateOrder, domainevent.StatusExecute))
+				},
+				func(s *entity.PurchaseSaga) {
+					s.Record(domainevent.NewPurchaseResultEvent(7, 1, domainevent.StepCreateOrder, domainevent.StatusFailed))
+				},
+				func(s *entity.PurchaseSaga) { s.ChangeStatus("", entity.SagaCompensated) },
+			}
+			for _, change := range changes {
+				saga, err := r.Load(ctx, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				change(saga)
+				if err := r.Save(ctx, saga); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			saga, err := r.Load(ctx, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saga.Version != 4 || saga.UserID != 7 || saga.CorrelationID != "correlation" ||
+				saga.Status != entity.SagaCompensated || saga.Step != domainevent.StepCreateOrder ||
+				saga.Steps[domainevent.StepCreateOrder] != domainevent.StatusFailed || saga.CreatedAt.IsZero() {
+				t.Errorf("Load() = %+v", saga)
+			}
+			var snapshotVersion int64
+			if snapshot, _ := store.LoadSnapshot(ctx, PurchaseSagaStreamID(1)); snapshot != nil {
+				snapshotVersion = snapshot.Version
+			}
+			if snapshotVersion != tt.wantSnapshot {
+				t.Errorf("snapshot version = %d, want %d", snapshotVersion, tt.wantSnapshot)
+			}
+		})
+	}
+}
+
+func TestEventSourcedPurchaseSagaRepositoryConflict(t *testing.T) {
+	ctx := context.Background()
+	r := &EventSourcedPurchaseSagaRepository{store: eventstore.NewMemoryStore()}
+
+	first, _ := r.Load(ctx, 1)
+	second, _ := r.Load(ctx, 1)
+	first.Start(1, "first")
+	second.Start(2, "second")
+	if err := r.Save(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Save(ctx, second); !errors.Is(err, repository.ErrConcurrencyConflict) {
+		t.Fatalf("Save() error = %v, want %v", err, repository.ErrConcurrencyConflict)
+	}
+
+	saga, _ := r.Load(ctx, 1)
+	if saga.CorrelationID != "first" || saga.Version != 1 {
+		t.Errorf("Load() = %+v, want the first saga", saga)
+	}
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/db/model"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/repository"
+	"gorm.io/gorm"
+)
+
+type GormSagaRepository struct {
+	db *gorm.DB
+}
+
+func NewGormSagaRepository(db *gorm.DB) repository.SagaRepository {
+	return &GormSagaRepository{
+		db: db,
+	}
+}
+
+// ListSagas implements repository.SagaRepository. The sagas are listed
+// from their projection, which lags behind their streams.
+func (g *GormSagaRepository) ListSagas(ctx context.Context, status entity.SagaStatus, limit int) ([]*entity.Saga, error) {
+	query := g.db.WithContext(ctx).Order("updated_at DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", string(status))
+	}
+	var rows []model.Saga
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	sagas := make([]*entity.Saga, 0, len(rows))
+	for i := range rows {
+		sagas = append(sagas, toSagaEntity(&rows[i]))
+	}
+	return sagas, nil
+}
+
+// AppendSagaEvent implements repository.SagaRepository.
+func (g *GormSagaRepository) AppendSagaEvent(ctx context.Context, evt *entity.SagaEvent) error {
+	row := model.SagaEvent{
+		PurchaseID:  evt.PurchaseID,
+		Kind:        string(evt.Kind),
+		Step:        evt.Step,
+		Status:      evt.Status,
+		Topic:       evt.Topic,
+		Handler:     evt.Handler,
+		MessageUUID: evt.MessageUUID,
+		Payload:     evt.Payload,
+		Error:       evt.Error,
+		TraceID:     evt.TraceID,
+	}
+	if err := g.db.WithContext(ctx).Create(&row).Error; err != nil {
+		return err
+	}
+	evt.ID = row.ID
+	evt.CreatedAt = row.CreatedAt
+	return nil
+}
+
+// ListSagaEvents implements repository.SagaRepository.
+func (g *GormSagaRepository) ListSagaEvents(ctx context.Context, purchaseID uint64) ([]*entity.SagaEvent, error) {
+	var rows []model.SagaEvent
+	if err := g.db.WithContext(ctx).Where("purchase_id = ?", purchaseID).Order("id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	events := make([]*entity.SagaEvent, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, &entity.SagaEvent{
+			ID:          row.ID,
+			PurchaseID:  row.PurchaseID,
+			Kind:        entity.SagaEventKind(row.Kind),
+			Step:        row.Step,
+			Status:      row.Status,
+			Topic:       row.Topic,
+			Handler:     row.Handler,
+			MessageUUID: row.MessageUUID,
+			Payload:     row.Payload,
+			Error:       row.Error,
+			TraceID:     row.TraceID,
+			CreatedAt:   row.CreatedAt,
+		})
+	}
+	return events, nil
+}
+
+func toSagaEntity(row *model.Saga) *entity.Saga {
+	return &entity.Saga{
+		PurchaseID:    row.PurchaseID,
+		UserID:        row.UserID,
+		CorrelationID: row.CorrelationID,
+		Status:        entity.SagaStatus(row.Status),
+		Step:          row.Step,
+		CreatedAt:     row.CreatedAt,
+		UpdatedAt:     row.UpdatedAt,
+	}
+}
@@ -22,6 +22,16 @@ func NewOrderApplication(orderService usecase.OrderUseCase) *OrderApplication {
 	}
 }
 
+type OrchestratorApplication struct {
+	OrchestratorService usecase.OrchestratorUseCase
+}
+
+func NewOrchestratorApplication(orchestratorService usecase.OrchestratorUseCase) *OrchestratorApplication {
+	return &OrchestratorApplication{
+		OrchestratorService: orchestratorService,
+	}
+}
+
 type PaymentApplication struct {
 	PaymentService usecase.PaymentUseCase
 }
@@ -2,25 +2,50 @@ package application
 
 import (
 	"context"
+	"time"
 
 	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
 	"github.com/Chengxufeng1994/go-saga-example/common/pb"
+	"github.com/Chengxufeng1994/go-saga-example/common/token"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/grpc/client"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/usecase"
 )
 
+const defaultJWKSCacheTTL = 300
+
 type AuthService struct {
 	authClient *client.AuthConn
+	verifier   token.Verifier
 }
 
-func NewAuthService(authConn *client.AuthConn) usecase.AuthUseCase {
-	return &AuthService{
+func NewAuthService(appCfg *libconfig.ApplicationConfig, authConn *client.AuthConn) usecase.AuthUseCase {
+	svc := &AuthService{
 		authClient: authConn,
 	}
+
+	// verify locally with the published keys when auth-svc signs asymmetrically,
+	// tokens older than an access token lifetime are refused
+	if jwksURL := appCfg.JWTConfig.JWKSURL; jwksURL != "" {
+		ttl := appCfg.JWTConfig.JWKSCacheTTL
+		if ttl == 0 {
+			ttl = defaultJWKSCacheTTL
+		}
+		svc.verifier = token.WithMaxAge(
+			token.NewKeySetVerifier(token.NewRemoteKeySet(jwksURL, time.Duration(ttl)*time.Second)),
+			time.Duration(appCfg.JWTConfig.AccessTokenExpires)*time.Second,
+		)
+	}
+
+	return svc
 }
 
 // VerifyToken implements usecase.AuthUseCase.
 func (a *AuthService) VerifyToken(ctx context.Context, accessToken string) (*dto.VerifyTokenResponse, error) {
+	if a.verifier != nil {
+		return a.verifyLocally(accessToken)
+	}
+
 	cli := pb.NewAuthServiceClient(a.authClient.Conn())
 	res, err := cli.VerifyToken(ctx, &pb.VerifyTokenRequest{AccessToken: accessToken})
 	if err != nil {
@@ -28,7 +53,45 @@ func (a *AuthService) VerifyToken(ctx context.Context, accessToken string) (*dto
 	}
 
 	return &dto.VerifyTokenResponse{
-		UserId:    res.UserId,
-		IsExpired: res.IsExpired,
+		UserId:      res.UserId,
+		SessionId:   res.SessionId,
+		Roles:       res.Roles,
+		Permissions: res.Permissions,
+		IsExpired:   res.IsExpired,
+	}, nil
+}
+
+// VerifyAPIKey implements usecase.AuthUseCase. Api keys are always verified
+// by auth-svc so revoked keys stop working right away.
+func (a *AuthService) VerifyAPIKey(ctx context.Context, apiKey string) (*dto.VerifyTokenResponse, error) {
+	cli := pb.NewAuthServiceClient(a.authClient.Conn())
+	res, err := cli.VerifyAPIKey(ctx, &pb.VerifyAPIKeyRequest{ApiKey: apiKey})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.VerifyTokenResponse{
+		UserId:      res.UserId,
+		Roles:       res.Roles,
+		Permissions: res.Permissions,
+	}, nil
+}
+
+// verifyLocally checks signature, expiry and age only, revoked sessions stay
+// valid until their access token is older than access_token_expires
+func (a *AuthService) verifyLocally(accessToken string) (*dto.VerifyTokenResponse, error) {
+	claims, err := a.verifier.Verify(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.IsRefresh {
+		return nil, usecase.ErrNotAccessToken
+	}
+
+	return &dto.VerifyTokenResponse{
+		UserId:      claims.UserID,
+		SessionId:   claims.SessionID,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
 	}, nil
 }
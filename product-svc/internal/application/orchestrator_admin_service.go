@@ -0,0 +1,216 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/event"
+	"github.com/Chengxufeng1994/go-saga-example/common/pb"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	domainevent "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/repository"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/usecase"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Operator actions recorded in the timeline of a saga
+const (
+	sagaActionRetry      = "RETRY"
+	sagaActionCompensate = "COMPENSATE"
+	sagaActionResolve    = "RESOLVE"
+)
+
+// commandTypes maps the topics of the commands to their message
+var commandTypes = map[string]func() proto.Message{
+	event.UpdateProductInventoryTopic:   func() proto.Message { return &pb.CreatePurchaseCommand{} },
+	event.RollbackProductInventoryTopic: func() proto.Message { return &pb.RollbackCommand{} },
+	event.CreateOrderTopic:              func() proto.Message { return &pb.CreatePurchaseCommand{} },
+	event.RollbackOrderTopic:            func() proto.Message { return &pb.RollbackCommand{} },
+	event.CreatePaymentTopic:            func() proto.Message { return &pb.CreatePurchaseCommand{} },
+	event.RollbackPaymentTopic:          func() proto.Message { return &pb.RollbackCommand{} },
+	event.CapturePaymentTopic:           func() proto.Message { return &pb.CapturePaymentCommand{} },
+}
+
+// ListSagas implements usecase.OrchestratorUseCase.
+func (svc *OrchestratorService) ListSagas(ctx context.Context, status entity.SagaStatus, limit int) ([]*entity.Saga, error) {
+	return svc.sagaRepository.ListSagas(ctx, status, limit)
+}
+
+// GetSaga implements usecase.OrchestratorUseCase.
+// The saga is rebuilt from its stream, not read from the list of sagas
+// projected from it.
+func (svc *OrchestratorService) GetSaga(ctx context.Context, purchaseID uint64) (*entity.Saga, []*entity.SagaEvent, error) {
+	saga, err := svc.loadSaga(ctx, purchaseID)
+	if err != nil {
+		return nil, nil, err
+	}
+	events, err := svc.sagaRepository.ListSagaEvents(ctx, purchaseID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return saga.Saga(), events, nil
+}
+
+// loadSaga rebuilds a saga which was started from its stream
+func (svc *OrchestratorService) loadSaga(ctx context.Context, purchaseID uint64) (*entity.PurchaseSaga, error) {
+	saga, err := svc.purchaseSagaRepository.Load(ctx, purchaseID)
+	if err != nil {
+		return nil, err
+	}
+	if saga.Version == 0 {
+		return nil, repository.NewErrNotFound("saga", strconv.FormatUint(purchaseID, 10))
+	}
+	return saga, nil
+}
+
+// GetSagaTimeline implements usecase.OrchestratorUseCase.
+func (svc *OrchestratorService) GetSagaTimeline(ctx context.Context, purchaseID uint64) (*dto.SagaTimeline, error) {
+	saga, events, err := svc.GetSaga(ctx, purchaseID)
+	if err != nil {
+		return nil, err
+	}
+	return newSagaTimeline(saga, events), nil
+}
+
+// RetrySaga implements usecase.OrchestratorUseCase.
+// The command gets a new message uuid, so the participant handles it again
+// instead of replaying the reply of its first delivery. The participants of
+// a choreographed saga handle no commands, it cannot be retried.
+func (svc *OrchestratorService) RetrySaga(ctx context.Context, purchaseID uint64) error {
+	if svc.choreographed {
+		return usecase.ErrSagaChoreographed
+	}
+	saga, events, err := svc.GetSaga(ctx, purchaseID)
+	if err != nil {
+		return err
+	}
+	switch saga.Status {
+	case entity.SagaResolved:
+		return usecase.ErrSagaResolved
+	case entity.SagaRunning, entity.SagaCompensating:
+	default:
+		return usecase.ErrSagaNotRunning
+	}
+
+	last, replied := lastCommand(events)
+	if last == nil {
+		return usecase.ErrSagaNoCommand
+	}
+	if replied {
+		return usecase.ErrSagaCommandReplied
+	}
+	newCommand, ok := commandTypes[last.Topic]
+	if !ok {
+		return usecase.ErrSagaNoCommand
+	}
+	cmd := newCommand()
+	if err := protojson.Unmarshal(last.Payload, cmd); err != nil {
+		return err
+	}
+
+	svc.logger.Infof("retry saga %v %s", purchaseID, last.Topic)
+	svc.appendSagaEvent(ctx, &entity.SagaEvent{
+		PurchaseID: purchaseID,
+		Kind:       entity.SagaAdmin,
+		Step:       last.Step,
+		Status:     sagaActionRetry,
+		Topic:      last.Topic,
+	})
+	return svc.sendCommand(ctx, last.Step, last.Topic, cmd, saga.UserID, purchaseID, saga.CorrelationID)
+}
+
+// lastCommand returns the last command of a saga and whether its
+// participant replied to it
+func lastCommand(events []*entity.SagaEvent) (*entity.SagaEvent, bool) {
+	var last *entity.SagaEvent
+	replied := false
+	for _, evt := range events {
+		switch evt.Kind {
+		case entity.SagaCommand:
+			last, replied = evt, false
+		case entity.SagaReply:
+			if last != nil && replySteps[evt.Handler].topic == last.Topic {
+				replied = true
+			}
+		}
+	}
+	return last, replied
+}
+
+// CompensateSaga implements usecase.OrchestratorUseCase.
+// The participants of a choreographed saga handle no commands, it cannot be
+// compensated.
+func (svc *OrchestratorService) CompensateSaga(ctx context.Context, purchaseID uint64) error {
+	if svc.choreographed {
+		return usecase.ErrSagaChoreographed
+	}
+	saga, err := svc.loadSaga(ctx, purchaseID)
+	if err != nil {
+		return err
+	}
+	switch saga.Status {
+	case entity.SagaResolved:
+		return usecase.ErrSagaResolved
+	case entity.SagaSucceeded:
+		return usecase.ErrSagaSucceeded
+	case entity.SagaCompensated, entity.SagaFailed:
+		return usecase.ErrSagaNotRunning
+	}
+
+	svc.logger.Infof("compensate saga %v from %s", purchaseID, saga.Step)
+	svc.appendSagaEvent(ctx, &entity.SagaEvent{
+		PurchaseID: purchaseID,
+		Kind:       entity.SagaAdmin,
+		Step:       saga.Step,
+		Status:     sagaActionCompensate,
+	})
+	svc.updateSaga(ctx, purchaseID, "", entity.SagaCompensating)
+
+	switch saga.Step {
+	case domainevent.StepCreateOrder:
+		return svc.rollbackFromOrder(ctx, saga.UserID, purchaseID, saga.CorrelationID)
+	case domainevent.StepCreatePayment:
+		return svc.rollbackFromPayment(ctx, saga.UserID, purchaseID, saga.CorrelationID)
+	default:
+		return svc.rollbackProductInventory(ctx, saga.UserID, purchaseID, saga.CorrelationID)
+	}
+}
+
+// ResolveSaga implements usecase.OrchestratorUseCase.
+// The orchestrator keeps no log of a choreographed saga, it cannot be
+// resolved.
+func (svc *OrchestratorService) ResolveSaga(ctx context.Context, purchaseID uint64, note string) error {
+	if svc.choreographed {
+		return usecase.ErrSagaChoreographed
+	}
+	saga, err := svc.loadSaga(ctx, purchaseID)
+	if err != nil {
+		return err
+	}
+	if saga.Status == entity.SagaResolved {
+		return usecase.ErrSagaResolved
+	}
+	resolved := false
+	if err := svc.changeSaga(ctx, purchaseID, func(saga *entity.PurchaseSaga) {
+		resolved = saga.ChangeStatus("", entity.SagaResolved)
+	}); err != nil {
+		return err
+	}
+	if !resolved {
+		return usecase.ErrSagaResolved
+	}
+
+	svc.logger.Infof("resolve saga %v: %s", purchaseID, note)
+	payload, _ := json.Marshal(map[string]string{"note": note})
+	svc.appendSagaEvent(ctx, &entity.SagaEvent{
+		PurchaseID: purchaseID,
+		Kind:       entity.SagaAdmin,
+		Step:       saga.Step,
+		Status:     sagaActionResolve,
+		Payload:    payload,
+	})
+	return nil
+}
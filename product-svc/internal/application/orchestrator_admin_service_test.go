@@ -0,0 +1,304 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/bus"
+	"github.com/Chengxufeng1994/go-saga-example/common/codec"
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/constant"
+	"github.com/Chengxufeng1994/go-saga-example/common/event"
+	"github.com/Chengxufeng1994/go-saga-example/common/pb"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	domainevent "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/repository"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/usecase"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// fakeSagaRepository keeps the timeline of one saga
+type fakeSagaRepository struct {
+	mu     sync.Mutex
+	events []*entity.SagaEvent
+}
+
+func (f *fakeSagaRepository) ListSagas(ctx context.Context, status entity.SagaStatus, limit int) ([]*entity.Saga, error) {
+	return nil, nil
+}
+
+func (f *fakeSagaRepository) AppendSagaEvent(ctx context.Context, evt *entity.SagaEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, evt)
+	return nil
+}
+
+func (f *fakeSagaRepository) ListSagaEvents(ctx context.Context, purchaseID uint64) ([]*entity.SagaEvent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.events, nil
+}
+
+type nopPurchaseResultRepository struct{}
+
+func (nopPurchaseResultRepository) PublishPurchaseResult(ctx context.Context, correlationID string, evt *domainevent.PurchaseResultEvent) error {
+	return nil
+}
+
+// memPurchaseSagaRepository keeps the saga streams in memory, Save checks
+// the version of the stream like the event store does
+type memPurchaseSagaRepository struct {
+	mu      sync.Mutex
+	streams map[uint64][]any
+}
+
+func newMemPurchaseSagaRepository() *memPurchaseSagaRepository {
+	return &memPurchaseSagaRepository{streams: map[uint64][]any{}}
+}
+
+func (r *memPurchaseSagaRepository) Load(ctx context.Context, purchaseID uint64) (*entity.PurchaseSaga, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	saga := entity.NewPurchaseSaga(purchaseID)
+	for _, evt := range r.streams[purchaseID] {
+		saga.Apply(evt)
+	}
+	return saga, nil
+}
+
+func (r *memPurchaseSagaRepository) Save(ctx context.Context, saga *entity.PurchaseSaga) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changes := saga.Changes()
+	if int64(len(r.streams[saga.PurchaseID])) != saga.Version-int64(len(changes)) {
+		return repository.ErrConcurrencyConflict
+	}
+	r.streams[saga.PurchaseID] = append(r.streams[saga.PurchaseID], changes...)
+	saga.ClearChanges()
+	return nil
+}
+
+// start appends the start of saga 1 and moves it to status at step
+func (r *memPurchaseSagaRepository) start(status entity.SagaStatus, step string) {
+	r.streams[1] = []any{
+		&domainevent.PurchaseSagaStartedEvent{UserID: 1, PurchaseID: 1, CorrelationID: "correlation"},
+		&domainevent.PurchaseSagaStatusChangedEvent{PurchaseID: 1, Step: step, Status: string(status)},
+	}
+}
+
+// topicPublisher records the topics of the published commands
+type topicPublisher struct {
+	topics []string
+}
+
+func (p *topicPublisher) Publish(topic string, messages ...*message.Message) error {
+	p.topics = append(p.topics, topic)
+	return nil
+}
+
+func (p *topicPublisher) Close() error { return nil }
+
+func newTestOrchestrator(sagas *fakeSagaRepository, purchaseSagas *memPurchaseSagaRepository) (*OrchestratorService, *topicPublisher) {
+	config.ContextLogger = logrus.NewEntry(logrus.New())
+	publisher := &topicPublisher{}
+	svc := NewOrchestratorService(&libconfig.ApplicationConfig{}, bus.New(nil, publisher, nil, codec.JSON),
+		nopPurchaseResultRepository{}, sagas, purchaseSagas)
+	return svc.(*OrchestratorService), publisher
+}
+
+func commandEvent(topic string) *entity.SagaEvent {
+	payload, _ := protojson.Marshal(&pb.RollbackCommand{PurchaseId: 1})
+	return &entity.SagaEvent{PurchaseID: 1, Kind: entity.SagaCommand, Topic: topic, Payload: payload}
+}
+
+func replyEvent(handler, status string) *entity.SagaEvent {
+	return &entity.SagaEvent{PurchaseID: 1, Kind: entity.SagaReply, Handler: handler, Status: status}
+}
+
+func TestRetrySaga(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  entity.SagaStatus
+		events  []*entity.SagaEvent
+		wantErr error
+	}{
+		{"running without reply", entity.SagaRunning, []*entity.SagaEvent{commandEvent(event.CreateOrderTopic)}, nil},
+		{"compensating without reply", entity.SagaCompensating, []*entity.SagaEvent{commandEvent(event.RollbackOrderTopic)}, nil},
+		{"retried command", entity.SagaRunning, []*entity.SagaEvent{
+			commandEvent(event.CreateOrderTopic), replyEvent(constant.CreateOrderHandler, domainevent.StatusFailed), commandEvent(event.CreateOrderTopic),
+		}, nil},
+		{"replied command", entity.SagaRunning, []*entity.SagaEvent{
+			commandEvent(event.CreateOrderTopic), replyEvent(constant.CreateOrderHandler, domainevent.StatusSucess),
+		}, usecase.ErrSagaCommandReplied},
+		{"no command", entity.SagaRunning, nil, usecase.ErrSagaNoCommand},
+		{"succeeded", entity.SagaSucceeded, []*entity.SagaEvent{commandEvent(event.CapturePaymentTopic)}, usecase.ErrSagaNotRunning},
+		{"compensated", entity.SagaCompensated, []*entity.SagaEvent{commandEvent(event.RollbackProductInventoryTopic)}, usecase.ErrSagaNotRunning},
+		{"failed", entity.SagaFailed, []*entity.SagaEvent{commandEvent(event.RollbackOrderTopic)}, usecase.ErrSagaNotRunning},
+		{"resolved", entity.SagaResolved, []*entity.SagaEvent{commandEvent(event.CreateOrderTopic)}, usecase.ErrSagaResolved},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			purchaseSagas := newMemPurchaseSagaRepository()
+			purchaseSagas.start(tt.status, domainevent.StepCreateOrder)
+			svc, publisher := newTestOrchestrator(&fakeSagaRepository{events: tt.events}, purchaseSagas)
+			err := svc.RetrySaga(context.Background(), 1)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RetrySaga() error = %v, want %v", err, tt.wantErr)
+			}
+			if wantPublished := tt.wantErr == nil; (len(publisher.topics) == 1) != wantPublished {
+				t.Errorf("RetrySaga() published %v, want a command %v", publisher.topics, wantPublished)
+			}
+		})
+	}
+}
+
+func TestCompensateSaga(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     entity.SagaStatus
+		step       string
+		wantErr    error
+		wantTopics []string
+	}{
+		{"from inventory", entity.SagaRunning, domainevent.StepUpdateProductInventory, nil,
+			[]string{event.RollbackProductInventoryTopic}},
+		{"from order", entity.SagaRunning, domainevent.StepCreateOrder, nil,
+			[]string{event.RollbackOrderTopic, event.RollbackProductInventoryTopic}},
+		{"from payment", entity.SagaCompensating, domainevent.StepCreatePayment, nil,
+			[]string{event.RollbackPaymentTopic, event.RollbackOrderTopic, event.RollbackProductInventoryTopic}},
+		{"succeeded", entity.SagaSucceeded, domainevent.StepCreatePayment, usecase.ErrSagaSucceeded, nil},
+		{"compensated", entity.SagaCompensated, domainevent.StepCreatePayment, usecase.ErrSagaNotRunning, nil},
+		{"failed", entity.SagaFailed, domainevent.StepCreatePayment, usecase.ErrSagaNotRunning, nil},
+		{"resolved", entity.SagaResolved, domainevent.StepCreatePayment, usecase.ErrSagaResolved, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			purchaseSagas := newMemPurchaseSagaRepository()
+			purchaseSagas.start(tt.status, tt.step)
+			svc, publisher := newTestOrchestrator(&fakeSagaRepository{}, purchaseSagas)
+			err := svc.CompensateSaga(context.Background(), 1)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CompensateSaga() error = %v, want %v", err, tt.wantErr)
+			}
+			if fmt.Sprint(publisher.topics) != fmt.Sprint(tt.wantTopics) {
+				t.Errorf("CompensateSaga() published %v, want %v", publisher.topics, tt.wantTopics)
+			}
+		})
+	}
+}
+
+func TestCompensated(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []*entity.SagaEvent
+		want   bool
+	}{
+		{"inventory released", []*entity.SagaEvent{
+			commandEvent(event.RollbackProductInventoryTopic),
+			replyEvent(constant.RollbackProductInventoryHandler, domainevent.StatusSucess),
+		}, true},
+		{"every rollback succeeded", []*entity.SagaEvent{
+			commandEvent(event.RollbackPaymentTopic), commandEvent(event.RollbackOrderTopic), commandEvent(event.RollbackProductInventoryTopic),
+			replyEvent(constant.RollbackOrderHandler, domainevent.StatusSucess),
+			replyEvent(constant.RollbackProductInventoryHandler, domainevent.StatusSucess),
+			replyEvent(constant.RollbackPaymentHandler, domainevent.StatusSucess),
+		}, true},
+		{"first rollback replied before the inventory is released", []*entity.SagaEvent{
+			commandEvent(event.RollbackPaymentTopic),
+			replyEvent(constant.RollbackPaymentHandler, domainevent.StatusSucess),
+		}, false},
+		{"order rollback pending", []*entity.SagaEvent{
+			commandEvent(event.RollbackOrderTopic), commandEvent(event.RollbackProductInventoryTopic),
+			replyEvent(constant.RollbackProductInventoryHandler, domainevent.StatusSucess),
+		}, false},
+		{"order rollback failed", []*entity.SagaEvent{
+			commandEvent(event.RollbackOrderTopic), commandEvent(event.RollbackProductInventoryTopic),
+			replyEvent(constant.RollbackOrderHandler, domainevent.StatusFailed),
+			replyEvent(constant.RollbackProductInventoryHandler, domainevent.StatusSucess),
+		}, false},
+		{"rollback commanded again", []*entity.SagaEvent{
+			commandEvent(event.RollbackProductInventoryTopic),
+			replyEvent(constant.RollbackProductInventoryHandler, domainevent.StatusSucess),
+			commandEvent(event.RollbackProductInventoryTopic),
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compensated(tt.events); got != tt.want {
+				t.Errorf("compensated() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveSaga(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  entity.SagaStatus
+		wantErr error
+	}{
+		{"running", entity.SagaRunning, nil},
+		{"failed", entity.SagaFailed, nil},
+		{"resolved", entity.SagaResolved, usecase.ErrSagaResolved},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			purchaseSagas := newMemPurchaseSagaRepository()
+			purchaseSagas.start(tt.status, domainevent.StepCreateOrder)
+			svc, _ := newTestOrchestrator(&fakeSagaRepository{}, purchaseSagas)
+			err := svc.ResolveSaga(context.Background(), 1, "refunded by hand")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ResolveSaga() error = %v, want %v", err, tt.wantErr)
+			}
+			saga, _ := purchaseSagas.Load(context.Background(), 1)
+			if saga.Status != entity.SagaResolved {
+				t.Errorf("ResolveSaga() left the saga %s", saga.Status)
+			}
+		})
+	}
+}
+
+func TestGetSagaNotStarted(t *testing.T) {
+	svc, _ := newTestOrchestrator(&fakeSagaRepository{}, newMemPurchaseSagaRepository())
+	_, _, err := svc.GetSaga(context.Background(), 1)
+	var notFound *repository.ErrNotFound
+	if !errors.As(err, &notFound) {
+		t.Errorf("GetSaga() error = %v, want not found", err)
+	}
+}
+
+func TestChoreographedSaga(t *testing.T) {
+	tests := []struct {
+		name   string
+		action func(svc *OrchestratorService) error
+	}{
+		{"retry", func(svc *OrchestratorService) error { return svc.RetrySaga(context.Background(), 1) }},
+		{"compensate", func(svc *OrchestratorService) error { return svc.CompensateSaga(context.Background(), 1) }},
+		{"resolve", func(svc *OrchestratorService) error {
+			return svc.ResolveSaga(context.Background(), 1, "refunded by hand")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			purchaseSagas := newMemPurchaseSagaRepository()
+			purchaseSagas.start(entity.SagaRunning, domainevent.StepCreateOrder)
+			svc, publisher := newTestOrchestrator(&fakeSagaRepository{events: []*entity.SagaEvent{commandEvent(event.CreateOrderTopic)}}, purchaseSagas)
+			svc.choreographed = true
+
+			if err := tt.action(svc); !errors.Is(err, usecase.ErrSagaChoreographed) {
+				t.Fatalf("error = %v, want %v", err, usecase.ErrSagaChoreographed)
+			}
+			saga, _ := purchaseSagas.Load(context.Background(), 1)
+			if len(publisher.topics) > 0 || saga.Status != entity.SagaRunning {
+				t.Errorf("published %v and left the saga %s, want nothing done", publisher.topics, saga.Status)
+			}
+		})
+	}
+}
@@ -2,38 +2,56 @@ package application
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"errors"
 	"time"
 
+	"github.com/Chengxufeng1994/go-saga-example/common/bus"
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
 	"github.com/Chengxufeng1994/go-saga-example/common/constant"
 	"github.com/Chengxufeng1994/go-saga-example/common/event"
 	"github.com/Chengxufeng1994/go-saga-example/common/pb"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/config"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
 	domainevent "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
-	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/broker"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/repository"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/usecase"
 	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill/message"
-	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
 	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// maxSaveAttempts bounds the saves of a saga racing the saves of its other
+// handlers, each conflict means another save succeeded
+const maxSaveAttempts = 10
+
 type OrchestratorService struct {
 	logger                   *logrus.Entry
-	natsPublisher            broker.NatsPublisher
+	awaitPaymentConfirmation bool
+	capture                  libconfig.Capture
+	// choreographed sagas run without the orchestrator, which keeps no log
+	// of them
+	choreographed            bool
+	bus                      *bus.Bus
 	purchaseResultRepository repository.PurchaseResultRepository
+	sagaRepository           repository.SagaRepository
+	purchaseSagaRepository   repository.PurchaseSagaRepository
 }
 
-func NewOrchestratorService(natsPublisher broker.NatsPublisher, purchaseResultRepository repository.PurchaseResultRepository) usecase.OrchestratorUseCase {
+func NewOrchestratorService(appCfg *libconfig.ApplicationConfig, b *bus.Bus, purchaseResultRepository repository.PurchaseResultRepository, sagaRepository repository.SagaRepository, purchaseSagaRepository repository.PurchaseSagaRepository) usecase.OrchestratorUseCase {
 	return &OrchestratorService{
 		logger:                   config.ContextLogger.WithFields(logrus.Fields{"type": "service:OrchestratorService"}),
-		natsPublisher:            natsPublisher,
+		awaitPaymentConfirmation: appCfg.SagaConfig.AwaitPaymentConfirmation,
+		capture:                  appCfg.SagaConfig.Capture,
+		choreographed:            appCfg.SagaConfig.Choreographed(),
+		bus:                      b,
 		purchaseResultRepository: purchaseResultRepository,
+		sagaRepository:           sagaRepository,
+		purchaseSagaRepository:   purchaseSagaRepository,
 	}
 }
 
@@ -45,25 +63,18 @@ func (svc *OrchestratorService) HandleTrx(parentCtx context.Context, purchase *e
 	defer span.End()
 
 	cmd := EncodeDomainPurchase(purchase)
-	payload, err := json.Marshal(cmd)
-	if err != nil {
-		return err
-	}
-
-	msg := message.NewMessage(watermill.NewUUID(), payload)
-	middleware.SetCorrelationID(correlationID, msg)
 	svc.logger.Infof("update product inventory %v", purchase.ID)
-	svc.purchaseResultRepository.PublishPurchaseResult(
-		correlationID,
+	if err := svc.changeSaga(ctx, purchase.ID, func(saga *entity.PurchaseSaga) {
+		saga.Start(purchase.Order.UserID, correlationID)
+	}); err != nil {
+		svc.logger.WithError(err).Errorf("start saga %v", purchase.ID)
+	}
+	svc.publishResult(
+		ctx, correlationID,
 		domainevent.NewPurchaseResultEvent(purchase.Order.UserID, cmd.PurchaseId, domainevent.StepUpdateProductInventory, domainevent.StatusExecute),
 	)
 
-	updateProductInventoryTopicEvt := &domainevent.Event{
-		Topic:       event.UpdateProductInventoryTopic,
-		MessageType: domainevent.TRX_MSG,
-		Message:     msg,
-	}
-	return svc.publishEvent(ctx, updateProductInventoryTopicEvt)
+	return svc.sendCommand(ctx, domainevent.StepUpdateProductInventory, event.UpdateProductInventoryTopic, cmd, purchase.Order.UserID, purchase.ID, correlationID)
 }
 
 // HandleReply implements usecase.OrchestratorUseCase.
@@ -73,9 +84,10 @@ func (svc *OrchestratorService) HandleReply(parentCtx context.Context, msg *mess
 	defer span.End()
 
 	handler := msg.Metadata.Get(constant.HandlerHeader)
+	svc.recordReply(ctx, handler, msg)
 	switch handler {
 	case constant.UpdateProductInventoryHandler:
-		resp, err := DecodeCreatePurchaseResponse(msg.Payload)
+		resp, err := DecodeCreatePurchaseResponse(msg)
 		if err != nil {
 			return err
 		}
@@ -85,14 +97,13 @@ func (svc *OrchestratorService) HandleReply(parentCtx context.Context, msg *mess
 		svc.logger.WithError(err).Error(resp.Error)
 		return svc.rollbackProductInventory(ctx, resp.Purchase.Order.UserID, resp.Purchase.ID, correlationID)
 	case constant.RollbackProductInventoryHandler:
-		resp, err := DecodeRollbackResponse(msg.Payload)
+		resp, err := DecodeRollbackResponse(msg)
 		if err != nil {
 			return err
 		}
-		return svc.purchaseResultRepository.PublishPurchaseResult(
-			correlationID, domainevent.NewPurchaseResultEvent(resp.UserID, resp.PurchaseID, domainevent.StepUpdateProductInventory, domainevent.StatusRollbackFailed))
+		return svc.rollbackReplied(ctx, resp, domainevent.StepUpdateProductInventory, correlationID)
 	case constant.CreateOrderHandler:
-		resp, err := DecodeCreatePurchaseResponse(msg.Payload)
+		resp, err := DecodeCreatePurchaseResponse(msg)
 		if err != nil {
 			return err
 		}
@@ -102,30 +113,61 @@ func (svc *OrchestratorService) HandleReply(parentCtx context.Context, msg *mess
 		svc.logger.WithError(err).Error(resp.Error)
 		return svc.rollbackFromOrder(ctx, resp.Purchase.Order.UserID, resp.Purchase.ID, correlationID)
 	case constant.RollbackOrderHandler:
-		resp, err := DecodeRollbackResponse(msg.Payload)
+		resp, err := DecodeRollbackResponse(msg)
 		if err != nil {
 			return err
 		}
-		return svc.purchaseResultRepository.PublishPurchaseResult(
-			correlationID, domainevent.NewPurchaseResultEvent(resp.UserID, resp.PurchaseID, domainevent.StepCreateOrder, domainevent.StatusRollbackFailed))
+		return svc.rollbackReplied(ctx, resp, domainevent.StepCreateOrder, correlationID)
 	case constant.CreatePaymentHandler:
-		resp, err := DecodeCreatePurchaseResponse(msg.Payload)
+		resp, err := DecodeCreatePurchaseResponse(msg)
 		if err != nil {
 			return err
 		}
 		if resp.Success {
-			return svc.purchaseResultRepository.PublishPurchaseResult(
-				correlationID, domainevent.NewPurchaseResultEvent(resp.Purchase.Order.UserID, resp.Purchase.ID, domainevent.StepCreatePayment, domainevent.StatusSucess))
+			return svc.capturePayment(ctx, resp.Purchase.Order.UserID, resp.Purchase.ID, correlationID)
 		}
 		svc.logger.WithError(err).Error(resp.Error)
 		return svc.rollbackFromPayment(ctx, resp.Purchase.Order.UserID, resp.Purchase.ID, correlationID)
 	case constant.RollbackPaymentHandler:
-		resp, err := DecodeRollbackResponse(msg.Payload)
+		resp, err := DecodeRollbackResponse(msg)
 		if err != nil {
 			return err
 		}
-		return svc.purchaseResultRepository.PublishPurchaseResult(
-			correlationID, domainevent.NewPurchaseResultEvent(resp.UserID, resp.PurchaseID, domainevent.StepCreatePayment, domainevent.StatusRollbackFailed))
+		return svc.rollbackReplied(ctx, resp, domainevent.StepCreatePayment, correlationID)
+	case constant.CapturePaymentHandler:
+		resp, err := DecodeCapturePaymentResponse(msg)
+		if err != nil {
+			return err
+		}
+		if resp.Success {
+			return svc.captured(ctx, resp.UserID, resp.PurchaseID, correlationID)
+		}
+		svc.logger.Errorf("capture payment %v failed: %s", resp.PurchaseID, resp.Error)
+		return svc.retryCapturePayment(ctx, resp.UserID, resp.PurchaseID, correlationID)
+	default:
+		return nil
+	}
+}
+
+// HandlePaymentStatus implements usecase.OrchestratorUseCase.
+// When the saga awaits payment confirmation it completes on a confirmed
+// capture and compensates on a failed one.
+func (svc *OrchestratorService) HandlePaymentStatus(parentCtx context.Context, evt *domainevent.PaymentStatusChangedEvent, correlationID string) error {
+	tr := otel.Tracer("handlePaymentStatus")
+	ctx, span := tr.Start(parentCtx, "event.HandlePaymentStatus")
+	defer span.End()
+
+	svc.logger.Infof("payment %v status %s confirmed by %s", evt.PurchaseID, evt.Status, evt.Provider)
+	if !svc.awaitPaymentConfirmation {
+		return nil
+	}
+
+	switch evt.Status {
+	case string(valueobject.PaymentCaptured):
+		return svc.publishResult(
+			ctx, correlationID, domainevent.NewPurchaseResultEvent(evt.UserID, evt.PurchaseID, domainevent.StepCreatePayment, domainevent.StatusSucess))
+	case valueobject.WebhookPaymentCaptureFailed:
+		return svc.rollbackFromPayment(ctx, evt.UserID, evt.PurchaseID, correlationID)
 	default:
 		return nil
 	}
@@ -133,145 +175,158 @@ func (svc *OrchestratorService) HandleReply(parentCtx context.Context, msg *mess
 
 func (svc *OrchestratorService) createOrder(ctx context.Context, purchase *entity.Purchase, correlationID string) error {
 	svc.logger.Infof("create order %v", purchase.ID)
-	svc.purchaseResultRepository.PublishPurchaseResult(
-		correlationID, domainevent.NewPurchaseResultEvent(purchase.Order.UserID, purchase.ID, domainevent.StepUpdateProductInventory, domainevent.StatusSucess))
+	svc.publishResult(
+		ctx, correlationID, domainevent.NewPurchaseResultEvent(purchase.Order.UserID, purchase.ID, domainevent.StepUpdateProductInventory, domainevent.StatusSucess))
 
 	cmd := EncodeDomainPurchase(purchase)
-	payload, err := json.Marshal(cmd)
-	if err != nil {
-		return err
-	}
-	msg := message.NewMessage(watermill.NewUUID(), payload)
-	middleware.SetCorrelationID(correlationID, msg)
 
-	svc.purchaseResultRepository.PublishPurchaseResult(
-		correlationID, domainevent.NewPurchaseResultEvent(purchase.Order.UserID, purchase.ID, domainevent.StepCreateOrder, domainevent.StatusExecute))
+	svc.publishResult(
+		ctx, correlationID, domainevent.NewPurchaseResultEvent(purchase.Order.UserID, purchase.ID, domainevent.StepCreateOrder, domainevent.StatusExecute))
 
-	createOrderTopicEvt := &domainevent.Event{
-		Topic:       event.CreateOrderTopic,
-		MessageType: domainevent.TRX_MSG,
-		Message:     msg,
-	}
-	return svc.publishEvent(ctx, createOrderTopicEvt)
+	return svc.sendCommand(ctx, domainevent.StepCreateOrder, event.CreateOrderTopic, cmd, purchase.Order.UserID, purchase.ID, correlationID)
 }
 
 func (svc *OrchestratorService) createPayment(ctx context.Context, purchase *entity.Purchase, correlationID string) error {
 	svc.logger.Infof("create payment %v", purchase.ID)
-	svc.purchaseResultRepository.PublishPurchaseResult(
-		correlationID, domainevent.NewPurchaseResultEvent(purchase.Order.UserID, purchase.ID, domainevent.StepCreateOrder, domainevent.StatusSucess))
+	svc.publishResult(
+		ctx, correlationID, domainevent.NewPurchaseResultEvent(purchase.Order.UserID, purchase.ID, domainevent.StepCreateOrder, domainevent.StatusSucess))
 
 	cmd := EncodeDomainPurchase(purchase)
-	payload, err := json.Marshal(cmd)
-	if err != nil {
-		return err
+
+	svc.publishResult(
+		ctx, correlationID, domainevent.NewPurchaseResultEvent(purchase.Order.UserID, purchase.ID, domainevent.StepCreatePayment, domainevent.StatusExecute))
+
+	return svc.sendCommand(ctx, domainevent.StepCreatePayment, event.CreatePaymentTopic, cmd, purchase.Order.UserID, purchase.ID, correlationID)
+}
+
+// capturePayment asks the payment service to capture the authorized funds,
+// the saga succeeds once the capture did
+func (svc *OrchestratorService) capturePayment(ctx context.Context, userID uint64, purchaseID uint64, correlationID string) error {
+	svc.logger.Infof("capture payment %v", purchaseID)
+	cmd := &pb.CapturePaymentCommand{
+		UserId:     userID,
+		PurchaseId: purchaseID,
+		Timestamp:  timestamppb.New(time.Now()),
 	}
-	msg := message.NewMessage(watermill.NewUUID(), payload)
-	middleware.SetCorrelationID(correlationID, msg)
 
-	svc.purchaseResultRepository.PublishPurchaseResult(
-		correlationID, domainevent.NewPurchaseResultEvent(purchase.Order.UserID, purchase.ID, domainevent.StepCreatePayment, domainevent.StatusExecute))
+	return svc.sendCommand(ctx, domainevent.StepCreatePayment, event.CapturePaymentTopic, cmd, userID, purchaseID, correlationID)
+}
+
+// captured marks the saga successful on a succeeded capture, unless it
+// awaits the provider's confirmation of the capture
+func (svc *OrchestratorService) captured(ctx context.Context, userID uint64, purchaseID uint64, correlationID string) error {
+	if svc.awaitPaymentConfirmation {
+		return nil
+	}
+	return svc.publishResult(
+		ctx, correlationID, domainevent.NewPurchaseResultEvent(userID, purchaseID, domainevent.StepCreatePayment, domainevent.StatusSucess))
+}
 
-	createPaymentTopicEvt := &domainevent.Event{
-		Topic:       event.CreatePaymentTopic,
-		MessageType: domainevent.TRX_MSG,
-		Message:     msg,
+// retryCapturePayment captures the authorization again, once the captures are
+// exhausted the purchase is compensated, which voids the authorization
+func (svc *OrchestratorService) retryCapturePayment(ctx context.Context, userID uint64, purchaseID uint64, correlationID string) error {
+	events, err := svc.sagaRepository.ListSagaEvents(ctx, purchaseID)
+	if err != nil {
+		return err
+	}
+	attempts := 0
+	for _, evt := range events {
+		if evt.Kind == entity.SagaCommand && evt.Topic == event.CapturePaymentTopic {
+			attempts++
+		}
 	}
-	return svc.publishEvent(ctx, createPaymentTopicEvt)
+	if attempts < svc.capture.Attempts() {
+		svc.logger.Infof("retry capture payment %v, attempt %d", purchaseID, attempts+1)
+		if err := svc.capture.Wait(ctx, attempts+1); err != nil {
+			return err
+		}
+		return svc.sendCommand(ctx, domainevent.StepCreatePayment, event.CapturePaymentTopic, &pb.CapturePaymentCommand{
+			UserId:     userID,
+			PurchaseId: purchaseID,
+			Timestamp:  timestamppb.New(time.Now()),
+		}, userID, purchaseID, correlationID)
+	}
+
+	svc.logger.Errorf("capture payment %v failed %d times, compensate", purchaseID, attempts)
+	return svc.rollbackFromPayment(ctx, userID, purchaseID, correlationID)
 }
 
 func (svc *OrchestratorService) rollbackProductInventory(ctx context.Context, userID uint64, purchaseID uint64, correlationID string) error {
 	svc.logger.Infof("rollback product inventory %v", purchaseID)
-	svc.purchaseResultRepository.PublishPurchaseResult(
-		correlationID,
+	svc.publishResult(
+		ctx, correlationID,
 		domainevent.NewPurchaseResultEvent(userID, purchaseID, domainevent.StepUpdateProductInventory, domainevent.StatusFailed),
 	)
 
 	cmd := &pb.RollbackCommand{
+		UserId:     userID,
 		PurchaseId: purchaseID,
 		Timestamp:  timestamppb.New(time.Now()),
 	}
-	payload, err := json.Marshal(cmd)
-	if err != nil {
-		return err
-	}
-	msg := message.NewMessage(watermill.NewUUID(), payload)
-	middleware.SetCorrelationID(correlationID, msg)
-	svc.purchaseResultRepository.PublishPurchaseResult(
-		correlationID,
-		domainevent.NewPurchaseResultEvent(userID, purchaseID, domainevent.StepUpdateProductInventory, domainevent.StatusRollbackFailed),
+	svc.publishResult(
+		ctx, correlationID,
+		domainevent.NewPurchaseResultEvent(userID, purchaseID, domainevent.StepUpdateProductInventory, domainevent.StatusRollbacked),
 	)
 
-	rollbackProductInventoryTopicEvt := &domainevent.Event{
-		Topic:       event.RollbackProductInventoryTopic,
-		MessageType: domainevent.TRX_MSG,
-		Message:     msg,
+	return svc.sendCommand(ctx, domainevent.StepUpdateProductInventory, event.RollbackProductInventoryTopic, cmd, userID, purchaseID, correlationID)
+}
+
+// rollbackUpdateProductInventory releases the inventory of the purchase, it
+// is the last rollback of every compensation
+func (svc *OrchestratorService) rollbackUpdateProductInventory(ctx context.Context, userID uint64, purchaseID uint64, correlationID string) error {
+	svc.logger.Infof("rollback update product inventory %v", purchaseID)
+	cmd := &pb.RollbackCommand{
+		UserId:     userID,
+		PurchaseId: purchaseID,
+		Timestamp:  timestamppb.New(time.Now()),
 	}
-	return svc.publishEvent(ctx, rollbackProductInventoryTopicEvt)
+
+	return svc.sendCommand(ctx, domainevent.StepUpdateProductInventory, event.RollbackProductInventoryTopic, cmd, userID, purchaseID, correlationID)
 }
 
 func (svc *OrchestratorService) rollbackCreateOrder(ctx context.Context, userID uint64, purchaseID uint64, correlationID string) error {
 	svc.logger.Infof("rollback create order %v", purchaseID)
 	cmd := &pb.RollbackCommand{
+		UserId:     userID,
 		PurchaseId: purchaseID,
 		Timestamp:  timestamppb.New(time.Now()),
 	}
-	payload, err := json.Marshal(cmd)
-	if err != nil {
-		return err
-	}
-	msg := message.NewMessage(watermill.NewUUID(), payload)
-	middleware.SetCorrelationID(correlationID, msg)
 
-	rollbackCreateOrderTopicEvt := &domainevent.Event{
-		Topic:       event.RollbackOrderTopic,
-		MessageType: domainevent.TRX_MSG,
-		Message:     msg,
-	}
-	return svc.publishEvent(ctx, rollbackCreateOrderTopicEvt)
+	return svc.sendCommand(ctx, domainevent.StepCreateOrder, event.RollbackOrderTopic, cmd, userID, purchaseID, correlationID)
 }
 
 func (svc *OrchestratorService) rollbackCreatePayment(ctx context.Context, userID uint64, purchaseID uint64, correlationID string) error {
 	svc.logger.Infof("rollback create payment %v", purchaseID)
 	cmd := &pb.RollbackCommand{
+		UserId:     userID,
 		PurchaseId: purchaseID,
 		Timestamp:  timestamppb.New(time.Now()),
 	}
-	payload, err := json.Marshal(cmd)
-	if err != nil {
-		return err
-	}
-	msg := message.NewMessage(watermill.NewUUID(), payload)
-	middleware.SetCorrelationID(correlationID, msg)
 
-	rollbackPaymentTopicEvt := &domainevent.Event{
-		Topic:       event.RollbackPaymentTopic,
-		MessageType: domainevent.TRX_MSG,
-		Message:     msg,
-	}
-	return svc.publishEvent(ctx, rollbackPaymentTopicEvt)
+	return svc.sendCommand(ctx, domainevent.StepCreatePayment, event.RollbackPaymentTopic, cmd, userID, purchaseID, correlationID)
 }
 
 func (svc *OrchestratorService) rollbackFromOrder(ctx context.Context, userID uint64, purchaseID uint64, correlationID string) error {
 	var err error
 	svc.logger.Infof("rollback from order %v", purchaseID)
-	svc.purchaseResultRepository.PublishPurchaseResult(
-		correlationID,
+	svc.publishResult(
+		ctx, correlationID,
 		domainevent.NewPurchaseResultEvent(userID, purchaseID, domainevent.StepCreateOrder, domainevent.StatusFailed),
 	)
 
-	svc.purchaseResultRepository.PublishPurchaseResult(
-		correlationID,
+	svc.publishResult(
+		ctx, correlationID,
 		domainevent.NewPurchaseResultEvent(userID, purchaseID, domainevent.StepCreateOrder, domainevent.StatusRollbacked),
 	)
 	if err = svc.rollbackCreateOrder(ctx, userID, purchaseID, correlationID); err != nil {
 		svc.logger.WithError(err).Error(err.Error())
 	}
 
-	svc.purchaseResultRepository.PublishPurchaseResult(
-		correlationID,
+	svc.publishResult(
+		ctx, correlationID,
 		domainevent.NewPurchaseResultEvent(userID, purchaseID, domainevent.StepUpdateProductInventory, domainevent.StatusRollbacked),
 	)
-	if err = svc.rollbackCreatePayment(ctx, userID, purchaseID, correlationID); err != nil {
+	if err = svc.rollbackUpdateProductInventory(ctx, userID, purchaseID, correlationID); err != nil {
 		svc.logger.WithError(err).Error(err.Error())
 	}
 
@@ -281,44 +336,218 @@ func (svc *OrchestratorService) rollbackFromOrder(ctx context.Context, userID ui
 func (svc *OrchestratorService) rollbackFromPayment(ctx context.Context, userID uint64, purchaseID uint64, correlationID string) error {
 	var err error
 	svc.logger.Infof("rollback from payment %v", purchaseID)
-	svc.purchaseResultRepository.PublishPurchaseResult(
-		correlationID,
+	svc.publishResult(
+		ctx, correlationID,
 		domainevent.NewPurchaseResultEvent(userID, purchaseID, domainevent.StepCreatePayment, domainevent.StatusFailed),
 	)
 
-	svc.purchaseResultRepository.PublishPurchaseResult(
-		correlationID,
+	svc.publishResult(
+		ctx, correlationID,
 		domainevent.NewPurchaseResultEvent(userID, purchaseID, domainevent.StepCreatePayment, domainevent.StatusRollbacked),
 	)
 	if err = svc.rollbackCreatePayment(ctx, userID, purchaseID, correlationID); err != nil {
 		svc.logger.WithError(err).Error(err.Error())
 	}
 
-	svc.purchaseResultRepository.PublishPurchaseResult(
-		correlationID,
+	svc.publishResult(
+		ctx, correlationID,
 		domainevent.NewPurchaseResultEvent(userID, purchaseID, domainevent.StepCreateOrder, domainevent.StatusRollbacked),
 	)
 	if err = svc.rollbackCreateOrder(ctx, userID, purchaseID, correlationID); err != nil {
 		svc.logger.WithError(err).Error(err.Error())
 	}
 
-	svc.purchaseResultRepository.PublishPurchaseResult(
-		correlationID,
+	svc.publishResult(
+		ctx, correlationID,
 		domainevent.NewPurchaseResultEvent(userID, purchaseID, domainevent.StepUpdateProductInventory, domainevent.StatusRollbacked),
 	)
-	if err = svc.rollbackCreatePayment(ctx, userID, purchaseID, correlationID); err != nil {
+	if err = svc.rollbackUpdateProductInventory(ctx, userID, purchaseID, correlationID); err != nil {
 		svc.logger.WithError(err).Error(err.Error())
 	}
 
 	return err
 }
 
-func (svc *OrchestratorService) publishEvent(ctx context.Context, evt *domainevent.Event) error {
-	broker.SetSpanContext(ctx, evt.Message)
-	switch evt.MessageType {
-	case domainevent.TRX_MSG:
-		return svc.natsPublisher.Publish(evt.Topic, evt.Message)
-	default:
-		return fmt.Errorf("unknown message type: %v", evt.MessageType)
+// rollbackReplied publishes the failure of a rollback, the rollback was
+// published as rolled back when it was commanded
+func (svc *OrchestratorService) rollbackReplied(ctx context.Context, resp *entity.RollbackResponse, step string, correlationID string) error {
+	if resp.Success {
+		return nil
+	}
+	svc.logger.Errorf("rollback %s %v failed: %s", step, resp.PurchaseID, resp.Error)
+	return svc.publishResult(
+		ctx, correlationID, domainevent.NewPurchaseResultEvent(resp.UserID, resp.PurchaseID, step, domainevent.StatusRollbackFailed))
+}
+
+// sendCommand publishes cmd and appends it to the timeline of the saga
+func (svc *OrchestratorService) sendCommand(ctx context.Context, step, topic string, cmd proto.Message, userID uint64, purchaseID uint64, correlationID string) error {
+	uuid := watermill.NewUUID()
+	err := bus.Publish(ctx, svc.bus, topic, cmd,
+		bus.WithUUID(uuid), bus.WithCorrelationID(correlationID), bus.WithPartitionKeys(userID, purchaseID))
+	svc.appendSagaEvent(ctx, &entity.SagaEvent{
+		PurchaseID:  purchaseID,
+		Kind:        entity.SagaCommand,
+		Step:        step,
+		Topic:       topic,
+		MessageUUID: uuid,
+		Payload:     marshalSagaPayload(cmd),
+		Error:       errorString(err),
+	})
+	return err
+}
+
+// publishResult publishes the purchase result and records it in the saga,
+// which moves to the status the result implies
+func (svc *OrchestratorService) publishResult(ctx context.Context, correlationID string, evt *domainevent.PurchaseResultEvent) error {
+	err := svc.purchaseResultRepository.PublishPurchaseResult(ctx, correlationID, evt)
+	svc.appendSagaEvent(ctx, &entity.SagaEvent{
+		PurchaseID: evt.PurchaseID,
+		Kind:       entity.SagaResult,
+		Step:       evt.Step,
+		Status:     evt.Status,
+		Topic:      event.PurchaseResultTopic,
+		Error:      errorString(err),
+	})
+
+	if err := svc.changeSaga(ctx, evt.PurchaseID, func(saga *entity.PurchaseSaga) {
+		saga.Record(evt)
+	}); err != nil {
+		svc.logger.WithError(err).Errorf("record saga %v result", evt.PurchaseID)
+	}
+	return err
+}
+
+// sagaReply is implemented by the replies of every saga step
+type sagaReply interface {
+	proto.Message
+	GetPurchaseId() uint64
+	GetSuccess() bool
+	GetError() string
+}
+
+// replySteps maps the handlers replying to the orchestrator to their step,
+// the topic of the command they handle and their reply
+var replySteps = map[string]struct {
+	step     string
+	topic    string
+	rollback bool
+	reply    func() sagaReply
+}{
+	constant.UpdateProductInventoryHandler:   {domainevent.StepUpdateProductInventory, event.UpdateProductInventoryTopic, false, func() sagaReply { return &pb.CreatePurchaseResponse{} }},
+	constant.RollbackProductInventoryHandler: {domainevent.StepUpdateProductInventory, event.RollbackProductInventoryTopic, true, func() sagaReply { return &pb.RollbackResponse{} }},
+	constant.CreateOrderHandler:              {domainevent.StepCreateOrder, event.CreateOrderTopic, false, func() sagaReply { return &pb.CreatePurchaseResponse{} }},
+	constant.RollbackOrderHandler:            {domainevent.StepCreateOrder, event.RollbackOrderTopic, true, func() sagaReply { return &pb.RollbackResponse{} }},
+	constant.CreatePaymentHandler:            {domainevent.StepCreatePayment, event.CreatePaymentTopic, false, func() sagaReply { return &pb.CreatePurchaseResponse{} }},
+	constant.RollbackPaymentHandler:          {domainevent.StepCreatePayment, event.RollbackPaymentTopic, true, func() sagaReply { return &pb.RollbackResponse{} }},
+	constant.CapturePaymentHandler:           {domainevent.StepCreatePayment, event.CapturePaymentTopic, false, func() sagaReply { return &pb.CapturePaymentResponse{} }},
+}
+
+// recordReply appends a reply to the timeline of its saga, a failed
+// rollback fails the compensation
+func (svc *OrchestratorService) recordReply(ctx context.Context, handler string, msg *message.Message) {
+	replyStep, ok := replySteps[handler]
+	if !ok {
+		return
+	}
+	reply := replyStep.reply()
+	if err := bus.Decode(msg, reply); err != nil {
+		return
+	}
+
+	status := domainevent.StatusSucess
+	if !reply.GetSuccess() {
+		status = domainevent.StatusFailed
+	}
+	svc.appendSagaEvent(ctx, &entity.SagaEvent{
+		PurchaseID:  reply.GetPurchaseId(),
+		Kind:        entity.SagaReply,
+		Step:        replyStep.step,
+		Status:      status,
+		Topic:       event.ReplyTopic,
+		Handler:     handler,
+		MessageUUID: msg.UUID,
+		Payload:     marshalSagaPayload(reply),
+		Error:       reply.GetError(),
+	})
+
+	if !replyStep.rollback {
+		return
+	}
+	if !reply.GetSuccess() {
+		svc.updateSaga(ctx, reply.GetPurchaseId(), "", entity.SagaFailed, entity.SagaCompensating, entity.SagaCompensated)
+		return
+	}
+	events, err := svc.sagaRepository.ListSagaEvents(ctx, reply.GetPurchaseId())
+	if err != nil {
+		svc.logger.WithError(err).Errorf("list saga %v events", reply.GetPurchaseId())
+		return
+	}
+	if compensated(events) {
+		svc.updateSaga(ctx, reply.GetPurchaseId(), "", entity.SagaCompensated, entity.SagaCompensating)
+	}
+}
+
+// compensated reports whether every rollback commanded in a saga was replied
+// successfully. The inventory is released last, so the saga is not
+// compensated before its rollback succeeded.
+func compensated(events []*entity.SagaEvent) bool {
+	pending := map[string]bool{event.RollbackProductInventoryTopic: true}
+	for _, evt := range events {
+		switch evt.Kind {
+		case entity.SagaCommand:
+			if rollbackTopics[evt.Topic] {
+				pending[evt.Topic] = true
+			}
+		case entity.SagaReply:
+			if replyStep, ok := replySteps[evt.Handler]; ok && replyStep.rollback && evt.Status == domainevent.StatusSucess {
+				delete(pending, replyStep.topic)
+			}
+		}
+	}
+	return len(pending) == 0
+}
+
+// rollbackTopics are the topics of the rollback commands
+var rollbackTopics = map[string]bool{
+	event.RollbackProductInventoryTopic: true,
+	event.RollbackOrderTopic:            true,
+	event.RollbackPaymentTopic:          true,
+}
+
+// appendSagaEvent records evt with the trace of ctx, the saga goes on when
+// the timeline cannot be written
+func (svc *OrchestratorService) appendSagaEvent(ctx context.Context, evt *entity.SagaEvent) {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		evt.TraceID = sc.TraceID().String()
+	}
+	if err := svc.sagaRepository.AppendSagaEvent(ctx, evt); err != nil {
+		svc.logger.WithError(err).Errorf("append saga %v event", evt.PurchaseID)
+	}
+}
+
+// changeSaga loads the saga, applies change and saves the events it
+// recorded, reloading the saga when another handler saved it first
+func (svc *OrchestratorService) changeSaga(ctx context.Context, purchaseID uint64, change func(*entity.PurchaseSaga)) error {
+	var err error
+	for attempt := 0; attempt < maxSaveAttempts; attempt++ {
+		var saga *entity.PurchaseSaga
+		if saga, err = svc.purchaseSagaRepository.Load(ctx, purchaseID); err != nil {
+			return err
+		}
+		change(saga)
+		if err = svc.purchaseSagaRepository.Save(ctx, saga); !errors.Is(err, repository.ErrConcurrencyConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+// updateSaga moves the saga to status when it is in one of the from
+// statuses, or in any but resolved when none is given
+func (svc *OrchestratorService) updateSaga(ctx context.Context, purchaseID uint64, step string, status entity.SagaStatus, from ...entity.SagaStatus) {
+	if err := svc.changeSaga(ctx, purchaseID, func(saga *entity.PurchaseSaga) {
+		saga.ChangeStatus(step, status, from...)
+	}); err != nil {
+		svc.logger.WithError(err).Errorf("update saga %v", purchaseID)
 	}
 }
@@ -0,0 +1,170 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/bus"
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/constant"
+	"github.com/Chengxufeng1994/go-saga-example/common/event"
+	"github.com/Chengxufeng1994/go-saga-example/common/pb"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	domainevent "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
+)
+
+func TestPublishResult(t *testing.T) {
+	tests := []struct {
+		name       string
+		results    []*domainevent.PurchaseResultEvent
+		wantStatus entity.SagaStatus
+		wantStep   string
+	}{
+		{"running", []*domainevent.PurchaseResultEvent{
+			domainevent.NewPurchaseResultEvent(1, 1, domainevent.StepUpdateProductInventory, domainevent.StatusSucess),
+			domainevent.NewPurchaseResultEvent(1, 1, domainevent.StepCreateOrder, domainevent.StatusExecute),
+		}, entity.SagaRunning, domainevent.StepCreateOrder},
+		{"succeeded", []*domainevent.PurchaseResultEvent{
+			domainevent.NewPurchaseResultEvent(1, 1, domainevent.StepCreatePayment, domainevent.StatusExecute),
+			domainevent.NewPurchaseResultEvent(1, 1, domainevent.StepCreatePayment, domainevent.StatusSucess),
+		}, entity.SagaSucceeded, domainevent.StepCreatePayment},
+		{"failed step", []*domainevent.PurchaseResultEvent{
+			domainevent.NewPurchaseResultEvent(1, 1, domainevent.StepCreateOrder, domainevent.StatusExecute),
+			domainevent.NewPurchaseResultEvent(1, 1, domainevent.StepCreateOrder, domainevent.StatusFailed),
+		}, entity.SagaCompensating, domainevent.StepCreateOrder},
+		{"rollbacks do not complete the compensation", []*domainevent.PurchaseResultEvent{
+			domainevent.NewPurchaseResultEvent(1, 1, domainevent.StepCreateOrder, domainevent.StatusFailed),
+			domainevent.NewPurchaseResultEvent(1, 1, domainevent.StepUpdateProductInventory, domainevent.StatusRollbacked),
+		}, entity.SagaCompensating, domainevent.StepCreateOrder},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			purchaseSagas := newMemPurchaseSagaRepository()
+			purchaseSagas.start(entity.SagaRunning, domainevent.StepUpdateProductInventory)
+			svc, _ := newTestOrchestrator(&fakeSagaRepository{}, purchaseSagas)
+			for _, result := range tt.results {
+				if err := svc.publishResult(context.Background(), "correlation", result); err != nil {
+					t.Fatal(err)
+				}
+			}
+			saga, _ := purchaseSagas.Load(context.Background(), 1)
+			if saga.Status != tt.wantStatus || saga.Step != tt.wantStep {
+				t.Errorf("saga is %s at %s, want %s at %s", saga.Status, saga.Step, tt.wantStatus, tt.wantStep)
+			}
+			if saga.Version != int64(2+len(tt.results)) {
+				t.Errorf("saga version = %d, want %d", saga.Version, 2+len(tt.results))
+			}
+		})
+	}
+}
+
+// TestPublishResultConcurrently records the results of handlers racing on
+// one saga, every result must be appended once to its stream
+func TestPublishResultConcurrently(t *testing.T) {
+	const results = 8
+	purchaseSagas := newMemPurchaseSagaRepository()
+	purchaseSagas.start(entity.SagaRunning, domainevent.StepUpdateProductInventory)
+	svc, _ := newTestOrchestrator(&fakeSagaRepository{}, purchaseSagas)
+
+	var wg sync.WaitGroup
+	for i := 0; i < results; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := domainevent.NewPurchaseResultEvent(1, 1, domainevent.StepUpdateProductInventory, domainevent.StatusExecute)
+			if err := svc.publishResult(context.Background(), "correlation", result); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	saga, _ := purchaseSagas.Load(context.Background(), 1)
+	if saga.Version != 2+results {
+		t.Errorf("saga version = %d, want %d", saga.Version, 2+results)
+	}
+}
+
+// resultRecorder records the purchase results the orchestrator publishes
+type resultRecorder struct {
+	mu      sync.Mutex
+	results []string
+}
+
+func (r *resultRecorder) PublishPurchaseResult(ctx context.Context, correlationID string, evt *domainevent.PurchaseResultEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, evt.Step+" "+evt.Status)
+	return nil
+}
+
+// TestCapturePayment replies to the capture of a payment, the saga may
+// only succeed once the capture did
+func TestCapturePayment(t *testing.T) {
+	succeeded := domainevent.StepCreatePayment + " " + domainevent.StatusSucess
+	tests := []struct {
+		name        string
+		await       bool
+		replies     []bool
+		wantResults []string
+		wantStatus  entity.SagaStatus
+	}{
+		{"captured", false, []bool{true}, []string{succeeded}, entity.SagaSucceeded},
+		{"captured on a retry", false, []bool{false, true}, []string{succeeded}, entity.SagaSucceeded},
+		{"awaiting the confirmation", true, []bool{true}, nil, entity.SagaRunning},
+		{"every capture failed", false, []bool{false, false, false}, []string{
+			domainevent.StepCreatePayment + " " + domainevent.StatusFailed,
+			domainevent.StepCreatePayment + " " + domainevent.StatusRollbacked,
+			domainevent.StepCreateOrder + " " + domainevent.StatusRollbacked,
+			domainevent.StepUpdateProductInventory + " " + domainevent.StatusRollbacked,
+		}, entity.SagaCompensating},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			sagas := &fakeSagaRepository{}
+			purchaseSagas := newMemPurchaseSagaRepository()
+			purchaseSagas.start(entity.SagaRunning, domainevent.StepCreatePayment)
+			svc, publisher := newTestOrchestrator(sagas, purchaseSagas)
+			svc.awaitPaymentConfirmation = tt.await
+			results := &resultRecorder{}
+			svc.purchaseResultRepository = results
+
+			if err := svc.capturePayment(ctx, 1, 1, "correlation"); err != nil {
+				t.Fatal(err)
+			}
+			if len(results.results) != 0 {
+				t.Fatalf("results before the capture replied = %v, want none", results.results)
+			}
+			for _, success := range tt.replies {
+				msg, err := bus.NewMessage(ctx, svc.bus, &pb.CapturePaymentResponse{UserId: 1, PurchaseId: 1, Success: success},
+					bus.WithHandler(constant.CapturePaymentHandler))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := svc.HandleReply(ctx, msg, "correlation"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if fmt.Sprint(results.results) != fmt.Sprint(tt.wantResults) {
+				t.Errorf("results = %v, want %v", results.results, tt.wantResults)
+			}
+			captures := 0
+			for _, topic := range publisher.topics {
+				if topic == event.CapturePaymentTopic {
+					captures++
+				}
+			}
+			if wantCaptures := min(len(tt.replies), libconfig.DefaultCaptureAttempts); captures != wantCaptures {
+				t.Errorf("captured %d times, want %d", captures, wantCaptures)
+			}
+			saga, _ := purchaseSagas.Load(ctx, 1)
+			if saga.Status != tt.wantStatus {
+				t.Errorf("saga is %s, want %s", saga.Status, tt.wantStatus)
+			}
+		})
+	}
+}
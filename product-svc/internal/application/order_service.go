@@ -97,3 +97,13 @@ func (svc *SagaOrderService) RollbackCreateOrder(ctx context.Context, orderID ui
 
 	return nil
 }
+
+// AnonymizeUser implements usecase.SagaOrderUseCase.
+func (svc *SagaOrderService) AnonymizeUser(ctx context.Context, userID uint64) error {
+	if err := svc.orderRepository.AnonymizeOrders(ctx, userID); err != nil {
+		svc.logger.WithError(err).Error(err.Error())
+		return model.NewAppError("AnonymizeUser", "app.order.anonymize_orders.error", nil, "").Wrap(err)
+	}
+
+	return nil
+}
@@ -2,26 +2,268 @@ package application
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"math/big"
+	"time"
 
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/currency"
 	"github.com/Chengxufeng1994/go-saga-example/common/model"
+	"github.com/Chengxufeng1994/go-saga-example/common/webhook"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/config"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/dto"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	domainevent "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/repository"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/usecase"
 	"github.com/sirupsen/logrus"
 )
 
 type PaymentService struct {
-	logger            *logrus.Entry
-	paymentRepository repository.PaymentRepository
+	logger                 *logrus.Entry
+	webhooks               map[string]libconfig.PaymentWebhook
+	paymentRepository      repository.PaymentRepository
+	paymentEventRepository repository.PaymentEventRepository
+	ledgerRepository       repository.LedgerRepository
 }
 
-func NewPaymentService(paymentRepository repository.PaymentRepository) usecase.PaymentUseCase {
+func NewPaymentService(
+	appCfg *libconfig.ApplicationConfig,
+	paymentRepository repository.PaymentRepository,
+	paymentEventRepository repository.PaymentEventRepository,
+	ledgerRepository repository.LedgerRepository) usecase.PaymentUseCase {
 	return &PaymentService{
-		logger:            config.ContextLogger.WithFields(logrus.Fields{"type": "service:PaymentService"}),
-		paymentRepository: paymentRepository,
+		logger:                 config.ContextLogger.WithFields(logrus.Fields{"type": "service:PaymentService"}),
+		webhooks:               appCfg.PaymentGateway.Webhooks,
+		paymentRepository:      paymentRepository,
+		paymentEventRepository: paymentEventRepository,
+		ledgerRepository:       ledgerRepository,
+	}
+}
+
+// GetPaymentLedger implements usecase.PaymentUseCase.
+// The ledger of any purchase is readable by users granted the ledger:read
+// permission.
+func (svc *PaymentService) GetPaymentLedger(ctx context.Context, paymentID uint64) (*dto.PaymentLedger, error) {
+	if _, err := svc.paymentRepository.GetPayment(ctx, paymentID); err != nil {
+		return nil, model.NewAppError("GetPaymentLedger", "app.payment.get_by_id.error", nil, "").Wrap(err)
+	}
+
+	balances, err := svc.ledgerRepository.GetAccountBalances(ctx, paymentID)
+	if err != nil {
+		return nil, model.NewAppError("GetPaymentLedger", "app.payment.get_account_balances.error", nil, "").Wrap(err)
+	}
+	entries, err := svc.ledgerRepository.ListJournalEntries(ctx, paymentID)
+	if err != nil {
+		return nil, model.NewAppError("GetPaymentLedger", "app.payment.list_journal_entries.error", nil, "").Wrap(err)
+	}
+
+	ledger := &dto.PaymentLedger{
+		PurchaseID: paymentID,
+		Balances:   make([]dto.AccountBalance, 0, len(balances)),
+		Entries:    make([]dto.JournalEntry, 0, len(entries)),
+	}
+	for _, balance := range balances {
+		ledger.Balances = append(ledger.Balances, dto.AccountBalance{
+			Account:      string(balance.Account),
+			CurrencyCode: balance.CurrencyCode,
+			Balance:      balance.Balance,
+		})
+	}
+	for _, entry := range entries {
+		postings := make([]dto.LedgerPosting, 0, len(entry.Postings))
+		for _, posting := range entry.Postings {
+			postings = append(postings, dto.LedgerPosting{
+				Account:      string(posting.Account),
+				CurrencyCode: posting.CurrencyCode,
+				Amount:       posting.Amount,
+			})
+		}
+		ledger.Entries = append(ledger.Entries, dto.JournalEntry{
+			ID:            entry.ID,
+			Operation:     string(entry.Operation),
+			TransactionID: entry.TransactionID,
+			Postings:      postings,
+			CreatedAt:     entry.CreatedAt,
+		})
+	}
+
+	return ledger, nil
+}
+
+// HandleWebhook implements usecase.PaymentUseCase.
+func (svc *PaymentService) HandleWebhook(ctx context.Context, provider, signature, timestamp string, payload []byte) (*dto.PaymentWebhookResponse, error) {
+	hook, ok := svc.webhooks[provider]
+	if !ok {
+		return nil, model.NewAppError("HandleWebhook", "app.payment.webhook_provider.error", nil, "").Wrap(usecase.ErrUnknownWebhookProvider)
+	}
+	tolerance := time.Duration(hook.Tolerance) * time.Second
+	if err := webhook.Verify(hook.Secret, signature, timestamp, payload, time.Now(), tolerance); err != nil {
+		return nil, model.NewAppError("HandleWebhook", "app.payment.webhook_signature.error", nil, "").Wrap(errors.Join(usecase.ErrInvalidWebhookSignature, err))
+	}
+
+	var body dto.PaymentWebhookEvent
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return nil, model.NewAppError("HandleWebhook", "app.payment.webhook_payload.error", nil, "").Wrap(err)
+	}
+	if body.ID == "" || body.AuthorizationID == "" {
+		return nil, model.NewAppError("HandleWebhook", "app.payment.webhook_payload.error", nil, "").
+			Wrap(repository.NewErrInvalidInput("webhook", "id", body.ID))
+	}
+	evt := &valueobject.WebhookEvent{
+		Provider:        provider,
+		EventID:         body.ID,
+		Type:            body.Type,
+		AuthorizationID: body.AuthorizationID,
+		TransactionID:   body.TransactionID,
+		Amount:          body.Amount,
+	}
+
+	payment, err := svc.paymentRepository.GetPaymentByAuthorizationID(ctx, evt.AuthorizationID)
+	if err != nil {
+		return nil, model.NewAppError("HandleWebhook", "app.payment.get_payment.error", nil, "").Wrap(err)
+	}
+
+	recorded, err := svc.hasTransaction(ctx, payment.ID, evt.TransactionID)
+	if err != nil {
+		return nil, model.NewAppError("HandleWebhook", "app.payment.list_status_history.error", nil, "").Wrap(err)
+	}
+	// the reply of the gateway may have recorded the transaction already
+	status, transition := string(payment.Status), (*entity.PaymentTransition)(nil)
+	if !recorded {
+		status, transition, err = webhookTransition(payment, evt)
+		if err != nil {
+			svc.logger.WithError(err).Error(err.Error())
+			return nil, model.NewAppError("HandleWebhook", "app.payment.webhook_transition.error", nil, "").Wrap(err)
+		}
+	}
+
+	// the event is recorded with the transition, a concurrent delivery of
+	// the same event or of another event of the same gateway transaction
+	// finds it recorded and changes nothing
+	saved, err := svc.paymentRepository.SaveWebhookEvent(ctx, payment.ID, evt, transition)
+	if err != nil {
+		svc.logger.WithError(err).Error(err.Error())
+		return nil, model.NewAppError("HandleWebhook", "app.payment.webhook_save.error", nil, "").Wrap(err)
+	}
+	if !saved {
+		return &dto.PaymentWebhookResponse{
+			EventID:   evt.EventID,
+			PaymentID: payment.ID,
+			Status:    string(payment.Status),
+			Duplicate: true,
+		}, nil
+	}
+
+	if err := svc.paymentEventRepository.PublishPaymentStatusChanged(ctx, &domainevent.PaymentStatusChangedEvent{
+		UserID:        payment.UserID,
+		PurchaseID:    payment.ID,
+		Status:        status,
+		Provider:      provider,
+		EventID:       evt.EventID,
+		EventType:     evt.Type,
+		CorrelationID: payment.CorrelationID,
+		Timestamp:     time.Now(),
+	}); err != nil {
+		svc.logger.WithError(err).Error(err.Error())
+		return nil, model.NewAppError("HandleWebhook", "app.payment.webhook_publish.error", nil, "").Wrap(err)
+	}
+
+	return &dto.PaymentWebhookResponse{
+		EventID:   evt.EventID,
+		PaymentID: payment.ID,
+		Status:    status,
+	}, nil
+}
+
+// hasTransaction reports whether the gateway transaction changed the status
+// of the payment already
+func (svc *PaymentService) hasTransaction(ctx context.Context, paymentID uint64, transactionID string) (bool, error) {
+	if transactionID == "" {
+		return false, nil
+	}
+	history, err := svc.paymentRepository.ListPaymentStatusHistory(ctx, paymentID)
+	if err != nil {
+		return false, err
+	}
+	for _, change := range history {
+		if change.TransactionID == transactionID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// webhookTransition returns the status the provider reported and the
+// transition moving the payment there. A payment that already is in that
+// status needs no transition so the confirmation can still be forwarded.
+func webhookTransition(payment *entity.Payment, evt *valueobject.WebhookEvent) (string, *entity.PaymentTransition, error) {
+	var from, to valueobject.PaymentStatus
+	var op valueobject.GatewayOperation
+	switch evt.Type {
+	case valueobject.WebhookPaymentCaptured:
+		from, to, op = valueobject.PaymentAuthorized, valueobject.PaymentCaptured, valueobject.GatewayCapture
+	case valueobject.WebhookPaymentVoided:
+		from, to, op = valueobject.PaymentAuthorized, valueobject.PaymentVoided, valueobject.GatewayVoid
+	case valueobject.WebhookPaymentRefunded:
+		// refunds may return part of the captured amount, a payment never
+		// captured has nothing to refund
+		switch payment.Status {
+		case valueobject.PaymentCaptured, valueobject.PaymentPartiallyRefunded, valueobject.PaymentRefunded:
+		default:
+			return "", nil, repository.ErrInvalidTransactionState
+		}
+		if evt.Amount <= 0 || evt.Amount > payment.RefundableAmount() {
+			return "", nil, repository.NewErrInvalidInput("webhook", "amount", evt.Amount)
+		}
+		// partial refunds of the same amount only differ by their gateway
+		// transaction, which replays are told apart by
+		if evt.TransactionID == "" {
+			return "", nil, repository.NewErrInvalidInput("webhook", "transaction_id", evt.TransactionID)
+		}
+		transaction := newWebhookTransaction(evt, valueobject.GatewayRefund)
+		to := payment.RefundStatus(evt.Amount)
+		return string(to), newPaymentTransition(payment, to, transaction), nil
+	case valueobject.WebhookPaymentCaptureFailed:
+		// the authorization stays open, the orchestrator decides whether to compensate
+		return valueobject.WebhookPaymentCaptureFailed, nil, nil
+	default:
+		return "", nil, usecase.ErrUnsupportedWebhookEvent
+	}
+
+	// captures and voids confirm the whole amount of the payment
+	if evt.Amount != payment.Amount {
+		return "", nil, repository.NewErrInvalidInput("webhook", "amount", evt.Amount)
+	}
+	if payment.Status == to {
+		return string(to), nil, nil
+	}
+	if payment.Status != from {
+		return "", nil, repository.ErrInvalidTransactionState
+	}
+	return string(to), newPaymentTransition(payment, to, newWebhookTransaction(evt, op)), nil
+}
+
+func newWebhookTransaction(evt *valueobject.WebhookEvent, op valueobject.GatewayOperation) *valueobject.GatewayTransaction {
+	return &valueobject.GatewayTransaction{
+		ID:          evt.TransactionID,
+		Operation:   op,
+		Amount:      evt.Amount,
+		ProcessedAt: time.Now(),
+	}
+}
+
+// newPaymentTransition moves the payment from its current status to the
+// status the gateway transaction leads to
+func newPaymentTransition(payment *entity.Payment, to valueobject.PaymentStatus, transaction *valueobject.GatewayTransaction) *entity.PaymentTransition {
+	return &entity.PaymentTransition{
+		From:           payment.Status,
+		To:             to,
+		RefundedAmount: payment.RefundedAmount,
+		Transaction:    transaction,
+		Entry:          entity.NewPaymentJournalEntry(payment, transaction),
 	}
 }
 
@@ -42,42 +284,195 @@ func (svc *PaymentService) GetPayment(ctx context.Context, userID uint64, paymen
 		return nil, model.NewAppError("GetPayment", "app.payment.user_id.error", nil, "").Wrap(err)
 	}
 
+	history, err := svc.paymentRepository.ListPaymentStatusHistory(ctx, paymentID)
+	if err != nil {
+		return nil, model.NewAppError("GetPayment", "app.payment.list_status_history.error", nil, "").Wrap(err)
+	}
+
+	statusHistory := make([]dto.PaymentStatusChange, 0, len(history))
+	for _, change := range history {
+		statusHistory = append(statusHistory, dto.PaymentStatusChange{
+			Status:        string(change.Status),
+			TransactionID: change.TransactionID,
+			CreatedAt:     change.CreatedAt,
+		})
+	}
+
 	return &dto.Payment{
-		ID:           paymentID,
-		UserID:       userID,
-		CurrencyCode: payment.CurrencyCode,
-		Amount:       payment.Amount,
+		ID:                   paymentID,
+		UserID:               userID,
+		CurrencyCode:         payment.CurrencyCode,
+		Amount:               payment.Amount,
+		OriginalCurrencyCode: payment.OriginalCurrencyCode,
+		OriginalAmount:       payment.OriginalAmount,
+		ExchangeRate:         payment.ExchangeRate,
+		Status:               string(payment.Status),
+		StatusHistory:        statusHistory,
 	}, nil
 }
 
 type SagaPaymentService struct {
-	logger            *logrus.Entry
-	paymentRepository repository.PaymentRepository
+	logger               *logrus.Entry
+	paymentRepository    repository.PaymentRepository
+	paymentGateway       repository.PaymentGateway
+	exchangeRateProvider repository.ExchangeRateProvider
 }
 
-func NewSagaPaymentService(paymentRepository repository.PaymentRepository) usecase.SagaPaymentUseCase {
+func NewSagaPaymentService(
+	paymentRepository repository.PaymentRepository,
+	paymentGateway repository.PaymentGateway,
+	exchangeRateProvider repository.ExchangeRateProvider) usecase.SagaPaymentUseCase {
 	return &SagaPaymentService{
-		logger:            config.ContextLogger.WithFields(logrus.Fields{"type": "service:SagaPaymentService"}),
-		paymentRepository: paymentRepository,
+		logger:               config.ContextLogger.WithFields(logrus.Fields{"type": "service:SagaPaymentService"}),
+		paymentRepository:    paymentRepository,
+		paymentGateway:       paymentGateway,
+		exchangeRateProvider: exchangeRateProvider,
 	}
 }
 
 // ExecuteCreatePayment implements usecase.SagaPaymentUseCase.
+// It only authorizes the payment, funds are captured once the saga succeeds.
 func (svc *SagaPaymentService) ExecuteCreatePayment(ctx context.Context, payment *entity.Payment) error {
-	if err := svc.paymentRepository.CreatePayment(ctx, payment); err != nil {
+	if err := svc.convertPayment(ctx, payment); err != nil {
+		svc.logger.WithError(err).Error(err.Error())
+		return model.NewAppError("ExecuteCreatePayment", "app.payment.convert_currency.error", nil, "").Wrap(err)
+	}
+
+	authorization, err := svc.paymentGateway.Authorize(ctx, payment)
+	if err != nil {
+		svc.logger.WithError(err).Error(err.Error())
+		return model.NewAppError("ExecuteCreatePayment", "app.payment.authorize_payment.error", nil, "").Wrap(err)
+	}
+	payment.AuthorizationID = authorization.ID
+	payment.Status = valueobject.PaymentAuthorized
+
+	if err := svc.paymentRepository.CreatePayment(ctx, payment, entity.NewPaymentJournalEntry(payment, authorization)); err != nil {
 		svc.logger.WithError(err).Error(err.Error())
+		if _, voidErr := svc.paymentGateway.Void(ctx, authorization.ID); voidErr != nil {
+			svc.logger.WithError(voidErr).Errorf("failed to void authorization %s", authorization.ID)
+		}
 		return model.NewAppError("ExecuteCreatePayment", "app.payment.create_payment.error", nil, "").Wrap(err)
 	}
 
 	return nil
 }
 
+// CapturePayment implements usecase.SagaPaymentUseCase.
+func (svc *SagaPaymentService) CapturePayment(ctx context.Context, paymentID uint64) error {
+	payment, err := svc.paymentRepository.GetPayment(ctx, paymentID)
+	if err != nil {
+		svc.logger.WithError(err).Error(err.Error())
+		return model.NewAppError("CapturePayment", "app.payment.get_payment.error", nil, "").Wrap(err)
+	}
+
+	switch payment.Status {
+	case valueobject.PaymentAuthorized:
+	case valueobject.PaymentCaptured:
+		return nil
+	default:
+		return model.NewAppError("CapturePayment", "app.payment.capture_payment.error", nil, "").Wrap(repository.ErrInvalidTransactionState)
+	}
+
+	capture, err := svc.paymentGateway.Capture(ctx, payment.AuthorizationID, payment.Amount)
+	if err != nil {
+		svc.logger.WithError(err).Error(err.Error())
+		return model.NewAppError("CapturePayment", "app.payment.capture_payment.error", nil, "").Wrap(err)
+	}
+
+	if err := svc.paymentRepository.UpdatePaymentStatus(ctx, paymentID, newPaymentTransition(payment, valueobject.PaymentCaptured, capture)); err != nil {
+		svc.logger.WithError(err).Error(err.Error())
+		return model.NewAppError("CapturePayment", "app.payment.update_payment_status.error", nil, "").Wrap(err)
+	}
+
+	return nil
+}
+
 // RollbackCreatePayment implements usecase.SagaPaymentUseCase.
+// An authorized payment is voided and a captured one is refunded what was
+// not refunded yet.
 func (svc *SagaPaymentService) RollbackCreatePayment(ctx context.Context, paymentID uint64) error {
-	if err := svc.paymentRepository.DeletePayment(ctx, paymentID); err != nil {
+	payment, err := svc.paymentRepository.GetPayment(ctx, paymentID)
+	if err != nil {
+		var nfErr *repository.ErrNotFound
+		if errors.As(err, &nfErr) {
+			// the payment was never authorized, nothing to compensate
+			return nil
+		}
+		svc.logger.WithError(err).Error(err.Error())
+		return model.NewAppError("RollbackCreatePayment", "app.payment.get_payment.error", nil, "").Wrap(err)
+	}
+
+	switch payment.Status {
+	case valueobject.PaymentAuthorized:
+		void, err := svc.paymentGateway.Void(ctx, payment.AuthorizationID)
+		if err != nil {
+			svc.logger.WithError(err).Error(err.Error())
+			return model.NewAppError("RollbackCreatePayment", "app.payment.void_payment.error", nil, "").Wrap(err)
+		}
+		err = svc.paymentRepository.UpdatePaymentStatus(ctx, paymentID, newPaymentTransition(payment, valueobject.PaymentVoided, void))
+		if err != nil {
+			svc.logger.WithError(err).Error(err.Error())
+			return model.NewAppError("RollbackCreatePayment", "app.payment.update_payment_status.error", nil, "").Wrap(err)
+		}
+	case valueobject.PaymentCaptured, valueobject.PaymentPartiallyRefunded:
+		refund, err := svc.paymentGateway.Refund(ctx, payment.CaptureID, payment.RefundableAmount())
+		if err != nil {
+			svc.logger.WithError(err).Error(err.Error())
+			return model.NewAppError("RollbackCreatePayment", "app.payment.refund_payment.error", nil, "").Wrap(err)
+		}
+		err = svc.paymentRepository.UpdatePaymentStatus(ctx, paymentID, newPaymentTransition(payment, valueobject.PaymentRefunded, refund))
+		if err != nil {
+			svc.logger.WithError(err).Error(err.Error())
+			return model.NewAppError("RollbackCreatePayment", "app.payment.update_payment_status.error", nil, "").Wrap(err)
+		}
+	}
+
+	return nil
+}
+
+// AnonymizeUser implements usecase.SagaPaymentUseCase.
+func (svc *SagaPaymentService) AnonymizeUser(ctx context.Context, userID uint64) error {
+	if err := svc.paymentRepository.AnonymizePayments(ctx, userID); err != nil {
 		svc.logger.WithError(err).Error(err.Error())
-		return model.NewAppError("RollbackCreatePayment", "app.payment.delete_payment.error", nil, "").Wrap(err)
+		return model.NewAppError("AnonymizeUser", "app.payment.anonymize_payments.error", nil, "").Wrap(err)
 	}
 
 	return nil
 }
+
+// convertPayment converts the original amount into the currency the customer
+// pays in and records the rate that was used.
+func (svc *SagaPaymentService) convertPayment(ctx context.Context, payment *entity.Payment) error {
+	payment.CurrencyCode = currency.Normalize(payment.CurrencyCode)
+	payment.OriginalCurrencyCode = currency.Normalize(payment.OriginalCurrencyCode)
+	if payment.OriginalCurrencyCode == "" {
+		payment.OriginalCurrencyCode = payment.CurrencyCode
+	}
+	if !currency.IsValid(payment.CurrencyCode) {
+		return repository.NewErrInvalidInput("payment", "currency_code", payment.CurrencyCode).Wrap(currency.ErrUnknownCurrency)
+	}
+	if !currency.IsValid(payment.OriginalCurrencyCode) {
+		return repository.NewErrInvalidInput("payment", "original_currency_code", payment.OriginalCurrencyCode).Wrap(currency.ErrUnknownCurrency)
+	}
+
+	rate := &valueobject.ExchangeRate{
+		From: payment.OriginalCurrencyCode,
+		To:   payment.CurrencyCode,
+		Rate: big.NewRat(1, 1),
+	}
+	if rate.From != rate.To {
+		var err error
+		rate, err = svc.exchangeRateProvider.GetExchangeRate(ctx, rate.From, rate.To)
+		if err != nil {
+			return err
+		}
+	}
+
+	amount, err := currency.Convert(payment.OriginalAmount, rate.From, rate.To, rate.Rate)
+	if err != nil {
+		return err
+	}
+	payment.Amount = amount
+	payment.ExchangeRate = rate.String()
+	return nil
+}
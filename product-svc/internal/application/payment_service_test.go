@@ -0,0 +1,142 @@
+package application
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/repository"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/usecase"
+)
+
+func TestWebhookTransition(t *testing.T) {
+	tests := []struct {
+		name           string
+		status         valueobject.PaymentStatus
+		eventType      string
+		amount         int64
+		wantStatus     string
+		wantTransition bool
+		wantErr        error
+	}{
+		{"capture", valueobject.PaymentAuthorized, valueobject.WebhookPaymentCaptured, 1000, "captured", true, nil},
+		{"capture already recorded", valueobject.PaymentCaptured, valueobject.WebhookPaymentCaptured, 1000, "captured", false, nil},
+		{"void", valueobject.PaymentAuthorized, valueobject.WebhookPaymentVoided, 1000, "voided", true, nil},
+		{"refund", valueobject.PaymentCaptured, valueobject.WebhookPaymentRefunded, 1000, "refunded", true, nil},
+		{"partial refund", valueobject.PaymentCaptured, valueobject.WebhookPaymentRefunded, 400, "partially_refunded", true, nil},
+		{"refund of the rest", valueobject.PaymentPartiallyRefunded, valueobject.WebhookPaymentRefunded, 600, "refunded", true, nil},
+		{"capture failed", valueobject.PaymentAuthorized, valueobject.WebhookPaymentCaptureFailed, 0, valueobject.WebhookPaymentCaptureFailed, false, nil},
+		{"refund of an authorization", valueobject.PaymentAuthorized, valueobject.WebhookPaymentRefunded, 1000, "", false, repository.ErrInvalidTransactionState},
+		{"unsupported type", valueobject.PaymentAuthorized, "payment.disputed", 1000, "", false, usecase.ErrUnsupportedWebhookEvent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment := &entity.Payment{ID: 1, Amount: 1000, CurrencyCode: "TWD", Status: tt.status}
+			if tt.status == valueobject.PaymentPartiallyRefunded {
+				payment.RefundedAmount = 400
+			}
+			status, transition, err := webhookTransition(payment, &valueobject.WebhookEvent{
+				Type:          tt.eventType,
+				TransactionID: "txn_1",
+				Amount:        tt.amount,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("webhookTransition() error = %v, want %v", err, tt.wantErr)
+			}
+			if status != tt.wantStatus {
+				t.Errorf("webhookTransition() status = %q, want %q", status, tt.wantStatus)
+			}
+			if (transition != nil) != tt.wantTransition {
+				t.Fatalf("webhookTransition() transition = %+v, want one %v", transition, tt.wantTransition)
+			}
+			if transition != nil && !transition.Entry.IsBalanced() {
+				t.Errorf("webhookTransition() entry is not balanced: %+v", transition.Entry)
+			}
+		})
+	}
+}
+
+func TestWebhookTransitionAmount(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    valueobject.PaymentStatus
+		refunded  int64
+		eventType string
+		amount    int64
+	}{
+		{"partial capture", valueobject.PaymentAuthorized, 0, valueobject.WebhookPaymentCaptured, 999},
+		{"partial void", valueobject.PaymentAuthorized, 0, valueobject.WebhookPaymentVoided, 1},
+		{"refund above the captured amount", valueobject.PaymentCaptured, 0, valueobject.WebhookPaymentRefunded, 1001},
+		{"refund above the rest", valueobject.PaymentPartiallyRefunded, 400, valueobject.WebhookPaymentRefunded, 601},
+		{"refund of nothing", valueobject.PaymentCaptured, 0, valueobject.WebhookPaymentRefunded, 0},
+		{"refund of a refunded payment", valueobject.PaymentRefunded, 1000, valueobject.WebhookPaymentRefunded, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment := &entity.Payment{ID: 1, Amount: 1000, CurrencyCode: "TWD", Status: tt.status, RefundedAmount: tt.refunded}
+			_, _, err := webhookTransition(payment, &valueobject.WebhookEvent{
+				Type:   tt.eventType,
+				Amount: tt.amount,
+			})
+			var invalidInput *repository.ErrInvalidInput
+			if !errors.As(err, &invalidInput) || invalidInput.Field != "amount" {
+				t.Fatalf("webhookTransition() error = %v, want invalid amount", err)
+			}
+		})
+	}
+}
+
+// TestWebhookRefundTransactionID rejects refunds which replays could not be
+// told apart from, partial refunds of one amount differ by transaction only
+func TestWebhookRefundTransactionID(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        valueobject.PaymentStatus
+		eventType     string
+		transactionID string
+		amount        int64
+		wantErr       bool
+	}{
+		{"partial refund", valueobject.PaymentCaptured, valueobject.WebhookPaymentRefunded, "ref_1", 400, false},
+		{"partial refund without transaction", valueobject.PaymentCaptured, valueobject.WebhookPaymentRefunded, "", 400, true},
+		{"capture without transaction", valueobject.PaymentAuthorized, valueobject.WebhookPaymentCaptured, "", 1000, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment := &entity.Payment{ID: 1, Amount: 1000, CurrencyCode: "TWD", Status: tt.status}
+			_, _, err := webhookTransition(payment, &valueobject.WebhookEvent{
+				Type:          tt.eventType,
+				TransactionID: tt.transactionID,
+				Amount:        tt.amount,
+			})
+			var invalidInput *repository.ErrInvalidInput
+			if gotErr := errors.As(err, &invalidInput) && invalidInput.Field == "transaction_id"; gotErr != tt.wantErr || (!tt.wantErr && err != nil) {
+				t.Fatalf("webhookTransition() error = %v, want invalid transaction_id %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPartialRefundTransition(t *testing.T) {
+	payment := &entity.Payment{ID: 1, Amount: 1000, CurrencyCode: "TWD", Status: valueobject.PaymentPartiallyRefunded, RefundedAmount: 300}
+	_, transition, err := webhookTransition(payment, &valueobject.WebhookEvent{
+		Type:          valueobject.WebhookPaymentRefunded,
+		TransactionID: "ref_2",
+		Amount:        200,
+	})
+	if err != nil {
+		t.Fatalf("webhookTransition() error = %v", err)
+	}
+	if transition.From != valueobject.PaymentPartiallyRefunded || transition.To != valueobject.PaymentPartiallyRefunded {
+		t.Errorf("webhookTransition() = %s -> %s, want partially_refunded -> partially_refunded", transition.From, transition.To)
+	}
+	if transition.RefundedAmount != 300 {
+		t.Errorf("webhookTransition() refunded amount = %d, want 300", transition.RefundedAmount)
+	}
+	for _, posting := range transition.Entry.Postings {
+		if posting.Amount != 200 && posting.Amount != -200 {
+			t.Errorf("journal entry posts %d, want the refunded 200", posting.Amount)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package application
 
 import (
 	"context"
+	"fmt"
 
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/currency"
 	"github.com/Chengxufeng1994/go-saga-example/common/model"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/config"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/dto"
@@ -15,14 +18,21 @@ import (
 
 type ProductService struct {
 	logger            *logrus.Entry
+	baseCurrency      string
 	productRepository repository.ProductRepository
 }
 
-func NewProductService(productRepository repository.ProductRepository) usecase.ProductUseCase {
+func NewProductService(appCfg *libconfig.ApplicationConfig, productRepository repository.ProductRepository) usecase.ProductUseCase {
+	baseCurrency := currency.Normalize(appCfg.CurrencyConfig.Base)
+	if !currency.IsValid(baseCurrency) {
+		panic(fmt.Sprintf("currency.base: %v: %q", currency.ErrUnknownCurrency, appCfg.CurrencyConfig.Base))
+	}
+
 	return &ProductService{
 		logger: config.ContextLogger.WithFields(logrus.Fields{
 			"type": "service:ProductService",
 		}),
+		baseCurrency:      baseCurrency,
 		productRepository: productRepository,
 	}
 }
@@ -54,12 +64,13 @@ func (p *ProductService) ListProducts(ctx context.Context) (*[]dto.Product, erro
 	dtos := make([]dto.Product, 0, len(*entities))
 	for _, entity := range *entities {
 		dtos = append(dtos, dto.Product{
-			ID:          entity.ID,
-			Name:        entity.Detail.Name,
-			Description: entity.Detail.Description,
-			BrandName:   entity.Detail.BrandName,
-			Price:       entity.Detail.Price,
-			Inventory:   entity.Inventory,
+			ID:           entity.ID,
+			Name:         entity.Detail.Name,
+			Description:  entity.Detail.Description,
+			BrandName:    entity.Detail.BrandName,
+			Price:        entity.Detail.Price,
+			CurrencyCode: p.baseCurrency,
+			Inventory:    entity.Inventory,
 		})
 	}
 
@@ -74,12 +85,13 @@ func (p *ProductService) GetProduct(ctx context.Context, id uint64) (*dto.Produc
 	}
 
 	return &dto.Product{
-		ID:          entity.ID,
-		Name:        entity.Detail.Name,
-		Description: entity.Detail.Description,
-		BrandName:   entity.Detail.BrandName,
-		Price:       entity.Detail.Price,
-		Inventory:   entity.Inventory,
+		ID:           entity.ID,
+		Name:         entity.Detail.Name,
+		Description:  entity.Detail.Description,
+		BrandName:    entity.Detail.BrandName,
+		Price:        entity.Detail.Price,
+		CurrencyCode: p.baseCurrency,
+		Inventory:    entity.Inventory,
 	}, nil
 }
 
@@ -100,12 +112,13 @@ func (p *ProductService) GetProducts(ctx context.Context, ids []uint64) (*[]dto.
 		}
 
 		products = append(products, dto.Product{
-			ID:          id,
-			Name:        productDetail.Name,
-			Description: productDetail.Description,
-			BrandName:   productDetail.BrandName,
-			Price:       productDetail.Price,
-			Inventory:   inventory,
+			ID:           id,
+			Name:         productDetail.Name,
+			Description:  productDetail.Description,
+			BrandName:    productDetail.BrandName,
+			Price:        productDetail.Price,
+			CurrencyCode: p.baseCurrency,
+			Inventory:    inventory,
 		})
 	}
 
@@ -122,7 +135,7 @@ func (p *ProductService) CheckProduct(ctx context.Context, req *dto.ProductCheck
 			return nil, err
 		}
 
-		productStatues = append(productStatues, valueobject.NewProductStatus(entity.ProductID, entity.Price, entity.Existed))
+		productStatues = append(productStatues, valueobject.NewProductStatus(entity.ProductID, entity.Price, p.baseCurrency, entity.Existed))
 	}
 
 	return &dto.ProductCheckResponse{
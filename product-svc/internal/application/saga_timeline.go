@@ -0,0 +1,164 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	domainevent "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
+)
+
+// sagaSteps are the steps of the purchase saga in the order they run
+var sagaSteps = []string{
+	domainevent.StepUpdateProductInventory,
+	domainevent.StepCreateOrder,
+	domainevent.StepCreatePayment,
+}
+
+// redactedSagaFields are the payload fields support staff do not get to see
+var redactedSagaFields = map[string]bool{
+	"cardToken": true,
+}
+
+const redacted = "[REDACTED]"
+
+// newSagaTimeline groups the events of a saga by step. A reply answers the
+// oldest unanswered command sent to the topic its handler consumes.
+func newSagaTimeline(saga *entity.Saga, events []*entity.SagaEvent) *dto.SagaTimeline {
+	timeline := &dto.SagaTimeline{
+		PurchaseID:    saga.PurchaseID,
+		UserID:        saga.UserID,
+		CorrelationID: saga.CorrelationID,
+		Status:        string(saga.Status),
+		Step:          saga.Step,
+		StartedAt:     saga.CreatedAt,
+		UpdatedAt:     saga.UpdatedAt,
+		Steps:         []dto.SagaStep{},
+		Actions:       []dto.SagaAction{},
+	}
+
+	var steps []*dto.SagaStep
+	stepOf := func(name string) *dto.SagaStep {
+		for _, step := range steps {
+			if step.Step == name {
+				return step
+			}
+		}
+		step := &dto.SagaStep{
+			Step:     name,
+			Attempts: []dto.SagaAttempt{},
+			Results:  []dto.SagaResult{},
+		}
+		steps = append(steps, step)
+		return step
+	}
+
+	sent := map[string]int{}
+	// indexes of the unanswered attempts by command topic
+	pending := map[string][]int{}
+	for _, evt := range events {
+		switch evt.Kind {
+		case entity.SagaCommand:
+			step := stepOf(evt.Step)
+			if sent[evt.Topic]++; sent[evt.Topic] > 1 {
+				step.Retries++
+			}
+			step.Attempts = append(step.Attempts, dto.SagaAttempt{Command: newSagaMessage(evt)})
+			pending[evt.Topic] = append(pending[evt.Topic], len(step.Attempts)-1)
+		case entity.SagaReply:
+			step := stepOf(evt.Step)
+			topic := replySteps[evt.Handler].topic
+			if queue := pending[topic]; len(queue) > 0 {
+				step.Attempts[queue[0]].Reply = newSagaMessage(evt)
+				pending[topic] = queue[1:]
+			} else {
+				step.Attempts = append(step.Attempts, dto.SagaAttempt{Reply: newSagaMessage(evt)})
+			}
+		case entity.SagaResult:
+			step := stepOf(evt.Step)
+			step.Status = evt.Status
+			step.Results = append(step.Results, dto.SagaResult{
+				Status:    evt.Status,
+				Error:     evt.Error,
+				TraceID:   evt.TraceID,
+				Timestamp: evt.CreatedAt,
+			})
+		case entity.SagaAdmin:
+			timeline.Actions = append(timeline.Actions, dto.SagaAction{
+				Action:    evt.Status,
+				Step:      evt.Step,
+				Topic:     evt.Topic,
+				Payload:   redactSagaPayload(evt.Payload),
+				Timestamp: evt.CreatedAt,
+			})
+		}
+	}
+
+	sort.SliceStable(steps, func(i, j int) bool {
+		return sagaStepIndex(steps[i].Step) < sagaStepIndex(steps[j].Step)
+	})
+	for _, step := range steps {
+		timeline.Steps = append(timeline.Steps, *step)
+	}
+	return timeline
+}
+
+func newSagaMessage(evt *entity.SagaEvent) *dto.SagaMessage {
+	return &dto.SagaMessage{
+		Topic:       evt.Topic,
+		Handler:     evt.Handler,
+		MessageUUID: evt.MessageUUID,
+		Status:      evt.Status,
+		Payload:     redactSagaPayload(evt.Payload),
+		Error:       evt.Error,
+		TraceID:     evt.TraceID,
+		Timestamp:   evt.CreatedAt,
+	}
+}
+
+// sagaStepIndex orders unknown steps last
+func sagaStepIndex(step string) int {
+	for i, s := range sagaSteps {
+		if s == step {
+			return i
+		}
+	}
+	return len(sagaSteps)
+}
+
+func redactSagaPayload(payload []byte) json.RawMessage {
+	if len(payload) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil
+	}
+	redactSagaFields(v)
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func redactSagaFields(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if redactedSagaFields[key] {
+				v[key] = redacted
+				continue
+			}
+			redactSagaFields(field)
+		}
+	case []any:
+		for _, field := range v {
+			redactSagaFields(field)
+		}
+	}
+}
@@ -1,13 +1,15 @@
 package application
 
 import (
-	"encoding/json"
 	"time"
 
+	"github.com/Chengxufeng1994/go-saga-example/common/bus"
 	"github.com/Chengxufeng1994/go-saga-example/common/pb"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
 	"github.com/ThreeDotsLabs/watermill/message"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -27,8 +29,10 @@ func EncodeDomainPurchase(purchase *entity.Purchase) *pb.CreatePurchaseCommand {
 				PurchasedItems: pbPurchasedItems,
 			},
 			Payment: &pb.Payment{
-				CurrencyCode: purchase.Payment.CurrencyCode,
-				Amount:       purchase.Payment.Amount,
+				CurrencyCode:         purchase.Payment.CurrencyCode,
+				Amount:               purchase.Payment.Amount,
+				CardToken:            purchase.Payment.CardToken,
+				OriginalCurrencyCode: purchase.Payment.OriginalCurrencyCode,
 			},
 		},
 		Timestamp: timestamppb.New(time.Now()),
@@ -36,9 +40,9 @@ func EncodeDomainPurchase(purchase *entity.Purchase) *pb.CreatePurchaseCommand {
 	return cmd
 }
 
-func DecodeCreatePurchaseResponse(payload message.Payload) (*entity.CreatePurchaseResponse, error) {
+func DecodeCreatePurchaseResponse(msg *message.Message) (*entity.CreatePurchaseResponse, error) {
 	var resp pb.CreatePurchaseResponse
-	if err := json.Unmarshal(payload, &resp); err != nil {
+	if err := bus.Decode(msg, &resp); err != nil {
 		return nil, err
 	}
 	purchaseID := resp.PurchaseId
@@ -60,9 +64,12 @@ func DecodeCreatePurchaseResponse(payload message.Payload) (*entity.CreatePurcha
 				PurchasedItems: &purchasedItems,
 			},
 			Payment: &entity.Payment{
-				ID:           purchaseID,
-				CurrencyCode: resp.Purchase.Payment.CurrencyCode,
-				Amount:       resp.Purchase.Payment.Amount,
+				ID:                   purchaseID,
+				CurrencyCode:         resp.Purchase.Payment.CurrencyCode,
+				Amount:               resp.Purchase.Payment.Amount,
+				CardToken:            resp.Purchase.Payment.CardToken,
+				OriginalCurrencyCode: resp.Purchase.Payment.OriginalCurrencyCode,
+				OriginalAmount:       resp.Purchase.Payment.Amount,
 			},
 		},
 		Success: resp.Success,
@@ -70,9 +77,9 @@ func DecodeCreatePurchaseResponse(payload message.Payload) (*entity.CreatePurcha
 	}, nil
 }
 
-func DecodeRollbackResponse(payload message.Payload) (*entity.RollbackResponse, error) {
+func DecodeRollbackResponse(msg *message.Message) (*entity.RollbackResponse, error) {
 	var resp pb.RollbackResponse
-	if err := json.Unmarshal(payload, &resp); err != nil {
+	if err := bus.Decode(msg, &resp); err != nil {
 		return nil, err
 	}
 
@@ -83,3 +90,33 @@ func DecodeRollbackResponse(payload message.Payload) (*entity.RollbackResponse,
 		Error:      resp.Error,
 	}, nil
 }
+
+func DecodeCapturePaymentResponse(msg *message.Message) (*entity.CapturePaymentResponse, error) {
+	var resp pb.CapturePaymentResponse
+	if err := bus.Decode(msg, &resp); err != nil {
+		return nil, err
+	}
+
+	return &entity.CapturePaymentResponse{
+		UserID:     resp.UserId,
+		PurchaseID: resp.PurchaseId,
+		Success:    resp.Success,
+		Error:      resp.Error,
+	}, nil
+}
+
+// marshalSagaPayload renders a saga message for its timeline
+func marshalSagaPayload(m proto.Message) []byte {
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
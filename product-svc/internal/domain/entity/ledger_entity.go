@@ -0,0 +1,74 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
+)
+
+// JournalEntry entity, a balanced set of ledger postings
+type JournalEntry struct {
+	ID            uint64
+	PurchaseID    uint64
+	Operation     valueobject.GatewayOperation
+	TransactionID string
+	Postings      []valueobject.Posting
+	CreatedAt     time.Time
+}
+
+// NewPaymentJournalEntry builds the entry posted when a gateway operation
+// succeeded for the payment:
+//
+//	authorize: Dr customer           Cr authorization_hold
+//	capture:   Dr authorization_hold Cr merchant
+//	void:      Dr authorization_hold Cr customer
+//	refund:    Dr refunds            Cr customer
+func NewPaymentJournalEntry(payment *Payment, transaction *valueobject.GatewayTransaction) *JournalEntry {
+	var debit, credit valueobject.LedgerAccount
+	switch transaction.Operation {
+	case valueobject.GatewayAuthorize:
+		debit, credit = valueobject.LedgerCustomer, valueobject.LedgerAuthorizationHold
+	case valueobject.GatewayCapture:
+		debit, credit = valueobject.LedgerAuthorizationHold, valueobject.LedgerMerchant
+	case valueobject.GatewayVoid:
+		debit, credit = valueobject.LedgerAuthorizationHold, valueobject.LedgerCustomer
+	case valueobject.GatewayRefund:
+		debit, credit = valueobject.LedgerRefunds, valueobject.LedgerCustomer
+	}
+
+	amount := transaction.Amount
+	if amount == 0 {
+		amount = payment.Amount
+	}
+
+	return &JournalEntry{
+		PurchaseID:    payment.ID,
+		Operation:     transaction.Operation,
+		TransactionID: transaction.ID,
+		Postings: []valueobject.Posting{
+			{Account: debit, CurrencyCode: payment.CurrencyCode, Amount: amount},
+			{Account: credit, CurrencyCode: payment.CurrencyCode, Amount: -amount},
+		},
+	}
+}
+
+// IsBalanced reports whether the postings of every currency sum up to zero.
+func (e *JournalEntry) IsBalanced() bool {
+	if len(e.Postings) < 2 {
+		return false
+	}
+
+	sums := make(map[string]int64)
+	for _, posting := range e.Postings {
+		if posting.Account == "" {
+			return false
+		}
+		sums[posting.CurrencyCode] += posting.Amount
+	}
+	for _, sum := range sums {
+		if sum != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
+)
+
+func TestNewPaymentJournalEntry(t *testing.T) {
+	payment := &Payment{ID: 7, CurrencyCode: "TWD", Amount: 1000}
+	tests := []struct {
+		op     valueobject.GatewayOperation
+		amount int64
+		debit  valueobject.LedgerAccount
+		credit valueobject.LedgerAccount
+		want   int64
+	}{
+		{valueobject.GatewayAuthorize, 1000, valueobject.LedgerCustomer, valueobject.LedgerAuthorizationHold, 1000},
+		{valueobject.GatewayCapture, 1000, valueobject.LedgerAuthorizationHold, valueobject.LedgerMerchant, 1000},
+		{valueobject.GatewayVoid, 0, valueobject.LedgerAuthorizationHold, valueobject.LedgerCustomer, 1000},
+		{valueobject.GatewayRefund, 250, valueobject.LedgerRefunds, valueobject.LedgerCustomer, 250},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.op), func(t *testing.T) {
+			entry := NewPaymentJournalEntry(payment, &valueobject.GatewayTransaction{ID: "txn", Operation: tt.op, Amount: tt.amount})
+			if !entry.IsBalanced() {
+				t.Fatalf("entry is not balanced: %+v", entry.Postings)
+			}
+			want := []valueobject.Posting{
+				{Account: tt.debit, CurrencyCode: "TWD", Amount: tt.want},
+				{Account: tt.credit, CurrencyCode: "TWD", Amount: -tt.want},
+			}
+			if len(entry.Postings) != len(want) || entry.Postings[0] != want[0] || entry.Postings[1] != want[1] {
+				t.Errorf("postings = %+v, want %+v", entry.Postings, want)
+			}
+			if entry.PurchaseID != payment.ID || entry.TransactionID != "txn" {
+				t.Errorf("entry = %+v, want purchase %d transaction txn", entry, payment.ID)
+			}
+		})
+	}
+}
+
+func TestJournalEntryIsBalanced(t *testing.T) {
+	tests := []struct {
+		name     string
+		postings []valueobject.Posting
+		want     bool
+	}{
+		{"balanced", []valueobject.Posting{
+			{Account: valueobject.LedgerCustomer, CurrencyCode: "TWD", Amount: 100},
+			{Account: valueobject.LedgerMerchant, CurrencyCode: "TWD", Amount: -100},
+		}, true},
+		{"balanced per currency", []valueobject.Posting{
+			{Account: valueobject.LedgerCustomer, CurrencyCode: "TWD", Amount: 100},
+			{Account: valueobject.LedgerMerchant, CurrencyCode: "TWD", Amount: -100},
+			{Account: valueobject.LedgerCustomer, CurrencyCode: "USD", Amount: 3},
+			{Account: valueobject.LedgerMerchant, CurrencyCode: "USD", Amount: -3},
+		}, true},
+		{"unbalanced", []valueobject.Posting{
+			{Account: valueobject.LedgerCustomer, CurrencyCode: "TWD", Amount: 100},
+			{Account: valueobject.LedgerMerchant, CurrencyCode: "TWD", Amount: -99},
+		}, false},
+		{"balanced across currencies only", []valueobject.Posting{
+			{Account: valueobject.LedgerCustomer, CurrencyCode: "TWD", Amount: 100},
+			{Account: valueobject.LedgerMerchant, CurrencyCode: "USD", Amount: -100},
+		}, false},
+		{"single posting", []valueobject.Posting{
+			{Account: valueobject.LedgerCustomer, CurrencyCode: "TWD", Amount: 0},
+		}, false},
+		{"missing account", []valueobject.Posting{
+			{Account: valueobject.LedgerCustomer, CurrencyCode: "TWD", Amount: 100},
+			{CurrencyCode: "TWD", Amount: -100},
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := &JournalEntry{Postings: tt.postings}
+			if got := entry.IsBalanced(); got != tt.want {
+				t.Errorf("IsBalanced() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPaymentRefunds(t *testing.T) {
+	payment := &Payment{Amount: 1000, Status: valueobject.PaymentCaptured}
+	if got := payment.RefundableAmount(); got != 1000 {
+		t.Fatalf("RefundableAmount() = %d, want 1000", got)
+	}
+	if got := payment.RefundStatus(400); got != valueobject.PaymentPartiallyRefunded {
+		t.Errorf("RefundStatus(400) = %s, want %s", got, valueobject.PaymentPartiallyRefunded)
+	}
+
+	payment.Status, payment.RefundedAmount = valueobject.PaymentPartiallyRefunded, 400
+	if got := payment.RefundableAmount(); got != 600 {
+		t.Errorf("RefundableAmount() = %d, want 600", got)
+	}
+	if got := payment.RefundStatus(600); got != valueobject.PaymentRefunded {
+		t.Errorf("RefundStatus(600) = %s, want %s", got, valueobject.PaymentRefunded)
+	}
+
+	payment.Status = valueobject.PaymentAuthorized
+	if got := payment.RefundableAmount(); got != 0 {
+		t.Errorf("RefundableAmount() of an authorization = %d, want 0", got)
+	}
+}
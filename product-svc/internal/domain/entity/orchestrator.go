@@ -14,3 +14,11 @@ type RollbackResponse struct {
 	Success    bool
 	Error      string
 }
+
+// CapturePaymentResponse value object
+type CapturePaymentResponse struct {
+	UserID     uint64
+	PurchaseID uint64
+	Success    bool
+	Error      string
+}
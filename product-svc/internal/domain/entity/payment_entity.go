@@ -1,9 +1,54 @@
 package entity
 
+import "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
+
 // Payment entity
 type Payment struct {
 	ID           uint64
 	UserID       uint64
 	CurrencyCode string
 	Amount       int64
+	CardToken    string
+	// amount before currency conversion
+	OriginalCurrencyCode string
+	OriginalAmount       int64
+	ExchangeRate         string
+	Status               valueobject.PaymentStatus
+	// RefundedAmount is the captured amount returned so far
+	RefundedAmount int64
+	// gateway references
+	AuthorizationID string
+	CaptureID       string
+	// CorrelationID of the saga which created the payment
+	CorrelationID string
+}
+
+// RefundableAmount is the captured amount not refunded yet
+func (p *Payment) RefundableAmount() int64 {
+	switch p.Status {
+	case valueobject.PaymentCaptured, valueobject.PaymentPartiallyRefunded:
+		return p.Amount - p.RefundedAmount
+	default:
+		return 0
+	}
+}
+
+// RefundStatus is the status of the payment once amount more is refunded
+func (p *Payment) RefundStatus(amount int64) valueobject.PaymentStatus {
+	if p.RefundedAmount+amount >= p.Amount {
+		return valueobject.PaymentRefunded
+	}
+	return valueobject.PaymentPartiallyRefunded
+}
+
+// PaymentTransition moves a payment from one status to another, the
+// transaction is posted to the ledger with the entry
+type PaymentTransition struct {
+	From valueobject.PaymentStatus
+	To   valueobject.PaymentStatus
+	// RefundedAmount is the refunded amount of the payment the transition
+	// expects, a refund adds its transaction amount to it
+	RefundedAmount int64
+	Transaction    *valueobject.GatewayTransaction
+	Entry          *JournalEntry
 }
@@ -0,0 +1,158 @@
+package entity
+
+import (
+	"time"
+
+	domainevent "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
+)
+
+// PurchaseSaga aggregate, the state of a purchase saga folded from the
+// events of its stream: the start of the saga, its purchase results and the
+// status changes of its compensation and its operators. It is the source of
+// truth of the saga, the sagas listed by operators are projected from it.
+type PurchaseSaga struct {
+	PurchaseID    uint64
+	UserID        uint64
+	CorrelationID string
+	Status        SagaStatus
+	Step          string
+	// Steps holds the last status of every step the saga reached
+	Steps     map[string]string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// Version is the number of events folded into the saga
+	Version int64
+
+	changes []any
+}
+
+func NewPurchaseSaga(purchaseID uint64) *PurchaseSaga {
+	return &PurchaseSaga{
+		PurchaseID: purchaseID,
+		Steps:      map[string]string{},
+	}
+}
+
+// Start starts the saga, a saga already started is left unchanged
+func (s *PurchaseSaga) Start(userID uint64, correlationID string) {
+	if s.Version > 0 {
+		return
+	}
+	s.record(&domainevent.PurchaseSagaStartedEvent{
+		UserID:        userID,
+		PurchaseID:    s.PurchaseID,
+		CorrelationID: correlationID,
+		Timestamp:     time.Now(),
+	})
+}
+
+// Record folds a new purchase result into the saga, it is saved with the
+// saga
+func (s *PurchaseSaga) Record(evt *domainevent.PurchaseResultEvent) {
+	s.record(evt)
+}
+
+// ChangeStatus moves the saga to status when it is in one of the from
+// statuses, or in any but resolved when none is given. An empty step leaves
+// the step unchanged. It reports whether the status changed.
+func (s *PurchaseSaga) ChangeStatus(step string, status SagaStatus, from ...SagaStatus) bool {
+	if !s.in(from...) {
+		return false
+	}
+	s.record(&domainevent.PurchaseSagaStatusChangedEvent{
+		PurchaseID: s.PurchaseID,
+		Step:       step,
+		Status:     string(status),
+		Timestamp:  time.Now(),
+	})
+	return true
+}
+
+func (s *PurchaseSaga) in(from ...SagaStatus) bool {
+	if len(from) == 0 {
+		return s.Status != SagaResolved
+	}
+	for _, status := range from {
+		if s.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *PurchaseSaga) record(evt any) {
+	s.Apply(evt)
+	s.changes = append(s.changes, evt)
+}
+
+// Changes returns the events recorded since the saga was loaded
+func (s *PurchaseSaga) Changes() []any {
+	return s.changes
+}
+
+// ClearChanges marks the recorded events saved
+func (s *PurchaseSaga) ClearChanges() {
+	s.changes = nil
+}
+
+// Apply folds an event of the stream into the saga. A failed step starts
+// the compensation, which completes once the orchestrator saw every
+// rollback succeed.
+func (s *PurchaseSaga) Apply(evt any) {
+	if s.Steps == nil {
+		s.Steps = map[string]string{}
+	}
+	s.Version++
+
+	switch evt := evt.(type) {
+	case *domainevent.PurchaseSagaStartedEvent:
+		s.UserID = evt.UserID
+		s.CorrelationID = evt.CorrelationID
+		s.CreatedAt = evt.Timestamp
+		s.UpdatedAt = evt.Timestamp
+		if s.Status == "" {
+			s.Status = SagaRunning
+			s.Step = domainevent.StepUpdateProductInventory
+		}
+	case *domainevent.PurchaseResultEvent:
+		s.UserID = evt.UserID
+		s.Steps[evt.Step] = evt.Status
+		s.UpdatedAt = evt.Timestamp
+
+		switch evt.Status {
+		case domainevent.StatusExecute:
+			if s.Status == "" || s.Status == SagaRunning {
+				s.Status = SagaRunning
+				s.Step = evt.Step
+			}
+		case domainevent.StatusSucess:
+			if s.Status == SagaRunning && evt.Step == domainevent.StepCreatePayment {
+				s.Status = SagaSucceeded
+			}
+		case domainevent.StatusFailed:
+			if s.Status == "" || s.Status == SagaRunning {
+				s.Status = SagaCompensating
+				s.Step = evt.Step
+			}
+		}
+	case *domainevent.PurchaseSagaStatusChangedEvent:
+		s.Status = SagaStatus(evt.Status)
+		if evt.Step != "" {
+			s.Step = evt.Step
+		}
+		s.UpdatedAt = evt.Timestamp
+	}
+}
+
+// Saga returns the saga as listed by operators
+func (s *PurchaseSaga) Saga() *Saga {
+	return &Saga{
+		PurchaseID:    s.PurchaseID,
+		UserID:        s.UserID,
+		CorrelationID: s.CorrelationID,
+		Status:        s.Status,
+		Step:          s.Step,
+		CreatedAt:     s.CreatedAt,
+		UpdatedAt:     s.UpdatedAt,
+	}
+}
@@ -0,0 +1,48 @@
+package entity
+
+import (
+	"testing"
+
+	domainevent "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
+)
+
+func TestPurchaseSagaChangeStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status SagaStatus
+		from   []SagaStatus
+		want   bool
+	}{
+		{"from any", SagaCompensating, nil, true},
+		{"resolved from any", SagaResolved, nil, false},
+		{"from its status", SagaCompensating, []SagaStatus{SagaRunning, SagaCompensating}, true},
+		{"from another status", SagaFailed, []SagaStatus{SagaCompensating}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saga := NewPurchaseSaga(1)
+			saga.Apply(&domainevent.PurchaseSagaStatusChangedEvent{PurchaseID: 1, Step: domainevent.StepCreateOrder, Status: string(tt.status)})
+
+			if got := saga.ChangeStatus(domainevent.StepCreatePayment, SagaCompensated, tt.from...); got != tt.want {
+				t.Fatalf("ChangeStatus() = %v, want %v", got, tt.want)
+			}
+			wantStatus, wantStep, wantChanges := tt.status, domainevent.StepCreateOrder, 0
+			if tt.want {
+				wantStatus, wantStep, wantChanges = SagaCompensated, domainevent.StepCreatePayment, 1
+			}
+			if saga.Status != wantStatus || saga.Step != wantStep || len(saga.Changes()) != wantChanges {
+				t.Errorf("saga is %s at %s with %d changes, want %s at %s with %d", saga.Status, saga.Step, len(saga.Changes()), wantStatus, wantStep, wantChanges)
+			}
+		})
+	}
+}
+
+func TestPurchaseSagaStart(t *testing.T) {
+	saga := NewPurchaseSaga(1)
+	saga.Start(7, "first")
+	saga.Start(8, "second")
+	if saga.Status != SagaRunning || saga.Step != domainevent.StepUpdateProductInventory ||
+		saga.CorrelationID != "first" || saga.Version != 1 || len(saga.Changes()) != 1 {
+		t.Errorf("Start() = %+v, want the first start only", saga)
+	}
+}
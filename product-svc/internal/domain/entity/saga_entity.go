@@ -0,0 +1,53 @@
+package entity
+
+import "time"
+
+type SagaStatus string
+
+const (
+	SagaRunning      SagaStatus = "RUNNING"
+	SagaSucceeded    SagaStatus = "SUCCEEDED"
+	SagaCompensating SagaStatus = "COMPENSATING"
+	SagaCompensated  SagaStatus = "COMPENSATED"
+	// SagaFailed is a saga whose compensation failed, it needs an operator
+	SagaFailed   SagaStatus = "FAILED"
+	SagaResolved SagaStatus = "RESOLVED"
+)
+
+type SagaEventKind string
+
+const (
+	SagaCommand SagaEventKind = "COMMAND"
+	SagaReply   SagaEventKind = "REPLY"
+	SagaResult  SagaEventKind = "RESULT"
+	// SagaAdmin is an operator action
+	SagaAdmin SagaEventKind = "ADMIN"
+)
+
+// Saga entity, the purchase saga run by the orchestrator
+type Saga struct {
+	PurchaseID    uint64
+	UserID        uint64
+	CorrelationID string
+	Status        SagaStatus
+	Step          string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// SagaEvent entity, an entry of the timeline of a saga
+type SagaEvent struct {
+	ID          uint64
+	PurchaseID  uint64
+	Kind        SagaEventKind
+	Step        string
+	Status      string
+	Topic       string
+	Handler     string
+	MessageUUID string
+	// Payload is the json of the message
+	Payload   []byte
+	Error     string
+	TraceID   string
+	CreatedAt time.Time
+}
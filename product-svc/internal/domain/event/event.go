@@ -31,13 +31,17 @@ var (
 	StatusRollbackFailed = "STATUS_ROLLBACK_FAIL"
 )
 
-// PurchaseResult event
+// PurchaseResult event, also the event of the purchase saga stream. Both
+// saga modes publish the same results: a step is executed, then succeeds or
+// fails. A failure rolls back the failed step and those before it, each
+// published as rolled back when its rollback starts and as rollback failed
+// only when it failed.
 type PurchaseResultEvent struct {
-	UserID     uint64
-	PurchaseID uint64
-	Step       string
-	Status     string
-	Timestamp  time.Time
+	UserID     uint64    `json:"user_id"`
+	PurchaseID uint64    `json:"purchase_id"`
+	Step       string    `json:"step"`
+	Status     string    `json:"status"`
+	Timestamp  time.Time `json:"timestamp"`
 }
 
 func NewPurchaseResultEvent(userID, purchaseID uint64, step, status string) *PurchaseResultEvent {
@@ -49,3 +53,35 @@ func NewPurchaseResultEvent(userID, purchaseID uint64, step, status string) *Pur
 		Timestamp:  time.Now(),
 	}
 }
+
+// PaymentStatusChangedEvent is published once a payment provider confirmed a
+// status change through a webhook
+type PaymentStatusChangedEvent struct {
+	UserID     uint64
+	PurchaseID uint64
+	Status     string
+	Provider   string
+	EventID    string
+	EventType  string
+	// CorrelationID of the saga which created the payment
+	CorrelationID string
+	Timestamp     time.Time
+}
+
+// PurchaseSagaStartedEvent opens the stream of a purchase saga
+type PurchaseSagaStartedEvent struct {
+	UserID        uint64    `json:"user_id"`
+	PurchaseID    uint64    `json:"purchase_id"`
+	CorrelationID string    `json:"correlation_id"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// PurchaseSagaStatusChangedEvent moves a purchase saga to a status its
+// purchase results do not imply, on the replies of the rollbacks and the
+// actions of operators
+type PurchaseSagaStatusChangedEvent struct {
+	PurchaseID uint64    `json:"purchase_id"`
+	Step       string    `json:"step,omitempty"`
+	Status     string    `json:"status"`
+	Timestamp  time.Time `json:"timestamp"`
+}
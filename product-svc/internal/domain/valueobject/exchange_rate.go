@@ -0,0 +1,15 @@
+package valueobject
+
+import "math/big"
+
+// ExchangeRate value object, one unit of From is worth Rate units of To
+type ExchangeRate struct {
+	From string
+	To   string
+	Rate *big.Rat
+}
+
+// String formats the rate the way it is recorded on a payment
+func (r *ExchangeRate) String() string {
+	return r.Rate.FloatString(8)
+}
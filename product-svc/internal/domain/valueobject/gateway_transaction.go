@@ -0,0 +1,21 @@
+package valueobject
+
+import "time"
+
+// GatewayOperation enumeration
+type GatewayOperation string
+
+const (
+	GatewayAuthorize GatewayOperation = "authorize"
+	GatewayCapture   GatewayOperation = "capture"
+	GatewayVoid      GatewayOperation = "void"
+	GatewayRefund    GatewayOperation = "refund"
+)
+
+// GatewayTransaction value object
+type GatewayTransaction struct {
+	ID          string
+	Operation   GatewayOperation
+	Amount      int64
+	ProcessedAt time.Time
+}
@@ -0,0 +1,29 @@
+package valueobject
+
+// LedgerAccount enumeration
+type LedgerAccount string
+
+const (
+	// LedgerCustomer is what the customer owes or has paid
+	LedgerCustomer LedgerAccount = "customer"
+	// LedgerAuthorizationHold holds authorized but not yet captured funds
+	LedgerAuthorizationHold LedgerAccount = "authorization_hold"
+	// LedgerMerchant is captured revenue
+	LedgerMerchant LedgerAccount = "merchant"
+	// LedgerRefunds is money returned to the customer
+	LedgerRefunds LedgerAccount = "refunds"
+)
+
+// Posting value object, debits are positive and credits negative
+type Posting struct {
+	Account      LedgerAccount
+	CurrencyCode string
+	Amount       int64
+}
+
+// AccountBalance value object
+type AccountBalance struct {
+	Account      LedgerAccount
+	CurrencyCode string
+	Balance      int64
+}
@@ -0,0 +1,26 @@
+package valueobject
+
+import "time"
+
+// PaymentStatus enumeration
+type PaymentStatus string
+
+const (
+	// PaymentAuthorized funds are held but not yet moved
+	PaymentAuthorized PaymentStatus = "authorized"
+	// PaymentCaptured funds are moved
+	PaymentCaptured PaymentStatus = "captured"
+	// PaymentVoided authorization is released without moving funds
+	PaymentVoided PaymentStatus = "voided"
+	// PaymentPartiallyRefunded part of the captured funds are returned
+	PaymentPartiallyRefunded PaymentStatus = "partially_refunded"
+	// PaymentRefunded captured funds are returned
+	PaymentRefunded PaymentStatus = "refunded"
+)
+
+// PaymentStatusChange value object
+type PaymentStatusChange struct {
+	Status        PaymentStatus
+	TransactionID string
+	CreatedAt     time.Time
+}
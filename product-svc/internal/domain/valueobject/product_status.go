@@ -12,20 +12,22 @@ const (
 
 // ProductStatus value object
 type ProductStatus struct {
-	ProductID uint64
-	Price     int64
-	Status    Status
+	ProductID    uint64
+	Price        int64
+	CurrencyCode string
+	Status       Status
 }
 
-func NewProductStatus(productID uint64, price int64, existed bool) *ProductStatus {
+func NewProductStatus(productID uint64, price int64, currencyCode string, existed bool) *ProductStatus {
 	status := ProductOk
 	if !existed {
 		status = ProductNotFound
 	}
 
 	return &ProductStatus{
-		ProductID: productID,
-		Price:     price,
-		Status:    status,
+		ProductID:    productID,
+		Price:        price,
+		CurrencyCode: currencyCode,
+		Status:       status,
 	}
 }
@@ -0,0 +1,19 @@
+package valueobject
+
+// webhook event types
+const (
+	WebhookPaymentCaptured      = "payment.captured"
+	WebhookPaymentCaptureFailed = "payment.capture_failed"
+	WebhookPaymentVoided        = "payment.voided"
+	WebhookPaymentRefunded      = "payment.refunded"
+)
+
+// WebhookEvent value object, a provider notification about a payment
+type WebhookEvent struct {
+	Provider        string
+	EventID         string
+	Type            string
+	AuthorizationID string
+	TransactionID   string
+	Amount          int64
+}
@@ -0,0 +1,31 @@
+package broker
+
+import (
+	"github.com/Chengxufeng1994/go-saga-example/common/bootstrap"
+	"github.com/Chengxufeng1994/go-saga-example/common/bus"
+	"github.com/Chengxufeng1994/go-saga-example/common/codec"
+	"github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/event"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// NewBus returns the bus of an event router, it encodes with c. The purchase
+// results returned by reacting handlers are published with results. Handlers
+// tuning their JetStream consumer get a subscriber of their own.
+func NewBus(bootCfg *bootstrap.BootstrapConfig, appCfg *config.ApplicationConfig, router *message.Router, publisher NatsPublisher, subscriber NatsSubscriber, results ResultPublisher, c codec.Codec) *bus.Bus {
+	b := bus.New(router, publisher, subscriber, c)
+	b.SetTopicPublisher(event.PurchaseResultTopic, results)
+	if appCfg.MessagingConfig.Backend(event.SagaTopicGroup, config.BackendNATS) != config.BackendNATS {
+		return b
+	}
+
+	defaultConsumer := handlerConfig(appCfg, "").Consumer
+	b.SetHandlerSubscriber(func(handler string) message.Subscriber {
+		consumer := handlerConfig(appCfg, handler).Consumer
+		if consumer == defaultConsumer {
+			return nil
+		}
+		return newTxSubscriber(bootCfg, appCfg, consumer)
+	})
+	return b
+}
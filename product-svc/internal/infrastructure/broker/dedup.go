@@ -0,0 +1,166 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/bus"
+	"github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/db/model"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultDedupTTL          = 7 * 24 * 60 * 60
+	defaultDedupClaimTimeout = 60
+	// dedupPurgeInterval limits how often processed messages past their ttl are deleted
+	dedupPurgeInterval = time.Minute
+)
+
+// NewDedupStore returns the store of the processed messages of a service
+// with a database, the processed-messages table unless configured otherwise
+func NewDedupStore(appCfg *config.ApplicationConfig, db *gorm.DB) bus.DedupStore {
+	store, err := newDedupStore(appCfg, config.DedupPostgres, db)
+	if err != nil {
+		panic(err)
+	}
+	return store
+}
+
+// NewOrchestratorDedupStore returns the store of the processed messages of
+// the orchestrator, which has no database and keeps them in redis
+func NewOrchestratorDedupStore(appCfg *config.ApplicationConfig) bus.DedupStore {
+	store, err := newDedupStore(appCfg, config.DedupRedis, nil)
+	if err != nil {
+		panic(err)
+	}
+	return store
+}
+
+func newDedupStore(appCfg *config.ApplicationConfig, fallback string, db *gorm.DB) (bus.DedupStore, error) {
+	cfg := appCfg.MessagingConfig.Dedup
+	ttl, claimTimeout := cfg.TTL, cfg.ClaimTimeout
+	if ttl == 0 {
+		ttl = defaultDedupTTL
+	}
+	if claimTimeout == 0 {
+		claimTimeout = defaultDedupClaimTimeout
+	}
+
+	store := cfg.Store
+	if store == "" {
+		store = fallback
+	}
+	switch store {
+	case config.DedupPostgres:
+		if db == nil {
+			return nil, fmt.Errorf("dedup store %s needs a database", store)
+		}
+		return NewGormDedupStore(db, time.Duration(ttl)*time.Second, time.Duration(claimTimeout)*time.Second), nil
+	case config.DedupRedis:
+		return bus.NewRedisDedupStore(getRedisClient(appCfg), time.Duration(ttl)*time.Second, time.Duration(claimTimeout)*time.Second), nil
+	case config.DedupNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown dedup store: %s", store)
+	}
+}
+
+// GormDedupStore keeps the processed messages in the processed-messages table
+type GormDedupStore struct {
+	db           *gorm.DB
+	ttl          time.Duration
+	claimTimeout time.Duration
+	lastPurge    atomic.Int64
+}
+
+func NewGormDedupStore(db *gorm.DB, ttl, claimTimeout time.Duration) *GormDedupStore {
+	return &GormDedupStore{
+		db:           db,
+		ttl:          ttl,
+		claimTimeout: claimTimeout,
+	}
+}
+
+// Claim implements bus.DedupStore. A claim which timed out and a message
+// processed longer than the ttl ago are claimed again.
+func (s *GormDedupStore) Claim(ctx context.Context, handler, uuid string) ([]*message.Message, bool, error) {
+	now := time.Now()
+	res := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&model.ProcessedMessage{
+		Handler:     handler,
+		MessageUUID: uuid,
+		ClaimedAt:   now,
+	})
+	if res.Error != nil {
+		return nil, false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return nil, true, nil
+	}
+
+	res = s.db.WithContext(ctx).Model(&model.ProcessedMessage{}).
+		Where("handler = ? AND message_uuid = ?", handler, uuid).
+		Where("(completed_at IS NULL AND claimed_at < ?) OR completed_at < ?", now.Add(-s.claimTimeout), now.Add(-s.ttl)).
+		Updates(map[string]any{"claimed_at": now, "completed_at": nil, "replies": nil})
+	if res.Error != nil {
+		return nil, false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return nil, true, nil
+	}
+
+	var row model.ProcessedMessage
+	if err := s.db.WithContext(ctx).Where("handler = ? AND message_uuid = ?", handler, uuid).First(&row).Error; err != nil {
+		return nil, false, err
+	}
+	if row.CompletedAt == nil {
+		return nil, false, bus.ErrMessageInProgress
+	}
+	replies, err := bus.DecodeReplies(row.Replies)
+	if err != nil {
+		return nil, false, err
+	}
+	return replies, false, nil
+}
+
+// Complete implements bus.DedupStore.
+func (s *GormDedupStore) Complete(ctx context.Context, handler, uuid string, replies []*message.Message) error {
+	data, err := bus.EncodeReplies(replies)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := s.db.WithContext(ctx).Model(&model.ProcessedMessage{}).
+		Where("handler = ? AND message_uuid = ?", handler, uuid).
+		Updates(map[string]any{"replies": data, "completed_at": now}).Error; err != nil {
+		return err
+	}
+
+	s.purge(ctx, now)
+	return nil
+}
+
+// Release implements bus.DedupStore.
+func (s *GormDedupStore) Release(ctx context.Context, handler, uuid string) error {
+	return s.db.WithContext(ctx).
+		Where("handler = ? AND message_uuid = ? AND completed_at IS NULL", handler, uuid).
+		Delete(&model.ProcessedMessage{}).Error
+}
+
+// purge deletes the messages processed longer than the ttl ago, at most
+// once per purge interval
+func (s *GormDedupStore) purge(ctx context.Context, now time.Time) {
+	last := s.lastPurge.Load()
+	if now.Sub(time.Unix(0, last)) < dedupPurgeInterval || !s.lastPurge.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	if err := s.db.WithContext(ctx).
+		Where("completed_at < ?", now.Add(-s.ttl)).
+		Delete(&model.ProcessedMessage{}).Error; err != nil {
+		logger.Error("Cannot purge processed messages", err, nil)
+	}
+}
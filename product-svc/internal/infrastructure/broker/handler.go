@@ -0,0 +1,97 @@
+package broker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/bus"
+	"github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+	"github.com/sony/gobreaker"
+)
+
+// defaultHandler is the tuning of handlers the messaging config leaves out
+var defaultHandler = config.Handler{
+	Timeout: 15000,
+	Retry: config.Retry{
+		MaxRetries:      3,
+		InitialInterval: 100,
+	},
+	Consumer: config.Consumer{
+		DeliverPolicy: "new",
+	},
+}
+
+// handlerConfig returns the tuning of a handler, the defaults for an empty name
+func handlerConfig(appCfg *config.ApplicationConfig, name string) config.Handler {
+	return appCfg.MessagingConfig.Router.Handler(defaultHandler, name)
+}
+
+// handlerMiddleware runs every handler through the middleware chain of its
+// tuning. The chain is built on the first message, the router only tells
+// the handler name in the message context.
+func handlerMiddleware(appCfg *config.ApplicationConfig, dedupStore bus.DedupStore) message.HandlerMiddleware {
+	return func(h message.HandlerFunc) message.HandlerFunc {
+		var (
+			once  sync.Once
+			chain message.HandlerFunc
+		)
+		return func(msg *message.Message) ([]*message.Message, error) {
+			once.Do(func() {
+				name := message.HandlerNameFromCtx(msg.Context())
+				chain = newHandlerChain(name, handlerConfig(appCfg, name), dedupStore, h)
+			})
+			return chain(msg)
+		}
+	}
+}
+
+func newHandlerChain(name string, cfg config.Handler, dedupStore bus.DedupStore, h message.HandlerFunc) message.HandlerFunc {
+	// outermost first
+	var middlewares []message.HandlerMiddleware
+	if cfg.Throttle.PerSecond > 0 {
+		middlewares = append(middlewares, middleware.NewThrottle(cfg.Throttle.PerSecond, time.Second).Middleware)
+	}
+	if cb := cfg.CircuitBreaker; cb.ConsecutiveFailures > 0 {
+		// An open breaker nacks the messages right away, they are redelivered
+		// once the broker gives up on them.
+		middlewares = append(middlewares, middleware.NewCircuitBreaker(gobreaker.Settings{
+			Name:        name,
+			MaxRequests: cb.MaxRequests,
+			Interval:    time.Duration(cb.Interval) * time.Second,
+			Timeout:     time.Duration(cb.Timeout) * time.Second,
+			ReadyToTrip: func(counts gobreaker.Counts) bool {
+				return counts.ConsecutiveFailures >= cb.ConsecutiveFailures
+			},
+		}).Middleware)
+	}
+	middlewares = append(middlewares,
+		// Timeout makes the handler cancel the incoming message's context after a specified time
+		middleware.Timeout(time.Duration(cfg.Timeout)*time.Millisecond),
+		// The handler function is retried if it returns an error.
+		// After MaxRetries, the message is Nacked and it's up to the PubSub to resend it.
+		middleware.Retry{
+			MaxRetries:          cfg.Retry.MaxRetries,
+			InitialInterval:     time.Duration(cfg.Retry.InitialInterval) * time.Millisecond,
+			MaxInterval:         time.Duration(cfg.Retry.MaxInterval) * time.Millisecond,
+			Multiplier:          cfg.Retry.Multiplier,
+			MaxElapsedTime:      time.Duration(cfg.Retry.MaxElapsedTime) * time.Millisecond,
+			RandomizationFactor: cfg.Retry.RandomizationFactor,
+			Logger:              logger,
+		}.Middleware,
+	)
+	if dedupStore != nil {
+		// Deduplicate acks a redelivered message with the replies of its first delivery.
+		// A failed attempt is released before Retry runs the next one.
+		middlewares = append(middlewares, bus.Deduplicate(dedupStore, logger))
+	}
+	// Recoverer handles panics from handlers.
+	// In this case, it passes them as errors to the Retry middleware.
+	middlewares = append(middlewares, middleware.Recoverer)
+
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
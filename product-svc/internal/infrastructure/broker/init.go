@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/Chengxufeng1994/go-saga-example/common/bootstrap"
+	"github.com/Chengxufeng1994/go-saga-example/common/bus"
+	"github.com/Chengxufeng1994/go-saga-example/common/config"
 	"github.com/ThreeDotsLabs/watermill/components/metrics"
 	"github.com/ThreeDotsLabs/watermill/message"
 	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
@@ -12,8 +14,10 @@ import (
 )
 
 // InitializeRouter factory
-func InitializeRouter(bootCfg *bootstrap.BootstrapConfig) *message.Router {
-	router, err := message.NewRouter(message.RouterConfig{}, logger)
+func InitializeRouter(bootCfg *bootstrap.BootstrapConfig, appCfg *config.ApplicationConfig, dedupStore bus.DedupStore) *message.Router {
+	router, err := message.NewRouter(message.RouterConfig{
+		CloseTimeout: time.Duration(appCfg.MessagingConfig.Router.CloseTimeout) * time.Second,
+	}, logger)
 	if err != nil {
 		panic(err)
 	}
@@ -32,18 +36,9 @@ func InitializeRouter(bootCfg *bootstrap.BootstrapConfig) *message.Router {
 	router.AddMiddleware(
 		// CorrelationID will copy the correlation id from the incoming message's metadata to the produced messages
 		middleware.CorrelationID,
-		// Timeout makes the handler cancel the incoming message's context after a specified time
-		middleware.Timeout(time.Second*15),
-		// The handler function is retried if it returns an error.
-		// After MaxRetries, the message is Nacked and it's up to the PubSub to resend it.
-		middleware.Retry{
-			MaxRetries:      3,
-			InitialInterval: time.Millisecond * 100,
-			Logger:          logger,
-		}.Middleware,
-		// Recoverer handles panics from handlers.
-		// In this case, it passes them as errors to the Retry middleware.
-		middleware.Recoverer,
+		// the timeout, retry, circuit breaker and throttle of each handler
+		// are tuned in the messaging config
+		handlerMiddleware(appCfg, dedupStore),
 	)
 	return router
 }
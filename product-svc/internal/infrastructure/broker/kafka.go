@@ -0,0 +1,32 @@
+package broker
+
+import (
+	"github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/ThreeDotsLabs/watermill-kafka/v2/pkg/kafka"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// newKafkaPublisher returns a Kafka publisher, the metadata travels in the
+// record headers
+func newKafkaPublisher(appCfg *config.ApplicationConfig) (message.Publisher, error) {
+	return kafka.NewPublisher(
+		kafka.PublisherConfig{
+			Brokers:   appCfg.MessagingConfig.Kafka.Brokers,
+			Marshaler: kafka.DefaultMarshaler{},
+		},
+		logger,
+	)
+}
+
+// newKafkaSubscriber returns a Kafka subscriber of the configured consumer group
+func newKafkaSubscriber(appCfg *config.ApplicationConfig) (message.Subscriber, error) {
+	return kafka.NewSubscriber(
+		kafka.SubscriberConfig{
+			Brokers:               appCfg.MessagingConfig.Kafka.Brokers,
+			Unmarshaler:           kafka.DefaultMarshaler{},
+			OverwriteSaramaConfig: kafka.DefaultSaramaSubscriberConfig(),
+			ConsumerGroup:         appCfg.MessagingConfig.Kafka.ConsumerGroup,
+		},
+		logger,
+	)
+}
@@ -1,35 +1,20 @@
 package broker
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
-	"github.com/Chengxufeng1994/go-saga-example/common/bootstrap"
 	"github.com/Chengxufeng1994/go-saga-example/common/config"
 	"github.com/ThreeDotsLabs/watermill-nats/v2/pkg/nats"
-	"github.com/ThreeDotsLabs/watermill/components/metrics"
 	"github.com/ThreeDotsLabs/watermill/message"
 	nc "github.com/nats-io/nats.go"
-	prom "github.com/prometheus/client_golang/prometheus"
 )
 
-type NatsPublisher message.Publisher
-type NatsSubscriber message.Subscriber
-
-var (
-	TxPublisher  NatsPublisher
-	TxSubscriber NatsSubscriber
-)
-
-// NewNATSPublisher returns a NATS publisher for event streaming
-func NewNATSPublisher(bootCfg *bootstrap.BootstrapConfig, appCfg *config.ApplicationConfig) NatsPublisher {
-	var err error
-	natsUrl := fmt.Sprintf("nats://%s:%d", appCfg.NatsConfig.Host, appCfg.NatsConfig.Port)
-	options := []nc.Option{
-		nc.RetryOnFailedConnect(true),
-		nc.Timeout(30 * time.Second),
-		nc.ReconnectWait(1 * time.Second),
-	}
+// newNATSPublisher returns a NATS JetStream publisher
+func newNATSPublisher(appCfg *config.ApplicationConfig) (message.Publisher, error) {
 
 	jsConfig := nats.JetStreamConfig{
 		AutoProvision: true,
@@ -38,80 +23,183 @@ func NewNATSPublisher(bootCfg *bootstrap.BootstrapConfig, appCfg *config.Applica
 		Disabled:      false,
 	}
 
-	TxPublisher, err = nats.NewPublisher(
+	return nats.NewPublisher(
 		nats.PublisherConfig{
-			URL:         natsUrl,
-			NatsOptions: options,
+			URL:         natsURL(appCfg),
+			NatsOptions: natsOptions(),
 			Marshaler:   marshaler,
 			JetStream:   jsConfig,
 		},
 		logger,
 	)
+}
+
+// newNATSSubscriber returns a NATS JetStream subscriber whose consumers are
+// tuned by consumer
+func newNATSSubscriber(appCfg *config.ApplicationConfig, consumer config.Consumer) (message.Subscriber, error) {
+	conn, err := nc.Connect(natsURL(appCfg), natsOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &natsSubscriber{
+		appCfg:   appCfg,
+		consumer: consumer,
+		conn:     conn,
+		js:       js,
+	}, nil
+}
+
+// natsSubscriber subscribes each topic with a JetStream subscriber of its
+// own. JetStream refuses to subscribe to a durable consumer created with
+// another tuning, so the consumer of the topic is updated to the tuning
+// first.
+type natsSubscriber struct {
+	appCfg   *config.ApplicationConfig
+	consumer config.Consumer
+	conn     *nc.Conn
+	js       nc.JetStreamContext
+
+	mu          sync.Mutex
+	subscribers []message.Subscriber
+}
+
+func (s *natsSubscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	durable := s.appCfg.NatsConfig.NatsSubscriber.DurableName
+	existing, err := updateConsumer(s.js, topic, durable, s.consumer)
+	if err != nil {
+		return nil, fmt.Errorf("update consumer %s of %s: %w", durable, topic, err)
+	}
+
+	// the deliver policy of an existing consumer cannot change
+	subOpts, err := consumerSubOpts(s.consumer, !existing)
+	if err != nil {
+		return nil, err
+	}
+	subscriberConfig := nats.SubscriberConfig{
+		URL:         natsURL(s.appCfg),
+		NatsOptions: natsOptions(),
+		Unmarshaler: marshaler,
+		JetStream: nats.JetStreamConfig{
+			AutoProvision:    true,
+			AckAsync:         true,
+			TrackMsgId:       true,
+			Disabled:         false,
+			SubscribeOptions: subOpts,
+			DurablePrefix:    durable,
+		},
+		QueueGroupPrefix: s.appCfg.NatsConfig.NatsSubscriber.QueueGroup,
+	}
+	if s.consumer.AckWait > 0 {
+		// the subscriber nacks a message the handler did not ack in time
+		subscriberConfig.AckWaitTimeout = time.Duration(s.consumer.AckWait) * time.Second
+	}
+	sub, err := nats.NewSubscriber(subscriberConfig, logger)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	registry, ok := prom.DefaultRegisterer.(*prom.Registry)
-	if !ok {
-		panic("prometheus type casting error")
+	s.mu.Lock()
+	s.subscribers = append(s.subscribers, sub)
+	s.mu.Unlock()
+	return sub.Subscribe(ctx, topic)
+}
+
+func (s *natsSubscriber) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for _, sub := range s.subscribers {
+		errs = append(errs, sub.Close())
+	}
+	s.conn.Close()
+	return errors.Join(errs...)
+}
+
+// updateConsumer updates the durable consumer of the stream to the tuning,
+// the settings left out keep their value. It tells whether the consumer
+// exists, a missing one is created by the subscription.
+func updateConsumer(js nc.JetStreamContext, stream, durable string, consumer config.Consumer) (bool, error) {
+	if durable == "" {
+		return false, nil
+	}
+	info, err := js.ConsumerInfo(stream, durable)
+	if errors.Is(err, nc.ErrStreamNotFound) || errors.Is(err, nc.ErrConsumerNotFound) {
+		return false, nil
 	}
-	metricsBuilder := metrics.NewPrometheusMetricsBuilder(registry, bootCfg.Application, "pubsub")
-	TxPublisher, err = metricsBuilder.DecoratePublisher(TxPublisher)
 	if err != nil {
-		panic("prometheus decorate publisher")
+		return false, err
 	}
 
-	return TxPublisher
+	cfg, changed := retuneConsumer(info.Config, consumer)
+	if !changed {
+		return true, nil
+	}
+	_, err = js.UpdateConsumer(stream, &cfg)
+	return true, err
+}
+
+// retuneConsumer returns cfg with the settings of the tuning
+func retuneConsumer(cfg nc.ConsumerConfig, consumer config.Consumer) (nc.ConsumerConfig, bool) {
+	changed := false
+	if ackWait := time.Duration(consumer.AckWait) * time.Second; ackWait > 0 && cfg.AckWait != ackWait {
+		cfg.AckWait = ackWait
+		changed = true
+	}
+	if consumer.MaxDeliver > 0 && cfg.MaxDeliver != consumer.MaxDeliver {
+		cfg.MaxDeliver = consumer.MaxDeliver
+		changed = true
+	}
+	if consumer.MaxAckPending > 0 && cfg.MaxAckPending != consumer.MaxAckPending {
+		cfg.MaxAckPending = consumer.MaxAckPending
+		changed = true
+	}
+	return cfg, changed
 }
 
-// NewNATSSubscriber returns a NATS subscriber for event streaming
-func NewNATSSubscriber(bootCfg *bootstrap.BootstrapConfig, appCfg *config.ApplicationConfig) NatsSubscriber {
-	var err error
-	natsUrl := fmt.Sprintf("nats://%s:%d", appCfg.NatsConfig.Host, appCfg.NatsConfig.Port)
-	options := []nc.Option{
+func natsURL(appCfg *config.ApplicationConfig) string {
+	return fmt.Sprintf("nats://%s:%d", appCfg.NatsConfig.Host, appCfg.NatsConfig.Port)
+}
+
+func natsOptions() []nc.Option {
+	return []nc.Option{
 		nc.RetryOnFailedConnect(true),
 		nc.Timeout(30 * time.Second),
 		nc.ReconnectWait(1 * time.Second),
 	}
+}
 
-	subOpts := []nc.SubOpt{
-		// nc.DeliverAll(),
-		nc.DeliverNew(),
-		nc.AckExplicit(),
+// consumerSubOpts returns the options of a subscription tuned by consumer,
+// the deliver policy only applies to new consumers
+func consumerSubOpts(consumer config.Consumer, newConsumer bool) ([]nc.SubOpt, error) {
+	var deliver nc.SubOpt
+	switch consumer.DeliverPolicy {
+	case "all":
+		deliver = nc.DeliverAll()
+	case "", "new":
+		deliver = nc.DeliverNew()
+	case "last":
+		deliver = nc.DeliverLast()
+	default:
+		return nil, fmt.Errorf("unknown deliver policy: %s", consumer.DeliverPolicy)
 	}
 
-	jsConfig := nats.JetStreamConfig{
-		AutoProvision:    true,
-		AckAsync:         true,
-		TrackMsgId:       true,
-		Disabled:         false,
-		SubscribeOptions: subOpts,
-		DurablePrefix:    appCfg.NatsConfig.NatsSubscriber.DurableName,
-	}
-
-	TxSubscriber, err = nats.NewSubscriber(
-		nats.SubscriberConfig{
-			URL:              natsUrl,
-			NatsOptions:      options,
-			Unmarshaler:      marshaler,
-			JetStream:        jsConfig,
-			QueueGroupPrefix: appCfg.NatsConfig.NatsSubscriber.QueueGroup,
-		},
-		logger,
-	)
-	if err != nil {
-		panic(err)
+	subOpts := []nc.SubOpt{nc.AckExplicit()}
+	if newConsumer {
+		subOpts = append(subOpts, deliver)
 	}
-
-	registry, ok := prom.DefaultRegisterer.(*prom.Registry)
-	if !ok {
-		panic("prometheus type casting error")
+	if consumer.AckWait > 0 {
+		subOpts = append(subOpts, nc.AckWait(time.Duration(consumer.AckWait)*time.Second))
 	}
-	metricsBuilder := metrics.NewPrometheusMetricsBuilder(registry, bootCfg.Application, "pubsub")
-	TxSubscriber, err = metricsBuilder.DecorateSubscriber(TxSubscriber)
-	if err != nil {
-		panic("prometheus decorate publisher")
+	if consumer.MaxDeliver > 0 {
+		subOpts = append(subOpts, nc.MaxDeliver(consumer.MaxDeliver))
 	}
-
-	return TxSubscriber
+	if consumer.MaxAckPending > 0 {
+		subOpts = append(subOpts, nc.MaxAckPending(consumer.MaxAckPending))
+	}
+	return subOpts, nil
 }
@@ -0,0 +1,41 @@
+package broker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/config"
+	nc "github.com/nats-io/nats.go"
+)
+
+func TestRetuneConsumer(t *testing.T) {
+	current := nc.ConsumerConfig{Durable: "product", AckWait: 30 * time.Second, MaxDeliver: 10, MaxAckPending: 100}
+	tests := []struct {
+		name        string
+		consumer    config.Consumer
+		want        nc.ConsumerConfig
+		wantChanged bool
+	}{
+		{"unchanged", config.Consumer{AckWait: 30, MaxDeliver: 10}, current, false},
+		{"nothing set", config.Consumer{}, current, false},
+		{
+			name:        "retuned",
+			consumer:    config.Consumer{AckWait: 60, MaxAckPending: 50, DeliverPolicy: "new"},
+			want:        nc.ConsumerConfig{Durable: "product", AckWait: 60 * time.Second, MaxDeliver: 10, MaxAckPending: 50},
+			wantChanged: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := retuneConsumer(current, tt.consumer)
+			if changed != tt.wantChanged {
+				t.Errorf("retuneConsumer() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if got.Durable != tt.want.Durable || got.AckWait != tt.want.AckWait ||
+				got.MaxDeliver != tt.want.MaxDeliver || got.MaxAckPending != tt.want.MaxAckPending ||
+				got.DeliverPolicy != tt.want.DeliverPolicy {
+				t.Errorf("retuneConsumer() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
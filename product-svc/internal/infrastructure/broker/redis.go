@@ -3,9 +3,9 @@ package broker
 import (
 	"context"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/Chengxufeng1994/go-saga-example/common/bootstrap"
 	"github.com/Chengxufeng1994/go-saga-example/common/config"
 	"github.com/Chengxufeng1994/go-saga-example/common/event"
 	"github.com/ThreeDotsLabs/watermill-redisstream/pkg/redisstream"
@@ -23,85 +23,81 @@ const (
 	delimiter       = ","
 )
 
-type RedisPublisher struct {
-	redisClient    redis.UniversalClient
-	redisPublisher message.Publisher
-}
-
-func (rp RedisPublisher) GetPublisher() message.Publisher {
-	return rp.redisPublisher
-}
-
-func (rp RedisPublisher) GetRedisClient() redis.UniversalClient {
-	return rp.redisClient
-}
+var (
+	redisClient     redis.UniversalClient
+	redisClientOnce sync.Once
+)
 
-func NewRedisPublisher(bootCfg *bootstrap.BootstrapConfig, libconfig *config.ApplicationConfig) *RedisPublisher {
-	rp := &RedisPublisher{}
+// getRedisClient returns the redis client shared by the redis publishers
+// and subscribers of the process
+func getRedisClient(libconfig *config.ApplicationConfig) redis.UniversalClient {
+	redisClientOnce.Do(func() {
+		poolTimeout := time.Duration(libconfig.RedisConfig.PoolTimeout) * time.Second
+		client := redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:           getServerAddrs(libconfig.RedisConfig.Addrs),
+			Password:        libconfig.RedisConfig.Password,
+			PoolSize:        libconfig.RedisConfig.PoolSize,
+			PoolTimeout:     poolTimeout,
+			MaxRetries:      libconfig.RedisConfig.MaxRetries,
+			MinRetryBackoff: minRetryBackoff,
+			MaxRetryBackoff: maxRetryBackoff,
+			DialTimeout:     dialTimeout,
+			ReadTimeout:     readTimeout,
+			WriteTimeout:    writeTimeout,
+			// To route commands by latency or randomly, enable one of the following.
+			ReadOnly: libconfig.RedisConfig.ReadOnly,
+			// RouteRandomly:  true,
+			// RouteByLatency: true,
+		})
 
-	poolTimeout := time.Duration(libconfig.RedisConfig.PoolTimeout) * time.Second
-	RedisClient := redis.NewClusterClient(&redis.ClusterOptions{
-		Addrs:           getServerAddrs(libconfig.RedisConfig.Addrs),
-		Password:        libconfig.RedisConfig.Password,
-		PoolSize:        libconfig.RedisConfig.PoolSize,
-		PoolTimeout:     poolTimeout,
-		MaxRetries:      libconfig.RedisConfig.MaxRetries,
-		MinRetryBackoff: minRetryBackoff,
-		MaxRetryBackoff: maxRetryBackoff,
-		DialTimeout:     dialTimeout,
-		ReadTimeout:     readTimeout,
-		WriteTimeout:    writeTimeout,
-		// To route commands by latency or randomly, enable one of the following.
-		ReadOnly: libconfig.RedisConfig.ReadOnly,
-		// RouteRandomly:  true,
-		// RouteByLatency: true,
-	})
+		ctx := context.Background()
+		err := client.ForEachShard(ctx, func(ctx context.Context, shard *redis.Client) error {
+			return shard.Ping(ctx).Err()
+		})
+		if err == redis.Nil || err != nil {
+			panic(err)
+		}
 
-	ctx := context.Background()
-	err := RedisClient.ForEachShard(ctx, func(ctx context.Context, shard *redis.Client) error {
-		return shard.Ping(ctx).Err()
-	})
-	if err == redis.Nil || err != nil {
-		panic(err)
-	}
+		err = client.ForEachSlave(ctx, func(ctx context.Context, shard *redis.Client) error {
+			return shard.Ping(ctx).Err()
+		})
+		if err == redis.Nil || err != nil {
+			panic(err)
+		}
 
-	err = RedisClient.ForEachSlave(ctx, func(ctx context.Context, shard *redis.Client) error {
-		return shard.Ping(ctx).Err()
+		redisotel.InstrumentTracing(client)
+		redisClient = client
 	})
-	if err == redis.Nil || err != nil {
-		panic(err)
-	}
-
-	rp.redisClient = RedisClient
-	redisotel.InstrumentTracing(rp.redisClient)
+	return redisClient
+}
 
+// newRedisPublisher returns a Redis Streams publisher
+func newRedisPublisher(libconfig *config.ApplicationConfig) (message.Publisher, error) {
 	// TODO: purchaseResultTopic mas lens in configuration
 	publisherConfig := redisstream.PublisherConfig{
-		Client:     rp.redisClient,
+		Client:     getRedisClient(libconfig),
 		Marshaller: &redisstream.DefaultMarshallerUnmarshaller{},
 		Maxlens: map[string]int64{
 			event.PurchaseResultTopic: 5000,
 		},
 	}
-	rp.redisPublisher, err = redisstream.NewPublisher(publisherConfig, logger)
-	if err != nil {
-		panic("failed to new redis publisher")
-	}
-
-	// registry, ok := prom.DefaultRegisterer.(*prom.Registry)
-	// if !ok {
-	// 	panic("failed to prometheus type casting")
-	// }
-	// metricsBuilder := metrics.NewPrometheusMetricsBuilder(registry, bootCfg.Application, "pubsub")
-	// pub, err := metricsBuilder.DecoratePublisher(rp.redisPublisher)
-	// if err != nil {
-	// 	panic("failed to decorate publisher")
-	// }
-	// rp.redisPublisher = pub
+	return redisstream.NewPublisher(publisherConfig, logger)
+}
 
-	return rp
+// newRedisSubscriber returns a Redis Streams subscriber of the configured
+// consumer group
+func newRedisSubscriber(libconfig *config.ApplicationConfig) (message.Subscriber, error) {
+	return redisstream.NewSubscriber(
+		redisstream.SubscriberConfig{
+			Client:        getRedisClient(libconfig),
+			Unmarshaller:  redisstream.DefaultMarshallerUnmarshaller{},
+			Consumer:      libconfig.RedisConfig.Subscriber.ConsumerID,
+			ConsumerGroup: libconfig.RedisConfig.Subscriber.ConsumerGroup,
+		},
+		logger,
+	)
 }
 
 func getServerAddrs(addrs string) []string {
-	return strings.Split(addrs, ",")
+	return strings.Split(addrs, delimiter)
 }
@@ -0,0 +1,138 @@
+package broker
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/bootstrap"
+	"github.com/Chengxufeng1994/go-saga-example/common/bus"
+	"github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/event"
+	"github.com/ThreeDotsLabs/watermill/components/metrics"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// NatsPublisher and NatsSubscriber carry the saga topic group. They are
+// backed by NATS unless the messaging config selects another backend.
+type NatsPublisher message.Publisher
+type NatsSubscriber message.Subscriber
+
+// ResultPublisher carries the result topic group, Redis Streams by default
+type ResultPublisher message.Publisher
+
+var (
+	TxPublisher  NatsPublisher
+	TxSubscriber NatsSubscriber
+
+	goChannel     *gochannel.GoChannel
+	goChannelOnce sync.Once
+)
+
+// NewTxPublisher returns the publisher of the saga topic group
+func NewTxPublisher(bootCfg *bootstrap.BootstrapConfig, appCfg *config.ApplicationConfig) NatsPublisher {
+	pub, err := newPublisher(appCfg, appCfg.MessagingConfig.Backend(event.SagaTopicGroup, config.BackendNATS))
+	if err != nil {
+		panic(err)
+	}
+	pub = bus.NewPartitionedPublisher(pub, newPartitioner(appCfg))
+
+	metricsBuilder := newMetricsBuilder(bootCfg)
+	TxPublisher, err = metricsBuilder.DecoratePublisher(pub)
+	if err != nil {
+		panic("prometheus decorate publisher")
+	}
+
+	return TxPublisher
+}
+
+// NewTxSubscriber returns the subscriber of the saga topic group
+func NewTxSubscriber(bootCfg *bootstrap.BootstrapConfig, appCfg *config.ApplicationConfig) NatsSubscriber {
+	TxSubscriber = newTxSubscriber(bootCfg, appCfg, handlerConfig(appCfg, "").Consumer)
+	return TxSubscriber
+}
+
+// newTxSubscriber returns a subscriber of the saga topic group, the
+// consumer tuning only applies to NATS
+func newTxSubscriber(bootCfg *bootstrap.BootstrapConfig, appCfg *config.ApplicationConfig, consumer config.Consumer) NatsSubscriber {
+	sub, err := newSubscriber(appCfg, appCfg.MessagingConfig.Backend(event.SagaTopicGroup, config.BackendNATS), consumer)
+	if err != nil {
+		panic(err)
+	}
+	sub = bus.NewPartitionedSubscriber(sub, newPartitioner(appCfg))
+
+	metricsBuilder := newMetricsBuilder(bootCfg)
+	decorated, err := metricsBuilder.DecorateSubscriber(sub)
+	if err != nil {
+		panic("prometheus decorate subscriber")
+	}
+
+	return decorated
+}
+
+// NewResultPublisher returns the publisher of the result topic group
+func NewResultPublisher(bootCfg *bootstrap.BootstrapConfig, appCfg *config.ApplicationConfig) ResultPublisher {
+	pub, err := newPublisher(appCfg, appCfg.MessagingConfig.Backend(event.ResultTopicGroup, config.BackendRedis))
+	if err != nil {
+		panic(err)
+	}
+	return pub
+}
+
+func newPublisher(appCfg *config.ApplicationConfig, backend string) (message.Publisher, error) {
+	switch backend {
+	case config.BackendNATS:
+		return newNATSPublisher(appCfg)
+	case config.BackendRedis:
+		return newRedisPublisher(appCfg)
+	case config.BackendKafka:
+		return newKafkaPublisher(appCfg)
+	case config.BackendGoChannel:
+		return getGoChannel(), nil
+	default:
+		return nil, fmt.Errorf("unknown broker backend: %s", backend)
+	}
+}
+
+func newSubscriber(appCfg *config.ApplicationConfig, backend string, consumer config.Consumer) (message.Subscriber, error) {
+	switch backend {
+	case config.BackendNATS:
+		return newNATSSubscriber(appCfg, consumer)
+	case config.BackendRedis:
+		return newRedisSubscriber(appCfg)
+	case config.BackendKafka:
+		return newKafkaSubscriber(appCfg)
+	case config.BackendGoChannel:
+		return getGoChannel(), nil
+	default:
+		return nil, fmt.Errorf("unknown broker backend: %s", backend)
+	}
+}
+
+// newPartitioner returns the partitioner of the configured routers, the saga
+// topics of a partitioned router are split into partition topics
+func newPartitioner(appCfg *config.ApplicationConfig) bus.Partitioner {
+	partitioner, err := bus.NewPartitioner(appCfg.MessagingConfig.Partitioning)
+	if err != nil {
+		panic(err)
+	}
+	return partitioner
+}
+
+// getGoChannel returns the in memory pub/sub of the process, it only
+// reaches the routers running in the same process
+func getGoChannel() *gochannel.GoChannel {
+	goChannelOnce.Do(func() {
+		goChannel = gochannel.NewGoChannel(gochannel.Config{}, logger)
+	})
+	return goChannel
+}
+
+func newMetricsBuilder(bootCfg *bootstrap.BootstrapConfig) metrics.PrometheusMetricsBuilder {
+	registry, ok := prom.DefaultRegisterer.(*prom.Registry)
+	if !ok {
+		panic("prometheus type casting error")
+	}
+	return metrics.NewPrometheusMetricsBuilder(registry, bootCfg.Application, "pubsub")
+}
@@ -0,0 +1,61 @@
+package broker
+
+import (
+	"testing"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/event"
+)
+
+func TestTopicGroupBackend(t *testing.T) {
+	tests := []struct {
+		name       string
+		transports map[string]string
+		group      string
+		fallback   string
+		want       string
+		wantErr    bool
+	}{
+		{"saga on gochannel", map[string]string{event.SagaTopicGroup: config.BackendGoChannel}, event.SagaTopicGroup, config.BackendNATS, config.BackendGoChannel, false},
+		{"result on gochannel", map[string]string{event.ResultTopicGroup: config.BackendGoChannel}, event.ResultTopicGroup, config.BackendRedis, config.BackendGoChannel, false},
+		{"default of an unlisted group", map[string]string{event.ResultTopicGroup: config.BackendGoChannel}, event.SagaTopicGroup, config.BackendNATS, config.BackendNATS, false},
+		{"unknown backend", map[string]string{event.SagaTopicGroup: "rabbitmq"}, event.SagaTopicGroup, config.BackendNATS, "rabbitmq", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appCfg := &config.ApplicationConfig{MessagingConfig: config.Messaging{Transports: tt.transports}}
+			backend := appCfg.MessagingConfig.Backend(tt.group, tt.fallback)
+			if backend != tt.want {
+				t.Fatalf("Backend() = %q, want %q", backend, tt.want)
+			}
+			// only the in memory backend is built, the others need a broker
+			if backend != config.BackendGoChannel && !tt.wantErr {
+				return
+			}
+
+			pub, err := newPublisher(appCfg, backend)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newPublisher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			sub, err := newSubscriber(appCfg, backend, config.Consumer{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newSubscriber() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (pub != getGoChannel() || sub != getGoChannel()) {
+				t.Errorf("backend %q is not the in memory pub/sub of the process", backend)
+			}
+		})
+	}
+}
+
+func TestNewResultPublisherUnknownBackend(t *testing.T) {
+	appCfg := &config.ApplicationConfig{MessagingConfig: config.Messaging{
+		Transports: map[string]string{event.ResultTopicGroup: "rabbitmq"},
+	}}
+	defer func() {
+		if recover() == nil {
+			t.Error("NewResultPublisher() with an unknown backend did not panic")
+		}
+	}()
+	NewResultPublisher(nil, appCfg)
+}
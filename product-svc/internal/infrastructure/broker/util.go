@@ -1,37 +1,33 @@
 package broker
 
 import (
-	"context"
-	"encoding/json"
-	"fmt"
-
-	"github.com/Chengxufeng1994/go-saga-example/common/constant"
+	"github.com/Chengxufeng1994/go-saga-example/common/codec"
+	"github.com/Chengxufeng1994/go-saga-example/common/codec/natscodec"
+	"github.com/Chengxufeng1994/go-saga-example/common/config"
 	"github.com/Chengxufeng1994/go-saga-example/common/pb"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	domainevent "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
 	"github.com/ThreeDotsLabs/watermill"
-	"github.com/ThreeDotsLabs/watermill-nats/v2/pkg/nats"
-	"github.com/ThreeDotsLabs/watermill/message"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/trace"
 )
 
 var (
 	logger    = watermill.NewStdLogger(true, false)
-	marshaler = &nats.GobMarshaler{}
-
-	TraceContext        = propagation.TraceContext{}
-	TraceparentHeader   = TraceContext.Fields()[0]
-	W3CSupportedVersion = 0
+	marshaler = natscodec.NewMarshaler()
 )
 
-// decode createPurchasedCommand to entity.Purchase
-func DecodeCreatePurchaseCommand(payload message.Payload) (*entity.Purchase, *pb.Purchase, error) {
-	var cmd pb.CreatePurchaseCommand
-	if err := json.Unmarshal(payload, &cmd); err != nil {
-		return nil, nil, err
+// NewCodec returns the codec of the published messages, every publisher of
+// the process encodes with it
+func NewCodec(appCfg *config.ApplicationConfig) codec.Codec {
+	c, err := codec.New(appCfg.MessagingConfig.Codec)
+	if err != nil {
+		panic(err)
 	}
+	return c
+}
 
+// decode createPurchasedCommand to entity.Purchase
+func DecodeCreatePurchaseCommand(cmd *pb.CreatePurchaseCommand) *entity.Purchase {
 	var purchasedItems []valueobject.PurchasedItem
 	for _, item := range cmd.Purchase.Order.PurchasedItems {
 		purchasedItems = append(purchasedItems, valueobject.PurchasedItem{
@@ -49,31 +45,28 @@ func DecodeCreatePurchaseCommand(payload message.Payload) (*entity.Purchase, *pb
 			PurchasedItems: &purchasedItems,
 		},
 		Payment: &entity.Payment{
-			ID:           purchaseID,
-			UserID:       cmd.Purchase.Order.UserId,
-			CurrencyCode: cmd.Purchase.Payment.CurrencyCode,
-			Amount:       cmd.Purchase.Payment.Amount,
+			ID:                   purchaseID,
+			UserID:               cmd.Purchase.Order.UserId,
+			CurrencyCode:         cmd.Purchase.Payment.CurrencyCode,
+			Amount:               cmd.Purchase.Payment.Amount,
+			CardToken:            cmd.Purchase.Payment.CardToken,
+			OriginalCurrencyCode: cmd.Purchase.Payment.OriginalCurrencyCode,
+			OriginalAmount:       cmd.Purchase.Payment.Amount,
 		},
 	}
 
-	return &ent, cmd.Purchase, nil
-}
-
-// SetSpanContext set span context to the message
-func SetSpanContext(ctx context.Context, msg *message.Message) {
-	msg.Metadata.Set(string(constant.CtxSpanKey), spanContextToW3C(ctx))
+	return &ent
 }
 
-func spanContextToW3C(ctx context.Context) string {
-	sc := trace.SpanContextFromContext(ctx)
-	if !sc.IsValid() {
-		return ""
+// decode PaymentStatusChanged to event.PaymentStatusChangedEvent
+func DecodePaymentStatusChanged(evt *pb.PaymentStatusChanged) *domainevent.PaymentStatusChangedEvent {
+	return &domainevent.PaymentStatusChangedEvent{
+		UserID:     evt.UserId,
+		PurchaseID: evt.PurchaseId,
+		Status:     evt.Status,
+		Provider:   evt.Provider,
+		EventID:    evt.EventId,
+		EventType:  evt.EventType,
+		Timestamp:  evt.Timestamp.AsTime(),
 	}
-	// Clear all flags other than the trace-context supported sampling bit.
-	flags := sc.TraceFlags() & trace.FlagsSampled
-	return fmt.Sprintf("%.2x-%s-%s-%s",
-		W3CSupportedVersion,
-		sc.TraceID(),
-		sc.SpanID(),
-		flags)
 }
@@ -0,0 +1,43 @@
+package eventstore
+
+import (
+	"time"
+
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
+	libeventstore "github.com/Chengxufeng1994/go-saga-example/common/eventstore"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/adapter/projection"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPollInterval = 1000
+	defaultBatchSize    = 100
+)
+
+func NewEventStore(db *gorm.DB) *libeventstore.PostgresStore {
+	return libeventstore.NewPostgresStore(db)
+}
+
+// NewProjector returns the projector running the read models of the
+// orchestrator
+func NewProjector(appCfg *libconfig.ApplicationConfig, store *libeventstore.PostgresStore, purchaseSagaView *projection.PurchaseSagaView) *libeventstore.Projector {
+	cfg := appCfg.SagaConfig.EventStore
+	pollInterval, batchSize := cfg.PollInterval, cfg.BatchSize
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	projector := libeventstore.NewProjector(
+		store,
+		config.ContextLogger.WithFields(logrus.Fields{"type": "eventstore:Projector"}),
+		time.Duration(pollInterval)*time.Millisecond,
+		batchSize,
+	)
+	projector.Register(purchaseSagaView)
+	return projector
+}
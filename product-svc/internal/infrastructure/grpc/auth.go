@@ -0,0 +1,91 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/common/constant"
+	"github.com/Chengxufeng1994/go-saga-example/common/rbac"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/usecase"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Authenticator authenticates grpc calls with a bearer token or an api key,
+// like the JwtAuthenticator of the http servers
+type Authenticator struct {
+	logger      *log.Entry
+	authService usecase.AuthUseCase
+}
+
+func NewAuthenticator(authService usecase.AuthUseCase) *Authenticator {
+	return &Authenticator{
+		logger: config.ContextLogger.WithFields(log.Fields{
+			"type": "interceptor:Authenticator",
+		}),
+		authService: authService,
+	}
+}
+
+// RequirePermission authenticates the caller and rejects calls lacking the
+// permission of their method, methods missing from permissions are rejected
+func (a *Authenticator) RequirePermission(permissions map[string]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		verifyTokenResponse, err := a.verify(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		permission, ok := permissions[info.FullMethod]
+		if !ok || !rbac.HasPermission(verifyTokenResponse.Permissions, permission) {
+			return nil, status.Error(codes.PermissionDenied, "permission denied")
+		}
+
+		ctx = context.WithValue(ctx, constant.CtxUserKey, verifyTokenResponse.UserId)
+		ctx = context.WithValue(ctx, constant.CtxRolesKey, verifyTokenResponse.Roles)
+		ctx = context.WithValue(ctx, constant.CtxPermissionsKey, verifyTokenResponse.Permissions)
+		return handler(ctx, req)
+	}
+}
+
+// verify verifies the api key or else the bearer token of the call
+func (a *Authenticator) verify(ctx context.Context) (*dto.VerifyTokenResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	var (
+		verifyTokenResponse *dto.VerifyTokenResponse
+		err                 error
+	)
+	if apiKey := first(md.Get(constant.APIKeyHeader)); apiKey != "" {
+		if verifyTokenResponse, err = a.authService.VerifyAPIKey(ctx, apiKey); err != nil {
+			a.logger.WithError(err).Error("verify api key")
+			return nil, status.Error(codes.Unauthenticated, "invalid api key")
+		}
+	} else {
+		accessToken, ok := strings.CutPrefix(first(md.Get("authorization")), "Bearer ")
+		if !ok || accessToken == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+		}
+		if verifyTokenResponse, err = a.authService.VerifyToken(ctx, accessToken); err != nil {
+			a.logger.WithError(err).Error("verify token")
+			return nil, status.Error(codes.Unauthenticated, "invalid token")
+		}
+	}
+
+	if verifyTokenResponse.IsExpired {
+		return nil, status.Error(codes.Unauthenticated, "expired token")
+	}
+	return verifyTokenResponse, nil
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,78 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Chengxufeng1994/go-saga-example/auth-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/common/rbac"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/config"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeAuthService grants the permissions of its tokens and api keys
+type fakeAuthService struct {
+	tokens map[string]*dto.VerifyTokenResponse
+}
+
+func (f fakeAuthService) VerifyToken(ctx context.Context, token string) (*dto.VerifyTokenResponse, error) {
+	if resp, ok := f.tokens[token]; ok {
+		return resp, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+func (f fakeAuthService) VerifyAPIKey(ctx context.Context, apiKey string) (*dto.VerifyTokenResponse, error) {
+	return f.VerifyToken(ctx, apiKey)
+}
+
+func TestRequirePermission(t *testing.T) {
+	config.ContextLogger = logrus.NewEntry(logrus.New())
+	authenticator := NewAuthenticator(fakeAuthService{tokens: map[string]*dto.VerifyTokenResponse{
+		"operator": {UserId: 1, Permissions: []string{rbac.PermissionSagaRead, rbac.PermissionSagaManage}},
+		"support":  {UserId: 2, Permissions: []string{rbac.PermissionSagaRead}},
+		"expired":  {UserId: 1, Permissions: []string{rbac.PermissionSagaManage}, IsExpired: true},
+	}})
+	interceptor := authenticator.RequirePermission(map[string]string{
+		"/saga.SagaAdminService/RetrySaga": rbac.PermissionSagaManage,
+	})
+
+	tests := []struct {
+		name   string
+		md     metadata.MD
+		method string
+		want   codes.Code
+	}{
+		{"bearer token", metadata.Pairs("authorization", "Bearer operator"), "/saga.SagaAdminService/RetrySaga", codes.OK},
+		{"api key", metadata.Pairs("x-api-key", "operator"), "/saga.SagaAdminService/RetrySaga", codes.OK},
+		{"no token", metadata.MD{}, "/saga.SagaAdminService/RetrySaga", codes.Unauthenticated},
+		{"not a bearer token", metadata.Pairs("authorization", "operator"), "/saga.SagaAdminService/RetrySaga", codes.Unauthenticated},
+		{"invalid token", metadata.Pairs("authorization", "Bearer forged"), "/saga.SagaAdminService/RetrySaga", codes.Unauthenticated},
+		{"invalid api key", metadata.Pairs("x-api-key", "forged"), "/saga.SagaAdminService/RetrySaga", codes.Unauthenticated},
+		{"expired token", metadata.Pairs("authorization", "Bearer expired"), "/saga.SagaAdminService/RetrySaga", codes.Unauthenticated},
+		{"missing permission", metadata.Pairs("authorization", "Bearer support"), "/saga.SagaAdminService/RetrySaga", codes.PermissionDenied},
+		{"unknown method", metadata.Pairs("authorization", "Bearer operator"), "/saga.SagaAdminService/DropSaga", codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				return nil, nil
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("interceptor() code = %v, want %v", got, tt.want)
+			}
+			if called != (tt.want == codes.OK) {
+				t.Errorf("handler called = %v, want %v", called, tt.want == codes.OK)
+			}
+		})
+	}
+}
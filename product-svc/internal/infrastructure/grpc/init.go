@@ -17,7 +17,9 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-func InitializeServer(logrusEntry *log.Entry) *grpc.Server {
+// InitializeServer returns a grpc server, the unary interceptors run after
+// the logging and tracing ones
+func InitializeServer(logrusEntry *log.Entry, interceptors ...grpc.UnaryServerInterceptor) *grpc.Server {
 	opts := []grpc.ServerOption{
 		grpc.MaxRecvMsgSize(1024 * 1024 * 8), // increase to 8 MB (default: 4 MB)
 		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
@@ -56,14 +58,14 @@ func InitializeServer(logrusEntry *log.Entry) *grpc.Server {
 			grpc_logrus.StreamServerInterceptor(logrusEntry, grpcOpts...),
 			grpc_recovery.StreamServerInterceptor(recoveryOpts...),
 		)),
-		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
+		grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(append([]grpc.UnaryServerInterceptor{
 			grpc_prometheus.UnaryServerInterceptor,
 			otelgrpc.UnaryServerInterceptor(),
 			grpc_ctxtags.UnaryServerInterceptor(grpc_ctxtags.WithFieldExtractor(grpc_ctxtags.CodeGenRequestFieldExtractor)),
 			grpc_logrus.UnaryServerInterceptor(logrusEntry, grpcOpts...),
 			LogTraceUnary(),
 			grpc_recovery.UnaryServerInterceptor(recoveryOpts...),
-		)),
+		}, interceptors...)...)),
 	)
 	return grpc.NewServer(opts...)
 }
@@ -0,0 +1,258 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/bootstrap"
+	"github.com/Chengxufeng1994/go-saga-example/common/pb"
+	"github.com/Chengxufeng1994/go-saga-example/common/rbac"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/dto"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	infragrpc "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/grpc"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/repository"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/usecase"
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const defaultListLimit = 50
+
+// methodPermissions are the permissions the admin api requires by method
+var methodPermissions = map[string]string{
+	pb.SagaAdminService_ListSagas_FullMethodName:       rbac.PermissionSagaManage,
+	pb.SagaAdminService_GetSaga_FullMethodName:         rbac.PermissionSagaManage,
+	pb.SagaAdminService_GetSagaTimeline_FullMethodName: rbac.PermissionSagaRead,
+	pb.SagaAdminService_RetrySaga_FullMethodName:       rbac.PermissionSagaManage,
+	pb.SagaAdminService_CompensateSaga_FullMethodName:  rbac.PermissionSagaManage,
+	pb.SagaAdminService_ResolveSaga_FullMethodName:     rbac.PermissionSagaManage,
+}
+
+// GrpcSagaAdminServer serves the admin api of the orchestrator
+type GrpcSagaAdminServer struct {
+	application         string
+	bootstrapConfig     *bootstrap.BootstrapConfig
+	orchestratorService usecase.OrchestratorUseCase
+	srv                 *grpc.Server
+	pb.UnimplementedSagaAdminServiceServer
+}
+
+func NewGrpcSagaAdminServer(bootCfg *bootstrap.BootstrapConfig, orchestratorService usecase.OrchestratorUseCase, authenticator *infragrpc.Authenticator) *GrpcSagaAdminServer {
+	grpcSagaAdminSrv := &GrpcSagaAdminServer{
+		application:         bootCfg.Application,
+		bootstrapConfig:     bootCfg,
+		orchestratorService: orchestratorService,
+	}
+
+	grpcSagaAdminSrv.srv = infragrpc.InitializeServer(config.ContextLogger, authenticator.RequirePermission(methodPermissions))
+	pb.RegisterSagaAdminServiceServer(grpcSagaAdminSrv.srv, grpcSagaAdminSrv)
+
+	grpc_prometheus.Register(grpcSagaAdminSrv.srv)
+	reflection.Register(grpcSagaAdminSrv.srv)
+	return grpcSagaAdminSrv
+}
+
+// ListSagas implements pb.SagaAdminServiceServer.
+func (s *GrpcSagaAdminServer) ListSagas(ctx context.Context, req *pb.ListSagasRequest) (*pb.ListSagasResponse, error) {
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	sagas, err := s.orchestratorService.ListSagas(ctx, entity.SagaStatus(req.Status), limit)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	pbSagas := make([]*pb.Saga, 0, len(sagas))
+	for _, saga := range sagas {
+		pbSagas = append(pbSagas, encodeSaga(saga))
+	}
+	return &pb.ListSagasResponse{
+		Sagas: pbSagas,
+	}, nil
+}
+
+// GetSaga implements pb.SagaAdminServiceServer.
+func (s *GrpcSagaAdminServer) GetSaga(ctx context.Context, req *pb.GetSagaRequest) (*pb.GetSagaResponse, error) {
+	saga, events, err := s.orchestratorService.GetSaga(ctx, req.PurchaseId)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	pbEvents := make([]*pb.SagaEvent, 0, len(events))
+	for _, evt := range events {
+		pbEvents = append(pbEvents, &pb.SagaEvent{
+			Id:          evt.ID,
+			Kind:        string(evt.Kind),
+			Step:        evt.Step,
+			Status:      evt.Status,
+			Topic:       evt.Topic,
+			Handler:     evt.Handler,
+			MessageUuid: evt.MessageUUID,
+			Payload:     string(evt.Payload),
+			Error:       evt.Error,
+			TraceId:     evt.TraceID,
+			CreatedAt:   timestamppb.New(evt.CreatedAt),
+		})
+	}
+	return &pb.GetSagaResponse{
+		Saga:   encodeSaga(saga),
+		Events: pbEvents,
+	}, nil
+}
+
+// GetSagaTimeline implements pb.SagaAdminServiceServer.
+func (s *GrpcSagaAdminServer) GetSagaTimeline(ctx context.Context, req *pb.GetSagaTimelineRequest) (*pb.SagaTimeline, error) {
+	timeline, err := s.orchestratorService.GetSagaTimeline(ctx, req.PurchaseId)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+
+	steps := make([]*pb.SagaStep, 0, len(timeline.Steps))
+	for _, step := range timeline.Steps {
+		attempts := make([]*pb.SagaAttempt, 0, len(step.Attempts))
+		for _, attempt := range step.Attempts {
+			attempts = append(attempts, &pb.SagaAttempt{
+				Command: encodeSagaMessage(attempt.Command),
+				Reply:   encodeSagaMessage(attempt.Reply),
+			})
+		}
+		results := make([]*pb.SagaResult, 0, len(step.Results))
+		for _, result := range step.Results {
+			results = append(results, &pb.SagaResult{
+				Status:    result.Status,
+				Error:     result.Error,
+				TraceId:   result.TraceID,
+				Timestamp: timestamppb.New(result.Timestamp),
+			})
+		}
+		steps = append(steps, &pb.SagaStep{
+			Step:     step.Step,
+			Status:   step.Status,
+			Retries:  int32(step.Retries),
+			Attempts: attempts,
+			Results:  results,
+		})
+	}
+	actions := make([]*pb.SagaAction, 0, len(timeline.Actions))
+	for _, action := range timeline.Actions {
+		actions = append(actions, &pb.SagaAction{
+			Action:    action.Action,
+			Step:      action.Step,
+			Topic:     action.Topic,
+			Payload:   string(action.Payload),
+			Timestamp: timestamppb.New(action.Timestamp),
+		})
+	}
+
+	return &pb.SagaTimeline{
+		Saga: &pb.Saga{
+			PurchaseId:    timeline.PurchaseID,
+			UserId:        timeline.UserID,
+			CorrelationId: timeline.CorrelationID,
+			Status:        timeline.Status,
+			Step:          timeline.Step,
+			CreatedAt:     timestamppb.New(timeline.StartedAt),
+			UpdatedAt:     timestamppb.New(timeline.UpdatedAt),
+		},
+		Steps:   steps,
+		Actions: actions,
+	}, nil
+}
+
+// RetrySaga implements pb.SagaAdminServiceServer.
+func (s *GrpcSagaAdminServer) RetrySaga(ctx context.Context, req *pb.RetrySagaRequest) (*pb.RetrySagaResponse, error) {
+	if err := s.orchestratorService.RetrySaga(ctx, req.PurchaseId); err != nil {
+		return nil, toStatusError(err)
+	}
+	return &pb.RetrySagaResponse{}, nil
+}
+
+// CompensateSaga implements pb.SagaAdminServiceServer.
+func (s *GrpcSagaAdminServer) CompensateSaga(ctx context.Context, req *pb.CompensateSagaRequest) (*pb.CompensateSagaResponse, error) {
+	if err := s.orchestratorService.CompensateSaga(ctx, req.PurchaseId); err != nil {
+		return nil, toStatusError(err)
+	}
+	return &pb.CompensateSagaResponse{}, nil
+}
+
+// ResolveSaga implements pb.SagaAdminServiceServer.
+func (s *GrpcSagaAdminServer) ResolveSaga(ctx context.Context, req *pb.ResolveSagaRequest) (*pb.ResolveSagaResponse, error) {
+	if err := s.orchestratorService.ResolveSaga(ctx, req.PurchaseId, req.Note); err != nil {
+		return nil, toStatusError(err)
+	}
+	return &pb.ResolveSagaResponse{}, nil
+}
+
+func (s *GrpcSagaAdminServer) Run() error {
+	// the admin api listens on the loopback interface unless a host is configured
+	host := s.bootstrapConfig.Grpc.Host
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	addr := fmt.Sprintf("%s:%d", host, s.bootstrapConfig.Grpc.Port)
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	config.ContextLogger.Infoln("grpc.Run listening on", s.bootstrapConfig.Grpc.Port)
+	if err := s.srv.Serve(lis); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *GrpcSagaAdminServer) GracefulShutdown(ctx context.Context) {
+	config.ContextLogger.Infoln("grpc.GracefulShutdown")
+	s.srv.GracefulStop()
+}
+
+func encodeSaga(saga *entity.Saga) *pb.Saga {
+	return &pb.Saga{
+		PurchaseId:    saga.PurchaseID,
+		UserId:        saga.UserID,
+		CorrelationId: saga.CorrelationID,
+		Status:        string(saga.Status),
+		Step:          saga.Step,
+		CreatedAt:     timestamppb.New(saga.CreatedAt),
+		UpdatedAt:     timestamppb.New(saga.UpdatedAt),
+	}
+}
+
+func encodeSagaMessage(msg *dto.SagaMessage) *pb.SagaMessage {
+	if msg == nil {
+		return nil
+	}
+	return &pb.SagaMessage{
+		Topic:       msg.Topic,
+		Handler:     msg.Handler,
+		MessageUuid: msg.MessageUUID,
+		Status:      msg.Status,
+		Payload:     string(msg.Payload),
+		Error:       msg.Error,
+		TraceId:     msg.TraceID,
+		Timestamp:   timestamppb.New(msg.Timestamp),
+	}
+}
+
+func toStatusError(err error) error {
+	var notFound *repository.ErrNotFound
+	switch {
+	case errors.As(err, &notFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, usecase.ErrSagaResolved), errors.Is(err, usecase.ErrSagaSucceeded), errors.Is(err, usecase.ErrSagaNoCommand),
+		errors.Is(err, usecase.ErrSagaNotRunning), errors.Is(err, usecase.ErrSagaCommandReplied), errors.Is(err, usecase.ErrSagaChoreographed):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Errorf(codes.Internal, "internal error: %v", err)
+	}
+}
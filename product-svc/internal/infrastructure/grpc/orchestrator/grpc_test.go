@@ -0,0 +1,25 @@
+package orchestrator
+
+import (
+	"testing"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/pb"
+	"github.com/Chengxufeng1994/go-saga-example/common/rbac"
+)
+
+func TestMethodPermissions(t *testing.T) {
+	want := map[string]string{
+		"ListSagas":       rbac.PermissionSagaManage,
+		"GetSaga":         rbac.PermissionSagaManage,
+		"GetSagaTimeline": rbac.PermissionSagaRead,
+		"RetrySaga":       rbac.PermissionSagaManage,
+		"CompensateSaga":  rbac.PermissionSagaManage,
+		"ResolveSaga":     rbac.PermissionSagaManage,
+	}
+	for _, method := range pb.SagaAdminService_ServiceDesc.Methods {
+		fullMethod := "/" + pb.SagaAdminService_ServiceDesc.ServiceName + "/" + method.MethodName
+		if got := methodPermissions[fullMethod]; got != want[method.MethodName] {
+			t.Errorf("%s requires %q, want %q", fullMethod, got, want[method.MethodName])
+		}
+	}
+}
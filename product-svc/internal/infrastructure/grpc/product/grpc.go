@@ -66,9 +66,10 @@ func (s *GrpcProductServer) CheckProducts(ctx context.Context, req *pb.CheckProd
 	pbStatues := make([]*pb.ProductStatus, 0, len(productStatues))
 	for _, status := range productStatues {
 		pbStatues = append(pbStatues, &pb.ProductStatus{
-			ProductId: status.ProductID,
-			Price:     status.Price,
-			Status:    getPbProductStatus(status.Status),
+			ProductId:    status.ProductID,
+			Price:        status.Price,
+			Status:       getPbProductStatus(status.Status),
+			CurrencyCode: status.CurrencyCode,
 		})
 	}
 
@@ -90,12 +91,13 @@ func (s *GrpcProductServer) GetProducts(ctx context.Context, req *pb.GetProducts
 	var products []*pb.Product
 	for _, product := range *result {
 		products = append(products, &pb.Product{
-			ProductId:   product.ID,
-			ProductName: product.Name,
-			Description: product.Description,
-			BrandName:   product.BrandName,
-			Inventory:   product.Inventory,
-			Price:       product.Price,
+			ProductId:    product.ID,
+			ProductName:  product.Name,
+			Description:  product.Description,
+			BrandName:    product.BrandName,
+			Inventory:    product.Inventory,
+			Price:        product.Price,
+			CurrencyCode: product.CurrencyCode,
 		})
 	}
 	return &pb.GetProductsResponse{
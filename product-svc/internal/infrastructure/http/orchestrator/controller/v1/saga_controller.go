@@ -0,0 +1,68 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/response"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/config"
+	infrahttp "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/repository"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/usecase"
+	"github.com/gin-gonic/gin"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type SagaController struct {
+	logger              *log.Entry
+	orchestratorService usecase.OrchestratorUseCase
+}
+
+func NewSagaController(orchestratorService usecase.OrchestratorUseCase) *SagaController {
+	return &SagaController{
+		logger: config.ContextLogger.WithFields(log.Fields{
+			"type": "controller:SagaController",
+		}),
+		orchestratorService: orchestratorService,
+	}
+}
+
+// GetSagaTimeline shows what happened to the saga of a purchase, step by step
+func (h *SagaController) GetSagaTimeline(c *gin.Context) {
+	purchaseID, err := strconv.ParseUint(c.Param("purchaseId"), 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("strconv parse uint")
+		c.AbortWithStatusJSON(http.StatusBadRequest, response.ErrorResponse{
+			BaseResponse: &response.BaseResponse{
+				Code:    http.StatusBadRequest,
+				Message: infrahttp.ErrInvalidParam.Error(),
+			},
+			Detail: err.Error()})
+		return
+	}
+
+	res, err := h.orchestratorService.GetSagaTimeline(c.Request.Context(), purchaseID)
+	if err != nil {
+		h.logger.WithError(err).Error("GetSagaTimeline")
+		code := http.StatusInternalServerError
+		var notFound *repository.ErrNotFound
+		if errors.As(err, &notFound) {
+			code = http.StatusNotFound
+		}
+		c.AbortWithStatusJSON(code, response.ErrorResponse{
+			BaseResponse: &response.BaseResponse{
+				Code:    code,
+				Message: err.Error(),
+			},
+			Detail: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response.SuccessResponse{
+		BaseResponse: &response.BaseResponse{
+			Code:    http.StatusOK,
+			Message: "success",
+		},
+		Data: res})
+}
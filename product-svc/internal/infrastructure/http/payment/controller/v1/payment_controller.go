@@ -1,11 +1,13 @@
 package v1
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/Chengxufeng1994/go-saga-example/common/constant"
 	"github.com/Chengxufeng1994/go-saga-example/common/response"
+	"github.com/Chengxufeng1994/go-saga-example/common/webhook"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/config"
 	infrahttp "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/usecase"
@@ -64,6 +66,63 @@ func (h *PaymentController) GetPayment(c *gin.Context) {
 	c.JSON(http.StatusOK, generateResponse(res))
 }
 
+func (h *PaymentController) GetPaymentLedger(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		h.logger.WithError(err).Error("strconv parse uint")
+		c.AbortWithStatusJSON(http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := h.paymentService.GetPaymentLedger(c.Request.Context(), id)
+	if err != nil {
+		h.logger.WithError(err).Error("GetPaymentLedger")
+		c.AbortWithStatusJSON(http.StatusBadRequest,
+			response.ErrorResponse{
+				BaseResponse: &response.BaseResponse{
+					Code:    http.StatusBadRequest,
+					Message: err.Error(),
+				},
+				Detail: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, generateResponse(res))
+}
+
+func (h *PaymentController) HandleWebhook(c *gin.Context) {
+	payload, err := c.GetRawData()
+	if err != nil {
+		h.logger.WithError(err).Error("GetRawData")
+		c.AbortWithStatusJSON(http.StatusBadRequest, response.ErrorResponse{
+			BaseResponse: &response.BaseResponse{
+				Code:    http.StatusBadRequest,
+				Message: infrahttp.ErrInvalidParam.Error(),
+			},
+			Detail: err.Error()})
+		return
+	}
+
+	res, err := h.paymentService.HandleWebhook(c.Request.Context(), c.Param("provider"), c.GetHeader(webhook.SignatureHeader), c.GetHeader(webhook.TimestampHeader), payload)
+	if err != nil {
+		h.logger.WithError(err).Error("HandleWebhook")
+		code := http.StatusBadRequest
+		switch {
+		case errors.Is(err, usecase.ErrUnknownWebhookProvider):
+			code = http.StatusNotFound
+		case errors.Is(err, usecase.ErrInvalidWebhookSignature):
+			code = http.StatusUnauthorized
+		}
+		c.AbortWithStatusJSON(code, response.ErrorResponse{
+			BaseResponse: &response.BaseResponse{
+				Code:    code,
+				Message: err.Error(),
+			},
+			Detail: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, generateResponse(res))
+}
+
 func generateResponse(res any) response.SuccessResponse {
 	return response.SuccessResponse{
 		BaseResponse: &response.BaseResponse{
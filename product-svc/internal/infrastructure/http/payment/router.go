@@ -3,6 +3,7 @@ package product
 import (
 	"net/http"
 
+	"github.com/Chengxufeng1994/go-saga-example/common/rbac"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/application"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http/middleware"
 	v1 "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http/payment/controller/v1"
@@ -40,9 +41,13 @@ func (r *Router) RegisterRoutes() {
 
 	paymentController := v1.NewPaymentController(r.app.PaymentService)
 	v1 := r.engine.Group("/api/v1")
+	// providers authenticate with a signature instead of a user token
+	v1.POST("/payment/webhooks/:provider", paymentController.HandleWebhook)
+
 	paymentGroup := v1.Group("/payment")
 	paymentGroup.Use(r.jwtAuthenticator.Auth())
 	{
 		paymentGroup.GET("/:id", paymentController.GetPayment)
+		paymentGroup.GET("/:id/ledger", rbac.RequirePermission(rbac.PermissionLedgerRead), paymentController.GetPaymentLedger)
 	}
 }
@@ -3,6 +3,7 @@ package product
 import (
 	"net/http"
 
+	"github.com/Chengxufeng1994/go-saga-example/common/rbac"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/application"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http/middleware"
 	v1 "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http/product/controller/v1"
@@ -43,7 +44,7 @@ func (r *Router) RegisterRoutes() {
 	productGroup := v1.Group("/product")
 	productGroup.Use(r.jwtAuthenticator.Auth())
 	{
-		productGroup.POST("/", productController.CreateProduct)
+		productGroup.POST("/", rbac.RequirePermission(rbac.PermissionCatalogWrite), productController.CreateProduct)
 		productGroup.GET("/", productController.ListProducts)
 		productGroup.GET("/:product_id", productController.ListProducts)
 	}
@@ -3,9 +3,12 @@ package infrastructure
 import (
 	"context"
 
+	"github.com/Chengxufeng1994/go-saga-example/common/eventstore"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/config"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/broker"
+	infragrpcorchestrator "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/grpc/orchestrator"
 	infragrpcproduct "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/grpc/product"
+	httporchestrator "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http/orchestrator"
 	httporder "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http/order"
 	httppayment "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http/payment"
 	httpproduct "github.com/Chengxufeng1994/go-saga-example/product-svc/internal/infrastructure/http/product"
@@ -33,7 +36,10 @@ type PaymentServer struct {
 
 // OrchestratorServer wrapper
 type OrchestratorServer struct {
+	HttpSrv        *httporchestrator.HttpServer
+	GrpcSrv        *infragrpcorchestrator.GrpcSagaAdminServer
 	EventRouter    broker.EventRouter
+	Projector      *eventstore.Projector
 	TracerProvider *sdktrace.TracerProvider
 }
 
@@ -178,15 +184,32 @@ func (srv *PaymentServer) GracefulShutdown(ctx context.Context) {
 }
 
 func NewOrchestratorServer(
+	httpSrv *httporchestrator.HttpServer,
+	grpcSrv *infragrpcorchestrator.GrpcSagaAdminServer,
 	eventRouter broker.EventRouter,
+	projector *eventstore.Projector,
 	tracerProvider *sdktrace.TracerProvider) *OrchestratorServer {
 	return &OrchestratorServer{
+		HttpSrv:     httpSrv,
+		GrpcSrv:     grpcSrv,
 		EventRouter: eventRouter,
+		Projector:   projector,
 	}
 }
 
 func (srv *OrchestratorServer) Run() error {
 	config.ContextLogger.Infoln("server.Run")
+	go func() {
+		if err := srv.HttpSrv.Run(); err != nil {
+			config.ContextLogger.Fatal(err)
+		}
+	}()
+
+	go func() {
+		if err := srv.GrpcSrv.Run(); err != nil {
+			config.ContextLogger.Fatal(err)
+		}
+	}()
 
 	go func() {
 		err := srv.EventRouter.Run()
@@ -195,16 +218,28 @@ func (srv *OrchestratorServer) Run() error {
 		}
 	}()
 
+	go func() {
+		if err := srv.Projector.Run(); err != nil {
+			config.ContextLogger.Fatal(err)
+		}
+	}()
+
 	return nil
 }
 
 func (srv *OrchestratorServer) GracefulShutdown(ctx context.Context) {
 	config.ContextLogger.Infoln("server.GracefulShutdown")
+	srv.HttpSrv.GracefulShutdown(ctx)
+	srv.GrpcSrv.GracefulShutdown(ctx)
 
 	if err := srv.EventRouter.GracefulShutdown(); err != nil {
 		config.ContextLogger.WithError(err).Error("server.GracefulShutdown event router shutdown")
 	}
 
+	if err := srv.Projector.Close(); err != nil {
+		config.ContextLogger.WithError(err).Error("server.GracefulShutdown projector shutdown")
+	}
+
 	if srv.TracerProvider != nil {
 		err := srv.TracerProvider.Shutdown(ctx)
 		if err != nil {
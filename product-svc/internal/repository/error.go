@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/Chengxufeng1994/go-saga-example/common/eventstore"
 	"gorm.io/gorm"
 )
 
@@ -21,6 +22,8 @@ const (
 
 var ErrRecordNotFound = gorm.ErrRecordNotFound
 
+var ErrConcurrencyConflict = eventstore.ErrConcurrencyConflict
+
 // ErrInvalidInput indicates an error that has occurred due to an invalid input.
 type ErrInvalidInput struct {
 	Entity  string // The entity which was sent as the input.
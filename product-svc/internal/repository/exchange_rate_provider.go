@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
+)
+
+// ExchangeRateProvider looks up currency exchange rates.
+type ExchangeRateProvider interface {
+	GetExchangeRate(ctx context.Context, from, to string) (*valueobject.ExchangeRate, error)
+}
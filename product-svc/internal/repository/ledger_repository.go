@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
+)
+
+// ErrUnbalancedJournalEntry is journal entry postings not summing up to zero error
+var ErrUnbalancedJournalEntry = errors.New("unbalanced journal entry")
+
+// LedgerRepository reads the payment ledger. Entries are posted together with
+// the payment status change they record, see PaymentRepository.
+type LedgerRepository interface {
+	ListJournalEntries(ctx context.Context, purchaseID uint64) ([]entity.JournalEntry, error)
+	GetAccountBalances(ctx context.Context, purchaseID uint64) ([]valueobject.AccountBalance, error)
+}
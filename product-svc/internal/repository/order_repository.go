@@ -13,4 +13,6 @@ type OrderRepository interface {
 	GetDetailedPurchasedItems(ctx context.Context, purchasedItems *[]valueobject.PurchasedItem) (*[]valueobject.DetailedPurchasedItem, error)
 	CreateOrder(ctx context.Context, order *entity.Order) error
 	DeleteOrder(ctx context.Context, orderID uint64) error
+	// AnonymizeOrders detaches the orders of a deleted user from the user
+	AnonymizeOrders(ctx context.Context, userID uint64) error
 }
@@ -0,0 +1,11 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
+)
+
+type PaymentEventRepository interface {
+	PublishPaymentStatusChanged(ctx context.Context, evt *event.PaymentStatusChangedEvent) error
+}
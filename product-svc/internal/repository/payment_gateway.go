@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
+)
+
+var (
+	// ErrPaymentDeclined is payment declined by the gateway error
+	ErrPaymentDeclined = errors.New("payment declined")
+	// ErrGatewayTimeout is payment gateway timeout error
+	ErrGatewayTimeout = errors.New("payment gateway timeout")
+	// ErrInvalidTransactionState is invalid gateway transaction state error
+	ErrInvalidTransactionState = errors.New("invalid transaction state")
+)
+
+// PaymentGateway moves money with an external payment provider.
+type PaymentGateway interface {
+	Authorize(ctx context.Context, payment *entity.Payment) (*valueobject.GatewayTransaction, error)
+	Capture(ctx context.Context, authorizationID string, amount int64) (*valueobject.GatewayTransaction, error)
+	Void(ctx context.Context, authorizationID string) (*valueobject.GatewayTransaction, error)
+	Refund(ctx context.Context, captureID string, amount int64) (*valueobject.GatewayTransaction, error)
+}
@@ -4,10 +4,22 @@ import (
 	"context"
 
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/valueobject"
 )
 
 type PaymentRepository interface {
 	GetPayment(ctx context.Context, paymentID uint64) (*entity.Payment, error)
-	CreatePayment(ctx context.Context, payment *entity.Payment) error
-	DeletePayment(ctx context.Context, paymentID uint64) error
+	GetPaymentByAuthorizationID(ctx context.Context, authorizationID string) (*entity.Payment, error)
+	// CreatePayment and UpdatePaymentStatus post the journal entry in the same transaction
+	CreatePayment(ctx context.Context, payment *entity.Payment, entry *entity.JournalEntry) error
+	UpdatePaymentStatus(ctx context.Context, paymentID uint64, transition *entity.PaymentTransition) error
+	ListPaymentStatusHistory(ctx context.Context, paymentID uint64) ([]valueobject.PaymentStatusChange, error)
+	// AnonymizePayments detaches the payments of a deleted user from the user,
+	// the amounts stay for the ledger
+	AnonymizePayments(ctx context.Context, userID uint64) error
+	// SaveWebhookEvent records a provider event and applies its transition,
+	// when not nil, in the same transaction. It reports false and applies
+	// nothing when the event, or another event of its gateway transaction,
+	// was already recorded.
+	SaveWebhookEvent(ctx context.Context, paymentID uint64, evt *valueobject.WebhookEvent, transition *entity.PaymentTransition) (bool, error)
 }
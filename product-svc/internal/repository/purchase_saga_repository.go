@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+)
+
+// PurchaseSagaRepository keeps every purchase saga as the stream of its
+// events, the source of truth of the saga
+type PurchaseSagaRepository interface {
+	// Load rebuilds the saga from its latest snapshot and the events after
+	// it, a saga without events is new
+	Load(ctx context.Context, purchaseID uint64) (*entity.PurchaseSaga, error)
+	// Save appends the events recorded since the saga was loaded, it returns
+	// ErrConcurrencyConflict when events were appended in the meantime
+	Save(ctx context.Context, saga *entity.PurchaseSaga) error
+}
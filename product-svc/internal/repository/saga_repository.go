@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+)
+
+// SagaRepository keeps the timeline of the sagas and lists them from their
+// projection, the state of a saga is kept by PurchaseSagaRepository
+type SagaRepository interface {
+	// ListSagas lists the latest sagas first, of every status when status is empty
+	ListSagas(ctx context.Context, status entity.SagaStatus, limit int) ([]*entity.Saga, error)
+	AppendSagaEvent(ctx context.Context, evt *entity.SagaEvent) error
+	ListSagaEvents(ctx context.Context, purchaseID uint64) ([]*entity.SagaEvent, error)
+}
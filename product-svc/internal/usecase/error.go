@@ -1 +1,26 @@
 package usecase
+
+import "errors"
+
+var (
+	// ErrUnknownWebhookProvider is webhook from an unconfigured provider error
+	ErrUnknownWebhookProvider = errors.New("unknown webhook provider")
+	// ErrInvalidWebhookSignature is webhook signature mismatch error
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+	// ErrUnsupportedWebhookEvent is unsupported webhook event type error
+	ErrUnsupportedWebhookEvent = errors.New("unsupported webhook event")
+	// ErrNotAccessToken is refresh token presented as access token error
+	ErrNotAccessToken = errors.New("not an access token")
+	// ErrSagaResolved is operator action on a resolved saga error
+	ErrSagaResolved = errors.New("saga is resolved")
+	// ErrSagaSucceeded is compensation of a succeeded saga error
+	ErrSagaSucceeded = errors.New("saga succeeded")
+	// ErrSagaNoCommand is retry of a saga without a recorded command error
+	ErrSagaNoCommand = errors.New("saga has no command")
+	// ErrSagaNotRunning is operator action on a saga which is neither running nor compensating error
+	ErrSagaNotRunning = errors.New("saga is neither running nor compensating")
+	// ErrSagaCommandReplied is retry of a saga whose last command was replied error
+	ErrSagaCommandReplied = errors.New("last command of the saga was replied")
+	// ErrSagaChoreographed is operator action while the saga is choreographed error
+	ErrSagaChoreographed = errors.New("saga is choreographed")
+)
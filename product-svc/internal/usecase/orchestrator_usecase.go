@@ -3,11 +3,27 @@ package usecase
 import (
 	"context"
 
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/dto"
 	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/entity"
+	"github.com/Chengxufeng1994/go-saga-example/product-svc/internal/domain/event"
 	"github.com/ThreeDotsLabs/watermill/message"
 )
 
 type OrchestratorUseCase interface {
 	HandleTrx(ctx context.Context, purchase *entity.Purchase, correlationID string) error
 	HandleReply(ctx context.Context, msg *message.Message, correlationID string) error
+	HandlePaymentStatus(ctx context.Context, evt *event.PaymentStatusChangedEvent, correlationID string) error
+
+	// ListSagas lists the latest sagas, of every status when status is empty
+	ListSagas(ctx context.Context, status entity.SagaStatus, limit int) ([]*entity.Saga, error)
+	// GetSaga returns the saga and its timeline
+	GetSaga(ctx context.Context, purchaseID uint64) (*entity.Saga, []*entity.SagaEvent, error)
+	// GetSagaTimeline returns the timeline of the saga by step
+	GetSagaTimeline(ctx context.Context, purchaseID uint64) (*dto.SagaTimeline, error)
+	// RetrySaga publishes the last command of the saga again
+	RetrySaga(ctx context.Context, purchaseID uint64) error
+	// CompensateSaga rolls back the saga from its current step
+	CompensateSaga(ctx context.Context, purchaseID uint64) error
+	// ResolveSaga closes a saga an operator took care of
+	ResolveSaga(ctx context.Context, purchaseID uint64, note string) error
 }
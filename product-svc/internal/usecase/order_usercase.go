@@ -16,4 +16,5 @@ type OrderUseCase interface {
 type SagaOrderUseCase interface {
 	ExecuteCreateOrder(ctx context.Context, order *entity.Order) error
 	RollbackCreateOrder(ctx context.Context, orderID uint64) error
+	AnonymizeUser(ctx context.Context, userID uint64) error
 }
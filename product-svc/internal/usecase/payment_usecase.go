@@ -10,12 +10,16 @@ import (
 // PaymentService interface
 type PaymentUseCase interface {
 	// command
+	HandleWebhook(ctx context.Context, provider, signature, timestamp string, payload []byte) (*dto.PaymentWebhookResponse, error)
 	// query
 	GetPayment(ctx context.Context, userID, paymentID uint64) (*dto.Payment, error)
+	GetPaymentLedger(ctx context.Context, paymentID uint64) (*dto.PaymentLedger, error)
 }
 
 // SagaPaymentService interface
 type SagaPaymentUseCase interface {
 	ExecuteCreatePayment(ctx context.Context, payment *entity.Payment) error
+	CapturePayment(ctx context.Context, paymentID uint64) error
 	RollbackCreatePayment(ctx context.Context, paymentID uint64) error
+	AnonymizeUser(ctx context.Context, userID uint64) error
 }
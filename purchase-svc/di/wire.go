@@ -9,6 +9,7 @@ import (
 	"github.com/Chengxufeng1994/go-saga-example/purchase-svc/config"
 	"github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/adapter/broker"
 	"github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/adapter/grpc"
+	"github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/adapter/token"
 	"github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/application"
 	"github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/server"
 	infrabroker "github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/server/broker"
@@ -41,14 +42,15 @@ func InitializeServer(appCfg *libconfig.ApplicationConfig, bootCfg *bootstrap.Bo
 		// redis.NewRedisClusterClient,
 		// init broker
 		// broker.NewRedisSubscriber,
-		infrabroker.NewNatsPublisher,
+		infrabroker.NewTxPublisher,
+		infrabroker.NewCodec,
 
 		// grpc client
 		srvgrpc.NewProductConn,
 		srvgrpc.NewAuthConn,
 
 		// internal services
-		grpc.NewGrpcAuthRepository,
+		token.NewAuthRepository,
 		grpc.NewGrpcProductRepository,
 		broker.NewNatsNatsPurchasePublisher,
 		application.NewAuthService,
@@ -12,6 +12,7 @@ import (
 	config2 "github.com/Chengxufeng1994/go-saga-example/purchase-svc/config"
 	broker2 "github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/adapter/broker"
 	grpc2 "github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/adapter/grpc"
+	"github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/adapter/token"
 	"github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/application"
 	"github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/server"
 	"github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/server/broker"
@@ -36,12 +37,13 @@ func InitializeServer(appCfg *config.ApplicationConfig, bootCfg *bootstrap.Boots
 	engine := http.NewGinEngine(bootCfg)
 	logger := config2.InitLogger(appCfg, bootCfg)
 	authConn := grpc.NewAuthConn(logger, appCfg)
-	authRepository := grpc2.NewGrpcAuthRepository(authConn)
+	authRepository := token.NewAuthRepository(appCfg, authConn)
 	authUseCase := application.NewAuthService(logger, authRepository)
 	productConn := grpc.NewProductConn(logger, appCfg)
 	productRepository := grpc2.NewGrpcProductRepository(productConn)
-	publisher := broker.NewNatsPublisher(appCfg)
-	purchasingRepository := broker2.NewNatsNatsPurchasePublisher(publisher)
+	publisher := broker.NewTxPublisher(appCfg)
+	codec := broker.NewCodec(appCfg)
+	purchasingRepository := broker2.NewNatsNatsPurchasePublisher(publisher, codec)
 	purchaseUseCase := application.NewPurchaseService(logger, productRepository, purchasingRepository)
 	applicationApplication := application.New(authUseCase, purchaseUseCase)
 	router := http.NewRouter(logger, engine, applicationApplication)
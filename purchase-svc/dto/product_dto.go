@@ -14,9 +14,10 @@ type CheckProductRequest struct {
 
 // CartItem is the JSON request that represents an order
 type ProductStatus struct {
-	ProductID uint64        `json:"product_id"`
-	Price     int64         `json:"price""`
-	Status    domain.Status `json:"status"`
+	ProductID    uint64        `json:"product_id"`
+	Price        int64         `json:"price"`
+	CurrencyCode string        `json:"currency_code"`
+	Status       domain.Status `json:"status"`
 }
 
 type CheckProductResponse struct {
@@ -13,7 +13,8 @@ type PurchaseCreationResponse struct {
 
 // Payment is the JSON request that represents a payment
 type Payment struct {
-	CurrencyCode string `json:"currency_code" binding:"required,oneof=NT US"`
+	CurrencyCode string `json:"currency_code" binding:"required,iso4217"`
+	CardToken    string `json:"card_token"`
 }
 
 // PurchaseResult is the HTTP JSON response of purchase result
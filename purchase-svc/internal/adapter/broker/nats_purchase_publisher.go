@@ -2,26 +2,25 @@ package broker
 
 import (
 	"context"
-	"encoding/json"
 	"time"
 
+	"github.com/Chengxufeng1994/go-saga-example/common/bus"
+	"github.com/Chengxufeng1994/go-saga-example/common/codec"
 	"github.com/Chengxufeng1994/go-saga-example/common/event"
 	"github.com/Chengxufeng1994/go-saga-example/common/pb"
 	"github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/domain"
 	"github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/repository"
-	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill/message"
-	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type NatsPurchasePublisher struct {
-	publisher message.Publisher
+	bus *bus.Bus
 }
 
-func NewNatsNatsPurchasePublisher(publisher message.Publisher) repository.PurchasingRepository {
+func NewNatsNatsPurchasePublisher(publisher message.Publisher, c codec.Codec) repository.PurchasingRepository {
 	return &NatsPurchasePublisher{
-		publisher: publisher,
+		bus: bus.New(nil, publisher, nil, c),
 	}
 }
 
@@ -45,23 +44,14 @@ func (n *NatsPurchasePublisher) CreatePurchase(ctx context.Context, purchase *do
 				PurchasedItems: purchasedItems,
 			},
 			Payment: &pb.Payment{
-				CurrencyCode: payment.CurrencyCode,
-				Amount:       payment.Amount,
+				CurrencyCode:         payment.CurrencyCode,
+				Amount:               payment.Amount,
+				CardToken:            payment.CardToken,
+				OriginalCurrencyCode: payment.OriginalCurrencyCode,
 			},
 		},
 		Timestamp: timestamppb.New(time.Now()),
 	}
-	payload, err := json.Marshal(createPurchaseDto)
-	if err != nil {
-		return err
-	}
-
-	msg := message.NewMessage(watermill.NewUUID(), payload)
-	middleware.SetCorrelationID(watermill.NewUUID(), msg)
-
-	if err := n.publisher.Publish(event.PurchaseTopic, msg); err != nil {
-		return err
-	}
-
-	return nil
+	// a purchase starts the correlation of its saga
+	return bus.Publish(ctx, n.bus, event.PurchaseTopic, createPurchaseDto, bus.WithPartitionKeys(order.UserID, purchase.ID))
 }
@@ -34,7 +34,27 @@ func (g *GrpcAuthRepository) VerifyToken(ctx context.Context, accessToken string
 	}
 
 	return &domain.Auth{
-		UserId:    res.UserId,
-		IsExpired: res.IsExpired,
+		UserId:      res.UserId,
+		Roles:       res.Roles,
+		Permissions: res.Permissions,
+		IsExpired:   res.IsExpired,
+	}, nil
+}
+
+// VerifyAPIKey implements repository.AuthRepository.
+func (g *GrpcAuthRepository) VerifyAPIKey(ctx context.Context, apiKey string) (*domain.Auth, error) {
+	ctx, span := otel.Tracer("purchase").Start(ctx, "Verify API Key")
+	defer span.End()
+
+	cli := pb.NewAuthServiceClient(g.AuthConn.Conn())
+	res, err := cli.VerifyAPIKey(ctx, &pb.VerifyAPIKeyRequest{ApiKey: apiKey})
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.Auth{
+		UserId:      res.UserId,
+		Roles:       res.Roles,
+		Permissions: res.Permissions,
 	}, nil
 }
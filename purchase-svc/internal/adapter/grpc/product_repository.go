@@ -40,9 +40,10 @@ func (r *GrpcProductRepository) CheckProducts(ctx context.Context, cartItems []*
 	var productProductStates []*domain.ProductStatus
 	for _, productStatus := range resp.ProductStatuses {
 		productProductStates = append(productProductStates, &domain.ProductStatus{
-			ProductID: productStatus.ProductId,
-			Price:     productStatus.Price,
-			Status:    getProductStatus(productStatus.Status),
+			ProductID:    productStatus.ProductId,
+			Price:        productStatus.Price,
+			CurrencyCode: productStatus.CurrencyCode,
+			Status:       getProductStatus(productStatus.Status),
 		})
 
 	}
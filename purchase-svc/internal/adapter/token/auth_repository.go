@@ -0,0 +1,77 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	libconfig "github.com/Chengxufeng1994/go-saga-example/common/config"
+	libtoken "github.com/Chengxufeng1994/go-saga-example/common/token"
+	"github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/adapter/grpc"
+	"github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/domain"
+	"github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/repository"
+	infragrpc "github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/server/grpc"
+)
+
+const defaultJWKSCacheTTL = 300
+
+var (
+	// ErrNotAccessToken refresh token presented as access token
+	ErrNotAccessToken = errors.New("not an access token")
+)
+
+// NewAuthRepository verifies tokens locally when a JWKS endpoint is
+// configured and falls back to the VerifyToken RPC of auth-svc otherwise
+func NewAuthRepository(appCfg *libconfig.ApplicationConfig, authConn *infragrpc.AuthConn) repository.AuthRepository {
+	jwksURL := appCfg.JWTConfig.JWKSURL
+	if jwksURL == "" {
+		return grpc.NewGrpcAuthRepository(authConn)
+	}
+
+	ttl := appCfg.JWTConfig.JWKSCacheTTL
+	if ttl == 0 {
+		ttl = defaultJWKSCacheTTL
+	}
+	return NewJWKSAuthRepository(
+		libtoken.NewRemoteKeySet(jwksURL, time.Duration(ttl)*time.Second),
+		time.Duration(appCfg.JWTConfig.AccessTokenExpires)*time.Second,
+		grpc.NewGrpcAuthRepository(authConn),
+	)
+}
+
+// JWKSAuthRepository checks signature, expiry and age only, revoked
+// sessions stay valid until their access token is older than maxAge. Api
+// keys are verified by the remote repository.
+type JWKSAuthRepository struct {
+	verifier libtoken.Verifier
+	remote   repository.AuthRepository
+}
+
+func NewJWKSAuthRepository(keySet libtoken.KeySet, maxAge time.Duration, remote repository.AuthRepository) repository.AuthRepository {
+	return &JWKSAuthRepository{
+		verifier: libtoken.WithMaxAge(libtoken.NewKeySetVerifier(keySet), maxAge),
+		remote:   remote,
+	}
+}
+
+// VerifyToken implements repository.AuthRepository.
+func (r *JWKSAuthRepository) VerifyToken(ctx context.Context, accessToken string) (*domain.Auth, error) {
+	claims, err := r.verifier.Verify(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.IsRefresh {
+		return nil, ErrNotAccessToken
+	}
+
+	return &domain.Auth{
+		UserId:      claims.UserID,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+	}, nil
+}
+
+// VerifyAPIKey implements repository.AuthRepository.
+func (r *JWKSAuthRepository) VerifyAPIKey(ctx context.Context, apiKey string) (*domain.Auth, error) {
+	return r.remote.VerifyAPIKey(ctx, apiKey)
+}
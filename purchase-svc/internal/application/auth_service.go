@@ -31,7 +31,23 @@ func (s *AuthService) VerifyToken(ctx context.Context, accessToken string) (*dto
 	}
 
 	return &dto.VerifyTokenResponse{
-		UserId:    res.UserId,
-		IsExpired: res.IsExpired,
+		UserId:      res.UserId,
+		Roles:       res.Roles,
+		Permissions: res.Permissions,
+		IsExpired:   res.IsExpired,
+	}, nil
+}
+
+// VerifyAPIKey implements usecase.AuthUseCase.
+func (s *AuthService) VerifyAPIKey(ctx context.Context, apiKey string) (*dto.VerifyTokenResponse, error) {
+	res, err := s.authRepository.VerifyAPIKey(ctx, apiKey)
+	if err != nil {
+		return nil, model.NewAppError("VerifyAPIKey", "app.auth.verify_api_key.error", nil, "")
+	}
+
+	return &dto.VerifyTokenResponse{
+		UserId:      res.UserId,
+		Roles:       res.Roles,
+		Permissions: res.Permissions,
 	}, nil
 }
@@ -21,6 +21,8 @@ var (
 	ErrProductNotfound = errors.New("product not found")
 	// ErrUnkownProductStatus unkown product status error
 	ErrUnkownProductStatus = errors.New("unknown product status")
+	// ErrMixedCurrencies is cart items priced in different currencies error
+	ErrMixedCurrencies = errors.New("mixed currencies")
 )
 
 type PurchaseService struct {
@@ -91,9 +93,10 @@ func (svc *PurchaseService) toProductStatusDto(domains []*domain.ProductStatus)
 	var dtos []*dto.ProductStatus
 	for _, ps := range domains {
 		dtos = append(dtos, &dto.ProductStatus{
-			ProductID: ps.ProductID,
-			Price:     ps.Price,
-			Status:    ps.Status,
+			ProductID:    ps.ProductID,
+			Price:        ps.Price,
+			CurrencyCode: ps.CurrencyCode,
+			Status:       ps.Status,
 		})
 	}
 
@@ -110,8 +113,16 @@ func (svc *PurchaseService) CreatePurchase(ctx context.Context, userID uint64, r
 		return nil, libmodel.NewAppError("CreatePurchase", "app.purchase.create.error", nil, "")
 	}
 
+	// prices are in minor units of the product currency, the payment
+	// service converts the total into the requested currency
 	var amount int64 = 0
+	var priceCurrencyCode string
 	for i, productStatus := range resp.ProductStatues {
+		if priceCurrencyCode == "" {
+			priceCurrencyCode = productStatus.CurrencyCode
+		} else if productStatus.CurrencyCode != priceCurrencyCode {
+			return nil, libmodel.NewAppError("CreatePurchase", "app.purchase.mixed_currencies.error", nil, "").Wrap(ErrMixedCurrencies)
+		}
 		amount += cartItems[i].Amount * productStatus.Price
 	}
 
@@ -135,8 +146,10 @@ func (svc *PurchaseService) CreatePurchase(ctx context.Context, userID uint64, r
 			CartItems: &cis,
 		},
 		Payment: &domain.Payment{
-			CurrencyCode: req.Payment.CurrencyCode,
-			Amount:       amount,
+			CurrencyCode:         req.Payment.CurrencyCode,
+			Amount:               amount,
+			OriginalCurrencyCode: priceCurrencyCode,
+			CardToken:            req.Payment.CardToken,
 		},
 	}); err != nil {
 		return nil, libmodel.NewAppError("CreatePurchase", "app.purchase.create.error", nil, "")
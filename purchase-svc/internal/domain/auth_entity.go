@@ -1,6 +1,8 @@
 package domain
 
 type Auth struct {
-	UserId    uint64 `json:"user_id"`
-	IsExpired bool   `json:"is_expired"`
+	UserId      uint64   `json:"user_id"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+	IsExpired   bool     `json:"is_expired"`
 }
@@ -4,4 +4,7 @@ package domain
 type Payment struct {
 	CurrencyCode string
 	Amount       int64
+	CardToken    string
+	// currency of Amount, converted to CurrencyCode by the payment service
+	OriginalCurrencyCode string
 }
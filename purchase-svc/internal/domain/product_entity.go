@@ -20,9 +20,10 @@ const (
 
 // ProductStatus value object
 type ProductStatus struct {
-	ProductID uint64
-	Price     int64
-	Status    Status
+	ProductID    uint64
+	Price        int64
+	CurrencyCode string
+	Status       Status
 }
 
 func NewProductStatus(productId uint64, price int64, status Status) *ProductStatus {
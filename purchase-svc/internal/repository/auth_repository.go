@@ -8,4 +8,5 @@ import (
 
 type AuthRepository interface {
 	VerifyToken(context.Context, string) (*domain.Auth, error)
+	VerifyAPIKey(context.Context, string) (*domain.Auth, error)
 }
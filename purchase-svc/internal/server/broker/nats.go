@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/Chengxufeng1994/go-saga-example/common/codec/natscodec"
 	"github.com/Chengxufeng1994/go-saga-example/common/config"
 	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill-nats/v2/pkg/nats"
@@ -14,12 +15,11 @@ import (
 
 var (
 	logger    = watermill.NewStdLogger(false, false)
-	marshaler = &nats.GobMarshaler{}
+	marshaler = natscodec.NewMarshaler()
 )
 
-// NewNatsPublisher returns a NATS publisher for event streaming
-func NewNatsPublisher(appCfg *config.ApplicationConfig) message.Publisher {
-	var err error
+// newNatsPublisher returns a NATS publisher for event streaming
+func newNatsPublisher(appCfg *config.ApplicationConfig) (message.Publisher, error) {
 	natsUrl := fmt.Sprintf("nats://%s:%d", appCfg.NatsConfig.Host, appCfg.NatsConfig.Port)
 	options := []nc.Option{
 		nc.RetryOnFailedConnect(true),
@@ -35,7 +35,7 @@ func NewNatsPublisher(appCfg *config.ApplicationConfig) message.Publisher {
 		AckAsync:       false,
 		DurablePrefix:  appCfg.NatsConfig.NatsSubscriber.DurableName,
 	}
-	pub, err := nats.NewPublisher(
+	return nats.NewPublisher(
 		nats.PublisherConfig{
 			URL:         natsUrl,
 			NatsOptions: options,
@@ -44,19 +44,4 @@ func NewNatsPublisher(appCfg *config.ApplicationConfig) message.Publisher {
 		},
 		logger,
 	)
-	if err != nil {
-		panic(err)
-	}
-
-	// registry, ok := prom.DefaultRegisterer.(*prom.Registry)
-	// if !ok {
-	// 	return nil, fmt.Errorf("prometheus type casting error")
-	// }
-	// metricsBuilder := metrics.NewPrometheusMetricsBuilder(registry, config.App, "pubsub")
-	// TxPublisher, err = metricsBuilder.DecoratePublisher(TxPublisher)
-	// if err != nil {
-	// 	return nil, err
-	// }
-
-	return pub
 }
@@ -0,0 +1,68 @@
+package broker
+
+import (
+	"fmt"
+
+	"github.com/Chengxufeng1994/go-saga-example/common/bus"
+	"github.com/Chengxufeng1994/go-saga-example/common/codec"
+	"github.com/Chengxufeng1994/go-saga-example/common/config"
+	"github.com/Chengxufeng1994/go-saga-example/common/event"
+	"github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/adapter/redis"
+	"github.com/ThreeDotsLabs/watermill-kafka/v2/pkg/kafka"
+	"github.com/ThreeDotsLabs/watermill-redisstream/pkg/redisstream"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+// NewTxPublisher returns the publisher of the saga topic group, NATS unless
+// the messaging config selects another backend
+func NewTxPublisher(appCfg *config.ApplicationConfig) message.Publisher {
+	var (
+		pub message.Publisher
+		err error
+	)
+	switch backend := appCfg.MessagingConfig.Backend(event.SagaTopicGroup, config.BackendNATS); backend {
+	case config.BackendNATS:
+		pub, err = newNatsPublisher(appCfg)
+	case config.BackendRedis:
+		pub, err = redisstream.NewPublisher(
+			redisstream.PublisherConfig{
+				Client:     redis.NewRedisClusterClient(appCfg),
+				Marshaller: redisstream.DefaultMarshallerUnmarshaller{},
+			},
+			logger,
+		)
+	case config.BackendKafka:
+		pub, err = kafka.NewPublisher(
+			kafka.PublisherConfig{
+				Brokers:   appCfg.MessagingConfig.Kafka.Brokers,
+				Marshaler: kafka.DefaultMarshaler{},
+			},
+			logger,
+		)
+	case config.BackendGoChannel:
+		// nothing in this process subscribes, only useful in tests
+		pub = gochannel.NewGoChannel(gochannel.Config{}, logger)
+	default:
+		err = fmt.Errorf("unknown broker backend: %s", backend)
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	// purchases go to the partition of the orchestrator router they belong to
+	partitioner, err := bus.NewPartitioner(appCfg.MessagingConfig.Partitioning)
+	if err != nil {
+		panic(err)
+	}
+	return bus.NewPartitionedPublisher(pub, partitioner)
+}
+
+// NewCodec returns the codec of the published messages
+func NewCodec(appCfg *config.ApplicationConfig) codec.Codec {
+	c, err := codec.New(appCfg.MessagingConfig.Codec)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/Chengxufeng1994/go-saga-example/common/constant"
 	"github.com/Chengxufeng1994/go-saga-example/common/model"
 	"github.com/Chengxufeng1994/go-saga-example/purchase-svc/config"
+	"github.com/Chengxufeng1994/go-saga-example/purchase-svc/dto"
 	"github.com/Chengxufeng1994/go-saga-example/purchase-svc/internal/usecase"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
@@ -38,18 +39,32 @@ func NewJwtAuthenticator(logger *config.Logger, authService usecase.AuthUseCase)
 
 func (authenticator *JwtAuthenticator) Auth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		accessToken := extractToken(c.Request)
-		if accessToken == "" {
-			err := model.NewAppError("AuthMiddleware", "app.auth.invalid_token.error", nil, "")
-			c.AbortWithStatusJSON(http.StatusUnauthorized, err)
-			return
-		}
+		var (
+			verifyTokenResponse *dto.VerifyTokenResponse
+			err                 error
+		)
+		// machine clients authenticate with an api key instead of a bearer token
+		if apiKey := c.GetHeader(constant.APIKeyHeader); apiKey != "" {
+			verifyTokenResponse, err = authenticator.authService.VerifyAPIKey(c.Request.Context(), apiKey)
+			if err != nil {
+				authenticator.logger.WithError(err).Error("verify api key")
+				c.AbortWithStatusJSON(http.StatusUnauthorized, model.NewAppError("AuthMiddleware", "app.auth.invalid_api_key.error", nil, ""))
+				return
+			}
+		} else {
+			accessToken := extractToken(c.Request)
+			if accessToken == "" {
+				err := model.NewAppError("AuthMiddleware", "app.auth.invalid_token.error", nil, "")
+				c.AbortWithStatusJSON(http.StatusUnauthorized, err)
+				return
+			}
 
-		verifyTokenResponse, err := authenticator.authService.VerifyToken(c.Request.Context(), accessToken)
-		if err != nil {
-			authenticator.logger.WithError(err).Error("verify token")
-			c.AbortWithStatusJSON(http.StatusUnauthorized, err)
-			return
+			verifyTokenResponse, err = authenticator.authService.VerifyToken(c.Request.Context(), accessToken)
+			if err != nil {
+				authenticator.logger.WithError(err).Error("verify token")
+				c.AbortWithStatusJSON(http.StatusUnauthorized, err)
+				return
+			}
 		}
 
 		if verifyTokenResponse.IsExpired {
@@ -58,7 +73,10 @@ func (authenticator *JwtAuthenticator) Auth() gin.HandlerFunc {
 			return
 		}
 
-		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), constant.CtxUserKey, verifyTokenResponse.UserId))
+		ctx := context.WithValue(c.Request.Context(), constant.CtxUserKey, verifyTokenResponse.UserId)
+		ctx = context.WithValue(ctx, constant.CtxRolesKey, verifyTokenResponse.Roles)
+		ctx = context.WithValue(ctx, constant.CtxPermissionsKey, verifyTokenResponse.Permissions)
+		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 	}
 }
@@ -8,4 +8,5 @@ import (
 
 type AuthUseCase interface {
 	VerifyToken(context.Context, string) (*dto.VerifyTokenResponse, error)
+	VerifyAPIKey(context.Context, string) (*dto.VerifyTokenResponse, error)
 }